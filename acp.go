@@ -0,0 +1,407 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Agent Client Protocol (https://agentclientprotocol.com): JSON-RPC 2.0 over
+// the agent's stdin/stdout, one message per line. Crit acts as the client:
+// it starts one long-lived agent process per profile and opens one ACP
+// session per live thread, so follow-up replies only send what is new.
+
+// acpProtocolVersion is the ACP major version crit speaks.
+const acpProtocolVersion = 1
+
+// acpMessage is a JSON-RPC request, notification or response.
+type acpMessage struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *acpError        `json:"error,omitempty"`
+}
+
+type acpError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *acpError) Error() string { return fmt.Sprintf("acp error %d: %s", e.Code, e.Message) }
+
+// acpUpdate is the params of a session/update notification. Only the fields
+// crit uses are decoded.
+type acpUpdate struct {
+	SessionID string `json:"sessionId"`
+	Update    struct {
+		SessionUpdate string `json:"sessionUpdate"` // "agent_message_chunk", "tool_call", "tool_call_update", ...
+		Content       struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		Kind   string `json:"kind"`   // tool kind, e.g. "edit"
+		Status string `json:"status"` // tool status, e.g. "completed"
+	} `json:"update"`
+}
+
+// acpConn is a running ACP agent process.
+type acpConn struct {
+	cmd   *exec.Cmd
+	root  string // fs requests are confined to this directory
+	write sync.Mutex
+	stdin io.WriteCloser
+
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]chan acpMessage
+	exited  chan struct{}
+	err     error // why the process exited; valid once exited is closed
+
+	onUpdate func(acpUpdate)
+	onWrite  func(sessionID, path string)
+}
+
+// startACP launches the agent command in root and performs the initialize
+// handshake. onUpdate receives session/update notifications; onWrite is
+// called after the agent writes a file through fs/write_text_file.
+func startACP(ctx context.Context, command, root string, onUpdate func(acpUpdate), onWrite func(sessionID, path string)) (*acpConn, error) {
+	parts := strings.Fields(command)
+	if len(parts) == 0 {
+		return nil, errors.New("agent_cmd not configured")
+	}
+	cmd := exec.Command(parts[0], parts[1:]...)
+	cmd.Dir = root
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting ACP agent: %w", err)
+	}
+	c := &acpConn{
+		cmd:      cmd,
+		root:     root,
+		stdin:    stdin,
+		pending:  make(map[int64]chan acpMessage),
+		exited:   make(chan struct{}),
+		onUpdate: onUpdate,
+		onWrite:  onWrite,
+	}
+	go c.readLoop(stdout)
+
+	var init struct {
+		ProtocolVersion int `json:"protocolVersion"`
+	}
+	err = c.call(ctx, "initialize", map[string]any{
+		"protocolVersion": acpProtocolVersion,
+		"clientCapabilities": map[string]any{
+			"fs": map[string]bool{"readTextFile": true, "writeTextFile": true},
+		},
+	}, &init)
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("ACP initialize: %w", err)
+	}
+	if init.ProtocolVersion != acpProtocolVersion {
+		c.Close()
+		return nil, fmt.Errorf("ACP agent speaks protocol version %d, crit needs %d", init.ProtocolVersion, acpProtocolVersion)
+	}
+	return c, nil
+}
+
+// Alive reports whether the agent process is still running.
+func (c *acpConn) Alive() bool {
+	select {
+	case <-c.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// Close kills the agent process.
+func (c *acpConn) Close() {
+	c.stdin.Close()
+	if c.cmd.Process != nil {
+		c.cmd.Process.Kill()
+	}
+	<-c.exited
+}
+
+// NewSession opens an ACP session rooted at the connection's directory.
+func (c *acpConn) NewSession(ctx context.Context) (string, error) {
+	var res struct {
+		SessionID string `json:"sessionId"`
+	}
+	if err := c.call(ctx, "session/new", map[string]any{"cwd": c.root, "mcpServers": []any{}}, &res); err != nil {
+		return "", err
+	}
+	if res.SessionID == "" {
+		return "", errors.New("ACP agent returned no session ID")
+	}
+	return res.SessionID, nil
+}
+
+// acpCancelGrace is how long Prompt waits for the agent to answer
+// session/cancel before killing it.
+var acpCancelGrace = 5 * time.Second
+
+// Prompt sends one user turn and blocks until the agent finishes it. The
+// agent's reply streams in through onUpdate. Cancelling ctx sends
+// session/cancel and waits for the agent to stop, killing it if it hasn't
+// within acpCancelGrace.
+func (c *acpConn) Prompt(ctx context.Context, sessionID, text string) (stopReason string, err error) {
+	var res struct {
+		StopReason string `json:"stopReason"`
+	}
+	params := map[string]any{
+		"sessionId": sessionID,
+		"prompt":    []map[string]string{{"type": "text", "text": text}},
+	}
+	// The prompt call itself ignores ctx: on cancellation ACP expects the
+	// client to send session/cancel and then wait for the "cancelled" result.
+	done := make(chan error, 1)
+	go func() { done <- c.call(context.Background(), "session/prompt", params, &res) }()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		c.notify("session/cancel", map[string]string{"sessionId": sessionID})
+		select {
+		case err = <-done:
+			if err == nil {
+				err = ctx.Err()
+			}
+		case <-time.After(acpCancelGrace):
+			// The pending call fails once the process is gone.
+			c.Close()
+			<-done
+			err = ctx.Err()
+		}
+	}
+	return res.StopReason, err
+}
+
+// call sends a request and decodes the result into out.
+func (c *acpConn) call(ctx context.Context, method string, params, out any) error {
+	c.mu.Lock()
+	c.nextID++
+	id := c.nextID
+	ch := make(chan acpMessage, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	raw := json.RawMessage(fmt.Sprint(id))
+	if err := c.send(acpMessage{ID: &raw, Method: method, Params: mustMarshal(params)}); err != nil {
+		return err
+	}
+	select {
+	case msg := <-ch:
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if out != nil && len(msg.Result) > 0 {
+			return json.Unmarshal(msg.Result, out)
+		}
+		return nil
+	case <-c.exited:
+		return fmt.Errorf("ACP agent exited: %v", c.err)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// notify sends a notification (no response expected).
+func (c *acpConn) notify(method string, params any) error {
+	return c.send(acpMessage{Method: method, Params: mustMarshal(params)})
+}
+
+func (c *acpConn) send(msg acpMessage) error {
+	msg.JSONRPC = "2.0"
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.write.Lock()
+	defer c.write.Unlock()
+	_, err = c.stdin.Write(append(data, '\n'))
+	return err
+}
+
+// readLoop dispatches messages from the agent until its stdout closes.
+func (c *acpConn) readLoop(r io.Reader) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64<<10), 16<<20)
+	for sc.Scan() {
+		var msg acpMessage
+		if err := json.Unmarshal(sc.Bytes(), &msg); err != nil {
+			log.Printf("acp: ignoring malformed message: %v", err)
+			continue
+		}
+		switch {
+		case msg.Method != "" && msg.ID != nil:
+			go c.handleRequest(msg)
+		case msg.Method == "session/update":
+			var u acpUpdate
+			if json.Unmarshal(msg.Params, &u) == nil && c.onUpdate != nil {
+				c.onUpdate(u)
+			}
+		case msg.Method == "" && msg.ID != nil:
+			var id int64
+			if json.Unmarshal(*msg.ID, &id) != nil {
+				continue
+			}
+			c.mu.Lock()
+			ch := c.pending[id]
+			c.mu.Unlock()
+			if ch != nil {
+				ch <- msg
+			}
+		}
+	}
+	c.err = c.cmd.Wait()
+	if c.err == nil {
+		c.err = sc.Err()
+	}
+	close(c.exited)
+}
+
+// handleRequest answers a request from the agent.
+func (c *acpConn) handleRequest(msg acpMessage) {
+	result, err := c.serve(msg.Method, msg.Params)
+	reply := acpMessage{ID: msg.ID}
+	if err != nil {
+		var ae *acpError
+		if !errors.As(err, &ae) {
+			ae = &acpError{Code: -32000, Message: err.Error()}
+		}
+		reply.Error = ae
+	} else if reply.Result = mustMarshal(result); reply.Result == nil {
+		reply.Result = json.RawMessage("null")
+	}
+	if err := c.send(reply); err != nil {
+		log.Printf("acp: replying to %s: %v", msg.Method, err)
+	}
+}
+
+func (c *acpConn) serve(method string, params json.RawMessage) (any, error) {
+	switch method {
+	case "fs/read_text_file":
+		var p struct {
+			Path  string `json:"path"`
+			Line  int    `json:"line"`
+			Limit int    `json:"limit"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &acpError{Code: -32602, Message: err.Error()}
+		}
+		path, err := c.confine(p.Path)
+		if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		content := string(data)
+		if p.Line > 0 || p.Limit > 0 {
+			lines := strings.SplitAfter(content, "\n")
+			start := max(p.Line-1, 0)
+			start = min(start, len(lines))
+			end := len(lines)
+			if p.Limit > 0 {
+				end = min(start+p.Limit, end)
+			}
+			content = strings.Join(lines[start:end], "")
+		}
+		return map[string]string{"content": content}, nil
+
+	case "fs/write_text_file":
+		var p struct {
+			SessionID string `json:"sessionId"`
+			Path      string `json:"path"`
+			Content   string `json:"content"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &acpError{Code: -32602, Message: err.Error()}
+		}
+		path, err := c.confine(p.Path)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, []byte(p.Content), 0o644); err != nil {
+			return nil, err
+		}
+		if c.onWrite != nil {
+			c.onWrite(p.SessionID, path)
+		}
+		return nil, nil
+
+	case "session/request_permission":
+		// Launching the agent is the trust decision, as with agent_cmd: allow
+		// the tool call once, falling back to the first offered option.
+		var p struct {
+			Options []struct {
+				OptionID string `json:"optionId"`
+				Kind     string `json:"kind"`
+			} `json:"options"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil || len(p.Options) == 0 {
+			return map[string]any{"outcome": map[string]string{"outcome": "cancelled"}}, nil
+		}
+		choice := p.Options[0].OptionID
+		for _, o := range p.Options {
+			if o.Kind == "allow_once" {
+				choice = o.OptionID
+				break
+			}
+		}
+		return map[string]any{"outcome": map[string]string{"outcome": "selected", "optionId": choice}}, nil
+	}
+	return nil, &acpError{Code: -32601, Message: "method not found: " + method}
+}
+
+// confine resolves path and rejects anything outside the connection root.
+func (c *acpConn) confine(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(c.root, path)
+	}
+	path = filepath.Clean(path)
+	rel, err := filepath.Rel(c.root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", &acpError{Code: -32602, Message: "path outside the repository: " + path}
+	}
+	return path, nil
+}
+
+func mustMarshal(v any) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	data, _ := json.Marshal(v)
+	return data
+}
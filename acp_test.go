@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestACPHelperProcess is a minimal ACP agent used by the tests below. It
+// answers each prompt with "turn N: <prompt>" and, when the prompt mentions
+// EDIT, writes edited.txt through the client's fs/write_text_file.
+func TestACPHelperProcess(t *testing.T) {
+	if os.Getenv("CRIT_ACP_HELPER") != "1" {
+		t.Skip("helper process")
+	}
+	in := bufio.NewScanner(os.Stdin)
+	in.Buffer(make([]byte, 0, 64<<10), 16<<20)
+	out := json.NewEncoder(os.Stdout)
+	turns := map[string]int{}
+	sessions := 0
+	for in.Scan() {
+		var msg acpMessage
+		json.Unmarshal(in.Bytes(), &msg)
+		switch msg.Method {
+		case "initialize":
+			if os.Getenv("CRIT_ACP_HELPER_EARLY_UPDATE") == "1" {
+				out.Encode(map[string]any{"jsonrpc": "2.0", "method": "session/update", "params": map[string]any{
+					"sessionId": "s0",
+					"update":    map[string]any{"sessionUpdate": "agent_message_chunk", "content": map[string]string{"type": "text", "text": "hello"}},
+				}})
+			}
+			out.Encode(map[string]any{"jsonrpc": "2.0", "id": msg.ID, "result": map[string]int{"protocolVersion": 1}})
+		case "session/new":
+			sessions++
+			out.Encode(map[string]any{"jsonrpc": "2.0", "id": msg.ID, "result": map[string]string{"sessionId": fmt.Sprintf("s%d", sessions)}})
+		case "session/prompt":
+			var p struct {
+				SessionID string `json:"sessionId"`
+				Prompt    []struct {
+					Text string `json:"text"`
+				} `json:"prompt"`
+			}
+			json.Unmarshal(msg.Params, &p)
+			if os.Getenv("CRIT_ACP_HELPER_HANG") == "1" {
+				continue // never answers, not even session/cancel
+			}
+			turns[p.SessionID]++
+			text := p.Prompt[0].Text
+			if strings.Contains(text, "EDIT") {
+				out.Encode(map[string]any{"jsonrpc": "2.0", "id": 100, "method": "fs/write_text_file",
+					"params": map[string]string{"sessionId": p.SessionID, "path": "edited.txt", "content": "edited\n"}})
+				in.Scan() // the client's response
+			}
+			for _, chunk := range []string{fmt.Sprintf("turn %d: ", turns[p.SessionID]), text} {
+				out.Encode(map[string]any{"jsonrpc": "2.0", "method": "session/update", "params": map[string]any{
+					"sessionId": p.SessionID,
+					"update":    map[string]any{"sessionUpdate": "agent_message_chunk", "content": map[string]string{"type": "text", "text": chunk}},
+				}})
+			}
+			out.Encode(map[string]any{"jsonrpc": "2.0", "id": msg.ID, "result": map[string]string{"stopReason": "end_turn"}})
+		}
+	}
+	os.Exit(0)
+}
+
+func setupACPServer(t *testing.T) (*Server, *Session) {
+	t.Helper()
+	t.Setenv("CRIT_ACP_HELPER", "1")
+	s, session := newTestServer(t)
+	session.RepoRoot = t.TempDir()
+	s.cfg.AgentProfiles = map[string]AgentProfile{
+		"acp": {Cmd: os.Args[0] + " -test.run=^TestACPHelperProcess$", Name: "fake", Protocol: "acp"},
+	}
+	t.Cleanup(s.closeAgents)
+	session.mu.Lock()
+	session.Files[0].Comments = []Comment{
+		{ID: "c1", StartLine: 1, EndLine: 1, Body: "please EDIT this", Author: "reviewer", Scope: "line"},
+	}
+	session.mu.Unlock()
+	return s, session
+}
+
+func TestRunAgentACP_FollowUpSendsOnlyNewReplies(t *testing.T) {
+	s, session := setupACPServer(t)
+	path := session.Files[0].Path
+
+	if err := s.runAgentCmd(context.Background(), "acp", "", "c1", path); err != nil {
+		t.Fatal(err)
+	}
+	replies := session.GetComments(path)[0].Replies
+	if len(replies) != 1 || !strings.HasPrefix(replies[0].Body, "turn 1: A reviewer left a comment") {
+		t.Fatalf("first turn replies = %+v, want the full prompt echoed", replies)
+	}
+	if replies[0].Author != "fake" {
+		t.Errorf("reply author = %q, want fake", replies[0].Author)
+	}
+	if got, _ := os.ReadFile(filepath.Join(session.RepoRoot, "edited.txt")); string(got) != "edited\n" {
+		t.Errorf("edited.txt = %q, want the agent's write", got)
+	}
+
+	session.AddReply(path, "c1", "what about tests?", "alice", "")
+	if err := s.runAgentCmd(context.Background(), "acp", "", "c1", path); err != nil {
+		t.Fatal(err)
+	}
+	replies = session.GetComments(path)[0].Replies
+	if len(replies) != 3 {
+		t.Fatalf("got %d replies, want 3", len(replies))
+	}
+	if want := "turn 2: Reply from alice:\n> what about tests?"; replies[2].Body != want {
+		t.Errorf("follow-up reply = %q, want %q", replies[2].Body, want)
+	}
+}
+
+func TestACPConnFor_UpdateBeforeInitialize(t *testing.T) {
+	s, session := setupACPServer(t)
+	t.Setenv("CRIT_ACP_HELPER_EARLY_UPDATE", "1")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := s.runAgentCmd(ctx, "acp", "", "c1", session.Files[0].Path); err != nil {
+		t.Fatalf("agent that sends session/update before initialize: %v", err)
+	}
+}
+
+func TestACPConn_PromptKillsAgentIgnoringCancel(t *testing.T) {
+	t.Setenv("CRIT_ACP_HELPER", "1")
+	t.Setenv("CRIT_ACP_HELPER_HANG", "1")
+	old := acpCancelGrace
+	acpCancelGrace = 100 * time.Millisecond
+	t.Cleanup(func() { acpCancelGrace = old })
+
+	c, err := startACP(context.Background(), os.Args[0]+" -test.run=^TestACPHelperProcess$", t.TempDir(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	id, err := c.NewSession(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.Prompt(ctx, id, "hello")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != context.DeadlineExceeded {
+			t.Errorf("Prompt err = %v, want %v", err, context.DeadlineExceeded)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Prompt still blocked on an agent that ignores session/cancel")
+	}
+	if c.Alive() {
+		t.Error("agent should have been killed")
+	}
+}
+
+func TestCloseAgents_DuringRun(t *testing.T) {
+	s, session := setupACPServer(t)
+	t.Setenv("CRIT_ACP_HELPER_HANG", "1")
+	path := session.Files[0].Path
+
+	done := make(chan error, 1)
+	go func() { done <- s.runAgentCmd(context.Background(), "acp", "", "c1", path) }()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		s.acp.mu.Lock()
+		inFlight := len(s.acp.turns)
+		s.acp.mu.Unlock()
+		if inFlight > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("prompt never started")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	s.closeAgents()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("run should fail once its agent is closed")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("run still blocked after closeAgents")
+	}
+	if err := s.runAgentCmd(context.Background(), "acp", "", "c1", path); err == nil {
+		t.Error("a run after closeAgents should fail instead of starting an agent")
+	}
+}
+
+func TestResolveAgent_ACPRejectsWorktree(t *testing.T) {
+	s, _ := newTestServer(t)
+	s.cfg.AgentWorktree = true
+	s.cfg.AgentProfiles = map[string]AgentProfile{
+		"acp": {Cmd: "agent", Protocol: "acp"},
+		"bad": {Cmd: "agent", Protocol: "acp", Workdir: "worktree"},
+	}
+	agent, err := s.resolveAgent("acp", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !agent.ACP || agent.Worktree {
+		t.Errorf("agent = %+v, want ACP without worktree", agent)
+	}
+	if _, err := s.resolveAgent("bad", ""); err == nil {
+		t.Error("expected an error for an ACP profile with workdir worktree")
+	}
+}
+
+func TestACPConn_Confine(t *testing.T) {
+	root := t.TempDir()
+	c := &acpConn{root: root}
+	for _, tc := range []struct {
+		path string
+		ok   bool
+	}{
+		{"a.txt", true},
+		{filepath.Join(root, "sub", "b.txt"), true},
+		{"../outside.txt", false},
+		{"/etc/passwd", false},
+		{"sub/../../x", false},
+	} {
+		_, err := c.confine(tc.path)
+		if (err == nil) != tc.ok {
+			t.Errorf("confine(%q) err = %v, want ok=%v", tc.path, err, tc.ok)
+		}
+	}
+}
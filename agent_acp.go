@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// acpThread is the ACP session backing one live thread.
+type acpThread struct {
+	profile   string
+	conn      *acpConn
+	sessionID string
+	sent      int // how many of the comment's replies the agent has already seen
+}
+
+// acpTurn collects one in-flight prompt's streamed output.
+type acpTurn struct {
+	commentID string
+	filePath  string
+	text      strings.Builder
+	edited    bool
+}
+
+// acpState holds the server's ACP agent processes and sessions.
+type acpState struct {
+	mu      sync.Mutex
+	conns   map[string]*acpConn   // profile → agent process
+	threads map[string]*acpThread // comment ID → session
+	turns   map[string]*acpTurn   // session ID → in-flight prompt
+	closed  bool                  // set by closeAgents; no agents start after it
+}
+
+var errAgentsClosed = errors.New("server is shutting down")
+
+// acpConnFor returns the running agent process for a profile, starting it
+// (and forgetting sessions from a previous, dead process) if needed. The
+// agent is started without s.acp.mu held: it may send session/update
+// before answering initialize, and onACPUpdate takes the lock.
+func (s *Server) acpConnFor(ctx context.Context, agent resolvedAgent) (*acpConn, error) {
+	s.acp.mu.Lock()
+	if s.acp.closed {
+		s.acp.mu.Unlock()
+		return nil, errAgentsClosed
+	}
+	if s.acp.conns == nil {
+		s.acp.conns = make(map[string]*acpConn)
+		s.acp.threads = make(map[string]*acpThread)
+		s.acp.turns = make(map[string]*acpTurn)
+	}
+	if c := s.acp.conns[agent.Profile]; c != nil && c.Alive() {
+		s.acp.mu.Unlock()
+		return c, nil
+	}
+	s.acp.mu.Unlock()
+
+	c, err := startACP(ctx, agent.Cmd, s.session.Load().RepoRoot, s.onACPUpdate, s.onACPWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	s.acp.mu.Lock()
+	defer s.acp.mu.Unlock()
+	if s.acp.closed {
+		// The server shut down while the agent was starting.
+		c.Close()
+		return nil, errAgentsClosed
+	}
+	if other := s.acp.conns[agent.Profile]; other != nil && other.Alive() {
+		// Another job started the agent meanwhile; use that one.
+		c.Close()
+		return other, nil
+	}
+	for id, t := range s.acp.threads {
+		if t.profile == agent.Profile {
+			delete(s.acp.threads, id)
+		}
+	}
+	log.Printf("acp: started agent for profile %s (pid %d)", agent.Profile, c.cmd.Process.Pid)
+	s.acp.conns[agent.Profile] = c
+	return c, nil
+}
+
+// runAgentACP answers a comment through a persistent ACP session. The first
+// turn sends the full prompt; later turns in the same live thread send only
+// the replies the agent hasn't seen yet.
+func (s *Server) runAgentACP(ctx context.Context, agent resolvedAgent, commentID, filePath string) error {
+	sess := s.session.Load()
+	comment, path, found := sess.FindCommentByID(commentID, filePath)
+	if !found {
+		return fmt.Errorf("comment %s no longer exists", commentID)
+	}
+	conn, err := s.acpConnFor(ctx, agent)
+	if err != nil {
+		return err
+	}
+
+	s.acp.mu.Lock()
+	thread := s.acp.threads[commentID]
+	if thread != nil && (thread.conn != conn || thread.profile != agent.Profile) {
+		thread = nil
+	}
+	s.acp.mu.Unlock()
+
+	var prompt string
+	if thread == nil {
+		sessionID, err := conn.NewSession(ctx)
+		if err != nil {
+			return fmt.Errorf("ACP session/new: %w", err)
+		}
+		thread = &acpThread{profile: agent.Profile, conn: conn, sessionID: sessionID}
+		s.acp.mu.Lock()
+		s.acp.threads[commentID] = thread
+		s.acp.mu.Unlock()
+		prompt = buildAgentPrompt(comment, path)
+	} else {
+		prompt = buildACPFollowUp(comment, thread.sent)
+	}
+
+	turn := &acpTurn{commentID: commentID, filePath: path}
+	text, edited, err := s.acpPrompt(ctx, agent, thread.conn, thread.sessionID, turn, prompt)
+	if err != nil {
+		return err
+	}
+
+	// Path may have changed during the run; look the comment up again.
+	_, path, found = sess.FindCommentByID(commentID, path)
+	if !found {
+		return fmt.Errorf("comment %s no longer exists", commentID)
+	}
+	if text != "" {
+		sess.addAgentReply(path, commentID, text, agent.Name)
+	}
+	if c, _, ok := sess.FindCommentByID(commentID, path); ok {
+		s.acp.mu.Lock()
+		thread.sent = len(c.Replies)
+		s.acp.mu.Unlock()
+	}
+	if edited {
+		sess.RefreshFileContent()
+		if sess.Mode == "git" {
+			sess.RefreshFileList()
+			sess.RefreshDiffs()
+		}
+	}
+	sess.notify(SSEEvent{Type: "comments-changed"})
+	return nil
+}
+
+// acpOneShot runs a single prompt in a fresh ACP session and returns the
+// agent's full reply. Used for pre-review passes.
+func (s *Server) acpOneShot(ctx context.Context, agent resolvedAgent, prompt string) (string, error) {
+	conn, err := s.acpConnFor(ctx, agent)
+	if err != nil {
+		return "", err
+	}
+	sessionID, err := conn.NewSession(ctx)
+	if err != nil {
+		return "", fmt.Errorf("ACP session/new: %w", err)
+	}
+	text, _, err := s.acpPrompt(ctx, agent, conn, sessionID, &acpTurn{}, prompt)
+	return text, err
+}
+
+// acpPrompt sends one turn, bounded by the profile timeout, and returns the
+// streamed reply and whether the agent edited files.
+func (s *Server) acpPrompt(ctx context.Context, agent resolvedAgent, conn *acpConn, sessionID string, turn *acpTurn, prompt string) (string, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, agent.Timeout)
+	defer cancel()
+
+	s.acp.mu.Lock()
+	s.acp.turns[sessionID] = turn
+	s.acp.mu.Unlock()
+	defer func() {
+		s.acp.mu.Lock()
+		delete(s.acp.turns, sessionID)
+		s.acp.mu.Unlock()
+	}()
+
+	stop, err := conn.Prompt(ctx, sessionID, prompt)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", false, fmt.Errorf("agent timed out after %s", agent.Timeout)
+		}
+		return "", false, err
+	}
+	log.Printf("acp: session %s turn finished (%s)", sessionID, stop)
+
+	s.acp.mu.Lock()
+	defer s.acp.mu.Unlock()
+	return strings.TrimSpace(turn.text.String()), turn.edited, nil
+}
+
+// buildACPFollowUp formats the replies posted since the agent's last turn.
+func buildACPFollowUp(c Comment, seen int) string {
+	var b strings.Builder
+	if seen > len(c.Replies) {
+		seen = len(c.Replies)
+	}
+	for _, reply := range c.Replies[seen:] {
+		b.WriteString(fmt.Sprintf("Reply from %s:\n> %s\n\n", reply.Author, reply.Body))
+	}
+	if b.Len() == 0 {
+		b.WriteString("Please take another look at this comment.\n")
+	}
+	return b.String()
+}
+
+// onACPUpdate streams agent message chunks to the browser as "agent-stream"
+// events and notes edit tool calls.
+func (s *Server) onACPUpdate(u acpUpdate) {
+	s.acp.mu.Lock()
+	turn := s.acp.turns[u.SessionID]
+	if turn == nil {
+		s.acp.mu.Unlock()
+		return
+	}
+	var chunk string
+	switch u.Update.SessionUpdate {
+	case "agent_message_chunk":
+		if u.Update.Content.Type == "text" {
+			chunk = u.Update.Content.Text
+			turn.text.WriteString(chunk)
+		}
+	case "tool_call", "tool_call_update":
+		if u.Update.Kind == "edit" {
+			turn.edited = true
+		}
+	}
+	commentID, filePath := turn.commentID, turn.filePath
+	s.acp.mu.Unlock()
+
+	if chunk == "" || commentID == "" {
+		return
+	}
+	if sess := s.session.Load(); sess != nil {
+		data, _ := json.Marshal(map[string]string{"comment_id": commentID, "text": chunk})
+		sess.notify(SSEEvent{Type: "agent-stream", Filename: filePath, Content: string(data)})
+	}
+}
+
+// onACPWrite records that the agent changed a file during the current turn.
+// The file watcher picks up the change itself and emits file-changed.
+func (s *Server) onACPWrite(sessionID, path string) {
+	s.acp.mu.Lock()
+	defer s.acp.mu.Unlock()
+	if turn := s.acp.turns[sessionID]; turn != nil {
+		turn.edited = true
+	}
+}
+
+// closeAgents stops any persistent agent processes. Jobs still running get
+// an error from their agent; the maps stay usable for their bookkeeping.
+func (s *Server) closeAgents() {
+	s.acp.mu.Lock()
+	conns := s.acp.conns
+	s.acp.closed = true
+	s.acp.conns = make(map[string]*acpConn)
+	s.acp.threads = make(map[string]*acpThread)
+	s.acp.turns = make(map[string]*acpTurn)
+	s.acp.mu.Unlock()
+	for _, c := range conns {
+		c.Close()
+	}
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Agent job states. A job moves queued -> running -> done/failed/cancelled,
+// or straight from queued to cancelled when it is cancelled before starting.
+const (
+	agentJobQueued    = "queued"
+	agentJobRunning   = "running"
+	agentJobDone      = "done"
+	agentJobFailed    = "failed"
+	agentJobCancelled = "cancelled"
+)
+
+// agentJobPreReview is the Kind of a job that reviews the whole diff rather
+// than answering a single comment.
+const agentJobPreReview = "pre-review"
+
+const (
+	// defaultAgentConcurrency is the number of agent runs allowed at once
+	// when agent_concurrency is not configured.
+	defaultAgentConcurrency = 2
+	// agentQueueLimit bounds the number of queued (not yet running) jobs.
+	agentQueueLimit = 32
+	// agentJobHistoryLimit bounds how many finished jobs are kept for listing.
+	agentJobHistoryLimit = 50
+)
+
+var (
+	errAgentQueueFull   = errors.New("agent queue is full")
+	errAgentJobNotFound = errors.New("agent job not found")
+	errAgentJobFinished = errors.New("agent job already finished")
+)
+
+// agentJob is a single "Send to agent" run tracked by the job manager.
+type agentJob struct {
+	ID         string `json:"id"`
+	Kind       string `json:"kind,omitempty"` // "" for comment replies, agentJobPreReview for pre-review passes
+	CommentID  string `json:"comment_id"`
+	FilePath   string `json:"file_path"`
+	Profile    string `json:"profile,omitempty"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	CreatedAt  string `json:"created_at"`
+	StartedAt  string `json:"started_at,omitempty"`
+	FinishedAt string `json:"finished_at,omitempty"`
+
+	prompt    string
+	cancel    context.CancelFunc
+	cancelled bool
+}
+
+// finished reports whether the job has reached a terminal state.
+func (j *agentJob) finished() bool {
+	return j.Status == agentJobDone || j.Status == agentJobFailed || j.Status == agentJobCancelled
+}
+
+// agentRunFunc executes one job. It must honour ctx cancellation.
+type agentRunFunc func(ctx context.Context, job agentJob) error
+
+// agentJobManager runs agent jobs through a bounded queue with a fixed
+// number of concurrent runs. When serializeFiles is set, two jobs whose
+// comments belong to the same file never run at the same time.
+type agentJobManager struct {
+	mu             sync.Mutex
+	jobs           map[string]*agentJob
+	order          []string // job IDs in submission order
+	running        int
+	busyFiles      map[string]int
+	concurrency    int
+	serializeFiles bool
+	run            agentRunFunc
+	onChange       func(agentJob)
+}
+
+// newAgentJobManager creates a manager that runs at most concurrency jobs at
+// once. onChange, if non-nil, is called (without the lock held) after every
+// status transition.
+func newAgentJobManager(concurrency int, serializeFiles bool, run agentRunFunc, onChange func(agentJob)) *agentJobManager {
+	if concurrency <= 0 {
+		concurrency = defaultAgentConcurrency
+	}
+	return &agentJobManager{
+		jobs:           make(map[string]*agentJob),
+		busyFiles:      make(map[string]int),
+		concurrency:    concurrency,
+		serializeFiles: serializeFiles,
+		run:            run,
+		onChange:       onChange,
+	}
+}
+
+// Submit enqueues a job for the given comment, to be run by the named agent
+// profile, and returns a snapshot of it.
+// Returns errAgentQueueFull if too many jobs are already waiting.
+func (m *agentJobManager) Submit(commentID, filePath, profile, prompt string) (agentJob, error) {
+	return m.submit(agentJob{CommentID: commentID, FilePath: filePath, Profile: profile, prompt: prompt})
+}
+
+// SubmitPreReview enqueues a pre-review pass over the whole diff.
+func (m *agentJobManager) SubmitPreReview(profile, prompt string) (agentJob, error) {
+	return m.submit(agentJob{Kind: agentJobPreReview, Profile: profile, prompt: prompt})
+}
+
+// submit enqueues a copy of spec with a fresh ID and queued status.
+func (m *agentJobManager) submit(spec agentJob) (agentJob, error) {
+	m.mu.Lock()
+	queued := 0
+	for _, j := range m.jobs {
+		if j.Status == agentJobQueued {
+			queued++
+		}
+	}
+	if queued >= agentQueueLimit {
+		m.mu.Unlock()
+		return agentJob{}, errAgentQueueFull
+	}
+	job := &agentJob{
+		ID:        randomID("job_"),
+		Kind:      spec.Kind,
+		CommentID: spec.CommentID,
+		FilePath:  spec.FilePath,
+		Profile:   spec.Profile,
+		Status:    agentJobQueued,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		prompt:    spec.prompt,
+	}
+	m.jobs[job.ID] = job
+	m.order = append(m.order, job.ID)
+	m.pruneLocked()
+	snap := *job
+	started := m.dispatchLocked()
+	m.mu.Unlock()
+
+	m.changed(snap)
+	for _, s := range started {
+		m.changed(s)
+	}
+	return snap, nil
+}
+
+// Get returns a snapshot of the job with the given ID.
+func (m *agentJobManager) Get(id string) (agentJob, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return agentJob{}, false
+	}
+	return *j, true
+}
+
+// List returns snapshots of all tracked jobs in submission order.
+func (m *agentJobManager) List() []agentJob {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := make([]agentJob, 0, len(m.order))
+	for _, id := range m.order {
+		result = append(result, *m.jobs[id])
+	}
+	return result
+}
+
+// Cancel stops a queued or running job. Queued jobs are cancelled
+// immediately; running jobs have their command context cancelled and move to
+// the cancelled state once the command exits.
+func (m *agentJobManager) Cancel(id string) (agentJob, error) {
+	m.mu.Lock()
+	j, ok := m.jobs[id]
+	if !ok {
+		m.mu.Unlock()
+		return agentJob{}, errAgentJobNotFound
+	}
+	if j.finished() {
+		snap := *j
+		m.mu.Unlock()
+		return snap, errAgentJobFinished
+	}
+	j.cancelled = true
+	if j.Status == agentJobQueued {
+		j.Status = agentJobCancelled
+		j.FinishedAt = time.Now().UTC().Format(time.RFC3339)
+		snap := *j
+		m.mu.Unlock()
+		m.changed(snap)
+		return snap, nil
+	}
+	if j.cancel != nil {
+		j.cancel()
+	}
+	snap := *j
+	m.mu.Unlock()
+	return snap, nil
+}
+
+// Retry resubmits a failed or cancelled job as a new job with the given
+// prompt, keeping its kind, comment, file and agent profile.
+func (m *agentJobManager) Retry(id, prompt string) (agentJob, error) {
+	m.mu.Lock()
+	j, ok := m.jobs[id]
+	if !ok {
+		m.mu.Unlock()
+		return agentJob{}, errAgentJobNotFound
+	}
+	if j.Status != agentJobFailed && j.Status != agentJobCancelled {
+		snap := *j
+		m.mu.Unlock()
+		return snap, errors.New("only failed or cancelled jobs can be retried")
+	}
+	spec := agentJob{Kind: j.Kind, CommentID: j.CommentID, FilePath: j.FilePath, Profile: j.Profile, prompt: prompt}
+	m.mu.Unlock()
+	return m.submit(spec)
+}
+
+// dispatchLocked starts as many queued jobs as concurrency allows and
+// returns snapshots of the jobs it started. Caller must hold m.mu.
+func (m *agentJobManager) dispatchLocked() []agentJob {
+	var started []agentJob
+	for _, id := range m.order {
+		if m.running >= m.concurrency {
+			break
+		}
+		j := m.jobs[id]
+		if j.Status != agentJobQueued {
+			continue
+		}
+		if m.serializeFiles && m.busyFiles[j.FilePath] > 0 {
+			continue
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		j.cancel = cancel
+		j.Status = agentJobRunning
+		j.StartedAt = time.Now().UTC().Format(time.RFC3339)
+		m.running++
+		m.busyFiles[j.FilePath]++
+		started = append(started, *j)
+		go m.execute(ctx, j.ID, *j)
+	}
+	return started
+}
+
+// execute runs a single job and records its outcome.
+func (m *agentJobManager) execute(ctx context.Context, id string, job agentJob) {
+	err := m.run(ctx, job)
+
+	m.mu.Lock()
+	j := m.jobs[id]
+	j.cancel = nil
+	j.FinishedAt = time.Now().UTC().Format(time.RFC3339)
+	switch {
+	case j.cancelled:
+		j.Status = agentJobCancelled
+	case err != nil:
+		j.Status = agentJobFailed
+		j.Error = err.Error()
+	default:
+		j.Status = agentJobDone
+	}
+	m.running--
+	if m.busyFiles[j.FilePath]--; m.busyFiles[j.FilePath] <= 0 {
+		delete(m.busyFiles, j.FilePath)
+	}
+	snap := *j
+	started := m.dispatchLocked()
+	m.mu.Unlock()
+
+	m.changed(snap)
+	for _, s := range started {
+		m.changed(s)
+	}
+}
+
+// pruneLocked drops the oldest finished jobs beyond agentJobHistoryLimit.
+// Caller must hold m.mu.
+func (m *agentJobManager) pruneLocked() {
+	finished := 0
+	for _, id := range m.order {
+		if m.jobs[id].finished() {
+			finished++
+		}
+	}
+	if finished <= agentJobHistoryLimit {
+		return
+	}
+	drop := finished - agentJobHistoryLimit
+	kept := m.order[:0]
+	for _, id := range m.order {
+		if drop > 0 && m.jobs[id].finished() {
+			delete(m.jobs, id)
+			drop--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	m.order = kept
+}
+
+func (m *agentJobManager) changed(job agentJob) {
+	if m.onChange != nil {
+		m.onChange(job)
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitForJobStatus polls until the job reaches want or the deadline passes.
+func waitForJobStatus(t *testing.T, m *agentJobManager, id, want string) agentJob {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if j, ok := m.Get(id); ok && j.Status == want {
+			return j
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	j, _ := m.Get(id)
+	t.Fatalf("job %s status = %q, want %q", id, j.Status, want)
+	return j
+}
+
+// blockingRun returns a run func that blocks each job until release is closed
+// (or its context is cancelled) and tracks the peak number of concurrent runs.
+func blockingRun(release <-chan struct{}, peak *int32) agentRunFunc {
+	var current int32
+	return func(ctx context.Context, job agentJob) error {
+		n := atomic.AddInt32(&current, 1)
+		for {
+			p := atomic.LoadInt32(peak)
+			if n <= p || atomic.CompareAndSwapInt32(peak, p, n) {
+				break
+			}
+		}
+		defer atomic.AddInt32(&current, -1)
+		select {
+		case <-release:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func TestAgentJobManager_RunsToCompletion(t *testing.T) {
+	m := newAgentJobManager(1, false, func(ctx context.Context, job agentJob) error {
+		if job.prompt != "do it" {
+			t.Errorf("prompt = %q, want %q", job.prompt, "do it")
+		}
+		return nil
+	}, nil)
+	job, err := m.Submit("c1", "a.go", "", "do it")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := waitForJobStatus(t, m, job.ID, agentJobDone)
+	if done.StartedAt == "" || done.FinishedAt == "" {
+		t.Errorf("expected timestamps, got started=%q finished=%q", done.StartedAt, done.FinishedAt)
+	}
+}
+
+func TestAgentJobManager_FailureRecordsError(t *testing.T) {
+	m := newAgentJobManager(1, false, func(ctx context.Context, job agentJob) error {
+		return errors.New("boom")
+	}, nil)
+	job, _ := m.Submit("c1", "a.go", "", "")
+	failed := waitForJobStatus(t, m, job.ID, agentJobFailed)
+	if failed.Error != "boom" {
+		t.Errorf("error = %q, want boom", failed.Error)
+	}
+}
+
+func TestAgentJobManager_ConcurrencyLimit(t *testing.T) {
+	release := make(chan struct{})
+	var peak int32
+	m := newAgentJobManager(2, false, blockingRun(release, &peak), nil)
+
+	var ids []string
+	for i := 0; i < 5; i++ {
+		j, err := m.Submit("c", "file"+string(rune('a'+i)), "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, j.ID)
+	}
+	waitForJobStatus(t, m, ids[0], agentJobRunning)
+	waitForJobStatus(t, m, ids[1], agentJobRunning)
+	if j, _ := m.Get(ids[2]); j.Status != agentJobQueued {
+		t.Errorf("third job status = %q, want queued", j.Status)
+	}
+
+	close(release)
+	for _, id := range ids {
+		waitForJobStatus(t, m, id, agentJobDone)
+	}
+	if peak > 2 {
+		t.Errorf("peak concurrency = %d, want <= 2", peak)
+	}
+}
+
+func TestAgentJobManager_SerializeSameFile(t *testing.T) {
+	release := make(chan struct{})
+	var peak int32
+	m := newAgentJobManager(4, true, blockingRun(release, &peak), nil)
+
+	a1, _ := m.Submit("c1", "a.go", "", "")
+	a2, _ := m.Submit("c2", "a.go", "", "")
+	b1, _ := m.Submit("c3", "b.go", "", "")
+
+	waitForJobStatus(t, m, a1.ID, agentJobRunning)
+	waitForJobStatus(t, m, b1.ID, agentJobRunning)
+	if j, _ := m.Get(a2.ID); j.Status != agentJobQueued {
+		t.Errorf("second job on a.go status = %q, want queued while first runs", j.Status)
+	}
+
+	close(release)
+	waitForJobStatus(t, m, a2.ID, agentJobDone)
+}
+
+func TestAgentJobManager_CancelQueued(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	var peak int32
+	m := newAgentJobManager(1, false, blockingRun(release, &peak), nil)
+
+	first, _ := m.Submit("c1", "a.go", "", "")
+	second, _ := m.Submit("c2", "b.go", "", "")
+	waitForJobStatus(t, m, first.ID, agentJobRunning)
+
+	j, err := m.Cancel(second.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.Status != agentJobCancelled {
+		t.Errorf("status = %q, want cancelled", j.Status)
+	}
+}
+
+func TestAgentJobManager_CancelRunning(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	var peak int32
+	m := newAgentJobManager(1, false, blockingRun(release, &peak), nil)
+
+	job, _ := m.Submit("c1", "a.go", "", "")
+	waitForJobStatus(t, m, job.ID, agentJobRunning)
+	if _, err := m.Cancel(job.ID); err != nil {
+		t.Fatal(err)
+	}
+	cancelled := waitForJobStatus(t, m, job.ID, agentJobCancelled)
+	if cancelled.Error != "" {
+		t.Errorf("cancelled job should not record an error, got %q", cancelled.Error)
+	}
+
+	if _, err := m.Cancel(job.ID); !errors.Is(err, errAgentJobFinished) {
+		t.Errorf("second cancel err = %v, want errAgentJobFinished", err)
+	}
+	if _, err := m.Cancel("job_missing"); !errors.Is(err, errAgentJobNotFound) {
+		t.Errorf("missing cancel err = %v, want errAgentJobNotFound", err)
+	}
+}
+
+func TestAgentJobManager_Retry(t *testing.T) {
+	var calls int32
+	m := newAgentJobManager(1, false, func(ctx context.Context, job agentJob) error {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return errors.New("first attempt fails")
+		}
+		return nil
+	}, nil)
+
+	job, _ := m.Submit("c1", "a.go", "", "v1")
+	waitForJobStatus(t, m, job.ID, agentJobFailed)
+
+	retry, err := m.Retry(job.ID, "v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retry.ID == job.ID {
+		t.Error("retry should create a new job")
+	}
+	if retry.CommentID != "c1" || retry.FilePath != "a.go" {
+		t.Errorf("retry = %+v, want same comment and file", retry)
+	}
+	waitForJobStatus(t, m, retry.ID, agentJobDone)
+
+	if _, err := m.Retry(retry.ID, "v3"); err == nil {
+		t.Error("expected error retrying a successful job")
+	}
+}
+
+func TestAgentJobManager_QueueFull(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	var peak int32
+	m := newAgentJobManager(1, false, blockingRun(release, &peak), nil)
+
+	first, _ := m.Submit("c0", "a.go", "", "")
+	waitForJobStatus(t, m, first.ID, agentJobRunning)
+	for i := 0; i < agentQueueLimit; i++ {
+		if _, err := m.Submit("c", "a.go", "", ""); err != nil {
+			t.Fatalf("submit %d: %v", i, err)
+		}
+	}
+	if _, err := m.Submit("c", "a.go", "", ""); !errors.Is(err, errAgentQueueFull) {
+		t.Errorf("err = %v, want errAgentQueueFull", err)
+	}
+}
+
+func TestAgentJobManager_OnChange(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string
+	done := make(chan struct{})
+	m := newAgentJobManager(1, false, func(ctx context.Context, job agentJob) error { return nil }, func(j agentJob) {
+		mu.Lock()
+		seen = append(seen, j.Status)
+		mu.Unlock()
+		if j.Status == agentJobDone {
+			close(done)
+		}
+	})
+	m.Submit("c1", "a.go", "", "")
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for done notification")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{agentJobQueued, agentJobRunning, agentJobDone}
+	if len(seen) != len(want) {
+		t.Fatalf("transitions = %v, want %v", seen, want)
+	}
+	for i := range want {
+		if seen[i] != want[i] {
+			t.Errorf("transition %d = %q, want %q", i, seen[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// preReviewPromptLimit caps the diff text sent to the agent in a pre-review
+// pass. Files past the limit are listed by name only.
+const preReviewPromptLimit = 256 << 10
+
+// preReviewFile is one file's input to a pre-review prompt.
+type preReviewFile struct {
+	Path    string
+	Status  string
+	Hunks   []DiffHunk
+	Content string // used when there are no hunks (e.g. markdown in files mode)
+}
+
+// preReviewFiles snapshots the files under review, loading lazy files.
+func (s *Session) preReviewFiles() []preReviewFile {
+	s.mu.RLock()
+	files := append([]*FileEntry(nil), s.Files...)
+	repoRoot, baseRef, vcs := s.RepoRoot, s.BaseRef, s.VCS
+	s.mu.RUnlock()
+
+	var result []preReviewFile
+	for _, f := range files {
+		if f.ensureLoaded(repoRoot, baseRef, vcs) != nil {
+			continue
+		}
+		s.mu.RLock()
+		pf := preReviewFile{Path: f.Path, Status: f.Status, Hunks: f.DiffHunks}
+		if len(pf.Hunks) == 0 {
+			pf.Content = f.Content
+		}
+		s.mu.RUnlock()
+		result = append(result, pf)
+	}
+	return result
+}
+
+// buildPreReviewPrompt asks the agent to review the diff and answer with a
+// JSON array in the same format `crit comment --json` accepts.
+func buildPreReviewPrompt(files []preReviewFile) string {
+	var b strings.Builder
+	b.WriteString("You are doing a first review pass on a change before a human reviewer looks at it.\n" +
+		"Point out bugs, risky changes, missing tests and unclear code. Skip style nits and praise.\n\n" +
+		"Respond with ONLY a JSON array, no prose and no code fences. Each element is one finding:\n" +
+		"  {\"file\": \"path/to/file\", \"line\": 42, \"body\": \"...\"}          a single line\n" +
+		"  {\"file\": \"path/to/file\", \"line\": \"40-45\", \"body\": \"...\"}     a line range\n" +
+		"  {\"file\": \"path/to/file\", \"scope\": \"file\", \"body\": \"...\"}    the whole file\n" +
+		"  {\"scope\": \"review\", \"body\": \"...\"}                            the change as a whole\n" +
+		"Line numbers refer to the new version of the file, as numbered below. " +
+		"Respond with [] if you have no findings.\n\n" +
+		"IMPORTANT: Do NOT edit files and do NOT run `crit` commands.\n\n")
+
+	var skipped []string
+	for _, f := range files {
+		section := renderPreReviewFile(f)
+		if b.Len()+len(section) > preReviewPromptLimit {
+			skipped = append(skipped, f.Path)
+			continue
+		}
+		b.WriteString(section)
+	}
+	if len(skipped) > 0 {
+		b.WriteString("The following files were also changed but left out for length; read them yourself if needed:\n")
+		for _, p := range skipped {
+			b.WriteString("- " + p + "\n")
+		}
+	}
+	return b.String()
+}
+
+// renderPreReviewFile formats one file as numbered diff lines ("  42 + code").
+// Deleted lines have no new-side number and cannot be commented on.
+func renderPreReviewFile(f preReviewFile) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "=== %s (%s) ===\n", f.Path, f.Status)
+	if len(f.Hunks) == 0 {
+		for i, line := range strings.Split(f.Content, "\n") {
+			fmt.Fprintf(&b, "%5d   %s\n", i+1, line)
+		}
+		b.WriteString("\n")
+		return b.String()
+	}
+	for _, h := range f.Hunks {
+		b.WriteString(h.Header + "\n")
+		for _, l := range h.Lines {
+			switch l.Type {
+			case "add":
+				fmt.Fprintf(&b, "%5d + %s\n", l.NewNum, l.Content)
+			case "del":
+				fmt.Fprintf(&b, "      - %s\n", l.Content)
+			default:
+				fmt.Fprintf(&b, "%5d   %s\n", l.NewNum, l.Content)
+			}
+		}
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// parsePreReviewOutput extracts the JSON array of findings from agent output,
+// tolerating prose or code fences around it.
+func parsePreReviewOutput(out string) ([]BulkCommentEntry, error) {
+	start := strings.Index(out, "[")
+	end := strings.LastIndex(out, "]")
+	if start < 0 || end < start {
+		return nil, errors.New("agent output contains no JSON array")
+	}
+	var entries []BulkCommentEntry
+	if err := json.Unmarshal([]byte(out[start:end+1]), &entries); err != nil {
+		return nil, fmt.Errorf("parsing agent findings: %w", err)
+	}
+	return entries, nil
+}
+
+// addAISuggestions validates entries with the bulk comment rules and adds
+// them to the session as comments by author, marked as AI suggestions.
+// Replies and entries that fail validation or name files outside the review
+// are skipped. Returns the number of comments added.
+func (s *Session) addAISuggestions(entries []BulkCommentEntry, author string) int {
+	scratch := CritJSON{Files: map[string]CritJSONFile{}}
+	for i, e := range entries {
+		if e.ReplyTo != "" {
+			continue
+		}
+		e.Author = ""
+		if err := processBulkEntry(&scratch, i, e, author, ""); err != nil {
+			log.Printf("pre-review: skipping finding: %v", err)
+		}
+	}
+
+	added := 0
+	for _, c := range scratch.ReviewComments {
+		rc := s.AddReviewComment(c.Body, author, "")
+		s.SetCommentAISuggestion("", rc.ID, true)
+		added++
+	}
+	for path, cf := range scratch.Files {
+		for _, c := range cf.Comments {
+			var nc Comment
+			var ok bool
+			if c.Scope == "file" {
+				nc, ok = s.AddFileComment(path, c.Body, author, "")
+			} else {
+				nc, ok = s.AddComment(path, c.StartLine, c.EndLine, "", c.Body, "", author, "")
+			}
+			if !ok {
+				log.Printf("pre-review: skipping finding on %s: file is not part of this review", path)
+				continue
+			}
+			s.SetCommentAISuggestion(path, nc.ID, true)
+			added++
+		}
+	}
+	return added
+}
+
+// runPreReview runs the agent over the whole diff and ingests its findings.
+func (s *Server) runPreReview(ctx context.Context, profile, prompt string) error {
+	agent, err := s.resolveAgent(profile, "")
+	if err != nil {
+		return err
+	}
+	sess := s.session.Load()
+	var stdout string
+	if agent.ACP {
+		stdout, err = s.acpOneShot(ctx, agent, prompt)
+	} else {
+		stdout, _, err = execAgent(ctx, agent, sess.RepoRoot, prompt, "pre-review")
+	}
+	if err != nil {
+		return err
+	}
+	entries, err := parsePreReviewOutput(stdout)
+	if err != nil {
+		log.Printf("pre-review: %v\nOutput: %s", err, truncateStr(stdout, 2000))
+		return err
+	}
+	added := sess.addAISuggestions(entries, agent.Name)
+	log.Printf("pre-review: added %d of %d finding(s)", added, len(entries))
+	if added > 0 {
+		sess.notify(SSEEvent{Type: "comments-changed"})
+	}
+	return nil
+}
+
+// handlePreReview queues an agent pre-review pass over the current diff.
+// POST /api/agent/pre-review with optional body {"profile": "name"}.
+func (s *Server) handlePreReview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.agentEnabled() {
+		http.Error(w, "agent_cmd not configured", http.StatusBadRequest)
+		return
+	}
+	var body struct {
+		Profile string `json:"profile"`
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	agent, err := s.resolveAgent(body.Profile, "")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	prompt := buildPreReviewPrompt(s.session.Load().preReviewFiles())
+	job, err := s.agentQueue().SubmitPreReview(agent.Profile, prompt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	writeJSON(w, job)
+}
+
+// handleCommentAISuggestion accepts or dismisses an AI pre-review comment.
+// Accepting keeps the comment as a regular review comment; dismissing deletes it.
+// An empty path addresses a review-level comment.
+func (s *Server) handleCommentAISuggestion(w http.ResponseWriter, r *http.Request, path, commentID, action string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sess := s.session.Load()
+	switch action {
+	case "accept":
+		c, ok := sess.SetCommentAISuggestion(path, commentID, false)
+		if !ok {
+			http.Error(w, "Comment not found", http.StatusNotFound)
+			return
+		}
+		sess.notify(SSEEvent{Type: "comments-changed"})
+		writeJSON(w, c)
+	case "dismiss":
+		var ok bool
+		if path == "" {
+			ok = sess.DeleteReviewComment(commentID)
+		} else {
+			ok = sess.DeleteComment(path, commentID)
+		}
+		if !ok {
+			http.Error(w, "Comment not found", http.StatusNotFound)
+			return
+		}
+		sess.notify(SSEEvent{Type: "comments-changed"})
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Unknown action", http.StatusNotFound)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParsePreReviewOutput(t *testing.T) {
+	out := "Here are my findings:\n```json\n[{\"file\": \"a.go\", \"line\": \"3-4\", \"body\": \"racy\"}]\n```\n"
+	entries, err := parsePreReviewOutput(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].File != "a.go" || entries[0].LineSpec != "3-4" {
+		t.Errorf("entries = %+v", entries)
+	}
+
+	if entries, err := parsePreReviewOutput("[]"); err != nil || len(entries) != 0 {
+		t.Errorf("empty array: %v, %v", entries, err)
+	}
+	if _, err := parsePreReviewOutput("no findings"); err == nil {
+		t.Error("expected error for output without a JSON array")
+	}
+	if _, err := parsePreReviewOutput("[not json]"); err == nil {
+		t.Error("expected error for malformed JSON")
+	}
+}
+
+func TestBuildPreReviewPrompt_NumbersNewLines(t *testing.T) {
+	files := []preReviewFile{{
+		Path:   "a.go",
+		Status: "modified",
+		Hunks: []DiffHunk{{Header: "@@ -1,2 +1,2 @@", Lines: []DiffLine{
+			{Type: "context", Content: "package a", OldNum: 1, NewNum: 1},
+			{Type: "del", Content: "var x = 1", OldNum: 2},
+			{Type: "add", Content: "var x = 2", NewNum: 2},
+		}}},
+	}, {
+		Path:    "notes.md",
+		Status:  "added",
+		Content: "hello",
+	}}
+	prompt := buildPreReviewPrompt(files)
+	for _, want := range []string{"=== a.go (modified) ===", "    2 + var x = 2", "      - var x = 1", "    1   hello"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt missing %q:\n%s", want, prompt)
+		}
+	}
+}
+
+func TestAddAISuggestions(t *testing.T) {
+	_, session := newTestServer(t)
+	added := session.addAISuggestions([]BulkCommentEntry{
+		{File: "test.md", Line: 2, Body: "line finding"},
+		{File: "test.md", Scope: "file", Body: "file finding"},
+		{Scope: "review", Body: "overall"},
+		{File: "missing.go", Line: 1, Body: "not in review"},
+		{File: "test.md", Body: "no line"},
+		{ReplyTo: "c1", Body: "replies are ignored"},
+	}, "claude")
+	if added != 3 {
+		t.Fatalf("added = %d, want 3", added)
+	}
+	comments := session.GetComments("test.md")
+	if len(comments) != 2 {
+		t.Fatalf("file comments = %d, want 2", len(comments))
+	}
+	for _, c := range comments {
+		if !c.AISuggestion || c.Author != "claude" {
+			t.Errorf("comment %+v should be an AI suggestion by claude", c)
+		}
+	}
+	review := session.GetReviewComments()
+	if len(review) != 1 || !review[0].AISuggestion {
+		t.Errorf("review comments = %+v, want one AI suggestion", review)
+	}
+}
+
+func TestHandlePreReview_IngestsFindings(t *testing.T) {
+	s, session := newTestServer(t)
+	script := filepath.Join(t.TempDir(), "agent.sh")
+	findings := `[{"file":"test.md","line":1,"body":"check this"}]`
+	os.WriteFile(script, []byte("#!/bin/sh\ncat >/dev/null\necho '"+findings+"'\n"), 0o755)
+	s.agentCmd = script
+
+	req := httptest.NewRequest("POST", "/api/agent/pre-review", nil)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	var job agentJob
+	if err := json.Unmarshal(w.Body.Bytes(), &job); err != nil {
+		t.Fatal(err)
+	}
+	if job.Kind != agentJobPreReview {
+		t.Errorf("kind = %q, want %q", job.Kind, agentJobPreReview)
+	}
+	waitForJobStatus(t, s.agentQueue(), job.ID, agentJobDone)
+
+	comments := session.GetComments("test.md")
+	if len(comments) != 1 || comments[0].Body != "check this" || !comments[0].AISuggestion {
+		t.Fatalf("comments = %+v, want one AI suggestion", comments)
+	}
+	if comments[0].Author != "agent.sh" {
+		t.Errorf("author = %q, want agent.sh", comments[0].Author)
+	}
+}
+
+func TestHandlePreReview_NoAgent(t *testing.T) {
+	s, _ := newTestServer(t)
+	req := httptest.NewRequest("POST", "/api/agent/pre-review", nil)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", w.Code)
+	}
+}
+
+func TestHandleCommentAISuggestion(t *testing.T) {
+	s, session := newTestServer(t)
+	session.addAISuggestions([]BulkCommentEntry{
+		{File: "test.md", Line: 1, Body: "keep"},
+		{File: "test.md", Line: 2, Body: "drop"},
+		{Scope: "review", Body: "overall"},
+	}, "agent")
+	var keep, drop string
+	for _, c := range session.GetComments("test.md") {
+		if c.Body == "keep" {
+			keep = c.ID
+		} else {
+			drop = c.ID
+		}
+	}
+
+	req := httptest.NewRequest("POST", "/api/comment/"+keep+"/ai-suggestion/accept?path=test.md", nil)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("accept: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("POST", "/api/comment/"+drop+"/ai-suggestion/dismiss?path=test.md", nil)
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("dismiss: expected 204, got %d: %s", w.Code, w.Body.String())
+	}
+
+	comments := session.GetComments("test.md")
+	if len(comments) != 1 || comments[0].ID != keep || comments[0].AISuggestion {
+		t.Errorf("comments = %+v, want only the accepted comment without the AI mark", comments)
+	}
+
+	reviewID := session.GetReviewComments()[0].ID
+	req = httptest.NewRequest("POST", "/api/review-comment/"+reviewID+"/ai-suggestion/accept", nil)
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || session.GetReviewComments()[0].AISuggestion {
+		t.Errorf("review accept: code %d, comments %+v", w.Code, session.GetReviewComments())
+	}
+
+	req = httptest.NewRequest("POST", "/api/comment/"+keep+"/ai-suggestion/bogus?path=test.md", nil)
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown action: expected 404, got %d", w.Code)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// defaultAgentProfile is the profile name given to a bare agent_cmd, and the
+// fallback when neither a request nor the config selects a profile.
+const defaultAgentProfile = "default"
+
+// AgentProfile is one named agent from the agent_profiles config map, e.g. a
+// cheap model for questions and a stronger one for refactors.
+type AgentProfile struct {
+	Cmd      string `json:"cmd"`                // command line, same rules as agent_cmd ({prompt} or stdin)
+	Name     string `json:"name,omitempty"`     // display name used as reply author (default: command binary)
+	Timeout  string `json:"timeout,omitempty"`  // Go duration such as "5m" (default: 10m)
+	Workdir  string `json:"workdir,omitempty"`  // "repo" or "worktree" (default: follows agent_worktree)
+	Protocol string `json:"protocol,omitempty"` // "acp" keeps one agent process running (default: a process per request)
+}
+
+// AgentProfileRule selects a default profile for comments on files matching
+// Pattern. Patterns use the same syntax as ignore_patterns.
+type AgentProfileRule struct {
+	Pattern string `json:"pattern"`
+	Profile string `json:"profile"`
+}
+
+// resolvedAgent is a profile with defaults applied, ready to run.
+type resolvedAgent struct {
+	Profile  string // key in agent_profiles
+	Cmd      string
+	Name     string
+	Timeout  time.Duration
+	Worktree bool
+	ACP      bool // long-lived Agent Client Protocol process
+}
+
+// agentProfileInfo is the public view of a profile returned by /api/config.
+// Commands are not included: only the default command is shown in settings.
+type agentProfileInfo struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// agentProfiles returns the configured profiles, with a bare agent_cmd
+// exposed as the "default" profile unless agent_profiles already defines one.
+func (s *Server) agentProfiles() map[string]AgentProfile {
+	profiles := make(map[string]AgentProfile, len(s.cfg.AgentProfiles)+1)
+	for name, p := range s.cfg.AgentProfiles {
+		if p.Cmd != "" {
+			profiles[name] = p
+		}
+	}
+	if _, ok := profiles[defaultAgentProfile]; !ok && s.agentCmd != "" {
+		profiles[defaultAgentProfile] = AgentProfile{Cmd: s.agentCmd}
+	}
+	return profiles
+}
+
+// agentEnabled reports whether any agent is configured.
+func (s *Server) agentEnabled() bool {
+	return len(s.agentProfiles()) > 0
+}
+
+// agentProfileList returns the configured profiles sorted by ID.
+func (s *Server) agentProfileList() []agentProfileInfo {
+	profiles := s.agentProfiles()
+	list := make([]agentProfileInfo, 0, len(profiles))
+	for id, p := range profiles {
+		list = append(list, agentProfileInfo{ID: id, Name: agentDisplayName(p)})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// resolveAgent picks the profile for a request. An explicit name wins; then
+// the first agent_profile_rules entry matching filePath; then
+// agent_default_profile; then "default"; then the only profile, if there is
+// exactly one.
+func (s *Server) resolveAgent(name, filePath string) (resolvedAgent, error) {
+	profiles := s.agentProfiles()
+	if len(profiles) == 0 {
+		return resolvedAgent{}, fmt.Errorf("agent_cmd not configured")
+	}
+	if name == "" && filePath != "" {
+		for _, rule := range s.cfg.AgentProfileRules {
+			if matchPattern(rule.Pattern, filePath) {
+				name = rule.Profile
+				break
+			}
+		}
+	}
+	if name == "" {
+		name = s.cfg.AgentDefault
+	}
+	if name == "" {
+		if _, ok := profiles[defaultAgentProfile]; ok {
+			name = defaultAgentProfile
+		} else if len(profiles) == 1 {
+			for id := range profiles {
+				name = id
+			}
+		} else {
+			return resolvedAgent{}, fmt.Errorf("no agent profile selected; set agent_default_profile")
+		}
+	}
+	p, ok := profiles[name]
+	if !ok {
+		return resolvedAgent{}, fmt.Errorf("unknown agent profile %q", name)
+	}
+
+	agent := resolvedAgent{Profile: name, Cmd: p.Cmd, Name: agentDisplayName(p), Timeout: agentTimeout, Worktree: s.cfg.AgentWorktree}
+	if p.Timeout != "" {
+		d, err := time.ParseDuration(p.Timeout)
+		if err != nil || d <= 0 {
+			return resolvedAgent{}, fmt.Errorf("agent profile %q: invalid timeout %q", name, p.Timeout)
+		}
+		agent.Timeout = d
+	}
+	switch p.Workdir {
+	case "":
+	case "repo":
+		agent.Worktree = false
+	case "worktree":
+		agent.Worktree = true
+	default:
+		return resolvedAgent{}, fmt.Errorf("agent profile %q: workdir must be \"repo\" or \"worktree\", got %q", name, p.Workdir)
+	}
+	switch p.Protocol {
+	case "":
+	case "acp":
+		// An ACP agent is one long-lived process rooted in the repository,
+		// so it can't run in a per-request worktree.
+		if p.Workdir == "worktree" {
+			return resolvedAgent{}, fmt.Errorf("agent profile %q: workdir \"worktree\" cannot be used with protocol \"acp\"", name)
+		}
+		agent.ACP = true
+		agent.Worktree = false
+	default:
+		return resolvedAgent{}, fmt.Errorf("agent profile %q: protocol must be \"acp\" or empty, got %q", name, p.Protocol)
+	}
+	return agent, nil
+}
+
+// agentDisplayName returns the profile's display name, falling back to the
+// command's binary name.
+func agentDisplayName(p AgentProfile) string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return agentName(p.Cmd)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolveAgent_LegacyAgentCmd(t *testing.T) {
+	s, _ := newTestServer(t)
+	s.agentCmd = "claude -p"
+
+	agent, err := s.resolveAgent("", "main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if agent.Profile != defaultAgentProfile || agent.Cmd != "claude -p" || agent.Name != "claude" {
+		t.Errorf("agent = %+v, want default profile running claude", agent)
+	}
+	if agent.Timeout != agentTimeout {
+		t.Errorf("timeout = %s, want %s", agent.Timeout, agentTimeout)
+	}
+}
+
+func TestResolveAgent_Precedence(t *testing.T) {
+	s, _ := newTestServer(t)
+	s.cfg.AgentProfiles = map[string]AgentProfile{
+		"cheap":    {Cmd: "llm -m small", Name: "Quick"},
+		"strong":   {Cmd: "claude -p", Timeout: "30m", Workdir: "worktree"},
+		"security": {Cmd: "sec-agent"},
+	}
+	s.cfg.AgentDefault = "cheap"
+	s.cfg.AgentProfileRules = []AgentProfileRule{
+		{Pattern: "auth/", Profile: "security"},
+		{Pattern: "*.go", Profile: "strong"},
+	}
+
+	tests := []struct {
+		name, profile, path, want string
+	}{
+		{"explicit wins", "cheap", "auth/login.go", "cheap"},
+		{"first rule wins", "", "auth/login.go", "security"},
+		{"second rule", "", "server.go", "strong"},
+		{"falls back to default", "", "README.md", "cheap"},
+		{"no path", "", "", "cheap"},
+	}
+	for _, tc := range tests {
+		agent, err := s.resolveAgent(tc.profile, tc.path)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if agent.Profile != tc.want {
+			t.Errorf("%s: profile = %q, want %q", tc.name, agent.Profile, tc.want)
+		}
+	}
+
+	strong, _ := s.resolveAgent("strong", "")
+	if strong.Timeout != 30*time.Minute || !strong.Worktree || strong.Name != "claude" {
+		t.Errorf("strong = %+v, want 30m worktree agent named claude", strong)
+	}
+	cheap, _ := s.resolveAgent("cheap", "")
+	if cheap.Name != "Quick" || cheap.Worktree {
+		t.Errorf("cheap = %+v, want display name Quick in repo", cheap)
+	}
+
+	if _, err := s.resolveAgent("missing", ""); err == nil {
+		t.Error("expected error for unknown profile")
+	}
+}
+
+func TestResolveAgent_Errors(t *testing.T) {
+	s, _ := newTestServer(t)
+	if _, err := s.resolveAgent("", ""); err == nil {
+		t.Error("expected error with no agents configured")
+	}
+
+	s.cfg.AgentProfiles = map[string]AgentProfile{
+		"a": {Cmd: "a"},
+		"b": {Cmd: "b", Timeout: "soon"},
+		"c": {Cmd: "c", Workdir: "tmp"},
+	}
+	if _, err := s.resolveAgent("", ""); err == nil {
+		t.Error("expected error when several profiles exist and none is the default")
+	}
+	if _, err := s.resolveAgent("b", ""); err == nil {
+		t.Error("expected error for invalid timeout")
+	}
+	if _, err := s.resolveAgent("c", ""); err == nil {
+		t.Error("expected error for invalid workdir")
+	}
+}
+
+func TestResolveAgent_SingleProfileIsDefault(t *testing.T) {
+	s, _ := newTestServer(t)
+	s.cfg.AgentProfiles = map[string]AgentProfile{"only": {Cmd: "echo"}}
+	agent, err := s.resolveAgent("", "x.go")
+	if err != nil || agent.Profile != "only" {
+		t.Errorf("agent = %+v, %v; want the only profile", agent, err)
+	}
+}
+
+func TestHandleAgentRequest_Profile(t *testing.T) {
+	s, session := newTestServer(t)
+	s.cfg.AgentProfiles = map[string]AgentProfile{"cheap": {Cmd: "echo hi", Name: "Quick"}}
+	session.mu.Lock()
+	session.Files[0].Comments = []Comment{
+		{ID: "c1", StartLine: 1, EndLine: 1, Body: "why?", Author: "reviewer", Scope: "line"},
+	}
+	session.mu.Unlock()
+
+	req := httptest.NewRequest("POST", "/api/agent/request", strings.NewReader(`{"comment_id":"c1","profile":"nope"}`))
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("unknown profile: expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("POST", "/api/agent/request", strings.NewReader(`{"comment_id":"c1","profile":"cheap"}`))
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	jobs := s.agentQueue().List()
+	if len(jobs) != 1 || jobs[0].Profile != "cheap" {
+		t.Fatalf("jobs = %+v, want one job for profile cheap", jobs)
+	}
+	waitForJobStatus(t, s.agentQueue(), jobs[0].ID, agentJobDone)
+	replies := session.GetComments("test.md")[0].Replies
+	if len(replies) != 1 || replies[0].Author != "Quick" {
+		t.Errorf("replies = %+v, want one reply authored by Quick", replies)
+	}
+}
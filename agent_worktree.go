@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// agentWorktree is a throwaway git worktree seeded with the main working
+// tree's current state (including uncommitted and untracked files). Agent
+// runs execute inside it so their edits never mix with the reviewer's work;
+// the result is extracted as a patch the reviewer can accept or reject.
+type agentWorktree struct {
+	repoRoot string // top level of the main working tree
+	subdir   string // session root relative to repoRoot ("" when they match)
+	parent   string // temp directory holding the worktree
+	dir      string // worktree checkout
+	baseTree string // tree object of the seeded state, used as the diff base
+}
+
+// newAgentWorktree creates a detached worktree of the repository containing
+// sessionRoot, at the current working tree state. The caller must call Remove
+// when done.
+func newAgentWorktree(ctx context.Context, sessionRoot string) (*agentWorktree, error) {
+	repoRoot, err := gitTopLevel(ctx, sessionRoot)
+	if err != nil {
+		return nil, err
+	}
+	subdir, _ := filepath.Rel(repoRoot, sessionRoot)
+	if subdir == "." || strings.HasPrefix(subdir, "..") {
+		subdir = ""
+	}
+
+	// `git stash create` snapshots tracked changes as a commit without touching
+	// the working tree or the stash list. Empty output means a clean tree.
+	rev, err := execGit(ctx, repoRoot, nil, "stash", "create")
+	if err != nil {
+		return nil, fmt.Errorf("snapshotting working tree: %w", err)
+	}
+	rev = strings.TrimSpace(rev)
+	if rev == "" {
+		rev = "HEAD"
+	}
+
+	parent, err := os.MkdirTemp("", "crit-agent-")
+	if err != nil {
+		return nil, fmt.Errorf("creating worktree directory: %w", err)
+	}
+	w := &agentWorktree{repoRoot: repoRoot, subdir: subdir, parent: parent, dir: filepath.Join(parent, "tree")}
+
+	if _, err := execGit(ctx, repoRoot, nil, "worktree", "add", "--detach", w.dir, rev); err != nil {
+		os.RemoveAll(parent)
+		return nil, fmt.Errorf("creating worktree: %w", err)
+	}
+	if err := w.copyUntracked(ctx); err != nil {
+		w.Remove()
+		return nil, err
+	}
+	if w.baseTree, err = w.writeTree(ctx); err != nil {
+		w.Remove()
+		return nil, err
+	}
+	return w, nil
+}
+
+// WorkDir returns the directory inside the worktree that corresponds to the
+// session root, so the agent starts in the same relative location.
+func (w *agentWorktree) WorkDir() string {
+	return filepath.Join(w.dir, w.subdir)
+}
+
+// copyUntracked copies untracked, non-ignored files from the main tree into
+// the worktree so the agent sees the same files the reviewer does.
+func (w *agentWorktree) copyUntracked(ctx context.Context) error {
+	out, err := execGit(ctx, w.repoRoot, nil, "ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return fmt.Errorf("listing untracked files: %w", err)
+	}
+	for _, rel := range strings.Split(out, "\x00") {
+		if rel == "" {
+			continue
+		}
+		if err := copyFile(filepath.Join(w.repoRoot, rel), filepath.Join(w.dir, rel)); err != nil {
+			return fmt.Errorf("copying %s into worktree: %w", rel, err)
+		}
+	}
+	return nil
+}
+
+// writeTree stages everything in the worktree and returns the resulting tree ID.
+func (w *agentWorktree) writeTree(ctx context.Context) (string, error) {
+	if _, err := execGit(ctx, w.dir, nil, "add", "-A"); err != nil {
+		return "", fmt.Errorf("staging worktree: %w", err)
+	}
+	tree, err := execGit(ctx, w.dir, nil, "write-tree")
+	if err != nil {
+		return "", fmt.Errorf("writing worktree tree: %w", err)
+	}
+	return strings.TrimSpace(tree), nil
+}
+
+// Diff returns a binary-safe patch of everything the agent changed in the
+// worktree, plus the list of touched paths. An empty patch means no edits.
+func (w *agentWorktree) Diff(ctx context.Context) (*ProposedChange, error) {
+	tree, err := w.writeTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if tree == w.baseTree {
+		return nil, nil
+	}
+	patch, err := execGit(ctx, w.dir, nil, "diff", "--binary", "--no-color", "--no-ext-diff", w.baseTree, tree)
+	if err != nil {
+		return nil, fmt.Errorf("diffing worktree: %w", err)
+	}
+	names, err := execGit(ctx, w.dir, nil, "diff", "--name-only", "-z", w.baseTree, tree)
+	if err != nil {
+		return nil, fmt.Errorf("listing changed files: %w", err)
+	}
+	var files []string
+	for _, name := range strings.Split(names, "\x00") {
+		if name != "" {
+			files = append(files, name)
+		}
+	}
+	return &ProposedChange{Patch: patch, Files: files}, nil
+}
+
+// Remove deletes the worktree and its temp directory. Safe to call more than once.
+func (w *agentWorktree) Remove() {
+	execGit(context.Background(), w.repoRoot, nil, "worktree", "remove", "--force", w.dir)
+	os.RemoveAll(w.parent)
+	execGit(context.Background(), w.repoRoot, nil, "worktree", "prune")
+}
+
+// applyProposedPatch applies an agent's patch to the main working tree.
+// The patch is checked first so a conflicting patch leaves the tree untouched.
+func applyProposedPatch(sessionRoot, patch string) error {
+	ctx := context.Background()
+	// Patch paths are relative to the top level; git apply run from a
+	// subdirectory would silently skip files outside it.
+	repoRoot, err := gitTopLevel(ctx, sessionRoot)
+	if err != nil {
+		return err
+	}
+	if _, err := execGit(ctx, repoRoot, strings.NewReader(patch), "apply", "--check", "--binary"); err != nil {
+		return fmt.Errorf("patch no longer applies cleanly: %w", err)
+	}
+	if _, err := execGit(ctx, repoRoot, strings.NewReader(patch), "apply", "--binary"); err != nil {
+		return fmt.Errorf("applying patch: %w", err)
+	}
+	return nil
+}
+
+// gitTopLevel returns the top-level directory of the git repository containing dir.
+func gitTopLevel(ctx context.Context, dir string) (string, error) {
+	out, err := execGit(ctx, dir, nil, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", fmt.Errorf("agent worktrees require a git repository: %w", err)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// execGit runs a git command in dir and returns stdout. stderr is folded into
+// the returned error.
+func execGit(ctx context.Context, dir string, stdin io.Reader, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Stdin = stdin
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return stdout.String(), nil
+}
+
+// copyFile copies src to dst, creating parent directories and preserving mode.
+func copyFile(src, dst string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, dst)
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, info.Mode().Perm())
+}
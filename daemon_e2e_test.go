@@ -99,8 +99,24 @@ func TestDaemonLifecycle(t *testing.T) {
 		t.Errorf("session CWD %q doesn't match repo dir %q", entry.CWD, repoDir)
 	}
 
+	// The loopback port wants the session's access token
+	if entry.Token == "" {
+		t.Fatal("session file has no access token")
+	}
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/api/session", entry.Port))
+	if err != nil {
+		t.Fatalf("session request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("session without token: got %d, want 401", resp.StatusCode)
+	}
+	// Talk to it over the port the way a browser would, with the token
+	port := sessionEntry{Port: entry.Port, Token: entry.Token}
+	client := daemonClient(port, 10*time.Second)
+
 	// Verify health endpoint
-	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/api/health", entry.Port))
+	resp, err = client.Get(daemonURL(port, "/api/health"))
 	if err != nil {
 		t.Fatalf("health check failed: %v", err)
 	}
@@ -113,7 +129,7 @@ func TestDaemonLifecycle(t *testing.T) {
 	readyDeadline := time.Now().Add(10 * time.Second)
 	sessionReady := false
 	for time.Now().Before(readyDeadline) {
-		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/api/session", entry.Port))
+		resp, err := client.Get(daemonURL(port, "/api/session"))
 		if err == nil {
 			resp.Body.Close()
 			if resp.StatusCode == 200 {
@@ -130,11 +146,7 @@ func TestDaemonLifecycle(t *testing.T) {
 	// Start review-cycle in background
 	done := make(chan string, 1)
 	go func() {
-		client := &http.Client{Timeout: 10 * time.Second}
-		r, err := client.Post(
-			fmt.Sprintf("http://localhost:%d/api/review-cycle", entry.Port),
-			"application/json", nil,
-		)
+		r, err := client.Post(daemonURL(port, "/api/review-cycle"), "application/json", nil)
 		if err != nil {
 			done <- fmt.Sprintf("error: %v", err)
 			return
@@ -146,10 +158,7 @@ func TestDaemonLifecycle(t *testing.T) {
 
 	// Simulate user finishing review
 	time.Sleep(200 * time.Millisecond)
-	finishResp, err := http.Post(
-		fmt.Sprintf("http://localhost:%d/api/finish", entry.Port),
-		"application/json", nil,
-	)
+	finishResp, err := client.Post(daemonURL(port, "/api/finish"), "application/json", nil)
 	if err != nil {
 		t.Fatalf("finish failed: %v", err)
 	}
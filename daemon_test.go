@@ -871,6 +871,15 @@ func TestIsDaemonAlive_NoPort(t *testing.T) {
 	}
 }
 
+func TestProcessRunning(t *testing.T) {
+	if !processRunning(os.Getpid()) {
+		t.Error("the test process should be running")
+	}
+	if processRunning(0) || processRunning(-1) {
+		t.Error("non-positive PIDs should not be running")
+	}
+}
+
 func TestRemoveSessionFile_CleansUpAssociatedFiles(t *testing.T) {
 	home := t.TempDir()
 	t.Setenv("HOME", home)
@@ -7,6 +7,10 @@ const NOGIT_PORT = process.env.CRIT_TEST_NOGIT_PORT || '3126';
 const MULTI_PORT = process.env.CRIT_TEST_MULTI_PORT || '3127';
 const debug = !!process.env.E2E_DEBUG;
 
+// crit's port wants an access token. Fix it for the fixture servers, which
+// inherit this environment, and send it with every request.
+process.env.CRIT_ACCESS_TOKEN ||= 'crit-e2e';
+
 export default defineConfig({
   testDir: './tests',
   fullyParallel: false,
@@ -15,6 +19,7 @@ export default defineConfig({
   reporter: [['html', { open: 'never' }], ['list']],
 
   use: {
+    extraHTTPHeaders: { Authorization: `Bearer ${process.env.CRIT_ACCESS_TOKEN}` },
     screenshot: 'only-on-failure',
     trace: debug ? 'retain-on-failure' : 'off',
     video: debug ? 'retain-on-failure' : 'off',
@@ -1,6 +1,10 @@
 #!/usr/bin/env bash
 set -euo pipefail
 
+# crit's loopback ports want an access token; fix it so curl can send it.
+export CRIT_ACCESS_TOKEN="${CRIT_ACCESS_TOKEN:-crit-e2e}"
+AUTH="Authorization: Bearer $CRIT_ACCESS_TOKEN"
+
 SCRIPT_DIR="$(cd "$(dirname "$0")" && pwd)"
 CRIT_SRC="$(cd "$SCRIPT_DIR/.." && pwd)"
 GIT_PORT="${CRIT_TEST_PORT:-3123}"
@@ -46,7 +50,7 @@ trap cleanup EXIT
 
 # Wait for servers to be ready
 for port in "$GIT_PORT" "$FILE_PORT" "$SINGLE_PORT" "$NOGIT_PORT" "$MULTI_PORT"; do
-  while ! curl -sf "http://localhost:$port/api/session" >/dev/null 2>&1; do
+  while ! curl -sf -H "$AUTH" "http://localhost:$port/api/session" >/dev/null 2>&1; do
     sleep 0.1
   done
 done
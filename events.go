@@ -0,0 +1,224 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Session events carry increasing sequence numbers and the most recent ones
+// are kept, so a browser that loses its connection (sleep, wifi, a daemon
+// busy with an agent round) picks up where it left off: EventSource sends the
+// last ID it saw as Last-Event-ID and /api/events replays what came after it.
+// When that is no longer kept, the client gets a resync event and reloads.
+//
+// Subscribers never lose an event they can't get back. Each one is fed from
+// the log by its own goroutine at the pace it reads; only transient events,
+// which a newer one always supersedes (streamed agent output, cursors), are
+// dropped for a subscriber that is behind.
+
+// eventReplayLimit is how many recent events a session keeps for replay.
+const eventReplayLimit = 512
+
+// transientEvents are relayed to whoever is connected but not numbered or
+// kept: replaying them would be stale, and their volume would push the events
+// that matter out of the replay buffer.
+var transientEvents = map[string]bool{
+	"agent-stream": true,
+	"presence":     true,
+	"typing":       true,
+}
+
+// clientActions are the events browsers may send through /api/events/action.
+// They are relayed to the other clients as transient events.
+var clientActions = map[string]bool{
+	"cursor":   true,
+	"presence": true, // answered with the roster instead; see presence.go
+	"typing":   true,
+}
+
+// eventLog numbers a session's events and keeps the latest for replay. The
+// zero value is ready to use.
+type eventLog struct {
+	mu       sync.Mutex
+	stream   string // random per process, so IDs from an earlier daemon don't match
+	seq      uint64
+	recent   []SSEEvent // oldest first
+	watchers map[chan struct{}]struct{}
+}
+
+func (l *eventLog) initLocked() {
+	if l.stream == "" {
+		var b [4]byte
+		_, _ = rand.Read(b[:])
+		l.stream = hex.EncodeToString(b[:])
+		l.watchers = make(map[chan struct{}]struct{})
+	}
+}
+
+// append numbers e, keeps it, and wakes the subscribers.
+func (l *eventLog) append(e SSEEvent) SSEEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.initLocked()
+	l.seq++
+	e.Seq = l.seq
+	if len(l.recent) == eventReplayLimit {
+		copy(l.recent, l.recent[1:])
+		l.recent = l.recent[:eventReplayLimit-1]
+	}
+	l.recent = append(l.recent, e)
+	l.wakeLocked()
+	return e
+}
+
+func (l *eventLog) wakeLocked() {
+	for w := range l.watchers {
+		select {
+		case w <- struct{}{}:
+		default: // already pending
+		}
+	}
+}
+
+func (l *eventLog) wake() {
+	l.mu.Lock()
+	l.wakeLocked()
+	l.mu.Unlock()
+}
+
+// since returns the events after seq. ok is false when some of them are no
+// longer kept, or seq is from the future.
+func (l *eventLog) since(seq uint64) (events []SSEEvent, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if seq > l.seq {
+		return nil, false
+	}
+	missing := l.seq - seq
+	if missing > uint64(len(l.recent)) {
+		return nil, false
+	}
+	return append([]SSEEvent(nil), l.recent[uint64(len(l.recent))-missing:]...), true
+}
+
+// last returns the sequence number of the latest event.
+func (l *eventLog) last() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.seq
+}
+
+// watch returns a channel that is signalled after each append, and the
+// sequence number at the time it was registered.
+func (l *eventLog) watch() (chan struct{}, uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.initLocked()
+	w := make(chan struct{}, 1)
+	l.watchers[w] = struct{}{}
+	return w, l.seq
+}
+
+func (l *eventLog) unwatch(w chan struct{}) {
+	l.mu.Lock()
+	delete(l.watchers, w)
+	l.mu.Unlock()
+}
+
+// id is the SSE id of the event numbered seq.
+func (l *eventLog) id(seq uint64) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.initLocked()
+	return l.stream + "-" + strconv.FormatUint(seq, 10)
+}
+
+// parseID returns the sequence number of an SSE id from this log. ok is
+// false for an id from another process.
+func (l *eventLog) parseID(id string) (seq uint64, ok bool) {
+	stream, n, found := strings.Cut(id, "-")
+	if !found {
+		return 0, false
+	}
+	l.mu.Lock()
+	l.initLocked()
+	mine := stream == l.stream
+	l.mu.Unlock()
+	if !mine {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(n, 10, 64)
+	return seq, err == nil
+}
+
+// pumpEvents feeds ch the events after cursor until ch is unsubscribed, then
+// closes it.
+func (s *Session) pumpEvents(ch chan SSEEvent, wake chan struct{}, cursor uint64) {
+	defer close(ch)
+	defer s.events.unwatch(wake)
+	for {
+		events, ok := s.events.since(cursor)
+		if !ok {
+			// Too far behind: say so instead of skipping ahead silently.
+			cursor = s.events.last()
+			events = []SSEEvent{{Type: "resync"}}
+		}
+		if len(events) == 0 {
+			<-wake
+			if !s.subscribed(ch) {
+				return
+			}
+			continue
+		}
+		for _, e := range events {
+			if !s.deliver(ch, wake, e) {
+				return
+			}
+			if e.Seq != 0 {
+				cursor = e.Seq
+			}
+		}
+	}
+}
+
+// deliver sends e on ch, waiting as long as it takes unless ch is
+// unsubscribed meanwhile.
+func (s *Session) deliver(ch chan SSEEvent, wake chan struct{}, e SSEEvent) bool {
+	for {
+		select {
+		case ch <- e:
+			return true
+		case <-wake:
+			if !s.subscribed(ch) {
+				return false
+			}
+		}
+	}
+}
+
+func (s *Session) subscribed(ch chan SSEEvent) bool {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+	_, ok := s.subscribers[ch]
+	return ok
+}
+
+// broadcast sends a transient event to every subscriber that has room for it.
+func (s *Session) broadcast(event SSEEvent) {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+	for ch := range s.subscribers {
+		select {
+		case ch <- event:
+		default: // superseded by the next one anyway
+		}
+	}
+}
+
+// newStreamClientID identifies one /api/events connection.
+func newStreamClientID() string {
+	return randomID("sc_")
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventLog_Since(t *testing.T) {
+	var l eventLog
+	for range eventReplayLimit + 10 {
+		l.append(SSEEvent{Type: "comments-changed"})
+	}
+	last := l.last()
+	if got, ok := l.since(last - 3); !ok || len(got) != 3 || got[2].Seq != last {
+		t.Errorf("since(last-3) = %d events, ok=%v", len(got), ok)
+	}
+	if got, ok := l.since(last); !ok || len(got) != 0 {
+		t.Errorf("since(last) = %d events, ok=%v", len(got), ok)
+	}
+	if _, ok := l.since(5); ok {
+		t.Error("events evicted from the buffer should not be reported as replayable")
+	}
+	if _, ok := l.since(last + 1); ok {
+		t.Error("a sequence number from the future should need a resync")
+	}
+}
+
+func TestEventLog_IDs(t *testing.T) {
+	var a, b eventLog
+	if seq, ok := a.parseID(a.id(42)); !ok || seq != 42 {
+		t.Errorf("round trip = %d, %v", seq, ok)
+	}
+	if _, ok := a.parseID(b.id(42)); ok {
+		t.Error("an ID from another log should not parse")
+	}
+}
+
+// recv reads one event from ch or fails after a second.
+func recv(t *testing.T, ch chan SSEEvent) SSEEvent {
+	t.Helper()
+	select {
+	case e := <-ch:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for an event")
+		return SSEEvent{}
+	}
+}
+
+func TestSession_SubscriberKeepsEveryEvent(t *testing.T) {
+	s := &Session{}
+	ch := s.Subscribe()
+	defer s.Unsubscribe(ch)
+
+	// Far more than the channel buffers, with nobody reading.
+	for range 100 {
+		s.notify(SSEEvent{Type: "comments-changed"})
+	}
+	for want := uint64(1); want <= 100; want++ {
+		if e := recv(t, ch); e.Seq != want {
+			t.Fatalf("got seq %d, want %d", e.Seq, want)
+		}
+	}
+}
+
+func TestSession_SubscribeSince(t *testing.T) {
+	s := &Session{}
+	for range 5 {
+		s.notify(SSEEvent{Type: "edit-detected"})
+	}
+	ch, ok := s.SubscribeSince(3)
+	if !ok {
+		t.Fatal("recent events should be replayable")
+	}
+	defer s.Unsubscribe(ch)
+	if e := recv(t, ch); e.Seq != 4 {
+		t.Errorf("first replayed seq = %d, want 4", e.Seq)
+	}
+	if e := recv(t, ch); e.Seq != 5 {
+		t.Errorf("second replayed seq = %d, want 5", e.Seq)
+	}
+
+	for range eventReplayLimit {
+		s.notify(SSEEvent{Type: "edit-detected"})
+	}
+	if _, ok := s.SubscribeSince(3); ok {
+		t.Error("expected no replay once the events are evicted")
+	}
+}
+
+func TestSession_TransientEventsAreNotLogged(t *testing.T) {
+	s := &Session{}
+	ch := s.Subscribe()
+	defer s.Unsubscribe(ch)
+
+	s.notify(SSEEvent{Type: "agent-stream", Content: "tok"})
+	if e := recv(t, ch); e.Type != "agent-stream" || e.Seq != 0 {
+		t.Errorf("got %+v", e)
+	}
+	if s.events.last() != 0 {
+		t.Error("transient events should not take a sequence number")
+	}
+}
+
+func TestSession_UnsubscribeClosesChannel(t *testing.T) {
+	s := &Session{}
+	ch := s.Subscribe()
+	s.notify(SSEEvent{Type: "comments-changed"})
+	s.Unsubscribe(ch)
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+		case <-deadline:
+			t.Fatal("channel not closed after Unsubscribe")
+		}
+	}
+}
+
+// sseStream reads an /api/events response one event at a time.
+type sseStream struct {
+	t    *testing.T
+	scan *bufio.Scanner
+}
+
+type sseMessage struct {
+	id, event string
+	data      SSEEvent
+}
+
+func openEvents(t *testing.T, url, lastID string) *sseStream {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, _ := http.NewRequestWithContext(ctx, "GET", url+"/api/events", nil)
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return &sseStream{t: t, scan: bufio.NewScanner(resp.Body)}
+}
+
+// next returns the next event other than presence updates, which arrive
+// whenever another client comes or goes.
+func (s *sseStream) next() sseMessage {
+	s.t.Helper()
+	for {
+		if m := s.read(); m.event != "presence" {
+			return m
+		}
+	}
+}
+
+// nextOf returns the next event of the given type.
+func (s *sseStream) nextOf(event string) sseMessage {
+	s.t.Helper()
+	for {
+		if m := s.read(); m.event == event {
+			return m
+		}
+	}
+}
+
+func (s *sseStream) read() sseMessage {
+	s.t.Helper()
+	var m sseMessage
+	for s.scan.Scan() {
+		line := s.scan.Text()
+		switch {
+		case line == "":
+			return m
+		case strings.HasPrefix(line, "id: "):
+			m.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			m.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &m.data)
+		}
+	}
+	s.t.Fatal("event stream ended")
+	return m
+}
+
+func TestHandleEvents_ReplaysAfterLastEventID(t *testing.T) {
+	s, sess := newTestServer(t)
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close) // after the streams are cancelled
+
+	sess.notify(SSEEvent{Type: "edit-detected", Content: "1"})
+	sess.notify(SSEEvent{Type: "comments-changed"})
+
+	stream := openEvents(t, ts.URL, sess.events.id(1))
+	if m := stream.next(); m.event != "hello" || m.data.Client == "" {
+		t.Fatalf("first event = %+v, want hello", m)
+	}
+	if m := stream.next(); m.event != "comments-changed" || m.id != sess.events.id(2) {
+		t.Errorf("replayed %+v, want comments-changed with id 2", m)
+	}
+}
+
+func TestHandleEvents_ResyncAfterRestart(t *testing.T) {
+	s, _ := newTestServer(t)
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close) // after the streams are cancelled
+
+	var earlier eventLog
+	stream := openEvents(t, ts.URL, earlier.id(7))
+	stream.next() // hello
+	if m := stream.next(); m.event != "resync" {
+		t.Errorf("got %q, want resync for an ID from an earlier daemon", m.event)
+	}
+}
+
+func TestHandleEventAction(t *testing.T) {
+	s, _ := newTestServer(t)
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close) // after the streams are cancelled
+
+	sender := openEvents(t, ts.URL, "")
+	me := sender.next().data.Client
+	other := openEvents(t, ts.URL, "")
+	other.next() // hello
+
+	post := func(body string) int {
+		resp, err := http.Post(ts.URL+"/api/events/action", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := post(`{"client":"sc_nobody","type":"cursor"}`); code != http.StatusConflict {
+		t.Errorf("unknown client = %d, want 409", code)
+	}
+	if code := post(`{"client":"` + me + `","type":"delete-everything"}`); code != http.StatusBadRequest {
+		t.Errorf("unknown action = %d, want 400", code)
+	}
+	if code := post(`{"client":"` + me + `","type":"cursor","filename":"test.md","content":"{\"line\":2}"}`); code != http.StatusNoContent {
+		t.Fatalf("cursor = %d, want 204", code)
+	}
+	if m := other.next(); m.event != "cursor" || m.data.Client != me || m.data.Filename != "test.md" || m.id != "" {
+		t.Errorf("relayed %+v", m)
+	}
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Forge abstracts the code-review host a branch's pull or merge request lives
+// on, so `crit pull` and `crit push` are not tied to GitHub. It sits alongside
+// VCS: VCS answers questions about the local checkout, Forge talks to the
+// hosted review.
+type Forge interface {
+	// Name returns the forge identifier ("github", "gitlab", "gitea", "gerrit").
+	Name() string
+
+	// ChangeLabel formats a change number the way the forge does ("PR #12", "MR !12").
+	ChangeLabel(number int) string
+
+	// DetectChange returns the open change for the current branch.
+	// If flag is non-zero, it's used directly.
+	DetectChange(flag int) (int, error)
+
+	// Pull merges the change's review threads into cj.
+	Pull(number int, cj *CritJSON) (pullResult, error)
+
+	// Push posts new comments and replies from cj to the change and records
+	// their remote IDs in the review file at critPath.
+	Push(number int, critPath string, cj CritJSON, opts pushOptions) error
+}
+
+// pullResult counts what a Forge.Pull changed in the review file.
+type pullResult struct {
+	Added    int // new comments and replies
+	Resolved int // existing comments whose resolved state was taken from the forge
+}
+
+// forgeSyncer is implemented by forges that can reconcile resolved state in
+// both directions (`crit sync`).
+type forgeSyncer interface {
+	Sync(number int, critPath string, prefer string, dryRun bool) error
+}
+
+// pushOptions are the forge-neutral `crit push` settings.
+type pushOptions struct {
+	DryRun  bool
+	Message string // top-level review body
+	Event   string // "COMMENT", "APPROVE" or "REQUEST_CHANGES" (see parsePushEvent)
+	Draft   bool   // save comments as drafts instead of publishing them (Gerrit only)
+}
+
+// detectForge picks the forge for the current repository. cfg.Forge wins when
+// set; otherwise the forge is inferred from the origin remote's host, falling
+// back to GitHub.
+func detectForge(cfg Config) (Forge, error) {
+	host, project := parseRemoteURL(originRemoteURL())
+	name := cfg.Forge
+	if name == "" {
+		name = forgeForHost(host, cfg.GitLabURL, cfg.GiteaURL, cfg.GerritURL)
+	}
+	switch name {
+	case "github":
+		api, err := newGitHubAPI("", cfg)
+		if err != nil {
+			return nil, err
+		}
+		return githubForge{api: api}, nil
+	case "gitlab":
+		if host == "" || project == "" {
+			return nil, fmt.Errorf("cannot determine the GitLab project: no usable origin remote")
+		}
+		return newGitLabForge(host, project, cfg.GitLabURL)
+	case "gerrit":
+		if project == "" {
+			return nil, fmt.Errorf("cannot determine the Gerrit project: no usable origin remote")
+		}
+		return gerritForgeFor(host, project, cfg)
+	case "gitea", "forgejo":
+		if project == "" {
+			return nil, fmt.Errorf("cannot determine the Gitea repository: no usable origin remote")
+		}
+		return giteaForgeFor(host, project, cfg)
+	default:
+		return nil, fmt.Errorf("unknown forge %q (valid: github, gitlab, gitea, forgejo, gerrit)", name)
+	}
+}
+
+// forgeForHost guesses the forge from a remote host name. gitlabURL,
+// giteaURL and gerritURL are the configured GitLab, Gitea/Forgejo and Gerrit
+// instances, if any.
+func forgeForHost(host, gitlabURL, giteaURL, gerritURL string) string {
+	if sameHost(gitlabURL, host) {
+		return "gitlab"
+	}
+	if sameHost(giteaURL, host) {
+		return "gitea"
+	}
+	if sameHost(gerritURL, host) {
+		return "gerrit"
+	}
+	lower := strings.ToLower(host)
+	switch {
+	case strings.Contains(lower, "gitlab"):
+		return "gitlab"
+	case strings.Contains(lower, "gerrit"), strings.HasSuffix(lower, ".googlesource.com"):
+		return "gerrit"
+	case strings.Contains(lower, "gitea"), strings.Contains(lower, "forgejo"), lower == "codeberg.org":
+		return "gitea"
+	}
+	return "github"
+}
+
+// sameHost reports whether the configured instance URL is on host.
+func sameHost(instance, host string) bool {
+	u, err := url.Parse(instance)
+	return instance != "" && err == nil && host != "" && strings.EqualFold(u.Hostname(), host)
+}
+
+// originRemoteURL returns the fetch URL of the origin remote, or "".
+func originRemoteURL() string { return originRemoteURLIn("") }
+
+// originRemoteURLIn is originRemoteURL for the checkout in dir.
+func originRemoteURLIn(dir string) string {
+	cmd := exec.Command("git", "remote", "get-url", "origin")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// parseRemoteURL extracts the host and project path from a git remote URL.
+// Handles https://host/group/project.git, ssh://git@host:22/group/project.git
+// and scp-style git@host:group/project.git. Returns empty strings if the URL
+// can't be parsed.
+func parseRemoteURL(remote string) (host, project string) {
+	if remote == "" {
+		return "", ""
+	}
+	if !strings.Contains(remote, "://") {
+		// scp-style: [user@]host:path
+		hostPart, path, ok := strings.Cut(remote, ":")
+		if !ok {
+			return "", ""
+		}
+		if _, h, ok := strings.Cut(hostPart, "@"); ok {
+			hostPart = h
+		}
+		return hostPart, strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	}
+	u, err := url.Parse(remote)
+	if err != nil {
+		return "", ""
+	}
+	return u.Hostname(), strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+}
+
+// githubForge syncs with GitHub pull requests (see githubAPI).
+type githubForge struct {
+	api githubAPI
+}
+
+func (githubForge) Name() string { return "github" }
+
+func (githubForge) ChangeLabel(number int) string { return fmt.Sprintf("PR #%d", number) }
+
+func (g githubForge) DetectChange(flag int) (int, error) { return detectPR(g.api, flag) }
+
+// Pull merges the PR's review comments, review bodies and conversation
+// comments, and takes thread resolutions made on GitHub since the last sync.
+func (g githubForge) Pull(number int, cj *CritJSON) (pullResult, error) {
+	ghComments, err := fetchPRComments(g.api, number)
+	if err != nil {
+		return pullResult{}, err
+	}
+	threads, threadsErr := fetchReviewThreads(g.api, number)
+	markGHThreadState(ghComments, threads)
+	res := pullResult{Added: mergeGHCommentsWithNames(cj, ghComments, ghCommentNames(g.api, ghComments))}
+	if added, err := pullGHReviews(g.api, number, cj); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not fetch PR reviews: %v\n", err)
+	} else {
+		res.Added += added
+	}
+	if threadsErr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not sync resolved state: %v\n", threadsErr)
+		return res, nil
+	}
+	res.Resolved = reconcileResolvedState(g.api, threads, cj, "", true, false).Pulled
+	return res, nil
+}
+
+// Push posts a review with the new comments (see planGHPush for comments
+// outside the PR diff), then resolves or unresolves threads for comments
+// resolved or reopened in crit since the last sync.
+func (g githubForge) Push(prNumber int, critPath string, cj CritJSON, opts pushOptions) error {
+	diff, err := fetchPRDiff(g.api, prNumber)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v; posting every comment inline\n", err)
+	}
+	headSHA, err := fetchPRHeadSHA(g.api, prNumber)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	moved := alignGHSuggestions(&cj, prHeadLines(g.api, headSHA), workingTreeLines(currentRepoRoot()))
+	plan := planGHPush(cj, diff)
+
+	var allReplies []ghReplyForPush
+	for _, cf := range cj.Files {
+		allReplies = append(allReplies, collectNewRepliesForPush(cf)...)
+	}
+
+	if opts.DryRun {
+		displayPushDryRun(plan, allReplies, prNumber, opts.Event, opts.Message)
+		if threads, err := fetchReviewThreads(g.api, prNumber); err == nil {
+			displayResolvedPlan(planResolvedSync(cj, threads, ""), false, true)
+		}
+		return nil
+	}
+
+	if plan.empty() && opts.Event == "COMMENT" {
+		fmt.Println("No unresolved comments to push.")
+	} else {
+		displayEvent := strings.ToLower(strings.ReplaceAll(opts.Event, "_", "-"))
+		fmt.Printf("Pushing %d comments to PR #%d (%s)...\n", len(plan.Comments), prNumber, displayEvent)
+		commentIDs, reviewID, err := createGHReview(g.api, prNumber, plan.Comments, plan.reviewBody(opts.Message), opts.Event)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Posted %d review comments to PR #%d (%s)\n", len(plan.Comments), prNumber, displayEvent)
+		if n := len(plan.BodyEntries); n > 0 {
+			fmt.Printf("Included %d comments without a diff line in the review body\n", n)
+		}
+
+		fileIDs := postGHFileComments(g.api, prNumber, headSHA, plan.FileComments)
+		replyIDs := postPushReplies(g.api, prNumber, allReplies)
+
+		for posted, original := range moved {
+			if id, ok := commentIDs[posted]; ok {
+				delete(commentIDs, posted)
+				commentIDs[original] = id
+			}
+		}
+
+		if err := updateCritJSONWithGitHubIDs(critPath, commentIDs, replyIDs); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to update review file with GitHub IDs: %v\n", err)
+		}
+		if err := recordGHPushExtras(critPath, fileIDs, reviewID, plan.BodyEntries); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to update review file with GitHub IDs: %v\n", err)
+		}
+	}
+
+	latest, err := loadCritJSON(critPath)
+	if err != nil {
+		return err
+	}
+	res, err := syncResolvedState(g.api, prNumber, &latest, "", false, true)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not sync resolved state: %v\n", err)
+		return nil
+	}
+	if res.Pushed > 0 {
+		fmt.Printf("Updated resolved state of %d review thread(s)\n", res.Pushed)
+	}
+	return saveCritJSON(critPath, latest)
+}
+
+// Sync pulls new comments, then reconciles resolved state in both directions.
+func (g githubForge) Sync(prNumber int, critPath string, prefer string, dryRun bool) error {
+	cj, err := loadCritJSON(critPath)
+	if err != nil {
+		return err
+	}
+	ghComments, err := fetchPRComments(g.api, prNumber)
+	if err != nil {
+		return err
+	}
+	threads, err := fetchReviewThreads(g.api, prNumber)
+	if err != nil {
+		return err
+	}
+	markGHThreadState(ghComments, threads)
+	added := mergeGHCommentsWithNames(&cj, ghComments, ghCommentNames(g.api, ghComments))
+	if n, err := pullGHReviews(g.api, prNumber, &cj); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not fetch PR reviews: %v\n", err)
+	} else {
+		added += n
+	}
+	changes := planResolvedSync(cj, threads, prefer)
+
+	if dryRun {
+		fmt.Printf("Would pull %d new comments from PR #%d\n", added, prNumber)
+		displayResolvedPlan(changes, true, true)
+		return nil
+	}
+
+	setRemote := func(threadID string, resolved bool) error { return setThreadResolved(g.api, threadID, resolved) }
+	res := applyResolvedSync(&cj, changes, true, true, setRemote)
+	if err := saveCritJSON(critPath, cj); err != nil {
+		return err
+	}
+	fmt.Printf("Synced PR #%d: %d new comments, %d resolved from GitHub, %d resolved on GitHub\n", prNumber, added, res.Pulled, res.Pushed)
+	reportResolvedConflicts(res.Conflicts)
+	if len(res.Conflicts) > 0 {
+		return fmt.Errorf("%d conflict(s) left unresolved", len(res.Conflicts))
+	}
+	return nil
+}
+
+// updateCritJSONRemoteIDs writes forge IDs back to the review file after a
+// push. commentIDs and replyIDs map crit comment/reply IDs to remote IDs.
+// The file is re-read so edits made while pushing are kept.
+func updateCritJSONRemoteIDs(critPath string, commentIDs, replyIDs map[string]string) error {
+	cj, err := loadCritJSON(critPath)
+	if err != nil {
+		return err
+	}
+	for path, cf := range cj.Files {
+		for i, c := range cf.Comments {
+			if id, ok := commentIDs[c.ID]; ok && c.RemoteID == "" {
+				cf.Comments[i].RemoteID = id
+			}
+			for j, r := range c.Replies {
+				if id, ok := replyIDs[r.ID]; ok && r.RemoteID == "" {
+					cf.Comments[i].Replies[j].RemoteID = id
+				}
+			}
+		}
+		cj.Files[path] = cf
+	}
+	return saveCritJSON(critPath, cj)
+}
+
+// restClient is a small JSON-over-HTTP client shared by the REST forges.
+type restClient struct {
+	name    string // forge name used in errors, e.g. "gitlab"
+	baseURL string // API root, e.g. "https://gitlab.example.com/api/v4"
+	header  string // auth header name, e.g. "PRIVATE-TOKEN"
+	token   string // auth header value
+	client  *http.Client
+}
+
+func newRESTClient(name, baseURL, header, token string) restClient {
+	return restClient{name: name, baseURL: strings.TrimSuffix(baseURL, "/"), header: header, token: token, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// do sends an API request and decodes a JSON response into out (if non-nil).
+// Returns the response headers for pagination.
+func (c *restClient) do(method, path string, query url.Values, body, out any) (http.Header, error) {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, u, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(c.header, c.token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", c.name, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 32<<20))
+	if err != nil {
+		return nil, fmt.Errorf("%s: reading response: %w", c.name, err)
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s: %s %s: %s: %s", c.name, method, path, resp.Status, truncateStr(strings.TrimSpace(string(data)), 200))
+	}
+	// Gerrit prefixes JSON responses with a line that stops them being
+	// evaluated as script.
+	data = bytes.TrimPrefix(data, []byte(")]}'\n"))
+	if out != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			return nil, fmt.Errorf("%s: parsing response: %w", c.name, err)
+		}
+	}
+	return resp.Header, nil
+}
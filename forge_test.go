@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+func TestParseRemoteURL(t *testing.T) {
+	tests := []struct {
+		remote, host, project string
+	}{
+		{"https://github.com/owner/repo.git", "github.com", "owner/repo"},
+		{"https://gitlab.example.com/group/sub/proj", "gitlab.example.com", "group/sub/proj"},
+		{"git@gitlab.com:group/proj.git", "gitlab.com", "group/proj"},
+		{"ssh://git@gitlab.internal:2222/team/app.git", "gitlab.internal", "team/app"},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		host, project := parseRemoteURL(tt.remote)
+		if host != tt.host || project != tt.project {
+			t.Errorf("parseRemoteURL(%q) = %q, %q; want %q, %q", tt.remote, host, project, tt.host, tt.project)
+		}
+	}
+}
+
+func TestForgeForHost(t *testing.T) {
+	for host, want := range map[string]string{
+		"github.com":          "github",
+		"gitlab.com":          "gitlab",
+		"gitlab.example.com":  "gitlab",
+		"codeberg.org":        "gitea",
+		"git.example.com":     "github",
+		"git.internal":        "gitea",  // matches gitea_url
+		"review.internal":     "gerrit", // matches gerrit_url
+		"code.internal":       "gitlab", // matches gitlab_url
+		"gerrit.example.com":  "gerrit",
+		"go.googlesource.com": "gerrit",
+		"":                    "github",
+	} {
+		if got := forgeForHost(host, "https://code.internal", "https://git.internal/", "https://review.internal"); got != want {
+			t.Errorf("forgeForHost(%q) = %q, want %q", host, got, want)
+		}
+	}
+}
+
+func TestDetectForge_Unknown(t *testing.T) {
+	if _, err := detectForge(Config{Forge: "bitbucket"}); err == nil {
+		t.Error("expected an error for an unknown forge")
+	}
+}
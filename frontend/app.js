@@ -1,6 +1,15 @@
 (function() {
   'use strict';
 
+  // The hub daemon serves each review under /s/<key>/. Root-relative API and
+  // file URLs are resolved against that prefix so the same app works both
+  // behind the hub and on a daemon's own port.
+  const basePath = (location.pathname.match(/^\/s\/[^/]+/) || [''])[0];
+  function fetch(url, opts) {
+    if (typeof url === 'string' && url.charAt(0) === '/') url = basePath + url;
+    return window.fetch(url, opts);
+  }
+
   // ===== Comment Markdown Renderer =====
   const commentMd = window.markdownit({
     html: false,
@@ -205,7 +214,11 @@
   let prData = null;     // PR metadata from /api/config (set once on load)
   let agentEnabled = false;
   let agentName = 'agent';
+  let agentProfiles = [];       // [{ id, name }] from agent_profiles config
   const pendingAgentRequests = new Set();
+  const agentProfileByComment = new Map(); // commentId → profile chosen via "Send now", reused for live-thread replies
+  const agentJobsByComment = new Map(); // commentId → active agent job ID (from agent-job SSE events)
+  const agentStreamText = new Map(); // commentId → reply text streamed so far by an ACP agent
 
   // Track active reply form state so it survives DOM re-renders (commentId → { text })
   const activeReplyForms = new Map();
@@ -564,6 +577,10 @@
     configAuthor = configRes.author || '';
     agentEnabled = configRes.agent_cmd_enabled || false;
     agentName = configRes.agent_name || 'agent';
+    agentProfiles = configRes.agent_profiles || [];
+    if (agentEnabled) {
+      document.getElementById('preReviewBtn').style.display = '';
+    }
 
     if (shareURL && session.mode !== 'git') {
       const shareBtn = document.getElementById('shareBtn');
@@ -1179,7 +1196,7 @@
   function rewriteImageSrcs(html) {
     return html.replace(/(<img\s[^>]*src=")([^"]+)(")/gi, function(match, pre, src, post) {
       if (/^https?:\/\/|^data:|^\//.test(src)) return match;
-      return pre + '/files/' + src + post;
+      return pre + basePath + '/files/' + src + post;
     });
   }
 
@@ -1416,6 +1433,7 @@
   function updateTreeActive(filePath) {
     if (filePath === activeTreePath) return;
     activeTreePath = filePath;
+    schedulePresence();
     const allFiles = document.querySelectorAll('.tree-file');
     for (let i = 0; i < allFiles.length; i++) {
       allFiles[i].classList.toggle('active', allFiles[i].dataset.treePath === filePath);
@@ -4447,6 +4465,26 @@
     actions.appendChild(submitBtn);
 
     if (agentEnabled && !opts.editingId) {
+      // With several profiles, let the reviewer pick which agent gets the comment.
+      // Empty value leaves the choice to the server's per-path rules.
+      let profileSelect = null;
+      if (agentProfiles.length > 1) {
+        profileSelect = document.createElement('select');
+        profileSelect.className = 'agent-profile-select';
+        profileSelect.title = 'Agent profile';
+        const auto = document.createElement('option');
+        auto.value = '';
+        auto.textContent = 'Auto';
+        profileSelect.appendChild(auto);
+        agentProfiles.forEach(function(p) {
+          const opt = document.createElement('option');
+          opt.value = p.id;
+          opt.textContent = p.name === p.id ? p.id : p.name + ' (' + p.id + ')';
+          profileSelect.appendChild(opt);
+        });
+        actions.appendChild(profileSelect);
+      }
+
       const sendBtn = document.createElement('button');
       sendBtn.className = 'btn btn-sm btn-agent';
       sendBtn.innerHTML = '<svg viewBox="0 0 24 24" width="12" height="12" fill="currentColor" style="vertical-align: -1px"><polygon points="13 2 3 14 12 14 11 22 21 10 12 10"/></svg> Send now';
@@ -4455,15 +4493,17 @@
         sendBtn.disabled = true;
         submitBtn.disabled = true;
         const fp = formObj.filePath;
+        const profile = profileSelect ? profileSelect.value : '';
         const comment = await submitComment(textarea.value, formObj);
         if (comment) {
           pendingAgentRequests.add(comment.id);
+          if (profile) agentProfileByComment.set(comment.id, profile);
           renderFileByPath(fp);
           try {
             const res = await fetch('/api/agent/request', {
               method: 'POST',
               headers: { 'Content-Type': 'application/json' },
-              body: JSON.stringify({ comment_id: comment.id, file_path: fp }),
+              body: JSON.stringify({ comment_id: comment.id, file_path: fp, profile: profile }),
             });
             if (!res.ok) throw new Error('Server returned ' + res.status);
             showMiniToast('Sent to agent');
@@ -4565,14 +4605,15 @@
 
     try {
       if (formObj.editingId) {
+        const idx = file.comments.findIndex(c => c.id === formObj.editingId);
         const res = await fetch('/api/comment/' + formObj.editingId + '?path=' + enc(filePath), {
           method: 'PUT',
-          headers: { 'Content-Type': 'application/json' },
-          body: JSON.stringify({ body: body.trim() })
+          headers: commentEditHeaders(),
+          body: JSON.stringify({ body: body.trim(), version: idx >= 0 ? file.comments[idx].version || 0 : undefined })
         });
+        if (await takeNewerComment(res, file.comments)) return null;
         if (!res.ok) throw new Error('Server returned ' + res.status);
         const updated = await res.json();
-        const idx = file.comments.findIndex(c => c.id === formObj.editingId);
         if (idx >= 0) file.comments[idx] = updated;
         userActedThisRound = true;
       } else {
@@ -4881,6 +4922,23 @@
       headerLeft.appendChild(driftedBadge);
     }
 
+    if (comment.ai_suggestion) {
+      const aiBadge = document.createElement('span');
+      aiBadge.className = 'ai-suggestion-badge';
+      aiBadge.textContent = 'AI suggestion';
+      aiBadge.title = 'Added by an agent pre-review. Accept to keep it as a review comment, or dismiss it.';
+      headerLeft.appendChild(aiBadge);
+    }
+
+    if (comment.github_review_state === 'CHANGES_REQUESTED' || comment.github_review_state === 'APPROVED') {
+      const stateBadge = document.createElement('span');
+      const changes = comment.github_review_state === 'CHANGES_REQUESTED';
+      stateBadge.className = 'github-review-state-badge ' + (changes ? 'changes-requested' : 'approved');
+      stateBadge.textContent = changes ? 'Changes requested' : 'Approved';
+      stateBadge.title = 'GitHub review state, pulled with crit pull';
+      headerLeft.appendChild(stateBadge);
+    }
+
     const actions = document.createElement('div');
     actions.className = 'comment-actions';
 
@@ -4971,6 +5029,15 @@
       card.appendChild(renderReplyList(comment, filePath || '', opts.repliesExtraClass));
     }
 
+    if (comment.ai_suggestion) {
+      card.appendChild(renderAISuggestionActions(comment, filePath));
+    }
+
+    // Proposed change from an agent run in an isolated worktree
+    if (comment.proposal && filePath) {
+      card.appendChild(renderProposal(comment, filePath));
+    }
+
     // Pending agent indicator
     if (pendingAgentRequests.has(comment.id)) {
       const pending = document.createElement('div');
@@ -4978,7 +5045,24 @@
       pending.dataset.commentId = comment.id;
       pending.innerHTML =
         '<span class="agent-pending-author">@' + agentName + '</span>' +
+        '<span class="agent-pending-stream"></span>' +
         '<span class="agent-pending-cursor">_</span>';
+      pending.querySelector('.agent-pending-stream').textContent = agentStreamText.get(comment.id) || '';
+      const jobId = agentJobsByComment.get(comment.id);
+      if (jobId) {
+        const cancelJob = document.createElement('button');
+        cancelJob.className = 'agent-pending-cancel';
+        cancelJob.textContent = 'Cancel';
+        cancelJob.title = 'Stop this agent run';
+        cancelJob.addEventListener('click', function() {
+          cancelJob.disabled = true;
+          fetch('/api/agent/request/' + enc(jobId), { method: 'DELETE' }).catch(function(err) {
+            console.error('Error cancelling agent run:', err);
+            cancelJob.disabled = false;
+          });
+        });
+        pending.appendChild(cancelJob);
+      }
       card.appendChild(pending);
     }
 
@@ -4998,6 +5082,109 @@
     return { wrapper: wrapper, card: card, actions: actions };
   }
 
+  // Accept/Dismiss buttons for a comment added by an agent pre-review.
+  // An empty filePath means a review-level comment.
+  function renderAISuggestionActions(comment, filePath) {
+    const el = document.createElement('div');
+    el.className = 'ai-suggestion-actions';
+    [['accept', 'Accept', 'Keep this as a review comment'],
+     ['dismiss', 'Dismiss', 'Delete this suggestion']].forEach(function(a) {
+      const btn = document.createElement('button');
+      btn.className = 'ai-suggestion-' + a[0];
+      btn.textContent = a[1];
+      btn.title = a[2];
+      btn.addEventListener('click', async function(e) {
+        e.stopPropagation();
+        el.querySelectorAll('button').forEach(function(b) { b.disabled = true; });
+        const url = filePath
+          ? '/api/comment/' + enc(comment.id) + '/ai-suggestion/' + a[0] + '?path=' + enc(filePath)
+          : '/api/review-comment/' + enc(comment.id) + '/ai-suggestion/' + a[0];
+        try {
+          const res = await fetch(url, { method: 'POST' });
+          if (!res.ok) throw new Error('Server returned ' + res.status);
+          userActedThisRound = true;
+        } catch (err) {
+          console.error('Error handling AI suggestion:', err);
+          showMiniToast('Failed to ' + a[0] + ' suggestion');
+          el.querySelectorAll('button').forEach(function(b) { b.disabled = false; });
+          return;
+        }
+        if (filePath) {
+          refreshFileComments(filePath);
+        } else {
+          await refreshReviewComments();
+          renderCommentsPanel();
+        }
+      });
+      el.appendChild(btn);
+    });
+    return el;
+  }
+
+  // Renders an agent's proposed change: touched files, the patch (collapsed),
+  // and Accept/Reject buttons that apply or discard it.
+  function renderProposal(comment, filePath) {
+    const proposal = comment.proposal;
+    const el = document.createElement('div');
+    el.className = 'agent-proposal';
+
+    const header = document.createElement('div');
+    header.className = 'agent-proposal-header';
+    const files = proposal.files || [];
+    header.textContent = '@' + (proposal.author || agentName) + ' proposed changes to ' +
+      files.length + (files.length === 1 ? ' file' : ' files');
+    el.appendChild(header);
+
+    if (files.length > 0) {
+      const list = document.createElement('ul');
+      list.className = 'agent-proposal-files';
+      files.forEach(function(f) {
+        const li = document.createElement('li');
+        li.textContent = f;
+        list.appendChild(li);
+      });
+      el.appendChild(list);
+    }
+
+    const details = document.createElement('details');
+    const summary = document.createElement('summary');
+    summary.textContent = 'Show patch';
+    const pre = document.createElement('pre');
+    pre.className = 'agent-proposal-patch';
+    pre.textContent = proposal.patch;
+    details.appendChild(summary);
+    details.appendChild(pre);
+    el.appendChild(details);
+
+    const actions = document.createElement('div');
+    actions.className = 'agent-proposal-actions';
+    [['accept', 'Accept', 'Apply these changes to your working tree'],
+     ['reject', 'Reject', 'Discard these changes']].forEach(function(a) {
+      const btn = document.createElement('button');
+      btn.className = 'agent-proposal-' + a[0];
+      btn.textContent = a[1];
+      btn.title = a[2];
+      btn.addEventListener('click', async function() {
+        actions.querySelectorAll('button').forEach(function(b) { b.disabled = true; });
+        try {
+          const res = await fetch('/api/comment/' + enc(comment.id) + '/proposal/' + a[0] + '?path=' + enc(filePath), { method: 'POST' });
+          if (!res.ok) throw new Error((await res.text()).trim() || 'Server returned ' + res.status);
+          userActedThisRound = true;
+          showMiniToast(a[0] === 'accept' ? 'Changes applied' : 'Changes discarded');
+        } catch (err) {
+          console.error('Error handling proposal:', err);
+          showMiniToast('Failed to ' + a[0] + ' changes: ' + err.message);
+          actions.querySelectorAll('button').forEach(function(b) { b.disabled = false; });
+          return;
+        }
+        refreshFileComments(filePath);
+      });
+      actions.appendChild(btn);
+    });
+    el.appendChild(actions);
+    return el;
+  }
+
   function createCommentElement(comment, filePath) {
     if (findFormForEdit(comment.id)) {
       return createInlineEditor(comment, filePath);
@@ -5297,6 +5484,28 @@
     updateCommentCount();
   }
 
+  // Headers for comment edits. Naming our event stream client keeps the
+  // resulting comments-changed event from being echoed back to this tab.
+  function commentEditHeaders() {
+    const headers = { 'Content-Type': 'application/json' };
+    if (eventClientId) headers['X-Crit-Client'] = eventClientId;
+    return headers;
+  }
+
+  // Edits carry the comment version they started from. A 409 means another
+  // reviewer changed it meanwhile: adopt their version so that saving again
+  // is a deliberate overwrite. Returns true if that happened.
+  async function takeNewerComment(res, comments) {
+    if (res.status !== 409) return false;
+    try {
+      const current = await res.json();
+      const idx = comments.findIndex(function(c) { return c.id === current.id; });
+      if (idx >= 0) comments[idx] = current;
+    } catch {}
+    showMiniToast('Someone else changed this comment \u2014 check their version, then save again');
+    return true;
+  }
+
   // Shared resolve/unresolve handler for both file-level and review-level comments.
   // `type` is 'file' or 'review'; `action` is 'resolve' or 'unresolve'.
   async function toggleResolveStatus(commentId, type, action, filePath) {
@@ -5304,13 +5513,18 @@
     const url = type === 'file'
       ? '/api/comment/' + commentId + '/resolve?path=' + enc(filePath)
       : '/api/review-comment/' + commentId + '/resolve';
+    const file = type === 'file' ? getFileByPath(filePath) : null;
+    const comments = file ? file.comments : reviewComments;
+    const comment = comments.find(function(c) { return c.id === commentId; });
     try {
       const res = await fetch(url, {
         method: 'PUT',
-        headers: { 'Content-Type': 'application/json' },
-        body: JSON.stringify({ resolved: resolved }),
+        headers: commentEditHeaders(),
+        body: JSON.stringify({ resolved: resolved, version: comment ? comment.version || 0 : undefined }),
       });
-      if (!res.ok) throw new Error('Server returned ' + res.status);
+      // On a conflict, fall through to the refresh so their change shows.
+      const stale = await takeNewerComment(res, comments);
+      if (!stale && !res.ok) throw new Error('Server returned ' + res.status);
     } catch (err) {
       console.error('Error ' + action + ':', err);
       showMiniToast('Failed to ' + action + ' comment');
@@ -5373,14 +5587,15 @@
   async function updateReviewComment(id, body) {
     if (!body.trim()) return;
     try {
+      const idx = reviewComments.findIndex(function(c) { return c.id === id; });
       const res = await fetch('/api/review-comment/' + id, {
         method: 'PUT',
-        headers: { 'Content-Type': 'application/json' },
-        body: JSON.stringify({ body: body.trim() })
+        headers: commentEditHeaders(),
+        body: JSON.stringify({ body: body.trim(), version: idx >= 0 ? reviewComments[idx].version || 0 : undefined })
       });
+      if (await takeNewerComment(res, reviewComments)) return;
       if (!res.ok) throw new Error('Server returned ' + res.status);
       const updated = await res.json();
-      const idx = reviewComments.findIndex(function(c) { return c.id === id; });
       if (idx >= 0) reviewComments[idx] = updated;
       userActedThisRound = true;
     } catch (err) {
@@ -5646,7 +5861,7 @@
           fetch('/api/agent/request', {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
-            body: JSON.stringify({ comment_id: commentId, file_path: filePath }),
+            body: JSON.stringify({ comment_id: commentId, file_path: filePath, profile: agentProfileByComment.get(commentId) || '' }),
           }).catch(function(err) {
             console.error('Error sending reply to agent:', err);
             pendingAgentRequests.delete(commentId);
@@ -6388,8 +6603,107 @@
 
   // ===== SSE Client =====
 
+  let eventClientId = ''; // this tab's ID on the event stream, for /api/events/action
+
+  // ===== Presence =====
+  // Other reviewers on the session: the daemon sends the roster with hello and
+  // on every change. This tab announces its name and the file in view, and
+  // that it is typing a comment, through /api/events/action.
+  const TYPING_TTL = 6000; // ms a typing notice lasts without a refresh
+  const TYPING_SEND_INTERVAL = 2000;
+  let presenceRoster = [];
+  let presenceTimer = null;
+  const typingByClient = new Map(); // client ID -> { file, line, until }
+  let typingSentAt = 0;
+
+  function sendEventAction(type, filename, content) {
+    if (!eventClientId) return;
+    fetch('/api/events/action', {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ client: eventClientId, type: type, filename: filename || '', content: content || '' })
+    }).catch(function() {});
+  }
+
+  function announcePresence() {
+    sendEventAction('presence', activeTreePath || '', configAuthor || '');
+  }
+
+  // Scrolling through files changes the active one quickly; settle first.
+  function schedulePresence() {
+    clearTimeout(presenceTimer);
+    presenceTimer = setTimeout(announcePresence, 500);
+  }
+
+  function presenceName(client) {
+    const p = presenceRoster.find(function(r) { return r.client === client; });
+    return (p && p.name) || 'Someone';
+  }
+
+  function renderPresence() {
+    const el = document.getElementById('presenceList');
+    if (!el) return;
+    el.innerHTML = '';
+    const others = presenceRoster.filter(function(p) { return p.client !== eventClientId; });
+    el.style.display = others.length ? '' : 'none';
+    for (const p of others) {
+      const name = p.name || 'Anonymous reviewer';
+      const chip = document.createElement('span');
+      chip.className = 'presence-chip';
+      chip.textContent = name.split(/\s+/).map(function(w) { return w.charAt(0); }).join('').slice(0, 2);
+      chip.title = name + (p.file ? ' \u2014 viewing ' + p.file : '');
+      el.appendChild(chip);
+    }
+  }
+
+  function renderTyping() {
+    const now = Date.now();
+    const notes = [];
+    for (const [client, t] of typingByClient) {
+      if (t.until < now || !presenceRoster.some(function(p) { return p.client === client; })) {
+        typingByClient.delete(client);
+        continue;
+      }
+      notes.push(presenceName(client) + ' is typing a comment on ' + (t.file ? t.file + ' ' : '') + 'line ' + t.line);
+    }
+    let el = document.querySelector('.typing-indicator');
+    if (notes.length === 0) {
+      if (el) el.remove();
+      return;
+    }
+    if (!el) {
+      el = document.createElement('div');
+      el.className = 'typing-indicator';
+      el.setAttribute('role', 'status');
+      document.body.appendChild(el);
+    }
+    el.textContent = notes.join(' \u00b7 ') + '\u2026';
+  }
+  setInterval(function() { if (typingByClient.size) renderTyping(); }, 1000);
+
+  function typingForm(target) {
+    const formEl = target && target.tagName === 'TEXTAREA' ? target.closest('.comment-form') : null;
+    if (!formEl) return null;
+    const formObj = activeForms.find(function(f) { return f.formKey === formEl.dataset.formKey; });
+    return formObj && formObj.startLine > 0 ? formObj : null;
+  }
+
+  document.addEventListener('input', function(e) {
+    const formObj = typingForm(e.target);
+    if (!formObj || Date.now() - typingSentAt < TYPING_SEND_INTERVAL) return;
+    typingSentAt = Date.now();
+    sendEventAction('typing', formObj.filePath, JSON.stringify({ line: formObj.endLine || formObj.startLine }));
+  });
+
+  document.addEventListener('focusout', function(e) {
+    const formObj = typingForm(e.target);
+    if (!formObj || !typingSentAt) return;
+    typingSentAt = 0;
+    sendEventAction('typing', formObj.filePath, JSON.stringify({ line: 0 }));
+  });
+
   function connectSSE() {
-    const source = new EventSource('/api/events');
+    const source = new EventSource(basePath + '/api/events');
 
     source.addEventListener('file-changed', async function() {
       try {
@@ -6533,6 +6847,39 @@
       }
     });
 
+    source.addEventListener('agent-job', function(e) {
+      try {
+        const job = JSON.parse(JSON.parse(e.data).content);
+        if (job.kind === 'pre-review') {
+          handlePreReviewJob(job);
+          return;
+        }
+        if (job.status === 'queued' || job.status === 'running') {
+          agentJobsByComment.set(job.comment_id, job.id);
+        } else {
+          agentJobsByComment.delete(job.comment_id);
+          agentStreamText.delete(job.comment_id);
+        }
+        if (job.status === 'failed' || job.status === 'cancelled') {
+          pendingAgentRequests.delete(job.comment_id);
+          showMiniToast(job.status === 'failed' ? 'Agent run failed' + (job.error ? ': ' + job.error : '') : 'Agent run cancelled');
+        }
+        renderFileByPath(job.file_path);
+      } catch {}
+    });
+
+    // ACP agents stream their reply; show it in the pending indicator until
+    // the finished reply arrives with comments-changed.
+    source.addEventListener('agent-stream', function(e) {
+      try {
+        const chunk = JSON.parse(JSON.parse(e.data).content);
+        const text = (agentStreamText.get(chunk.comment_id) || '') + chunk.text;
+        agentStreamText.set(chunk.comment_id, text);
+        const el = document.querySelector('.agent-pending-reply[data-comment-id="' + CSS.escape(chunk.comment_id) + '"] .agent-pending-stream');
+        if (el) el.textContent = text;
+      } catch {}
+    });
+
     source.addEventListener('base-changed', function() {
       reloadForScope();
       fetchCommits();
@@ -6543,6 +6890,46 @@
       showDisconnected();
     });
 
+    // Sent first on every connection; identifies this tab for /api/events/action
+    // and carries the presence roster.
+    source.addEventListener('hello', function(e) {
+      try {
+        const data = JSON.parse(e.data);
+        eventClientId = data.client;
+        presenceRoster = JSON.parse(data.content || '[]');
+        renderPresence();
+        announcePresence();
+      } catch {}
+    });
+
+    source.addEventListener('presence', function(e) {
+      try {
+        presenceRoster = JSON.parse(JSON.parse(e.data).content);
+        renderPresence();
+        renderTyping();
+      } catch {}
+    });
+
+    source.addEventListener('typing', function(e) {
+      try {
+        const data = JSON.parse(e.data);
+        const line = JSON.parse(data.content).line;
+        if (line > 0) {
+          typingByClient.set(data.client, { file: data.filename, line: line, until: Date.now() + TYPING_TTL });
+        } else {
+          typingByClient.delete(data.client);
+        }
+        renderTyping();
+      } catch {}
+    });
+
+    // The events missed while disconnected are gone (or the daemon restarted):
+    // start over from the server's state. Drafts survive via autosave.
+    source.addEventListener('resync', function() {
+      source.close();
+      location.reload();
+    });
+
     let sseErrorCount = 0;
     source.addEventListener('message', function() { sseErrorCount = 0; });
     source.addEventListener('file-changed', function() { sseErrorCount = 0; });
@@ -6732,6 +7119,34 @@
     }
   }
 
+  // ===== Agent pre-review =====
+  // The agent reviews the diff first; findings arrive as comments marked ai_suggestion.
+  document.getElementById('preReviewBtn').addEventListener('click', async function() {
+    const btn = this;
+    btn.disabled = true;
+    try {
+      const res = await fetch('/api/agent/pre-review', { method: 'POST' });
+      if (!res.ok) throw new Error((await res.text()).trim() || 'Server returned ' + res.status);
+      showMiniToast('Agent pre-review started');
+    } catch (err) {
+      console.error('Error starting pre-review:', err);
+      showMiniToast('Failed to start pre-review: ' + err.message);
+      btn.disabled = false;
+    }
+  });
+
+  function handlePreReviewJob(job) {
+    const btn = document.getElementById('preReviewBtn');
+    const active = job.status === 'queued' || job.status === 'running';
+    btn.disabled = active;
+    btn.classList.toggle('pulsing', job.status === 'running');
+    if (job.status === 'done') {
+      showMiniToast('Agent pre-review finished');
+    } else if (job.status === 'failed') {
+      showMiniToast('Agent pre-review failed' + (job.error ? ': ' + job.error : ''));
+    }
+  }
+
   document.getElementById('shareBtn').addEventListener('click', async function() {
     // If already shared, toggle modal
     if (hostedURL) {
@@ -7639,6 +8054,13 @@
       html += '<span class="config-card-title">Agent Command</span>';
       html += '</div>';
       html += '<div class="config-card-cmd-value"><code>' + escapeHtml(cfg.agent_cmd || cfg.agent_name || '') + '</code></div>';
+      const profiles = cfg.agent_profiles || [];
+      if (profiles.length > 1) {
+        html += '<div class="config-card-body">Profiles: ' + profiles.map(function(p) {
+          const label = escapeHtml(p.id) + (p.id === cfg.agent_profile ? ' (default)' : '');
+          return '<code>' + label + '</code>';
+        }).join(' ') + '</div>';
+      }
       html += '</div>';
     } else {
       html += '<div class="config-card config-card--orange config-card--unconfigured"><div class="config-card-header">';
@@ -0,0 +1,564 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gerritForge syncs with Gerrit changes over the REST API. The change is
+// found from the Change-Id trailer of HEAD's commit message, and comments are
+// read from and written to its current patch set. A Gerrit thread is a chain
+// of comments linked by in_reply_to; its resolved state is the "unresolved"
+// flag of its latest comment.
+type gerritForge struct {
+	restClient
+	project string
+}
+
+// newGerritForge returns a Gerrit client. baseURL is the instance root
+// (gerrit_url); user and password are an HTTP password from the Gerrit
+// settings page, falling back to GERRIT_USER and GERRIT_PASSWORD.
+func newGerritForge(baseURL, user, password, project string) (*gerritForge, error) {
+	if user == "" {
+		user = os.Getenv("GERRIT_USER")
+	}
+	if password == "" {
+		password = os.Getenv("GERRIT_PASSWORD")
+	}
+	if user == "" || password == "" {
+		return nil, errors.New("no Gerrit credentials. Set gerrit_user and gerrit_password in ~/.crit.config.json or GERRIT_USER and GERRIT_PASSWORD")
+	}
+	auth := base64.StdEncoding.EncodeToString([]byte(user + ":" + password))
+	return &gerritForge{
+		// Authenticated endpoints live under /a/.
+		restClient: newRESTClient("gerrit", strings.TrimSuffix(baseURL, "/")+"/a", "Authorization", "Basic "+auth),
+		project:    strings.TrimPrefix(project, "a/"),
+	}, nil
+}
+
+// gerritForgeFor returns the Gerrit client for a project whose origin is on
+// host. Credentials only go to the instance in gerrit_url: the origin host is
+// up to the repository, and so is a project config that sets "forge".
+func gerritForgeFor(host, project string, cfg Config) (*gerritForge, error) {
+	if cfg.GerritURL == "" {
+		return nil, fmt.Errorf("set gerrit_url in ~/.crit.config.json (probably %q): crit only sends Gerrit credentials to that instance", gerritBaseURL(host))
+	}
+	return newGerritForge(cfg.GerritURL, cfg.GerritUser, cfg.GerritPassword, project)
+}
+
+// gerritBaseURL guesses the web root for a remote host. googlesource.com
+// serves code from <name>.googlesource.com and reviews from
+// <name>-review.googlesource.com.
+func gerritBaseURL(host string) string {
+	if name, ok := strings.CutSuffix(host, ".googlesource.com"); ok && !strings.HasSuffix(name, "-review") {
+		host = name + "-review.googlesource.com"
+	}
+	return "https://" + host
+}
+
+// gerritComment is a published or draft comment. Path is filled in from the
+// key of the comments map. Line is 0 for file comments; Range, when set,
+// spans several lines.
+type gerritComment struct {
+	ID         string       `json:"id,omitempty"`
+	Path       string       `json:"path,omitempty"`
+	PatchSet   int          `json:"patch_set,omitempty"`
+	Side       string       `json:"side,omitempty"` // "PARENT" for the base of the patch set
+	Line       int          `json:"line,omitempty"`
+	Range      *gerritRange `json:"range,omitempty"`
+	InReplyTo  string       `json:"in_reply_to,omitempty"`
+	Message    string       `json:"message"`
+	Updated    string       `json:"updated,omitempty"`
+	Unresolved *bool        `json:"unresolved,omitempty"`
+	Author     struct {
+		Name     string `json:"name"`
+		Username string `json:"username"`
+	} `json:"author,omitzero"`
+}
+
+type gerritRange struct {
+	StartLine      int `json:"start_line"`
+	StartCharacter int `json:"start_character"`
+	EndLine        int `json:"end_line"`
+	EndCharacter   int `json:"end_character"`
+}
+
+// lines returns the comment's line range (0, 0 for a file comment).
+func (c gerritComment) lines() (start, end int) {
+	if c.Range != nil && c.Range.StartLine > 0 {
+		return c.Range.StartLine, c.Range.EndLine
+	}
+	return c.Line, c.Line
+}
+
+// gerritTime converts Gerrit's "2006-01-02 15:04:05.000000000" UTC
+// timestamps to RFC 3339.
+func gerritTime(s string) string {
+	t, err := time.Parse("2006-01-02 15:04:05.000000000", s)
+	if err != nil {
+		return s
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func (g *gerritForge) Name() string { return "gerrit" }
+
+func (g *gerritForge) ChangeLabel(number int) string { return fmt.Sprintf("change %d", number) }
+
+func (g *gerritForge) changePath(number int, suffix string) string {
+	return "/changes/" + url.PathEscape(g.project) + "~" + strconv.Itoa(number) + suffix
+}
+
+// headChangeID returns the Change-Id trailer of HEAD's commit message, or "".
+func headChangeID() string {
+	out, err := exec.Command("git", "log", "-1", "--format=%B", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return parseChangeID(string(out))
+}
+
+// parseChangeID returns the last Change-Id trailer in a commit message.
+func parseChangeID(msg string) string {
+	id := ""
+	for _, line := range strings.Split(msg, "\n") {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(line), "Change-Id:"); ok {
+			if v = strings.TrimSpace(v); len(v) == 41 && v[0] == 'I' {
+				id = v
+			}
+		}
+	}
+	return id
+}
+
+// DetectChange returns the change whose Change-Id is in HEAD's commit message.
+func (g *gerritForge) DetectChange(flag int) (int, error) {
+	if flag > 0 {
+		return flag, nil
+	}
+	id := headChangeID()
+	if id == "" {
+		return 0, errors.New("HEAD has no Change-Id trailer (try: crit pull <change-number>)")
+	}
+	var changes []struct {
+		Number int `json:"_number"`
+	}
+	q := url.Values{"q": {"change:" + id + " project:" + g.project}}
+	if _, err := g.do(http.MethodGet, "/changes/", q, nil, &changes); err != nil {
+		return 0, err
+	}
+	if len(changes) == 0 {
+		return 0, fmt.Errorf("no change found for Change-Id %s (try: crit pull <change-number>)", id)
+	}
+	return changes[0].Number, nil
+}
+
+// currentPatchSet returns the number of a change's current patch set.
+func (g *gerritForge) currentPatchSet(number int) (int, error) {
+	var change struct {
+		CurrentRevision string `json:"current_revision"`
+		Revisions       map[string]struct {
+			Number int `json:"_number"`
+		} `json:"revisions"`
+	}
+	if _, err := g.do(http.MethodGet, g.changePath(number, ""), url.Values{"o": {"CURRENT_REVISION"}}, nil, &change); err != nil {
+		return 0, err
+	}
+	rev, ok := change.Revisions[change.CurrentRevision]
+	if !ok {
+		return 0, fmt.Errorf("gerrit: change %d has no current patch set", number)
+	}
+	return rev.Number, nil
+}
+
+// fetchComments returns every published comment on a change.
+func (g *gerritForge) fetchComments(number int) ([]gerritComment, error) {
+	var byPath map[string][]gerritComment
+	if _, err := g.do(http.MethodGet, g.changePath(number, "/comments"), nil, nil, &byPath); err != nil {
+		return nil, err
+	}
+	var all []gerritComment
+	for _, path := range slices.Sorted(maps.Keys(byPath)) {
+		for _, c := range byPath[path] {
+			c.Path = path
+			all = append(all, c)
+		}
+	}
+	return all, nil
+}
+
+func (g *gerritForge) Pull(number int, cj *CritJSON) (pullResult, error) {
+	patchSet, err := g.currentPatchSet(number)
+	if err != nil {
+		return pullResult{}, err
+	}
+	comments, err := g.fetchComments(number)
+	if err != nil {
+		return pullResult{}, err
+	}
+	return mergeGerritComments(cj, comments, patchSet), nil
+}
+
+// gerritThreads groups comments into threads, oldest first, keyed on their
+// root (the comment reached by following in_reply_to). Only threads started
+// on patchSet, on the patch set's side of the diff and on a real file (not
+// /COMMIT_MSG or /PATCHSET_LEVEL) are kept.
+func gerritThreads(comments []gerritComment, patchSet int) [][]gerritComment {
+	byID := make(map[string]gerritComment, len(comments))
+	for _, c := range comments {
+		byID[c.ID] = c
+	}
+	rootOf := func(c gerritComment) gerritComment {
+		for seen := 0; c.InReplyTo != "" && seen < len(comments); seen++ {
+			parent, ok := byID[c.InReplyTo]
+			if !ok {
+				break
+			}
+			c = parent
+		}
+		return c
+	}
+	groups := make(map[string][]gerritComment)
+	var order []string
+	for _, c := range comments {
+		root := rootOf(c)
+		if root.PatchSet != patchSet || root.Side == "PARENT" || strings.HasPrefix(root.Path, "/") {
+			continue
+		}
+		if _, ok := groups[root.ID]; !ok {
+			order = append(order, root.ID)
+		}
+		groups[root.ID] = append(groups[root.ID], c)
+	}
+	threads := make([][]gerritComment, 0, len(order))
+	for _, id := range order {
+		t := groups[id]
+		sort.SliceStable(t, func(i, j int) bool {
+			if (t[i].ID == id) != (t[j].ID == id) {
+				return t[i].ID == id // root first
+			}
+			return t[i].Updated < t[j].Updated
+		})
+		threads = append(threads, t)
+	}
+	return threads
+}
+
+// gerritThreadResolved reports a thread's resolved state: that of its latest
+// comment that sets one.
+func gerritThreadResolved(thread []gerritComment) bool {
+	for i := len(thread) - 1; i >= 0; i-- {
+		if thread[i].Unresolved != nil {
+			return !*thread[i].Unresolved
+		}
+	}
+	return false
+}
+
+// mergeGerritComments merges Gerrit threads into cj the way mergeGHComments
+// merges GitHub's: roots are matched by RemoteID, then by author, lines and
+// body, later comments become replies, and changes to the resolved state on
+// Gerrit are taken over (see pullRemoteResolved).
+func mergeGerritComments(cj *CritJSON, comments []gerritComment, patchSet int) pullResult {
+	now := time.Now().UTC().Format(time.RFC3339)
+	cj.UpdatedAt = now
+
+	var res pullResult
+	for _, thread := range gerritThreads(comments, patchSet) {
+		root := thread[0]
+		resolved := gerritThreadResolved(thread)
+
+		if filePath, ci, found := findCommentByRemoteID(cj, root.ID); found {
+			cf := cj.Files[filePath]
+			if pullRemoteResolved(&cf.Comments[ci], resolved) {
+				res.Resolved++
+			}
+			res.Added += appendNewGerritReplies(&cf.Comments[ci], thread[1:])
+			cj.Files[filePath] = cf
+			continue
+		}
+
+		cf, ok := cj.Files[root.Path]
+		if !ok {
+			cf = CritJSONFile{Status: "modified", Comments: []Comment{}}
+		}
+		start, end := root.lines()
+		author := displayName(root.Author.Username, root.Author.Name)
+		if isDuplicateGHComment(cf.Comments, 0, author, start, end, root.Message) {
+			continue
+		}
+		comment := Comment{
+			ID: randomCommentID(), StartLine: start, EndLine: end,
+			Body: root.Message, Author: author, CreatedAt: gerritTime(root.Updated),
+			UpdatedAt: now, Resolved: resolved, RemoteID: root.ID, RemoteResolved: boolPtr(resolved),
+		}
+		if end == 0 {
+			comment.Scope = "file"
+		}
+		res.Added += 1 + appendNewGerritReplies(&comment, thread[1:])
+		cf.Comments = append(cf.Comments, comment)
+		cj.Files[root.Path] = cf
+	}
+	return res
+}
+
+// appendNewGerritReplies adds comments not yet present (by RemoteID) as replies.
+func appendNewGerritReplies(c *Comment, comments []gerritComment) int {
+	added := 0
+	for _, gc := range comments {
+		if isDuplicateRemoteReply(c.Replies, gc.ID) {
+			continue
+		}
+		c.Replies = append(c.Replies, Reply{
+			ID:        randomReplyID(),
+			Body:      gc.Message,
+			Author:    displayName(gc.Author.Username, gc.Author.Name),
+			CreatedAt: gerritTime(gc.Updated),
+			RemoteID:  gc.ID,
+		})
+		added++
+	}
+	return added
+}
+
+// gerritVote maps a parsePushEvent value to a Code-Review vote. Approving
+// votes +1 rather than +2, which usually means "submittable" and is often
+// restricted to maintainers.
+func gerritVote(event string) (int, bool) {
+	switch event {
+	case "APPROVE":
+		return 1, true
+	case "REQUEST_CHANGES":
+		return -1, true
+	}
+	return 0, false
+}
+
+// gerritPending is a comment about to be pushed, with the crit ID it came from.
+type gerritPending struct {
+	critID string // crit comment or reply ID
+	reply  bool
+	input  gerritComment
+}
+
+// gerritDoneMessage is the reply that resolves a thread resolved in crit,
+// as Gerrit's own "Done" button does.
+const gerritDoneMessage = "Done"
+
+// planGerritPush collects what a push sends: new unresolved comments (with
+// a range when they span several lines), new replies, and a "Done" reply for
+// threads resolved in crit but not on Gerrit. Replies go on the line the
+// thread has on Gerrit, which may differ from where the comment sits locally,
+// and carry the comment's resolved state. lineLen returns the length of a
+// line in the working tree, for range ends.
+func planGerritPush(cj CritJSON, remote []gerritComment, patchSet int, lineLen func(path string, line int) int) []gerritPending {
+	threads := make(map[string][]gerritComment)
+	for _, t := range gerritThreads(remote, patchSet) {
+		threads[t[0].ID] = t
+	}
+	var out []gerritPending
+	for _, path := range slices.Sorted(maps.Keys(cj.Files)) {
+		for _, c := range cj.Files[path].Comments {
+			if c.RemoteID == "" {
+				if c.Resolved || (c.EndLine == 0 && c.Scope != "file") {
+					continue
+				}
+				in := gerritComment{Path: path, Message: c.Body, Unresolved: boolPtr(true)}
+				if c.EndLine > 0 {
+					in.Line = c.EndLine
+					if c.StartLine > 0 && c.StartLine < c.EndLine {
+						in.Range = &gerritRange{StartLine: c.StartLine, EndLine: c.EndLine, EndCharacter: lineLen(path, c.EndLine)}
+					}
+				}
+				out = append(out, gerritPending{critID: c.ID, input: in})
+				continue
+			}
+			thread, ok := threads[c.RemoteID]
+			if !ok {
+				continue
+			}
+			root := thread[0]
+			replyTo := thread[len(thread)-1].ID
+			var newReplies []Reply
+			for _, r := range c.Replies {
+				if r.RemoteID == "" {
+					newReplies = append(newReplies, r)
+				}
+			}
+			if len(newReplies) == 0 && c.Resolved && !gerritThreadResolved(thread) {
+				newReplies = append(newReplies, Reply{Body: gerritDoneMessage})
+			}
+			for i, r := range newReplies {
+				in := gerritComment{Path: root.Path, Line: root.Line, Range: root.Range, InReplyTo: replyTo, Message: r.Body}
+				if i == len(newReplies)-1 {
+					in.Unresolved = boolPtr(!c.Resolved)
+				}
+				out = append(out, gerritPending{critID: r.ID, reply: true, input: in})
+			}
+		}
+	}
+	return out
+}
+
+// Push posts new comments and replies to the change's current patch set,
+// as one published review carrying the message and vote, or with
+// opts.Draft as draft comments for the user to publish from Gerrit.
+func (g *gerritForge) Push(number int, critPath string, cj CritJSON, opts pushOptions) error {
+	vote, voting := gerritVote(opts.Event)
+	if opts.Draft && (voting || opts.Message != "") {
+		return errors.New("--draft can't carry a vote or message; publish them from Gerrit")
+	}
+	patchSet, err := g.currentPatchSet(number)
+	if err != nil {
+		return err
+	}
+	remote, err := g.fetchComments(number)
+	if err != nil {
+		return err
+	}
+	lines := workingTreeLines(currentRepoRoot())
+	pending := planGerritPush(cj, remote, patchSet, func(path string, line int) int {
+		if l, err := lines(path); err == nil && line >= 1 && line <= len(l) {
+			return len([]rune(l[line-1]))
+		}
+		return 0
+	})
+	if len(pending) == 0 && !voting && opts.Message == "" {
+		fmt.Println("No unresolved comments to push.")
+		return nil
+	}
+
+	label := g.ChangeLabel(number)
+	if opts.DryRun {
+		kind := "comments"
+		if opts.Draft {
+			kind = "draft comments"
+		}
+		fmt.Printf("Would post %d %s to %s (patch set %d):\n\n", len(pending), kind, label, patchSet)
+		if opts.Message != "" {
+			fmt.Printf("  Review message: %s\n\n", opts.Message)
+		}
+		for _, p := range pending {
+			if p.reply {
+				fmt.Printf("  Would reply to Gerrit comment %s: %.60s\n", p.input.InReplyTo, p.input.Message)
+				continue
+			}
+			start, end := p.input.lines()
+			switch {
+			case end == 0:
+				fmt.Printf("  %s (file-level)\n", p.input.Path)
+			case start != end:
+				fmt.Printf("  %s:%d-%d\n", p.input.Path, start, end)
+			default:
+				fmt.Printf("  %s:%d\n", p.input.Path, end)
+			}
+			fmt.Printf("    %s\n\n", p.input.Message)
+		}
+		if voting {
+			fmt.Printf("  Would vote Code-Review%+d\n", vote)
+		}
+		return nil
+	}
+
+	commentIDs := make(map[string]string)
+	replyIDs := make(map[string]string)
+	record := func(p gerritPending, id string) {
+		if p.critID == "" || id == "" {
+			return // a "Done" reply has no crit counterpart
+		}
+		if p.reply {
+			replyIDs[p.critID] = id
+		} else {
+			commentIDs[p.critID] = id
+		}
+	}
+
+	if opts.Draft {
+		posted := 0
+		for _, p := range pending {
+			var created gerritComment
+			if _, err := g.do(http.MethodPut, g.changePath(number, "/revisions/current/drafts"), nil, p.input, &created); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to save draft on %s: %v\n", p.input.Path, err)
+				continue
+			}
+			record(p, created.ID)
+			posted++
+		}
+		fmt.Printf("Saved %d draft comments on %s; publish them from Gerrit\n", posted, label)
+	} else {
+		review := map[string]any{"comments": gerritReviewComments(pending)}
+		if opts.Message != "" {
+			review["message"] = opts.Message
+		}
+		if voting {
+			review["labels"] = map[string]int{"Code-Review": vote}
+		}
+		fmt.Printf("Pushing %d comments to %s...\n", len(pending), label)
+		if _, err := g.do(http.MethodPost, g.changePath(number, "/revisions/current/review"), nil, review, nil); err != nil {
+			return fmt.Errorf("posting review: %w", err)
+		}
+		fmt.Printf("Posted %d comments to %s\n", len(pending), label)
+		if voting {
+			fmt.Printf("Voted Code-Review%+d\n", vote)
+		}
+		// The review response has no comment IDs: find the new comments by
+		// their place and text.
+		if after, err := g.fetchComments(number); err == nil {
+			for p, id := range matchGerritComments(pending, remote, after) {
+				record(pending[p], id)
+			}
+		}
+	}
+
+	if len(commentIDs) > 0 || len(replyIDs) > 0 {
+		if err := updateCritJSONRemoteIDs(critPath, commentIDs, replyIDs); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to update review file with Gerrit IDs: %v\n", err)
+		}
+	}
+	return nil
+}
+
+// gerritReviewComments groups pending comments by path for ReviewInput.
+func gerritReviewComments(pending []gerritPending) map[string][]gerritComment {
+	byPath := make(map[string][]gerritComment)
+	for _, p := range pending {
+		in := p.input
+		in.Path = "" // carried by the map key
+		byPath[p.input.Path] = append(byPath[p.input.Path], in)
+	}
+	return byPath
+}
+
+// matchGerritComments pairs pushed comments with the comments that appeared
+// on the change (in after but not before), by path, line, parent and text.
+// It returns pending index -> Gerrit comment ID.
+func matchGerritComments(pending []gerritPending, before, after []gerritComment) map[int]string {
+	known := make(map[string]bool, len(before))
+	for _, c := range before {
+		known[c.ID] = true
+	}
+	ids := make(map[int]string)
+	for i, p := range pending {
+		for _, c := range after {
+			if known[c.ID] || c.Path != p.input.Path || c.Line != p.input.Line ||
+				c.InReplyTo != p.input.InReplyTo || c.Message != p.input.Message {
+				continue
+			}
+			ids[i] = c.ID
+			known[c.ID] = true
+			break
+		}
+	}
+	return ids
+}
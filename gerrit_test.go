@@ -0,0 +1,316 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeGerrit is a minimal stand-in for the Gerrit changes REST API, serving
+// change 7 of project "proj" at patch set 2.
+type fakeGerrit struct {
+	mu       sync.Mutex
+	comments []gerritComment
+	drafts   []gerritComment
+	reviews  []map[string]any // bodies of POST .../review
+	nextID   int
+}
+
+func (f *fakeGerrit) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if user, pass, ok := r.BasicAuth(); !ok || user != "alice" || pass != "secret" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	reply := func(v any) {
+		io.WriteString(w, ")]}'\n")
+		json.NewEncoder(w).Encode(v)
+	}
+	path := r.URL.EscapedPath()
+	switch {
+	case r.Method == "GET" && path == "/a/changes/":
+		if r.URL.Query().Get("q") != "change:I0123456789abcdef0123456789abcdef01234567 project:proj" {
+			reply([]any{})
+			return
+		}
+		reply([]map[string]int{{"_number": 7}})
+	case r.Method == "GET" && path == "/a/changes/proj~7":
+		reply(map[string]any{"current_revision": "abc", "revisions": map[string]any{"abc": map[string]int{"_number": 2}}})
+	case r.Method == "GET" && path == "/a/changes/proj~7/comments":
+		byPath := make(map[string][]gerritComment)
+		for _, c := range f.comments {
+			p := c.Path
+			c.Path = ""
+			byPath[p] = append(byPath[p], c)
+		}
+		reply(byPath)
+	case r.Method == "POST" && path == "/a/changes/proj~7/revisions/current/review":
+		body, _ := io.ReadAll(r.Body)
+		var review map[string]any
+		json.Unmarshal(body, &review)
+		f.reviews = append(f.reviews, review)
+		var input struct {
+			Comments map[string][]gerritComment `json:"comments"`
+		}
+		json.Unmarshal(body, &input)
+		for p, cs := range input.Comments {
+			for _, c := range cs {
+				f.nextID++
+				c.ID, c.Path, c.PatchSet = fmt.Sprintf("new%d", f.nextID), p, 2
+				f.comments = append(f.comments, c)
+			}
+		}
+		reply(map[string]any{})
+	case r.Method == "PUT" && path == "/a/changes/proj~7/revisions/current/drafts":
+		var c gerritComment
+		json.NewDecoder(r.Body).Decode(&c)
+		f.nextID++
+		c.ID = fmt.Sprintf("draft%d", f.nextID)
+		f.drafts = append(f.drafts, c)
+		reply(c)
+	default:
+		http.Error(w, "unexpected "+r.Method+" "+path, http.StatusNotFound)
+	}
+}
+
+func newFakeGerritForge(t *testing.T, fake *fakeGerrit) *gerritForge {
+	t.Helper()
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	g, err := newGerritForge(srv.URL+"/", "alice", "secret", "a/proj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.client = srv.Client()
+	return g
+}
+
+func gerritTestComment(id, user, path string, patchSet, line int, msg string) gerritComment {
+	c := gerritComment{ID: id, Path: path, PatchSet: patchSet, Line: line, Message: msg, Updated: "2026-03-01 10:00:00.000000000"}
+	c.Author.Username = user
+	return c
+}
+
+func TestParseChangeID(t *testing.T) {
+	msg := "Fix the thing\n\nLonger text.\n\nChange-Id: I0123456789abcdef0123456789abcdef01234567\nSigned-off-by: A <a@b>\n"
+	if got := parseChangeID(msg); got != "I0123456789abcdef0123456789abcdef01234567" {
+		t.Errorf("got %q", got)
+	}
+	if got := parseChangeID("No trailer\n\nChange-Id: short\n"); got != "" {
+		t.Errorf("got %q, want none", got)
+	}
+}
+
+func TestGerritBaseURL(t *testing.T) {
+	for host, want := range map[string]string{
+		"go.googlesource.com":        "https://go-review.googlesource.com",
+		"go-review.googlesource.com": "https://go-review.googlesource.com",
+		"gerrit.example.com":         "https://gerrit.example.com",
+	} {
+		if got := gerritBaseURL(host); got != want {
+			t.Errorf("gerritBaseURL(%q) = %q, want %q", host, got, want)
+		}
+	}
+}
+
+func TestGerritForge_Pull(t *testing.T) {
+	root := gerritTestComment("c1", "bob", "main.go", 2, 5, "why?")
+	root.Range = &gerritRange{StartLine: 3, EndLine: 5}
+	root.Unresolved = boolPtr(true)
+	reply := gerritTestComment("c2", "carol", "main.go", 2, 5, "Done")
+	reply.InReplyTo, reply.Unresolved = "c1", boolPtr(false)
+	reply.Updated = "2026-03-01 11:00:00.000000000"
+	fileComment := gerritTestComment("c3", "bob", "util.go", 2, 0, "split this file")
+	oldPS := gerritTestComment("c4", "bob", "main.go", 1, 9, "on an old patch set")
+	base := gerritTestComment("c5", "bob", "main.go", 2, 9, "on the base")
+	base.Side = "PARENT"
+	commitMsg := gerritTestComment("c6", "bob", "/COMMIT_MSG", 2, 1, "typo")
+
+	fake := &fakeGerrit{comments: []gerritComment{reply, root, fileComment, oldPS, base, commitMsg}}
+	g := newFakeGerritForge(t, fake)
+
+	cj := CritJSON{Files: map[string]CritJSONFile{}}
+	res, err := g.Pull(7, &cj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Added != 3 {
+		t.Errorf("added = %d, want 3", res.Added)
+	}
+	main := cj.Files["main.go"].Comments
+	if len(main) != 1 {
+		t.Fatalf("main.go comments = %+v, want one thread", main)
+	}
+	c := main[0]
+	if c.RemoteID != "c1" || c.StartLine != 3 || c.EndLine != 5 || !c.Resolved || c.CreatedAt != "2026-03-01T10:00:00Z" {
+		t.Errorf("root = %+v", c)
+	}
+	if len(c.Replies) != 1 || c.Replies[0].RemoteID != "c2" || c.Replies[0].Author != "carol" {
+		t.Errorf("replies = %+v", c.Replies)
+	}
+	if fc := cj.Files["util.go"].Comments; len(fc) != 1 || fc[0].Scope != "file" {
+		t.Errorf("util.go comments = %+v, want a file comment", fc)
+	}
+	if _, ok := cj.Files["/COMMIT_MSG"]; ok {
+		t.Error("commit message comments should be skipped")
+	}
+
+	if res, _ := g.Pull(7, &cj); res.Added != 0 || res.Resolved != 0 {
+		t.Errorf("second pull = %+v, want nothing changed", res)
+	}
+}
+
+func TestMergeGerritComments_KeepsUnpushedResolution(t *testing.T) {
+	open := gerritTestComment("c1", "bob", "main.go", 2, 5, "why?")
+	open.Unresolved = boolPtr(true)
+	cj := CritJSON{Files: map[string]CritJSONFile{}}
+	mergeGerritComments(&cj, []gerritComment{open}, 2)
+
+	// Resolved in crit, not pushed yet: the next pull leaves it alone.
+	cj.Files["main.go"].Comments[0].Resolved = true
+	if res := mergeGerritComments(&cj, []gerritComment{open}, 2); res.Resolved != 0 || !cj.Files["main.go"].Comments[0].Resolved {
+		t.Errorf("pull reopened a comment resolved in crit: %+v", cj.Files["main.go"].Comments[0])
+	}
+
+	// Once someone resolves it on Gerrit and reopens it, crit follows.
+	done := gerritTestComment("c2", "carol", "main.go", 2, 5, "Done")
+	done.InReplyTo, done.Unresolved = "c1", boolPtr(false)
+	done.Updated = "2026-03-01 11:00:00.000000000"
+	mergeGerritComments(&cj, []gerritComment{open, done}, 2)
+	reopen := gerritTestComment("c3", "bob", "main.go", 2, 5, "not quite")
+	reopen.InReplyTo, reopen.Unresolved = "c1", boolPtr(true)
+	reopen.Updated = "2026-03-01 12:00:00.000000000"
+	if res := mergeGerritComments(&cj, []gerritComment{open, done, reopen}, 2); res.Resolved != 1 || cj.Files["main.go"].Comments[0].Resolved {
+		t.Errorf("pull did not take a reopen from Gerrit: %+v", cj.Files["main.go"].Comments[0])
+	}
+}
+
+func TestGerritForge_Push(t *testing.T) {
+	root := gerritTestComment("c1", "bob", "main.go", 2, 7, "old thread")
+	root.Unresolved = boolPtr(true)
+	other := gerritTestComment("c9", "bob", "main.go", 2, 20, "another thread")
+	other.Unresolved = boolPtr(true)
+	fake := &fakeGerrit{comments: []gerritComment{root, other}}
+	g := newFakeGerritForge(t, fake)
+
+	critPath := filepath.Join(t.TempDir(), "review.json")
+	cj := CritJSON{Files: map[string]CritJSONFile{
+		"main.go": {Comments: []Comment{
+			{ID: "c_new", StartLine: 1, EndLine: 2, Body: "new"},
+			{ID: "c_res", StartLine: 3, EndLine: 3, Body: "resolved locally", Resolved: true},
+			{ID: "c_old", StartLine: 9, EndLine: 9, Body: "old thread", RemoteID: "c1",
+				Replies: []Reply{{ID: "rp_1", Body: "my reply"}}},
+			{ID: "c_done", StartLine: 20, EndLine: 20, Body: "another thread", RemoteID: "c9", Resolved: true},
+		}},
+	}}
+	if err := saveCritJSON(critPath, cj); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Push(7, critPath, cj, pushOptions{Event: "REQUEST_CHANGES", Message: "please fix"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fake.reviews) != 1 {
+		t.Fatalf("posted %d reviews, want 1", len(fake.reviews))
+	}
+	review := fake.reviews[0]
+	if review["message"] != "please fix" || review["labels"].(map[string]any)["Code-Review"] != float64(-1) {
+		t.Errorf("review = %v, want the message and Code-Review -1", review)
+	}
+	sent := review["comments"].(map[string]any)["main.go"].([]any)
+	if len(sent) != 3 {
+		t.Fatalf("comments = %v, want new comment, reply and Done", sent)
+	}
+	first := sent[0].(map[string]any)
+	if rng, _ := first["range"].(map[string]any); rng == nil || rng["start_line"] != float64(1) || rng["end_line"] != float64(2) {
+		t.Errorf("new comment = %v, want a 1-2 range", first)
+	}
+	replyIn := sent[1].(map[string]any)
+	if replyIn["in_reply_to"] != "c1" || replyIn["line"] != float64(7) || replyIn["unresolved"] != true {
+		t.Errorf("reply = %v, want it on Gerrit's line 7, still unresolved", replyIn)
+	}
+	done := sent[2].(map[string]any)
+	if done["in_reply_to"] != "c9" || done["message"] != gerritDoneMessage || done["unresolved"] != false {
+		t.Errorf("done = %v", done)
+	}
+
+	saved, err := loadCritJSON(critPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := saved.Files["main.go"].Comments
+	if got[0].RemoteID != "new1" || got[1].RemoteID != "" || got[2].Replies[0].RemoteID != "new2" {
+		t.Errorf("remote IDs = %q %q %q, want new1, empty, new2", got[0].RemoteID, got[1].RemoteID, got[2].Replies[0].RemoteID)
+	}
+}
+
+func TestGerritForge_PushDrafts(t *testing.T) {
+	fake := &fakeGerrit{}
+	g := newFakeGerritForge(t, fake)
+
+	critPath := filepath.Join(t.TempDir(), "review.json")
+	cj := CritJSON{Files: map[string]CritJSONFile{
+		"main.go": {Comments: []Comment{{ID: "c_new", StartLine: 4, EndLine: 4, Body: "nit"}}},
+	}}
+	if err := saveCritJSON(critPath, cj); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Push(7, critPath, cj, pushOptions{Event: "APPROVE", Draft: true}); err == nil {
+		t.Error("expected an error for a vote with --draft")
+	}
+	if err := g.Push(7, critPath, cj, pushOptions{Event: "COMMENT", Draft: true}); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.reviews) != 0 || len(fake.drafts) != 1 || fake.drafts[0].Path != "main.go" || fake.drafts[0].Line != 4 {
+		t.Errorf("reviews = %v, drafts = %+v; want one draft on main.go:4", fake.reviews, fake.drafts)
+	}
+	saved, _ := loadCritJSON(critPath)
+	if id := saved.Files["main.go"].Comments[0].RemoteID; id != "draft1" {
+		t.Errorf("RemoteID = %q, want draft1", id)
+	}
+}
+
+func TestGerritForge_DetectChange(t *testing.T) {
+	dir := initTestRepo(t)
+	writeFile(t, filepath.Join(dir, "a.txt"), "a")
+	runGit(t, dir, "add", "a.txt")
+	runGit(t, dir, "commit", "-m", "Add a\n\nChange-Id: I0123456789abcdef0123456789abcdef01234567")
+	t.Chdir(dir)
+
+	g := newFakeGerritForge(t, &fakeGerrit{})
+	if n, err := g.DetectChange(0); err != nil || n != 7 {
+		t.Errorf("DetectChange(0) = %d, %v; want 7", n, err)
+	}
+
+	runGit(t, dir, "commit", "--allow-empty", "-m", "no trailer")
+	if _, err := g.DetectChange(0); err == nil || !strings.Contains(err.Error(), "Change-Id") {
+		t.Errorf("err = %v, want a missing Change-Id error", err)
+	}
+}
+
+func TestNewGerritForge_NeedsCredentials(t *testing.T) {
+	t.Setenv("GERRIT_USER", "")
+	t.Setenv("GERRIT_PASSWORD", "")
+	if _, err := newGerritForge("https://review.example.com", "alice", "", "proj"); err == nil {
+		t.Error("expected an error without a password")
+	}
+}
+
+func TestGerritForgeFor_OnlyConfiguredInstance(t *testing.T) {
+	t.Setenv("GERRIT_USER", "alice")
+	t.Setenv("GERRIT_PASSWORD", "secret")
+	if _, err := gerritForgeFor("gerrit.attacker.example", "proj", Config{}); err == nil {
+		t.Error("credentials were sent to a host guessed from the origin remote")
+	}
+	g, err := gerritForgeFor("gerrit.attacker.example", "proj", Config{GerritURL: "https://review.example.com/"})
+	if err != nil || g.baseURL != "https://review.example.com/a" {
+		t.Errorf("gerritForgeFor with gerrit_url = %+v, %v; want the configured instance", g, err)
+	}
+}
@@ -38,8 +38,12 @@ type DiffLine struct {
 }
 
 // IsGitRepo returns true if the current directory is inside a git repository.
-func IsGitRepo() bool {
+func IsGitRepo() bool { return isGitRepoInDir("") }
+
+// isGitRepoInDir is IsGitRepo for dir.
+func isGitRepoInDir(dir string) bool {
 	cmd := exec.Command("git", "rev-parse", "--is-inside-work-tree")
+	cmd.Dir = dir
 	out, err := cmd.Output()
 	if err != nil {
 		return false
@@ -48,8 +52,12 @@ func IsGitRepo() bool {
 }
 
 // RepoRoot returns the absolute path to the git repository root.
-func RepoRoot() (string, error) {
+func RepoRoot() (string, error) { return repoRootInDir("") }
+
+// repoRootInDir is RepoRoot for the repository containing dir.
+func repoRootInDir(dir string) (string, error) {
 	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	cmd.Dir = dir
 	out, err := cmd.Output()
 	if err != nil {
 		return "", fmt.Errorf("not a git repository")
@@ -94,9 +102,13 @@ func getDefaultBranchOverride() string {
 	return defaultBranchOverride
 }
 
-func detectDefaultBranch() string {
+func detectDefaultBranch() string { return detectDefaultBranchInDir("") }
+
+// detectDefaultBranchInDir is detectDefaultBranch for the repository in dir.
+func detectDefaultBranchInDir(dir string) string {
 	// Try remote HEAD first
 	cmd := exec.Command("git", "symbolic-ref", "refs/remotes/origin/HEAD")
+	cmd.Dir = dir
 	out, err := cmd.Output()
 	if err == nil {
 		ref := strings.TrimSpace(string(out))
@@ -107,13 +119,13 @@ func detectDefaultBranch() string {
 		}
 	}
 
-	// Fallback: check if main exists
-	if err := exec.Command("git", "rev-parse", "--verify", "main").Run(); err == nil {
-		return "main"
-	}
-	// Fallback: check if master exists
-	if err := exec.Command("git", "rev-parse", "--verify", "master").Run(); err == nil {
-		return "master"
+	// Fallback: check if main, then master, exists
+	for _, branch := range []string{"main", "master"} {
+		cmd := exec.Command("git", "rev-parse", "--verify", branch)
+		cmd.Dir = dir
+		if cmd.Run() == nil {
+			return branch
+		}
 	}
 	return "main"
 }
@@ -141,8 +153,12 @@ func RemoteBranches(dir string) ([]string, error) {
 }
 
 // CurrentBranch returns the name of the current branch.
-func CurrentBranch() string {
+func CurrentBranch() string { return currentBranchInDir("") }
+
+// currentBranchInDir is CurrentBranch for the checkout in dir.
+func currentBranchInDir(dir string) string {
 	cmd := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD")
+	cmd.Dir = dir
 	out, err := cmd.Output()
 	if err != nil {
 		return ""
@@ -156,8 +172,12 @@ func isOnDefaultBranch() bool {
 }
 
 // MergeBase returns the merge base commit between HEAD and the given base ref.
-func MergeBase(base string) (string, error) {
+func MergeBase(base string) (string, error) { return mergeBaseInDir(base, "") }
+
+// mergeBaseInDir is MergeBase for the checkout in dir.
+func mergeBaseInDir(base, dir string) (string, error) {
 	cmd := exec.Command("git", "merge-base", "HEAD", base)
+	cmd.Dir = dir
 	out, err := cmd.Output()
 	if err != nil {
 		return "", fmt.Errorf("merge-base failed: %w", err)
@@ -206,8 +226,12 @@ func ChangedFilesScoped(scope, baseRef string) ([]FileChange, error) {
 }
 
 // changedFilesStaged returns only staged (cached) changes.
-func changedFilesStaged() ([]FileChange, error) {
+func changedFilesStaged() ([]FileChange, error) { return changedFilesStagedInDir("") }
+
+// changedFilesStagedInDir is like changedFilesStaged but runs git from the specified directory.
+func changedFilesStagedInDir(dir string) ([]FileChange, error) {
 	cmd := exec.Command("git", "diff", "--cached", "--name-status")
+	cmd.Dir = dir
 	out, err := cmd.Output()
 	if err != nil {
 		return nil, fmt.Errorf("git diff --cached failed: %w", err)
@@ -216,8 +240,12 @@ func changedFilesStaged() ([]FileChange, error) {
 }
 
 // changedFilesUnstaged returns unstaged modifications plus untracked files.
-func changedFilesUnstaged() ([]FileChange, error) {
+func changedFilesUnstaged() ([]FileChange, error) { return changedFilesUnstagedInDir("") }
+
+// changedFilesUnstagedInDir is like changedFilesUnstaged but runs git from the specified directory.
+func changedFilesUnstagedInDir(dir string) ([]FileChange, error) {
 	cmd := exec.Command("git", "diff", "--name-status")
+	cmd.Dir = dir
 	out, err := cmd.Output()
 	if err != nil {
 		return nil, fmt.Errorf("git diff failed: %w", err)
@@ -225,7 +253,7 @@ func changedFilesUnstaged() ([]FileChange, error) {
 
 	changes := parseNameStatus(string(out))
 
-	untracked, err := untrackedFiles()
+	untracked, err := untrackedFilesInDir(dir)
 	if err != nil {
 		return nil, err
 	}
@@ -237,10 +265,16 @@ func changedFilesUnstaged() ([]FileChange, error) {
 // changedFilesBranch returns files changed between baseRef and HEAD.
 // Returns nil if baseRef is empty.
 func changedFilesBranch(baseRef string) ([]FileChange, error) {
+	return changedFilesBranchInDir(baseRef, "")
+}
+
+// changedFilesBranchInDir is like changedFilesBranch but runs git from the specified directory.
+func changedFilesBranchInDir(baseRef, dir string) ([]FileChange, error) {
 	if baseRef == "" {
 		return nil, nil
 	}
 	cmd := exec.Command("git", "diff", baseRef+"..HEAD", "--name-status")
+	cmd.Dir = dir
 	out, err := cmd.Output()
 	if err != nil {
 		return nil, fmt.Errorf("git diff %s..HEAD failed: %w", baseRef, err)
@@ -865,8 +899,12 @@ func ParseUnifiedDiff(diff string) []DiffHunk {
 
 // WorkingTreeFingerprint returns a string representing the current working tree state.
 // Compare consecutive calls to detect changes.
-func WorkingTreeFingerprint() string {
+func WorkingTreeFingerprint() string { return workingTreeFingerprintInDir("") }
+
+// workingTreeFingerprintInDir is WorkingTreeFingerprint for the checkout in dir.
+func workingTreeFingerprintInDir(dir string) string {
 	cmd := exec.Command("git", "--no-optional-locks", "status", "--porcelain")
+	cmd.Dir = dir
 	out, err := cmd.Output()
 	if err != nil {
 		return ""
@@ -5,56 +5,134 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"sync"
 )
 
 // GitVCS implements VCS for git repositories. Each method delegates to the
 // existing package-level function in git.go.
-type GitVCS struct{}
+//
+// A zero GitVCS runs git in the process working directory and shares the
+// package-level default branch cache and override. One with Dir set runs git
+// there and keeps both to itself, so that the hub can host sessions for
+// several checkouts in one process (see hub.go).
+type GitVCS struct {
+	Dir string // checkout to run git in; "" for the working directory
+
+	defaultBranchOnce sync.Once
+	defaultBranch     string       // auto-detected value (set via sync.Once)
+	overrideBranch    string       // explicit override (empty = no override)
+	defaultBranchMu   sync.RWMutex // protects defaultBranch and overrideBranch
+}
+
+// in returns the directory for a method called with dir: dir itself, or the
+// bound checkout when dir is empty.
+func (g *GitVCS) in(dir string) string {
+	if dir == "" {
+		return g.Dir
+	}
+	return dir
+}
 
 func (g *GitVCS) Name() string { return "git" }
 
-func (g *GitVCS) RepoRoot() (string, error) { return RepoRoot() }
+func (g *GitVCS) RepoRoot() (string, error) { return repoRootInDir(g.Dir) }
 
-func (g *GitVCS) CurrentBranch() string { return CurrentBranch() }
+func (g *GitVCS) CurrentBranch() string { return currentBranchInDir(g.Dir) }
 
-func (g *GitVCS) DefaultBranch() string { return DefaultBranch() }
+// DefaultBranch returns the default branch: the package-level one for a
+// zero GitVCS, otherwise the instance's, detected on first call.
+func (g *GitVCS) DefaultBranch() string {
+	if g.Dir == "" {
+		return DefaultBranch()
+	}
+	g.defaultBranchMu.RLock()
+	override := g.overrideBranch
+	g.defaultBranchMu.RUnlock()
+	if override != "" {
+		return override
+	}
+	g.defaultBranchOnce.Do(func() {
+		branch := detectDefaultBranchInDir(g.Dir)
+		g.defaultBranchMu.Lock()
+		g.defaultBranch = branch
+		g.defaultBranchMu.Unlock()
+	})
+	g.defaultBranchMu.RLock()
+	defer g.defaultBranchMu.RUnlock()
+	if g.overrideBranch != "" {
+		return g.overrideBranch
+	}
+	return g.defaultBranch
+}
 
-func (g *GitVCS) SetDefaultBranchOverride(branch string) { setDefaultBranchOverride(branch) }
+func (g *GitVCS) SetDefaultBranchOverride(branch string) {
+	if g.Dir == "" {
+		setDefaultBranchOverride(branch)
+		return
+	}
+	g.defaultBranchMu.Lock()
+	g.overrideBranch = branch
+	g.defaultBranchMu.Unlock()
+}
 
-func (g *GitVCS) GetDefaultBranchOverride() string { return getDefaultBranchOverride() }
+func (g *GitVCS) GetDefaultBranchOverride() string {
+	if g.Dir == "" {
+		return getDefaultBranchOverride()
+	}
+	g.defaultBranchMu.RLock()
+	defer g.defaultBranchMu.RUnlock()
+	return g.overrideBranch
+}
 
-func (g *GitVCS) MergeBase(ref string) (string, error) { return MergeBase(ref) }
+func (g *GitVCS) MergeBase(ref string) (string, error) { return mergeBaseInDir(ref, g.Dir) }
 
 func (g *GitVCS) ChangedFilesOnDefaultInDir(dir string) ([]FileChange, error) {
-	return changedFilesOnDefaultInDir(dir)
+	return changedFilesOnDefaultInDir(g.in(dir))
 }
 
 func (g *GitVCS) ChangedFilesFromBaseInDir(baseRef, dir string) ([]FileChange, error) {
-	return changedFilesFromBaseInDir(baseRef, dir)
+	return changedFilesFromBaseInDir(baseRef, g.in(dir))
 }
 
+// ChangedFilesScoped is the package-level ChangedFilesScoped for the bound
+// checkout. An unknown scope gets the whole working state, measured against
+// this instance's default branch.
 func (g *GitVCS) ChangedFilesScoped(scope, baseRef string) ([]FileChange, error) {
-	return ChangedFilesScoped(scope, baseRef)
+	switch scope {
+	case "branch":
+		return changedFilesBranchInDir(baseRef, g.Dir)
+	case "staged":
+		return changedFilesStagedInDir(g.Dir)
+	case "unstaged":
+		return changedFilesUnstagedInDir(g.Dir)
+	}
+	defaultBranch := g.DefaultBranch()
+	if g.CurrentBranch() != defaultBranch {
+		if mergeBase, err := g.MergeBase(defaultBranch); err == nil {
+			return changedFilesFromBaseInDir(mergeBase, g.Dir)
+		}
+	}
+	return changedFilesOnDefaultInDir(g.Dir)
 }
 
 func (g *GitVCS) ChangedFilesForCommit(sha, dir string) ([]FileChange, error) {
-	return ChangedFilesForCommit(sha, dir)
+	return ChangedFilesForCommit(sha, g.in(dir))
 }
 
 func (g *GitVCS) FileDiffUnified(path, baseRef, dir string) ([]DiffHunk, error) {
-	return fileDiffUnified(path, baseRef, dir)
+	return fileDiffUnified(path, baseRef, g.in(dir))
 }
 
 func (g *GitVCS) FileDiffUnifiedCtx(ctx context.Context, path, baseRef, dir string) ([]DiffHunk, error) {
-	return fileDiffUnifiedCtx(ctx, path, baseRef, dir)
+	return fileDiffUnifiedCtx(ctx, path, baseRef, g.in(dir))
 }
 
 func (g *GitVCS) FileDiffScoped(path, scope, baseRef, dir string) ([]DiffHunk, error) {
-	return FileDiffScoped(path, scope, baseRef, dir)
+	return FileDiffScoped(path, scope, baseRef, g.in(dir))
 }
 
 func (g *GitVCS) FileDiffForCommit(path, sha, dir string) ([]DiffHunk, error) {
-	return FileDiffForCommit(path, sha, dir)
+	return FileDiffForCommit(path, sha, g.in(dir))
 }
 
 func (g *GitVCS) FileDiffUnifiedNewFile(path string) ([]DiffHunk, error) {
@@ -66,29 +144,31 @@ func (g *GitVCS) FileDiffUnifiedNewFile(path string) ([]DiffHunk, error) {
 }
 
 func (g *GitVCS) CommitLog(baseRef, dir string) ([]CommitInfo, error) {
-	return CommitLog(baseRef, dir)
+	return CommitLog(baseRef, g.in(dir))
 }
 
-func (g *GitVCS) WorkingTreeFingerprint() string { return WorkingTreeFingerprint() }
+func (g *GitVCS) WorkingTreeFingerprint() string { return workingTreeFingerprintInDir(g.Dir) }
 
 func (g *GitVCS) UntrackedFiles(dir string) ([]FileChange, error) {
-	return untrackedFilesInDir(dir)
+	return untrackedFilesInDir(g.in(dir))
 }
 
 func (g *GitVCS) AllTrackedFiles(dir string) ([]string, error) {
-	return AllTrackedFiles(dir)
+	return AllTrackedFiles(g.in(dir))
 }
 
 func (g *GitVCS) RemoteBranches(dir string) ([]string, error) {
-	return RemoteBranches(dir)
+	return RemoteBranches(g.in(dir))
 }
 
 func (g *GitVCS) DiffNumstat(baseRef, dir string) (map[string]NumstatEntry, error) {
-	return DiffNumstatDir(baseRef, dir)
+	return DiffNumstatDir(baseRef, g.in(dir))
 }
 
 func (g *GitVCS) UserName() string {
-	out, err := exec.Command("git", "config", "user.name").Output()
+	cmd := exec.Command("git", "config", "user.name")
+	cmd.Dir = g.Dir
+	out, err := cmd.Output()
 	if err != nil {
 		return ""
 	}
@@ -97,7 +177,7 @@ func (g *GitVCS) UserName() string {
 
 // FileContentAtRef returns the content of a file at the given git ref.
 func (g *GitVCS) FileContentAtRef(path, ref, dir string) (string, error) {
-	content := fileContentAtRef(path, ref, dir)
+	content := fileContentAtRef(path, ref, g.in(dir))
 	return content, nil
 }
 
@@ -105,7 +185,7 @@ func (g *GitVCS) FileContentAtRef(path, ref, dir string) (string, error) {
 // Note: the VCS interface uses (path, baseRef, dir) order while the underlying
 // fileStatusInRepo uses (path, repoRoot, baseRef) — arguments are reordered here.
 func (g *GitVCS) FileStatusInRepo(path, baseRef, repoRoot string) string {
-	return fileStatusInRepo(path, repoRoot, baseRef)
+	return fileStatusInRepo(path, g.in(repoRoot), baseRef)
 }
 
 func (g *GitVCS) HasStagingArea() bool { return true }
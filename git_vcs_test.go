@@ -1,6 +1,9 @@
 package main
 
-import "testing"
+import (
+	"path/filepath"
+	"testing"
+)
 
 // Compile-time interface compliance check.
 var _ VCS = &GitVCS{}
@@ -33,3 +36,57 @@ func TestDetectVCS_GitOverride(t *testing.T) {
 		t.Errorf("DetectVCS(\"git\") should return GitVCS, got %v", vcs)
 	}
 }
+
+func TestGitVCS_DirIsIndependentOfWorkingDirectory(t *testing.T) {
+	dir := initTestRepo(t)
+	runGit(t, dir, "checkout", "-b", "feature")
+	writeFile(t, filepath.Join(dir, "notes.md"), "# Notes")
+	runGit(t, dir, "add", "notes.md")
+	runGit(t, dir, "commit", "-m", "notes")
+
+	// The test runs in crit's own checkout, not dir.
+	g := &GitVCS{Dir: dir}
+	root, err := g.RepoRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := filepath.EvalSymlinks(dir)
+	if got, _ := filepath.EvalSymlinks(root); got != want {
+		t.Errorf("RepoRoot() = %q, want %q", root, dir)
+	}
+	if got := g.CurrentBranch(); got != "feature" {
+		t.Errorf("CurrentBranch() = %q, want feature", got)
+	}
+	if got := g.DefaultBranch(); got != "main" {
+		t.Errorf("DefaultBranch() = %q, want main", got)
+	}
+	changes, err := g.ChangedFilesScoped("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Path != "notes.md" {
+		t.Errorf("ChangedFilesScoped() = %+v, want notes.md", changes)
+	}
+}
+
+func TestGitVCS_DirKeepsItsOwnDefaultBranchOverride(t *testing.T) {
+	g := &GitVCS{Dir: initTestRepo(t)}
+	g.SetDefaultBranchOverride("develop")
+	if got := g.GetDefaultBranchOverride(); got != "develop" {
+		t.Errorf("GetDefaultBranchOverride() = %q, want develop", got)
+	}
+	if got := (&GitVCS{}).GetDefaultBranchOverride(); got != "" {
+		t.Errorf("process-wide override = %q, want none", got)
+	}
+}
+
+func TestDetectVCSIn_BindsDir(t *testing.T) {
+	dir := initTestRepo(t)
+	g, ok := DetectVCSIn(dir, "").(*GitVCS)
+	if !ok || g.Dir != dir {
+		t.Fatalf("DetectVCSIn(%q) = %#v, want a GitVCS for it", dir, g)
+	}
+	if DetectVCSIn(t.TempDir(), "") != nil {
+		t.Error("DetectVCSIn(a plain directory) should be nil")
+	}
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// giteaForge syncs with Gitea and Forgejo pull requests over their REST API
+// (v1), which mirrors GitHub's review model: comments are posted as part of a
+// review, and comments on the same path and line form one conversation.
+// Gitea has no reply endpoint, so replies are posted as review comments on
+// the root comment's line.
+type giteaForge struct {
+	restClient
+	owner, repo string
+}
+
+// giteaForgeFor returns the Gitea/Forgejo client for a project whose origin
+// is on host. The token only goes to the instance in gitea_url, for the same
+// reason as gerritForgeFor.
+func giteaForgeFor(host, project string, cfg Config) (*giteaForge, error) {
+	if cfg.GiteaURL == "" {
+		return nil, fmt.Errorf("set gitea_url in ~/.crit.config.json (probably %q): crit only sends the Gitea token to that instance", "https://"+host)
+	}
+	return newGiteaForge(cfg.GiteaURL, cfg.GiteaToken, project)
+}
+
+// newGiteaForge returns a Gitea/Forgejo client. baseURL is the instance root
+// (gitea_url); the token comes from gitea_token or GITEA_TOKEN.
+func newGiteaForge(baseURL, token, project string) (*giteaForge, error) {
+	if token == "" {
+		token = os.Getenv("GITEA_TOKEN")
+	}
+	if token == "" {
+		return nil, errors.New("no Gitea token. Set gitea_token in ~/.crit.config.json or GITEA_TOKEN")
+	}
+	owner, repo, ok := strings.Cut(project, "/")
+	if !ok || owner == "" || repo == "" {
+		return nil, fmt.Errorf("cannot determine the Gitea repository from %q", project)
+	}
+	return &giteaForge{
+		restClient: newRESTClient("gitea", strings.TrimSuffix(baseURL, "/")+"/api/v1", "Authorization", "token "+token),
+		owner:      owner,
+		repo:       repo,
+	}, nil
+}
+
+// giteaComment is a pull request review comment. Position is the line in the
+// new file (0 for comments on removed lines); Resolver is set once the
+// conversation is resolved.
+type giteaComment struct {
+	ID       int64  `json:"id"`
+	Body     string `json:"body"`
+	Path     string `json:"path"`
+	Position int    `json:"position"`
+	User     struct {
+		Login    string `json:"login"`
+		FullName string `json:"full_name"`
+	} `json:"user"`
+	Resolver *struct {
+		Login string `json:"login"`
+	} `json:"resolver"`
+	CreatedAt string `json:"created_at"`
+}
+
+func (g *giteaForge) Name() string { return "gitea" }
+
+func (g *giteaForge) ChangeLabel(number int) string { return fmt.Sprintf("PR #%d", number) }
+
+func (g *giteaForge) repoPath(suffix string) string {
+	return "/repos/" + url.PathEscape(g.owner) + "/" + url.PathEscape(g.repo) + suffix
+}
+
+// DetectChange returns the open pull request whose head is the current branch.
+func (g *giteaForge) DetectChange(flag int) (int, error) {
+	if flag > 0 {
+		return flag, nil
+	}
+	branch := CurrentBranch()
+	for page := 1; ; page++ {
+		var prs []struct {
+			Number int `json:"number"`
+			Head   struct {
+				Ref string `json:"ref"`
+			} `json:"head"`
+		}
+		q := url.Values{"state": {"open"}, "limit": {"50"}, "page": {strconv.Itoa(page)}}
+		if _, err := g.do(http.MethodGet, g.repoPath("/pulls"), q, nil, &prs); err != nil {
+			return 0, err
+		}
+		for _, pr := range prs {
+			if pr.Head.Ref == branch {
+				return pr.Number, nil
+			}
+		}
+		if len(prs) < 50 {
+			return 0, fmt.Errorf("no open PR found for branch %s (try: crit pull <pr-number>)", branch)
+		}
+	}
+}
+
+// fetchComments returns every review comment on a pull request.
+func (g *giteaForge) fetchComments(number int) ([]giteaComment, error) {
+	var reviews []struct {
+		ID int64 `json:"id"`
+	}
+	for page := 1; ; page++ {
+		var batch []struct {
+			ID int64 `json:"id"`
+		}
+		q := url.Values{"limit": {"50"}, "page": {strconv.Itoa(page)}}
+		if _, err := g.do(http.MethodGet, g.repoPath(fmt.Sprintf("/pulls/%d/reviews", number)), q, nil, &batch); err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, batch...)
+		if len(batch) < 50 {
+			break
+		}
+	}
+	var all []giteaComment
+	for _, r := range reviews {
+		comments, err := g.reviewComments(number, r.ID)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, comments...)
+	}
+	return all, nil
+}
+
+func (g *giteaForge) reviewComments(number int, reviewID int64) ([]giteaComment, error) {
+	var comments []giteaComment
+	_, err := g.do(http.MethodGet, g.repoPath(fmt.Sprintf("/pulls/%d/reviews/%d/comments", number, reviewID)), nil, nil, &comments)
+	return comments, err
+}
+
+func (g *giteaForge) Pull(number int, cj *CritJSON) (pullResult, error) {
+	comments, err := g.fetchComments(number)
+	if err != nil {
+		return pullResult{}, err
+	}
+	return mergeGiteaComments(cj, comments), nil
+}
+
+// giteaThreads groups comments into conversations keyed by path and line,
+// ordered oldest first. Comments on removed lines are dropped.
+func giteaThreads(comments []giteaComment) [][]giteaComment {
+	type key struct {
+		path string
+		line int
+	}
+	groups := make(map[key][]giteaComment)
+	for _, c := range comments {
+		if c.Position == 0 {
+			continue
+		}
+		k := key{c.Path, c.Position}
+		groups[k] = append(groups[k], c)
+	}
+	var threads [][]giteaComment
+	for _, k := range slices.SortedFunc(maps.Keys(groups), func(a, b key) int {
+		if a.path != b.path {
+			return strings.Compare(a.path, b.path)
+		}
+		return a.line - b.line
+	}) {
+		t := groups[k]
+		sort.Slice(t, func(i, j int) bool { return t[i].ID < t[j].ID })
+		threads = append(threads, t)
+	}
+	return threads
+}
+
+// mergeGiteaComments merges Gitea review conversations into cj. The first
+// comment of a conversation is the root (matched by RemoteID), later ones
+// become replies, and changes to the resolved state on Gitea are taken over
+// (see pullRemoteResolved).
+func mergeGiteaComments(cj *CritJSON, comments []giteaComment) pullResult {
+	now := time.Now().UTC().Format(time.RFC3339)
+	cj.UpdatedAt = now
+
+	var res pullResult
+	for _, thread := range giteaThreads(comments) {
+		root := thread[0]
+		rootID := strconv.FormatInt(root.ID, 10)
+		resolved := root.Resolver != nil
+
+		if filePath, ci, found := findCommentByRemoteID(cj, rootID); found {
+			cf := cj.Files[filePath]
+			if pullRemoteResolved(&cf.Comments[ci], resolved) {
+				res.Resolved++
+			}
+			res.Added += appendNewGiteaReplies(&cf.Comments[ci], thread[1:])
+			cj.Files[filePath] = cf
+			continue
+		}
+
+		comment := Comment{
+			ID: randomCommentID(), StartLine: root.Position, EndLine: root.Position,
+			Body: root.Body, Author: displayName(root.User.Login, root.User.FullName),
+			CreatedAt: root.CreatedAt, UpdatedAt: now, Resolved: resolved, RemoteID: rootID,
+			RemoteResolved: boolPtr(resolved),
+		}
+		res.Added += 1 + appendNewGiteaReplies(&comment, thread[1:])
+
+		cf, ok := cj.Files[root.Path]
+		if !ok {
+			cf = CritJSONFile{Status: "modified", Comments: []Comment{}}
+		}
+		cf.Comments = append(cf.Comments, comment)
+		cj.Files[root.Path] = cf
+	}
+	return res
+}
+
+// appendNewGiteaReplies adds comments not yet present (by RemoteID) as replies.
+func appendNewGiteaReplies(c *Comment, comments []giteaComment) int {
+	added := 0
+	for _, gc := range comments {
+		id := strconv.FormatInt(gc.ID, 10)
+		if isDuplicateRemoteReply(c.Replies, id) {
+			continue
+		}
+		c.Replies = append(c.Replies, Reply{
+			ID:        randomReplyID(),
+			Body:      gc.Body,
+			Author:    displayName(gc.User.Login, gc.User.FullName),
+			CreatedAt: gc.CreatedAt,
+			RemoteID:  id,
+		})
+		added++
+	}
+	return added
+}
+
+// giteaReviewEvent maps a parsePushEvent value to Gitea's review state.
+func giteaReviewEvent(event string) string {
+	if event == "APPROVE" {
+		return "APPROVED"
+	}
+	return event
+}
+
+// Push posts one review holding every new unresolved comment and every new
+// reply, with the same selection rules as critJSONToGHComments.
+func (g *giteaForge) Push(number int, critPath string, cj CritJSON, opts pushOptions) error {
+	type pending struct {
+		id     string // crit comment or reply ID
+		reply  bool
+		path   string
+		line   int
+		body   string
+		parent string // remote root ID, for replies
+	}
+	var roots, replies []pending
+	hasReplies := false
+	for _, path := range slices.Sorted(maps.Keys(cj.Files)) {
+		for _, c := range cj.Files[path].Comments {
+			if c.RemoteID == "" {
+				if !c.Resolved && c.EndLine > 0 {
+					roots = append(roots, pending{id: c.ID, path: path, line: c.EndLine, body: c.Body})
+				}
+				continue
+			}
+			for _, r := range c.Replies {
+				if r.RemoteID == "" {
+					replies = append(replies, pending{id: r.ID, reply: true, body: r.Body, parent: c.RemoteID})
+					hasReplies = true
+				}
+			}
+		}
+	}
+	if len(roots) == 0 && !hasReplies && opts.Event == "COMMENT" {
+		fmt.Println("No unresolved comments to push.")
+		return nil
+	}
+
+	// Replies go on the root comment's line as Gitea last saw it, which may
+	// differ from where the comment sits locally.
+	if hasReplies {
+		existing, err := g.fetchComments(number)
+		if err != nil {
+			return err
+		}
+		byID := make(map[string]giteaComment, len(existing))
+		for _, gc := range existing {
+			byID[strconv.FormatInt(gc.ID, 10)] = gc
+		}
+		kept := replies[:0]
+		for _, r := range replies {
+			root, ok := byID[r.parent]
+			if !ok || root.Position == 0 {
+				fmt.Fprintf(os.Stderr, "Warning: skipping reply to comment %s, which is no longer on the PR\n", r.parent)
+				continue
+			}
+			r.path, r.line = root.Path, root.Position
+			kept = append(kept, r)
+		}
+		replies = kept
+	}
+	all := append(roots, replies...)
+
+	label := g.ChangeLabel(number)
+	displayEvent := strings.ToLower(strings.ReplaceAll(opts.Event, "_", "-"))
+	if opts.DryRun {
+		fmt.Printf("Would post %d comments to %s (event: %s):\n\n", len(roots), label, displayEvent)
+		if opts.Message != "" {
+			fmt.Printf("  Review body: %s\n\n", opts.Message)
+		}
+		for _, p := range roots {
+			fmt.Printf("  %s:%d\n    %s\n\n", p.path, p.line, p.body)
+		}
+		for _, p := range replies {
+			fmt.Printf("  Would reply to Gitea comment %s: %.60s\n", p.parent, p.body)
+		}
+		return nil
+	}
+
+	reviewComments := make([]map[string]any, 0, len(all))
+	for _, p := range all {
+		reviewComments = append(reviewComments, map[string]any{"path": p.path, "body": p.body, "new_position": p.line})
+	}
+	fmt.Printf("Pushing %d comments to %s (%s)...\n", len(roots), label, displayEvent)
+	var review struct {
+		ID int64 `json:"id"`
+	}
+	payload := map[string]any{"event": giteaReviewEvent(opts.Event), "body": opts.Message, "comments": reviewComments}
+	if _, err := g.do(http.MethodPost, g.repoPath(fmt.Sprintf("/pulls/%d/reviews", number)), nil, payload, &review); err != nil {
+		return fmt.Errorf("creating review: %w", err)
+	}
+	fmt.Printf("Posted %d review comments to %s (%s)\n", len(roots), label, displayEvent)
+	if len(replies) > 0 {
+		fmt.Printf("Posted %d replies\n", len(replies))
+	}
+
+	// The review's comments come back in the order they were submitted;
+	// zip them with our input to record IDs, as createGHReview does.
+	if review.ID == 0 || len(all) == 0 {
+		return nil
+	}
+	created, err := g.reviewComments(number, review.ID)
+	if err != nil {
+		return nil //nolint:nilerr // non-fatal: review was created, ID mapping is best-effort
+	}
+	sort.Slice(created, func(i, j int) bool { return created[i].ID < created[j].ID })
+	commentIDs := make(map[string]string)
+	replyIDs := make(map[string]string)
+	for i, gc := range created {
+		if i >= len(all) {
+			break
+		}
+		id := strconv.FormatInt(gc.ID, 10)
+		if all[i].reply {
+			replyIDs[all[i].id] = id
+		} else {
+			commentIDs[all[i].id] = id
+		}
+	}
+	if err := updateCritJSONRemoteIDs(critPath, commentIDs, replyIDs); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to update review file with Gitea IDs: %v\n", err)
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeGitea is a minimal stand-in for the Gitea/Forgejo v1 pull review API.
+type fakeGitea struct {
+	mu       sync.Mutex
+	reviews  map[int64][]giteaComment // review ID → comments
+	nextID   int64
+	payloads []map[string]any // bodies of POST .../reviews
+}
+
+func (f *fakeGitea) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("Authorization") != "token tok" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1/repos/owner/repo")
+	switch {
+	case r.Method == "GET" && path == "/pulls":
+		json.NewEncoder(w).Encode([]map[string]any{
+			{"number": 3, "head": map[string]string{"ref": "other"}},
+			{"number": 5, "head": map[string]string{"ref": CurrentBranch()}},
+		})
+	case r.Method == "GET" && path == "/pulls/5/reviews":
+		var list []map[string]int64
+		for id := int64(1); id <= f.nextID; id++ {
+			if _, ok := f.reviews[id]; ok {
+				list = append(list, map[string]int64{"id": id})
+			}
+		}
+		json.NewEncoder(w).Encode(list)
+	case r.Method == "GET" && strings.HasPrefix(path, "/pulls/5/reviews/") && strings.HasSuffix(path, "/comments"):
+		var id int64
+		json.Unmarshal([]byte(strings.TrimSuffix(strings.TrimPrefix(path, "/pulls/5/reviews/"), "/comments")), &id)
+		json.NewEncoder(w).Encode(f.reviews[id])
+	case r.Method == "POST" && path == "/pulls/5/reviews":
+		body, _ := io.ReadAll(r.Body)
+		var p map[string]any
+		json.Unmarshal(body, &p)
+		f.payloads = append(f.payloads, p)
+		f.nextID++
+		var created []giteaComment
+		comments, _ := p["comments"].([]any)
+		for i, c := range comments {
+			m := c.(map[string]any)
+			created = append(created, giteaComment{ID: f.nextID*100 + int64(i), Path: m["path"].(string), Position: int(m["new_position"].(float64)), Body: m["body"].(string)})
+		}
+		f.reviews[f.nextID] = created
+		json.NewEncoder(w).Encode(map[string]int64{"id": f.nextID})
+	default:
+		http.Error(w, "unexpected "+r.Method+" "+path, http.StatusNotFound)
+	}
+}
+
+func newFakeGiteaForge(t *testing.T, fake *fakeGitea) *giteaForge {
+	t.Helper()
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	g, err := newGiteaForge(srv.URL+"/", "tok", "owner/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.client = srv.Client()
+	return g
+}
+
+func giteaTestComment(id int64, user, path string, line int, body string) giteaComment {
+	c := giteaComment{ID: id, Path: path, Position: line, Body: body, CreatedAt: "2026-01-01T00:00:00Z"}
+	c.User.Login = user
+	return c
+}
+
+func TestGiteaForge_Pull(t *testing.T) {
+	root := giteaTestComment(10, "alice", "main.go", 4, "why?")
+	root.Resolver = &struct {
+		Login string `json:"login"`
+	}{"alice"}
+	fake := &fakeGitea{nextID: 2, reviews: map[int64][]giteaComment{
+		1: {root, giteaTestComment(11, "alice", "old.go", 0, "on a removed line")},
+		2: {giteaTestComment(12, "bob", "main.go", 4, "because"), giteaTestComment(13, "bob", "util.go", 9, "typo")},
+	}}
+	g := newFakeGiteaForge(t, fake)
+
+	cj := CritJSON{Files: map[string]CritJSONFile{}}
+	res, err := g.Pull(5, &cj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Added != 3 {
+		t.Errorf("added = %d, want 3", res.Added)
+	}
+	main := cj.Files["main.go"].Comments
+	if len(main) != 1 || main[0].RemoteID != "10" || !main[0].Resolved || main[0].StartLine != 4 {
+		t.Fatalf("main.go comments = %+v", main)
+	}
+	if len(main[0].Replies) != 1 || main[0].Replies[0].RemoteID != "12" || main[0].Replies[0].Author != "bob" {
+		t.Errorf("replies = %+v, want bob's reply", main[0].Replies)
+	}
+	if _, ok := cj.Files["old.go"]; ok {
+		t.Error("comments on removed lines should be skipped")
+	}
+
+	if res, _ := g.Pull(5, &cj); res.Added != 0 || res.Resolved != 0 {
+		t.Errorf("second pull = %+v, want nothing changed", res)
+	}
+}
+
+func TestMergeGiteaComments_KeepsUnpushedResolution(t *testing.T) {
+	root := giteaTestComment(10, "alice", "main.go", 4, "why?")
+	cj := CritJSON{Files: map[string]CritJSONFile{}}
+	mergeGiteaComments(&cj, []giteaComment{root})
+
+	// Resolved in crit, not pushed yet: the next pull leaves it alone.
+	cj.Files["main.go"].Comments[0].Resolved = true
+	if res := mergeGiteaComments(&cj, []giteaComment{root}); res.Resolved != 0 || !cj.Files["main.go"].Comments[0].Resolved {
+		t.Errorf("pull reopened a comment resolved in crit: %+v", cj.Files["main.go"].Comments[0])
+	}
+
+	// Resolved on Gitea, then reopened there: crit follows the reopen.
+	resolved := root
+	resolved.Resolver = &struct {
+		Login string `json:"login"`
+	}{"bob"}
+	mergeGiteaComments(&cj, []giteaComment{resolved})
+	if res := mergeGiteaComments(&cj, []giteaComment{root}); res.Resolved != 1 || cj.Files["main.go"].Comments[0].Resolved {
+		t.Errorf("pull did not take a reopen from Gitea: %+v", cj.Files["main.go"].Comments[0])
+	}
+}
+
+func TestGiteaForge_Push(t *testing.T) {
+	fake := &fakeGitea{reviews: map[int64][]giteaComment{}}
+	g := newFakeGiteaForge(t, fake)
+
+	// An existing conversation on main.go:7 that the local comment has since moved from.
+	fake.nextID = 1
+	fake.reviews[1] = []giteaComment{giteaTestComment(50, "bob", "main.go", 7, "old thread")}
+
+	critPath := filepath.Join(t.TempDir(), "review.json")
+	cj := CritJSON{Files: map[string]CritJSONFile{
+		"main.go": {Comments: []Comment{
+			{ID: "c_new", StartLine: 1, EndLine: 2, Body: "new"},
+			{ID: "c_res", StartLine: 3, EndLine: 3, Body: "resolved", Resolved: true},
+			{ID: "c_old", StartLine: 9, EndLine: 9, Body: "old thread", RemoteID: "50",
+				Replies: []Reply{{ID: "rp_1", Body: "my reply"}}},
+		}},
+	}}
+	if err := saveCritJSON(critPath, cj); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Push(5, critPath, cj, pushOptions{Event: "APPROVE", Message: "lgtm"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fake.payloads) != 1 {
+		t.Fatalf("posted %d reviews, want 1", len(fake.payloads))
+	}
+	p := fake.payloads[0]
+	if p["event"] != "APPROVED" || p["body"] != "lgtm" {
+		t.Errorf("review event/body = %v/%v", p["event"], p["body"])
+	}
+	comments := p["comments"].([]any)
+	if len(comments) != 2 {
+		t.Fatalf("review comments = %v, want new comment + reply", comments)
+	}
+	reply := comments[1].(map[string]any)
+	if reply["new_position"] != float64(7) || reply["body"] != "my reply" {
+		t.Errorf("reply = %v, want it on the remote root's line 7", reply)
+	}
+
+	saved, err := loadCritJSON(critPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := saved.Files["main.go"].Comments
+	if got[0].RemoteID != "200" || got[1].RemoteID != "" || got[2].Replies[0].RemoteID != "201" {
+		t.Errorf("remote IDs = %q %q %q, want 200, empty, 201", got[0].RemoteID, got[1].RemoteID, got[2].Replies[0].RemoteID)
+	}
+}
+
+func TestGiteaForge_DetectChange(t *testing.T) {
+	g := newFakeGiteaForge(t, &fakeGitea{reviews: map[int64][]giteaComment{}})
+	if n, err := g.DetectChange(0); err != nil || n != 5 {
+		t.Errorf("DetectChange(0) = %d, %v; want 5", n, err)
+	}
+}
+
+func TestNewGiteaForge_NeedsToken(t *testing.T) {
+	t.Setenv("GITEA_TOKEN", "")
+	if _, err := newGiteaForge("https://git.example.com", "", "owner/repo"); err == nil {
+		t.Error("expected an error without a token")
+	}
+}
+
+func TestGiteaForgeFor_OnlyConfiguredInstance(t *testing.T) {
+	cfg := Config{GiteaToken: "tok"}
+	if _, err := giteaForgeFor("gitea.attacker.example", "owner/repo", cfg); err == nil {
+		t.Error("the token was sent to a host guessed from the origin remote")
+	}
+	cfg.GiteaURL = "https://git.example.com"
+	g, err := giteaForgeFor("gitea.attacker.example", "owner/repo", cfg)
+	if err != nil || g.baseURL != "https://git.example.com/api/v1" {
+		t.Errorf("giteaForgeFor with gitea_url = %+v, %v; want the configured instance", g, err)
+	}
+}
@@ -2,18 +2,14 @@ package main
 
 import (
 	"bytes"
-	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
+	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
-	"testing"
 	"time"
 )
 
@@ -33,37 +29,92 @@ type ghComment struct {
 	// see userNameCache.
 	CreatedAt   string `json:"created_at"`
 	InReplyToID int64  `json:"in_reply_to_id"`
+
+	// Where the comment was made. GitHub sets Line to 0 once a push makes the
+	// comment outdated; the original fields keep its place in the commit it
+	// was made on (see anchorGHComment).
+	OriginalLine      int    `json:"original_line"`
+	OriginalStartLine int    `json:"original_start_line"`
+	OriginalCommitID  string `json:"original_commit_id"`
+	DiffHunk          string `json:"diff_hunk"`
+
+	// ThreadResolved is whether the thread a root comment starts is
+	// resolved, when known (see markGHThreadState).
+	ThreadResolved *bool `json:"-"`
 }
 
-// requireGH checks that the gh CLI is installed and authenticated.
-func requireGH() error {
-	if _, err := exec.LookPath("gh"); err != nil {
-		return fmt.Errorf("gh CLI not found. Install it: https://cli.github.com")
+// markGHThreadState records on each root comment whether its review thread
+// is resolved, so a comment pulled for the first time starts out in the
+// thread's state rather than as a conflict with it.
+func markGHThreadState(ghComments []ghComment, threads []ghThread) {
+	resolved := make(map[int64]bool, len(threads))
+	for _, t := range threads {
+		if t.RootID != 0 {
+			resolved[t.RootID] = t.IsResolved
+		}
 	}
-	cmd := exec.Command("gh", "auth", "status")
-	cmd.Stdout = nil
-	cmd.Stderr = nil
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("gh is not authenticated. Run: gh auth login")
+	for i := range ghComments {
+		if r, ok := resolved[ghComments[i].ID]; ok {
+			ghComments[i].ThreadResolved = boolPtr(r)
+		}
 	}
-	return nil
+}
+
+// ghPull is a pull request as returned by the REST API. The list endpoint
+// leaves out the size fields (Additions, Deletions, ChangedFiles).
+type ghPull struct {
+	Number       int    `json:"number"`
+	HTMLURL      string `json:"html_url"`
+	Title        string `json:"title"`
+	Body         string `json:"body"`
+	State        string `json:"state"` // "open" or "closed"
+	Draft        bool   `json:"draft"`
+	Merged       bool   `json:"merged"`
+	Additions    int    `json:"additions"`
+	Deletions    int    `json:"deletions"`
+	ChangedFiles int    `json:"changed_files"`
+	User         struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	Head struct {
+		Ref string `json:"ref"`
+		SHA string `json:"sha"`
+	} `json:"head"`
+	Base struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+	CreatedAt string `json:"created_at"`
 }
 
 // detectPR returns the PR number for the current branch.
 // If prFlag is non-zero, it's used directly.
-func detectPR(prFlag int) (int, error) {
+func detectPR(api githubAPI, prFlag int) (int, error) {
 	if prFlag > 0 {
 		return prFlag, nil
 	}
-	out, err := exec.Command("gh", "pr", "view", "--json", "number", "--jq", ".number").Output()
+	pr, err := findBranchPR(api, CurrentBranch())
 	if err != nil {
+		return 0, err
+	}
+	if pr == nil {
 		return 0, fmt.Errorf("no PR found for current branch (try: crit pull <pr-number>)")
 	}
-	n, err := strconv.Atoi(strings.TrimSpace(string(out)))
+	return pr.Number, nil
+}
+
+// findBranchPR returns the open PR whose head is branch, or nil if there is none.
+func findBranchPR(api githubAPI, branch string) (*ghPull, error) {
+	if branch == "" {
+		return nil, nil
+	}
+	pulls, err := ghList[ghPull](api, "repos/{owner}/{repo}/pulls?state=open&head={owner}:"+url.QueryEscape(branch))
 	if err != nil {
-		return 0, fmt.Errorf("unexpected PR number: %s", string(out))
+		return nil, fmt.Errorf("looking up the PR for %s: %w", branch, err)
 	}
-	return n, nil
+	if len(pulls) == 0 {
+		return nil, nil
+	}
+	return &pulls[0], nil
 }
 
 // PRInfo holds metadata about the PR for the current branch.
@@ -83,13 +134,6 @@ type PRInfo struct {
 	CreatedAt    string `json:"createdAt"`
 }
 
-// prAuthor is used to unmarshal the nested author field from gh output.
-// Name is GitHub's optional display name; we prefer it over Login when set.
-type prAuthor struct {
-	Login string `json:"login"`
-	Name  string `json:"name"`
-}
-
 // displayName returns name when set, falling back to login. Used to convert
 // GitHub-imported author identities into the friendlier display string we
 // pass through to crit-web.
@@ -100,194 +144,92 @@ func displayName(login, name string) string {
 	return login
 }
 
-// userNameCache memoizes login → display-name lookups for the duration of a
-// single `crit pull`. The /pulls/.../comments REST payload only returns
-// `login`, so we hit /users/{login} once per unique commenter.
+// userNameCache maps logins to display names for the duration of a single
+// `crit pull`. GitHub's comment payloads only carry `login`, so names are
+// fetched from /users/{login} up front, once per unique commenter.
 type userNameCache map[string]string
 
-// lookup returns the display name for a login, fetching from GitHub on cache
-// miss. On any error or missing name, returns login (always a valid fallback).
-//
-// Under `go test`, the network call is skipped entirely — tests that want to
-// exercise display-name resolution should pre-populate the cache before
-// invoking the merge functions.
-func (c userNameCache) lookup(login string) string {
-	if login == "" {
-		return ""
-	}
-	if cached, ok := c[login]; ok {
-		return cached
-	}
-	if testing.Testing() {
-		c[login] = login
-		return login
-	}
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	out, err := exec.CommandContext(ctx, "gh", "api", "users/"+login, "--jq", ".name // \"\"").Output()
-	if err != nil {
-		c[login] = login
-		return login
+// fetch looks up the display names of logins not in the cache yet. On any
+// error or missing name the login itself is cached.
+func (c userNameCache) fetch(api githubAPI, logins ...string) {
+	for _, login := range logins {
+		if _, ok := c[login]; ok || login == "" {
+			continue
+		}
+		var user struct {
+			Name string `json:"name"`
+		}
+		if err := api.call("GET", "users/"+url.PathEscape(login), nil, &user); err != nil {
+			c[login] = login
+			continue
+		}
+		c[login] = displayName(login, user.Name)
 	}
-	name := strings.TrimSpace(string(out))
-	resolved := displayName(login, name)
-	c[login] = resolved
-	return resolved
 }
 
-// prInfoRaw mirrors the gh JSON output shape (author is nested).
-type prInfoRaw struct {
-	URL          string   `json:"url"`
-	Number       int      `json:"number"`
-	Title        string   `json:"title"`
-	IsDraft      bool     `json:"isDraft"`
-	State        string   `json:"state"`
-	Body         string   `json:"body"`
-	BaseRefName  string   `json:"baseRefName"`
-	HeadRefName  string   `json:"headRefName"`
-	Additions    int      `json:"additions"`
-	Deletions    int      `json:"deletions"`
-	ChangedFiles int      `json:"changedFiles"`
-	Author       prAuthor `json:"author"`
-	CreatedAt    string   `json:"createdAt"`
+// lookup returns the display name for a login, or the login itself when it
+// wasn't fetched (always a valid fallback).
+func (c userNameCache) lookup(login string) string {
+	if name, ok := c[login]; ok {
+		return name
+	}
+	return login
 }
 
-// detectPRInfo returns PR metadata for the current branch.
-// Returns nil if gh is unavailable, no PR exists, or the PR is merged/closed
-// (to avoid associating a new local branch with a stale PR that had the same name).
-func detectPRInfo() *PRInfo {
-	if err := requireGH(); err != nil {
-		return nil
+// ghCommentNames fetches the display names of everyone in ghComments.
+func ghCommentNames(api githubAPI, ghComments []ghComment) userNameCache {
+	names := make(userNameCache)
+	for _, gc := range ghComments {
+		names.fetch(api, gc.User.Login)
 	}
-	out, err := exec.Command("gh", "pr", "view", "--json",
-		"number,url,title,isDraft,state,body,baseRefName,headRefName,additions,deletions,changedFiles,author,createdAt").Output()
-	if err != nil {
+	return names
+}
+
+// detectPRInfo returns PR metadata for branch.
+// Returns nil if GitHub is unreachable, no PR exists, or the PR is
+// merged/closed (to avoid associating a new local branch with a stale PR that
+// had the same name).
+func detectPRInfo(api githubAPI, branch string) *PRInfo {
+	found, err := findBranchPR(api, branch)
+	if err != nil || found == nil {
 		return nil
 	}
-	var raw prInfoRaw
-	if err := json.Unmarshal(out, &raw); err != nil {
+	// The list endpoint has no size fields; fetch the PR itself.
+	var pr ghPull
+	if err := api.call("GET", fmt.Sprintf("repos/{owner}/{repo}/pulls/%d", found.Number), nil, &pr); err != nil {
 		return nil
 	}
-	if raw.URL == "" || raw.State == "MERGED" || raw.State == "CLOSED" {
+	if pr.HTMLURL == "" || pr.Merged || pr.State != "open" {
 		return nil
 	}
+	names := make(userNameCache)
+	names.fetch(api, pr.User.Login)
 	return &PRInfo{
-		URL:          raw.URL,
-		Number:       raw.Number,
-		Title:        raw.Title,
-		IsDraft:      raw.IsDraft,
-		State:        raw.State,
-		Body:         raw.Body,
-		BaseRefName:  raw.BaseRefName,
-		HeadRefName:  raw.HeadRefName,
-		Additions:    raw.Additions,
-		Deletions:    raw.Deletions,
-		ChangedFiles: raw.ChangedFiles,
-		AuthorLogin:  displayName(raw.Author.Login, raw.Author.Name),
-		CreatedAt:    raw.CreatedAt,
+		URL:          pr.HTMLURL,
+		Number:       pr.Number,
+		Title:        pr.Title,
+		IsDraft:      pr.Draft,
+		State:        "OPEN",
+		Body:         pr.Body,
+		BaseRefName:  pr.Base.Ref,
+		HeadRefName:  pr.Head.Ref,
+		Additions:    pr.Additions,
+		Deletions:    pr.Deletions,
+		ChangedFiles: pr.ChangedFiles,
+		AuthorLogin:  names.lookup(pr.User.Login),
+		CreatedAt:    pr.CreatedAt,
 	}
 }
 
 // fetchPRComments fetches all review comments for a PR.
-func fetchPRComments(prNumber int) ([]ghComment, error) {
-	if ghSupportsAPISlurp() {
-		return fetchPRCommentsWithSlurp(prNumber)
-	}
-	// TODO: Remove this compatibility path once Crit requires gh v2.48.0+,
-	// which added `gh api --slurp`.
-	return fetchPRCommentsWithoutSlurp(prNumber)
-}
-
-func ghSupportsAPISlurp() bool {
-	out, err := exec.Command("gh", "version").Output()
-	if err != nil {
-		return false
-	}
-	return ghVersionSupportsSlurp(string(out))
-}
-
-func ghVersionSupportsSlurp(versionOutput string) bool {
-	fields := strings.Fields(versionOutput)
-	if len(fields) < 3 || fields[0] != "gh" || fields[1] != "version" {
-		return false
-	}
-	return versionAtLeast(fields[2], 2, 48, 0)
-}
-
-func versionAtLeast(version string, wantMajor, wantMinor, wantPatch int) bool {
-	core := strings.SplitN(strings.TrimPrefix(version, "v"), "-", 2)[0]
-	parts := strings.Split(core, ".")
-	if len(parts) < 3 {
-		return false
-	}
-	major, err := strconv.Atoi(parts[0])
-	if err != nil {
-		return false
-	}
-	minor, err := strconv.Atoi(parts[1])
-	if err != nil {
-		return false
-	}
-	patch, err := strconv.Atoi(parts[2])
-	if err != nil {
-		return false
-	}
-	if major != wantMajor {
-		return major > wantMajor
-	}
-	if minor != wantMinor {
-		return minor > wantMinor
-	}
-	return patch >= wantPatch
-}
-
-func fetchPRCommentsWithSlurp(prNumber int) ([]ghComment, error) {
-	out, err := exec.Command("gh", "api",
-		fmt.Sprintf("repos/{owner}/{repo}/pulls/%d/comments", prNumber),
-		"--paginate",
-		"--slurp",
-	).Output()
+func fetchPRComments(api githubAPI, prNumber int) ([]ghComment, error) {
+	comments, err := ghList[ghComment](api, fmt.Sprintf("repos/{owner}/{repo}/pulls/%d/comments", prNumber))
 	if err != nil {
 		return nil, fmt.Errorf("fetching PR comments: %w", err)
 	}
-
-	var pages [][]ghComment
-	if err := json.Unmarshal(out, &pages); err != nil {
-		return nil, fmt.Errorf("parsing PR comments: %w", err)
-	}
-
-	var comments []ghComment
-	for _, page := range pages {
-		comments = append(comments, page...)
-	}
 	return comments, nil
 }
 
-func fetchPRCommentsWithoutSlurp(prNumber int) ([]ghComment, error) {
-	out, err := exec.Command("gh", "api",
-		fmt.Sprintf("repos/{owner}/{repo}/pulls/%d/comments", prNumber),
-		"--paginate",
-		"--jq",
-		".[]",
-	).Output()
-	if err != nil {
-		return nil, fmt.Errorf("fetching PR comments: %w", err)
-	}
-
-	dec := json.NewDecoder(bytes.NewReader(out))
-	var comments []ghComment
-	for {
-		var c ghComment
-		if err := dec.Decode(&c); err != nil {
-			if errors.Is(err, io.EOF) {
-				return comments, nil
-			}
-			return nil, fmt.Errorf("parsing PR comments: %w", err)
-		}
-		comments = append(comments, c)
-	}
-}
-
 // isDuplicateGHComment checks if a GitHub comment already exists in the comment list.
 // If ghID is non-zero, matches by GitHubID. Otherwise falls back to author+lines+body.
 func isDuplicateGHComment(comments []Comment, ghID int64, author string, startLine, endLine int, body string) bool {
@@ -331,7 +273,7 @@ func separateRootsAndReplies(ghComments []ghComment) ([]ghComment, map[int64][]g
 	var roots []ghComment
 	replyMap := make(map[int64][]ghComment)
 	for _, gc := range ghComments {
-		if gc.Line == 0 || gc.Side == "LEFT" {
+		if (gc.Line == 0 && gc.OriginalLine == 0) || gc.Side == "LEFT" {
 			continue
 		}
 		if gc.InReplyToID == 0 {
@@ -368,20 +310,18 @@ func appendNewGHReplies(comments []Comment, ci int, childReplies []ghComment, na
 }
 
 // mergeRootComment handles a single root ghComment: either deduplicates or creates it.
-func mergeRootComment(cj *CritJSON, gc ghComment, replyMap map[int64][]ghComment, now string, names userNameCache) int {
+func mergeRootComment(cj *CritJSON, gc ghComment, replyMap map[int64][]ghComment, now string, names userNameCache, lines func(path string) ([]string, error)) int {
 	cf, ok := cj.Files[gc.Path]
 	if !ok {
 		cf = CritJSONFile{Status: "modified", Comments: []Comment{}}
 	}
 
-	startLine := gc.StartLine
-	if startLine == 0 {
-		startLine = gc.Line
-	}
+	startLine, endLine := ghCommentLines(gc)
 
 	authorName := names.lookup(gc.User.Login)
+	body := normalizeGHSuggestion(gc.Body)
 
-	if isDuplicateGHComment(cf.Comments, gc.ID, authorName, startLine, gc.Line, gc.Body) {
+	if isDuplicateGHComment(cf.Comments, gc.ID, authorName, startLine, endLine, body) {
 		added := 0
 		if childReplies, hasReplies := replyMap[gc.ID]; hasReplies {
 			for ci, c := range cf.Comments {
@@ -397,10 +337,15 @@ func mergeRootComment(cj *CritJSON, gc ghComment, replyMap map[int64][]ghComment
 
 	commentID := randomCommentID()
 	comment := Comment{
-		ID: commentID, StartLine: startLine, EndLine: gc.Line,
-		Body: gc.Body, Author: authorName, CreatedAt: gc.CreatedAt,
+		ID: commentID, StartLine: startLine, EndLine: endLine,
+		Body: body, Author: authorName, CreatedAt: gc.CreatedAt,
 		UpdatedAt: now, GitHubID: gc.ID,
 	}
+	if gc.ThreadResolved != nil {
+		comment.Resolved = *gc.ThreadResolved
+		comment.RemoteResolved = boolPtr(*gc.ThreadResolved)
+	}
+	anchorGHComment(&comment, gc, lines)
 
 	added := 0
 	if childReplies, hasReplies := replyMap[gc.ID]; hasReplies {
@@ -448,23 +393,24 @@ func mergeOrphanReplies(cj *CritJSON, roots []ghComment, replyMap map[int64][]gh
 // Only includes RIGHT-side comments (comments on the new version of the file).
 // Handles threading: root comments become top-level Comments, replies become Reply entries.
 // Deduplicates by GitHubID (preferred) or author+lines+body to prevent duplicates from repeated pulls.
+// Authors are recorded by login; see mergeGHCommentsWithNames.
 func mergeGHComments(cj *CritJSON, ghComments []ghComment) int {
 	return mergeGHCommentsWithNames(cj, ghComments, make(userNameCache))
 }
 
-// mergeGHCommentsWithNames is the form of mergeGHComments that lets callers
-// supply a pre-populated cache. Production uses mergeGHComments (fresh
-// cache, lazy /users/{login} lookups). Tests can pre-populate to assert on
-// resolved display names without going to the network.
+// mergeGHCommentsWithNames is the form of mergeGHComments that records
+// display names from a cache. Production fills it with ghCommentNames; tests
+// pre-populate it to assert on resolved names without going to the network.
 func mergeGHCommentsWithNames(cj *CritJSON, ghComments []ghComment, names userNameCache) int {
 	now := time.Now().UTC().Format(time.RFC3339)
 	cj.UpdatedAt = now
 
 	roots, replyMap := separateRootsAndReplies(ghComments)
+	lines := workingTreeLines(currentRepoRoot())
 
 	added := 0
 	for _, gc := range roots {
-		added += mergeRootComment(cj, gc, replyMap, now, names)
+		added += mergeRootComment(cj, gc, replyMap, now, names, lines)
 	}
 	added += mergeOrphanReplies(cj, roots, replyMap, names)
 
@@ -602,59 +548,30 @@ func collectNewRepliesForPush(cf CritJSONFile) []ghReplyForPush {
 
 // postGHReply posts a reply to an existing GitHub PR review comment.
 // Returns the GitHub ID of the newly created reply.
-func postGHReply(prNumber int, parentGHID int64, body string) (int64, error) {
-	payload, err := json.Marshal(map[string]any{
+func postGHReply(api githubAPI, prNumber int, parentGHID int64, body string) (int64, error) {
+	return postGHPRComment(api, prNumber, map[string]any{
 		"body":        body,
 		"in_reply_to": parentGHID,
 	})
-	if err != nil {
-		return 0, fmt.Errorf("marshal reply: %w", err)
-	}
-	cmd := exec.Command("gh", "api",
-		fmt.Sprintf("repos/{owner}/{repo}/pulls/%d/comments", prNumber),
-		"--method", "POST",
-		"--input", "-",
-	)
-	cmd.Stdin = bytes.NewReader(payload)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return 0, fmt.Errorf("gh api: %s: %w", string(output), err)
-	}
+}
+
+// postGHPRComment creates a single PR review comment outside a review and
+// returns its GitHub ID.
+func postGHPRComment(api githubAPI, prNumber int, fields map[string]any) (int64, error) {
 	var resp struct {
 		ID int64 `json:"id"`
 	}
-	if err := json.Unmarshal(output, &resp); err != nil {
-		return 0, fmt.Errorf("parsing reply response: %w", err)
+	if err := api.call("POST", fmt.Sprintf("repos/{owner}/{repo}/pulls/%d/comments", prNumber), fields, &resp); err != nil {
+		return 0, err
 	}
 	return resp.ID, nil
 }
 
 // critJSONToGHComments converts review file comments to GitHub review comment format.
-// Returns the list of comments suitable for the GitHub "create review" API.
+// Returns the list of inline comments suitable for the GitHub "create review" API,
+// without checking them against the PR diff (see planGHPush).
 func critJSONToGHComments(cj CritJSON) []map[string]any {
-	var result []map[string]any
-	for path, cf := range cj.Files {
-		for _, c := range cf.Comments {
-			if c.Resolved {
-				continue // don't post resolved comments
-			}
-			if c.GitHubID != 0 {
-				continue // already pushed
-			}
-			comment := map[string]any{
-				"path": path,
-				"line": c.EndLine,
-				"side": "RIGHT",
-				"body": c.Body,
-			}
-			if c.StartLine != c.EndLine {
-				comment["start_line"] = c.StartLine
-				comment["start_side"] = "RIGHT"
-			}
-			result = append(result, comment)
-		}
-	}
-	return result
+	return planGHPush(cj, nil).Comments
 }
 
 // parsePushEvent maps a user-facing event flag value to the GitHub API event string.
@@ -687,61 +604,44 @@ func buildReviewPayload(comments []map[string]any, message string, event string)
 
 // createGHReview posts a review with inline comments to a GitHub PR.
 // message is the top-level review body (empty string posts no top-level comment).
-// Returns a map of "path:endLine" -> GitHubID for each created comment.
-func createGHReview(prNumber int, comments []map[string]any, message string, event string) (map[string]int64, error) {
+// Returns a map of "path:endLine" -> GitHubID for each created comment, and
+// the review's ID (0 if unknown).
+func createGHReview(api githubAPI, prNumber int, comments []map[string]any, message string, event string) (map[string]int64, int64, error) {
 	data, err := buildReviewPayload(comments, message, event)
 	if err != nil {
-		return nil, fmt.Errorf("marshaling review: %w", err)
-	}
-
-	var stdout, stderr bytes.Buffer
-	cmd := exec.Command("gh", "api",
-		fmt.Sprintf("repos/{owner}/{repo}/pulls/%d/reviews", prNumber),
-		"--method", "POST",
-		"--input", "-",
-	)
-	cmd.Stdin = bytes.NewReader(data)
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-	if err := cmd.Run(); err != nil {
-		if stderr.Len() > 0 {
-			return nil, fmt.Errorf("creating review: %s", strings.TrimSpace(stderr.String()))
-		}
-		return nil, fmt.Errorf("creating review: %w", err)
+		return nil, 0, fmt.Errorf("marshaling review: %w", err)
 	}
 
-	// Parse review ID from response, then fetch its comments in a second call.
-	// The create-review response does not include comment objects — only the review itself.
+	// The create-review response does not include comment objects — only the
+	// review itself — so its comments are fetched in a second call.
 	var reviewResp struct {
 		ID int64 `json:"id"`
 	}
+	if err := api.call("POST", fmt.Sprintf("repos/{owner}/{repo}/pulls/%d/reviews", prNumber), json.RawMessage(data), &reviewResp); err != nil {
+		return nil, 0, fmt.Errorf("creating review: %w", err)
+	}
 	idMap := make(map[string]int64)
-	if err := json.Unmarshal(stdout.Bytes(), &reviewResp); err != nil || reviewResp.ID == 0 {
-		return idMap, nil //nolint:nilerr // non-fatal: review was created, just can't map IDs
+	if reviewResp.ID == 0 {
+		return idMap, 0, nil // non-fatal: review was created, just can't map IDs
 	}
 
 	// Fetch this review's comments and zip with our input to map IDs by position.
 	// We use the review-scoped endpoint (only returns this review's comments, in order).
-	commentOut, err := exec.Command("gh", "api",
-		fmt.Sprintf("repos/{owner}/{repo}/pulls/%d/reviews/%d/comments", prNumber, reviewResp.ID),
-	).Output()
-	if err != nil {
-		return idMap, nil //nolint:nilerr // non-fatal: review was created, comment ID mapping is best-effort
-	}
-	var reviewComments []struct {
+	reviewComments, err := ghList[struct {
 		ID int64 `json:"id"`
-	}
-	if err := json.Unmarshal(commentOut, &reviewComments); err == nil {
-		for i, rc := range reviewComments {
-			if i < len(comments) {
-				path, _ := comments[i]["path"].(string)
-				line, _ := comments[i]["line"].(int)
-				key := fmt.Sprintf("%s:%d", path, line)
-				idMap[key] = rc.ID
-			}
+	}](api, fmt.Sprintf("repos/{owner}/{repo}/pulls/%d/reviews/%d/comments", prNumber, reviewResp.ID))
+	if err != nil {
+		return idMap, reviewResp.ID, nil //nolint:nilerr // non-fatal: review was created, comment ID mapping is best-effort
+	}
+	for i, rc := range reviewComments {
+		if i < len(comments) {
+			path, _ := comments[i]["path"].(string)
+			line, _ := comments[i]["line"].(int)
+			key := fmt.Sprintf("%s:%d", path, line)
+			idMap[key] = rc.ID
 		}
 	}
-	return idMap, nil
+	return idMap, reviewResp.ID, nil
 }
 
 // replyKey uniquely identifies a reply for GitHubID mapping after push.
@@ -769,6 +669,7 @@ func updateCritJSONWithGitHubIDs(critPath string, commentIDs map[string]int64, r
 				key := fmt.Sprintf("%s:%d", path, c.EndLine)
 				if id, ok := commentIDs[key]; ok {
 					cf.Comments[i].GitHubID = id
+					cf.Comments[i].RemoteResolved = boolPtr(false) // new threads start unresolved
 				}
 			}
 			for j, r := range c.Replies {
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// ghCommentLines returns the lines a pulled comment is on: its current
+// position in the PR head, or for outdated comments (Line == 0) its position
+// in the commit it was made on.
+func ghCommentLines(gc ghComment) (start, end int) {
+	start, end = gc.StartLine, gc.Line
+	if end == 0 {
+		start, end = gc.OriginalStartLine, gc.OriginalLine
+	}
+	if start == 0 {
+		start = end
+	}
+	return start, end
+}
+
+// ghCommentAnchor returns the text of the lines a comment was made on, as of
+// its original commit. The diff hunk GitHub attaches to every comment ends at
+// the commented line, so it usually holds the whole range; otherwise the
+// file is read at original_commit_id when that commit is available locally.
+func ghCommentAnchor(gc ghComment) string {
+	end := gc.OriginalLine
+	if end == 0 {
+		return ""
+	}
+	start := gc.OriginalStartLine
+	if start == 0 {
+		start = end
+	}
+
+	byLine := make(map[int]string)
+	for _, h := range ParseUnifiedDiff(gc.DiffHunk) {
+		for _, l := range h.Lines {
+			if l.NewNum != 0 {
+				byLine[l.NewNum] = l.Content
+			}
+		}
+	}
+	if text, ok := joinLineRange(byLine, start, end); ok {
+		return text
+	}
+
+	if gc.OriginalCommitID == "" {
+		return ""
+	}
+	out, err := exec.Command("git", "show", gc.OriginalCommitID+":"+gc.Path).Output()
+	if err != nil {
+		return ""
+	}
+	lines := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+	if start < 1 || end > len(lines) {
+		return ""
+	}
+	return strings.Join(lines[start-1:end], "\n")
+}
+
+func joinLineRange(byLine map[int]string, start, end int) (string, bool) {
+	parts := make([]string, 0, end-start+1)
+	for n := start; n <= end; n++ {
+		l, ok := byLine[n]
+		if !ok {
+			return "", false
+		}
+		parts = append(parts, l)
+	}
+	return strings.Join(parts, "\n"), true
+}
+
+// anchorGHComment places a newly pulled comment on the working tree, the way
+// carry-forward does between rounds: the commented text is looked for at the
+// GitHub position first, then anywhere in the file. If it can't be found (or
+// the file is gone) the comment keeps GitHub's position and is marked
+// Drifted. Comments GitHub gives no hunk or commit for are left as they are.
+func anchorGHComment(c *Comment, gc ghComment, lines func(path string) ([]string, error)) {
+	anchor := ghCommentAnchor(gc)
+	if anchor == "" {
+		return
+	}
+	c.Anchor = anchor
+	current, err := lines(gc.Path)
+	if err != nil {
+		c.Drifted = true
+		return
+	}
+	start, end, drifted := verifyAndCorrectPosition(current, anchor, c.StartLine, c.EndLine)
+	c.StartLine, c.EndLine, c.Drifted = start, end, drifted != 0
+}
+
+// currentRepoRoot returns the root of the checkout in the working directory,
+// or "." outside one.
+func currentRepoRoot() string {
+	if vcs := DetectVCS(""); vcs != nil {
+		if root, err := vcs.RepoRoot(); err == nil {
+			return root
+		}
+	}
+	return "."
+}
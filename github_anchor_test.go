@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testDiffHunk = `@@ -1,4 +1,6 @@
+ package main
+ 
++// greet says hello.
+ func greet() {
+-	println("hi")
++	println("hello")
++	println("world")`
+
+func TestGHCommentAnchor_FromDiffHunk(t *testing.T) {
+	gc := ghComment{Path: "a.go", OriginalStartLine: 5, OriginalLine: 6, DiffHunk: testDiffHunk}
+	if got, want := ghCommentAnchor(gc), "\tprintln(\"hello\")\n\tprintln(\"world\")"; got != want {
+		t.Errorf("anchor = %q, want %q", got, want)
+	}
+	// A range starting before the hunk can't be read from it.
+	gc.OriginalStartLine = 0
+	gc.OriginalLine = 9
+	if got := ghCommentAnchor(gc); got != "" {
+		t.Errorf("anchor = %q, want empty", got)
+	}
+}
+
+func TestMergeGHComments_ReanchorsOutdatedComment(t *testing.T) {
+	dir := t.TempDir()
+	// Since the comment was made, two lines were added above the function.
+	current := "package main\n\nimport \"fmt\"\n\n// greet says hello.\nfunc greet() {\n\tprintln(\"hello\")\n\tprintln(\"world\")\n}\n"
+	if err := os.WriteFile(filepath.Join(dir, "a.go"), []byte(current), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+
+	comments := []ghComment{
+		// Outdated: GitHub no longer has a current line.
+		{ID: 1, Path: "a.go", Side: "RIGHT", Body: "print once", OriginalStartLine: 5, OriginalLine: 6, DiffHunk: testDiffHunk},
+		{ID: 2, Path: "a.go", Side: "RIGHT", Body: "reply", InReplyToID: 1, OriginalLine: 6},
+		// The commented line no longer exists anywhere.
+		{ID: 3, Path: "a.go", Side: "RIGHT", Body: "gone", OriginalLine: 5, DiffHunk: "@@ -1,1 +1,5 @@\n a\n+b\n+c\n+d\n+removed since"},
+		// The file was deleted.
+		{ID: 4, Path: "deleted.go", Line: 3, Side: "RIGHT", Body: "deleted", OriginalLine: 3, DiffHunk: "@@ -0,0 +1,3 @@\n+x\n+y\n+z"},
+	}
+	cj := CritJSON{Files: map[string]CritJSONFile{}}
+	added := mergeGHCommentsWithNames(&cj, comments, userNameCache{})
+	if added != 4 {
+		t.Errorf("added = %d, want 4", added)
+	}
+
+	got := cj.Files["a.go"].Comments
+	if len(got) != 2 {
+		t.Fatalf("got %d comments on a.go, want 2", len(got))
+	}
+	if c := got[0]; c.StartLine != 7 || c.EndLine != 8 || c.Drifted || len(c.Replies) != 1 {
+		t.Errorf("outdated comment = lines %d-%d drifted=%v replies=%d, want 7-8, not drifted, 1 reply", c.StartLine, c.EndLine, c.Drifted, len(c.Replies))
+	}
+	if c := got[1]; !c.Drifted || c.EndLine != 5 {
+		t.Errorf("unplaceable comment = %+v, want drifted at line 5", c)
+	}
+	if c := cj.Files["deleted.go"].Comments[0]; !c.Drifted || c.EndLine != 3 {
+		t.Errorf("comment on deleted file = %+v, want drifted at line 3", c)
+	}
+}
@@ -0,0 +1,432 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// githubAPI is how crit reaches GitHub: githubClient talks to the API
+// directly with a token, ghCLI shells out to the gh CLI when crit has no
+// token of its own. Paths are REST paths relative to the API root, as with
+// `gh api`, and may use the {owner} and {repo} placeholders.
+type githubAPI interface {
+	// call sends a REST request and decodes the JSON response into out (if non-nil).
+	call(method, path string, body, out any) error
+	// list fetches every page of a list endpoint.
+	list(path string) ([]json.RawMessage, error)
+	// raw GETs path with the given Accept header and returns the body as is.
+	raw(path, accept string) ([]byte, error)
+	// graphQL runs a query and returns the whole response ({"data", "errors"}).
+	// String variables may use the placeholders too.
+	graphQL(query string, vars map[string]any) ([]byte, error)
+}
+
+// ghList fetches every item of a list endpoint.
+func ghList[T any](api githubAPI, path string) ([]T, error) {
+	raws, err := api.list(path)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]T, 0, len(raws))
+	for _, r := range raws {
+		var item T
+		if err := json.Unmarshal(r, &item); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", path, err)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// newGitHubAPI returns a client for the repository checked out in dir ("" for
+// the working directory). It uses the native client when a token is found
+// (see githubToken) and falls back to the gh CLI otherwise.
+func newGitHubAPI(dir string, cfg Config) (githubAPI, error) {
+	host, project := parseRemoteURL(originRemoteURLIn(dir))
+	owner, repo, _ := strings.Cut(project, "/")
+	if owner != "" && repo != "" {
+		if token := githubToken(host, cfg); token != "" {
+			base := cfg.GitHubAPIURL
+			if base == "" {
+				base = githubAPIBase(host)
+			}
+			return newGitHubClient(base, token, owner, repo), nil
+		}
+	}
+	if err := requireGH(); err != nil {
+		if githubAPIBase(host) != "https://api.github.com" && cfg.GitHubAPIURL == "" {
+			return nil, fmt.Errorf("no GitHub token found for %s (set GH_ENTERPRISE_TOKEN) and %w", host, err)
+		}
+		return nil, fmt.Errorf("no GitHub token found (set GITHUB_TOKEN or github_token in ~/.crit.config.json) and %w", err)
+	}
+	return ghCLI{dir: dir}, nil
+}
+
+// githubAPIBase returns the REST API root for a GitHub host: api.github.com
+// for github.com, /api/v3 on GitHub Enterprise Server. Hosts without a dot
+// are taken to be SSH aliases for github.com.
+func githubAPIBase(host string) string {
+	if host == "" || strings.EqualFold(host, "github.com") || !strings.Contains(host, ".") {
+		return "https://api.github.com"
+	}
+	return "https://" + host + "/api/v3"
+}
+
+// githubGraphQLURL returns the GraphQL endpoint that goes with a REST root.
+func githubGraphQLURL(base string) string {
+	if root, ok := strings.CutSuffix(base, "/api/v3"); ok {
+		return root + "/api/graphql"
+	}
+	return base + "/graphql"
+}
+
+// githubToken finds a token for host. github_token from the global config,
+// the GITHUB_TOKEN and GH_TOKEN variables and gh's default login are
+// github.com credentials, so they are only used for github.com or an API
+// root configured with github_api_url. Any other host gets only the
+// enterprise variables gh reads and gh's login for that host: origin may
+// point at a server that merely isn't recognised as another forge, and it
+// must never be sent the user's github.com token.
+func githubToken(host string, cfg Config) string {
+	if githubAPIBase(host) != "https://api.github.com" {
+		for _, env := range []string{"GH_ENTERPRISE_TOKEN", "GITHUB_ENTERPRISE_TOKEN"} {
+			if token := os.Getenv(env); token != "" {
+				return token
+			}
+		}
+		if cfg.GitHubAPIURL == "" {
+			return ghAuthToken("auth", "token", "--hostname", host)
+		}
+	}
+	if cfg.GitHubToken != "" {
+		return cfg.GitHubToken
+	}
+	for _, env := range []string{"GITHUB_TOKEN", "GH_TOKEN"} {
+		if token := os.Getenv(env); token != "" {
+			return token
+		}
+	}
+	hostname := "github.com"
+	if cfg.GitHubAPIURL != "" && strings.Contains(host, ".") {
+		hostname = host
+	}
+	return ghAuthToken("auth", "token", "--hostname", hostname)
+}
+
+// ghAuthToken returns the token `gh <args>` prints, or "" without gh.
+func ghAuthToken(args ...string) string {
+	if _, err := exec.LookPath("gh"); err != nil {
+		return ""
+	}
+	out, err := exec.Command("gh", args...).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// requireGH checks that the gh CLI is installed and authenticated.
+func requireGH() error {
+	if _, err := exec.LookPath("gh"); err != nil {
+		return fmt.Errorf("gh CLI not found. Install it: https://cli.github.com")
+	}
+	cmd := exec.Command("gh", "auth", "status")
+	cmd.Stdout = nil
+	cmd.Stderr = nil
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("gh is not authenticated. Run: gh auth login")
+	}
+	return nil
+}
+
+// githubClient is the native GitHub client: REST and GraphQL over HTTP with
+// a bearer token. List calls follow Link headers, and requests that hit a
+// rate limit are retried once the limit resets if that is soon enough.
+type githubClient struct {
+	baseURL     string // REST root, e.g. "https://api.github.com"
+	graphQLURL  string
+	token       string
+	owner, repo string
+	client      *http.Client
+	maxWait     time.Duration // longest rate-limit wait before giving up
+	sleep       func(time.Duration)
+}
+
+func newGitHubClient(baseURL, token, owner, repo string) *githubClient {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	return &githubClient{
+		baseURL:    baseURL,
+		graphQLURL: githubGraphQLURL(baseURL),
+		token:      token,
+		owner:      owner,
+		repo:       repo,
+		client:     &http.Client{Timeout: 30 * time.Second},
+		maxWait:    time.Minute,
+		sleep:      time.Sleep,
+	}
+}
+
+// expand fills in the {owner} and {repo} placeholders.
+func (c *githubClient) expand(s string) string {
+	return strings.NewReplacer("{owner}", c.owner, "{repo}", c.repo).Replace(s)
+}
+
+func (c *githubClient) url(path string) string {
+	if strings.HasPrefix(path, "https://") || strings.HasPrefix(path, "http://") {
+		return path
+	}
+	return c.baseURL + "/" + strings.TrimPrefix(c.expand(path), "/")
+}
+
+func (c *githubClient) call(method, path string, body, out any) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	resp, _, err := c.do(method, c.url(path), data, "application/vnd.github+json")
+	if err != nil {
+		return err
+	}
+	if out != nil && len(resp) > 0 {
+		if err := json.Unmarshal(resp, out); err != nil {
+			return fmt.Errorf("github: parsing response: %w", err)
+		}
+	}
+	return nil
+}
+
+func (c *githubClient) list(path string) ([]json.RawMessage, error) {
+	next := c.url(path)
+	if !strings.Contains(next, "per_page=") {
+		sep := "?"
+		if strings.Contains(next, "?") {
+			sep = "&"
+		}
+		next += sep + "per_page=100"
+	}
+	var items []json.RawMessage
+	for next != "" {
+		data, header, err := c.do(http.MethodGet, next, nil, "application/vnd.github+json")
+		if err != nil {
+			return nil, err
+		}
+		var page []json.RawMessage
+		if err := json.Unmarshal(data, &page); err != nil {
+			return nil, fmt.Errorf("github: parsing %s: %w", path, err)
+		}
+		items = append(items, page...)
+		next = nextPageURL(header.Get("Link"))
+	}
+	return items, nil
+}
+
+func (c *githubClient) raw(path, accept string) ([]byte, error) {
+	data, _, err := c.do(http.MethodGet, c.url(path), nil, accept)
+	return data, err
+}
+
+func (c *githubClient) graphQL(query string, vars map[string]any) ([]byte, error) {
+	expanded := make(map[string]any, len(vars))
+	for k, v := range vars {
+		if s, ok := v.(string); ok {
+			v = c.expand(s)
+		}
+		expanded[k] = v
+	}
+	data, err := json.Marshal(map[string]any{"query": query, "variables": expanded})
+	if err != nil {
+		return nil, err
+	}
+	resp, _, err := c.do(http.MethodPost, c.graphQLURL, data, "application/json")
+	return resp, err
+}
+
+// do sends one request, waiting out rate limits, and returns the body of a
+// successful response.
+func (c *githubClient) do(method, u string, body []byte, accept string) ([]byte, http.Header, error) {
+	for attempt := 0; ; attempt++ {
+		var reqBody io.Reader
+		if body != nil {
+			reqBody = bytes.NewReader(body)
+		}
+		req, err := http.NewRequest(method, u, reqBody)
+		if err != nil {
+			return nil, nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+c.token)
+		req.Header.Set("Accept", accept)
+		req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+		req.Header.Set("User-Agent", "crit")
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return nil, nil, fmt.Errorf("github: %w", err)
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, 32<<20))
+		resp.Body.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("github: reading response: %w", err)
+		}
+		if wait, limited := rateLimitWait(resp, time.Now()); limited {
+			if attempt >= 2 || wait > c.maxWait {
+				return nil, nil, fmt.Errorf("github: rate limit exceeded, retry in %s", wait.Round(time.Second))
+			}
+			c.sleep(wait)
+			continue
+		}
+		if resp.StatusCode >= 300 {
+			return nil, nil, fmt.Errorf("github: %s %s: %s", method, strings.TrimPrefix(u, c.baseURL), githubErrorMessage(resp.Status, data))
+		}
+		return data, resp.Header, nil
+	}
+}
+
+// rateLimitWait reports whether a response was rejected by a rate limit and
+// how long to wait before retrying. GitHub answers 429, or 403 with
+// X-RateLimit-Remaining: 0 (primary limit) or a Retry-After header
+// (secondary limit); other 403s are permission errors.
+func rateLimitWait(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+	if s := resp.Header.Get("Retry-After"); s != "" {
+		if secs, err := strconv.Atoi(s); err == nil {
+			return time.Duration(secs) * time.Second, true
+		}
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		wait := time.Second
+		if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			if d := time.Unix(reset, 0).Sub(now); d > wait {
+				wait = d
+			}
+		}
+		return wait, true
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return time.Minute, true
+	}
+	return 0, false
+}
+
+// githubErrorMessage prefers the "message" field of a GitHub error body.
+func githubErrorMessage(status string, body []byte) string {
+	var e struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &e) == nil && e.Message != "" {
+		return status + ": " + e.Message
+	}
+	return status + ": " + truncateStr(strings.TrimSpace(string(body)), 200)
+}
+
+// nextPageURL returns the rel="next" target of a Link header, or "".
+func nextPageURL(link string) string {
+	for _, part := range strings.Split(link, ",") {
+		target, params, ok := strings.Cut(strings.TrimSpace(part), ";")
+		if !ok || !strings.Contains(params, `rel="next"`) {
+			continue
+		}
+		return strings.Trim(strings.TrimSpace(target), "<>")
+	}
+	return ""
+}
+
+// ghCLI reaches GitHub through `gh api`, which fills in the placeholders
+// from the repository gh resolves for dir.
+type ghCLI struct {
+	dir string
+}
+
+func (g ghCLI) run(stdin []byte, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("gh", args...)
+	cmd.Dir = g.dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return stdout.Bytes(), fmt.Errorf("gh %s: %s", args[0], msg)
+		}
+		return stdout.Bytes(), fmt.Errorf("gh %s: %w", args[0], err)
+	}
+	return stdout.Bytes(), nil
+}
+
+func (g ghCLI) call(method, path string, body, out any) error {
+	args := []string{"api", path, "--method", method}
+	var stdin []byte
+	if body != nil {
+		var err error
+		if stdin, err = json.Marshal(body); err != nil {
+			return err
+		}
+		args = append(args, "--input", "-")
+	}
+	data, err := g.run(stdin, args...)
+	if err != nil {
+		return err
+	}
+	if out != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("parsing %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// list streams items one per line with --jq, which works on every gh
+// version (unlike --slurp).
+func (g ghCLI) list(path string) ([]json.RawMessage, error) {
+	data, err := g.run(nil, "api", path, "--paginate", "--jq", ".[]")
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	var items []json.RawMessage
+	for {
+		var item json.RawMessage
+		if err := dec.Decode(&item); err != nil {
+			if errors.Is(err, io.EOF) {
+				return items, nil
+			}
+			return nil, fmt.Errorf("parsing %s: %w", path, err)
+		}
+		items = append(items, item)
+	}
+}
+
+func (g ghCLI) raw(path, accept string) ([]byte, error) {
+	return g.run(nil, "api", "-H", "Accept: "+accept, path)
+}
+
+func (g ghCLI) graphQL(query string, vars map[string]any) ([]byte, error) {
+	args := []string{"api", "graphql", "-f", "query=" + query}
+	for k, v := range vars {
+		// -F fills in placeholders and sends numbers as numbers; -f sends
+		// other strings verbatim.
+		if s, ok := v.(string); ok && !strings.Contains(s, "{") {
+			args = append(args, "-f", k+"="+s)
+		} else {
+			args = append(args, "-F", fmt.Sprintf("%s=%v", k, v))
+		}
+	}
+	return g.run(nil, args...)
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newTestGitHubClient returns a client for acme/widgets on srv.
+func newTestGitHubClient(t *testing.T, srv *httptest.Server) *githubClient {
+	t.Helper()
+	c := newGitHubClient(srv.URL, "tok", "acme", "widgets")
+	c.sleep = func(time.Duration) {}
+	return c
+}
+
+func TestGitHubAPIBase(t *testing.T) {
+	tests := []struct {
+		host, rest, graphQL string
+	}{
+		{"github.com", "https://api.github.com", "https://api.github.com/graphql"},
+		{"", "https://api.github.com", "https://api.github.com/graphql"},
+		{"github-work", "https://api.github.com", "https://api.github.com/graphql"}, // ssh alias
+		{"ghe.example.com", "https://ghe.example.com/api/v3", "https://ghe.example.com/api/graphql"},
+	}
+	for _, tt := range tests {
+		rest := githubAPIBase(tt.host)
+		if rest != tt.rest || githubGraphQLURL(rest) != tt.graphQL {
+			t.Errorf("%q: got %s, %s; want %s, %s", tt.host, rest, githubGraphQLURL(rest), tt.rest, tt.graphQL)
+		}
+	}
+}
+
+func TestNextPageURL(t *testing.T) {
+	link := `<https://api.github.com/x?page=3>; rel="next", <https://api.github.com/x?page=9>; rel="last"`
+	if got := nextPageURL(link); got != "https://api.github.com/x?page=3" {
+		t.Errorf("got %q", got)
+	}
+	if got := nextPageURL(`<https://api.github.com/x?page=1>; rel="prev"`); got != "" {
+		t.Errorf("got %q, want none", got)
+	}
+}
+
+func TestGitHubClient_ListFollowsLinks(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+		if r.URL.Path != "/repos/acme/widgets/pulls/7/comments" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page == 0 {
+			if r.URL.Query().Get("per_page") != "100" {
+				t.Errorf("per_page = %q", r.URL.Query().Get("per_page"))
+			}
+			w.Header().Set("Link", fmt.Sprintf(`<%s%s?page=2>; rel="next"`, srv.URL, r.URL.Path))
+		}
+		fmt.Fprintf(w, `[{"id": %d, "path": "a.go", "line": 1}]`, page+1)
+	}))
+	defer srv.Close()
+
+	comments, err := fetchPRComments(newTestGitHubClient(t, srv), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 2 || comments[0].ID != 1 || comments[1].ID != 3 {
+		t.Errorf("comments = %+v, want IDs 1 and 3", comments)
+	}
+}
+
+func TestGitHubClient_WaitsOutRateLimit(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(10*time.Second).Unix(), 10))
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		io.WriteString(w, `{"id": 5}`)
+	}))
+	defer srv.Close()
+
+	c := newTestGitHubClient(t, srv)
+	var slept time.Duration
+	c.sleep = func(d time.Duration) { slept = d }
+	id, err := postGHReply(c, 1, 2, "thanks")
+	if err != nil || id != 5 {
+		t.Fatalf("postGHReply = %d, %v", id, err)
+	}
+	if calls != 2 || slept <= 0 || slept > 11*time.Second {
+		t.Errorf("calls = %d, slept %s; want a retry after about 10s", calls, slept)
+	}
+}
+
+func TestGitHubClient_RateLimitTooLong(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	c := newTestGitHubClient(t, srv)
+	c.sleep = func(time.Duration) { t.Error("should not wait an hour") }
+	if err := c.call("GET", "users/alice", nil, nil); err == nil {
+		t.Fatal("expected a rate limit error")
+	}
+}
+
+func TestGitHubClient_PermissionErrorIsNotRateLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, `{"message": "Resource not accessible by integration"}`)
+	}))
+	defer srv.Close()
+
+	err := newTestGitHubClient(t, srv).call("POST", "repos/{owner}/{repo}/check-runs", map[string]any{}, nil)
+	if err == nil || err.Error() != "github: POST /repos/acme/widgets/check-runs: 403 Forbidden: Resource not accessible by integration" {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestGitHubClient_GraphQL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/graphql" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		var req struct {
+			Variables map[string]any `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatal(err)
+		}
+		if req.Variables["owner"] != "acme" || req.Variables["name"] != "widgets" || req.Variables["number"] != float64(3) {
+			t.Errorf("variables = %v", req.Variables)
+		}
+		io.WriteString(w, `{"data":{"repository":{"pullRequest":{"reviewThreads":{
+			"pageInfo":{"hasNextPage":false},
+			"nodes":[{"id":"T1","isResolved":true,"comments":{"nodes":[{"databaseId":42}]}}]}}}}}`)
+	}))
+	defer srv.Close()
+
+	threads, err := fetchReviewThreads(newTestGitHubClient(t, srv), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(threads) != 1 || threads[0] != (ghThread{ID: "T1", IsResolved: true, RootID: 42}) {
+		t.Errorf("threads = %+v", threads)
+	}
+}
+
+func TestCreateGHReview_MapsCommentIDs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/repos/acme/widgets/pulls/4/reviews":
+			io.WriteString(w, `{"id": 900}`)
+		case r.Method == "GET" && r.URL.Path == "/repos/acme/widgets/pulls/4/reviews/900/comments":
+			io.WriteString(w, `[{"id": 11}, {"id": 12}]`)
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	comments := []map[string]any{{"path": "a.go", "line": 3}, {"path": "b.go", "line": 8}}
+	ids, reviewID, err := createGHReview(newTestGitHubClient(t, srv), 4, comments, "", "COMMENT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reviewID != 900 || ids["a.go:3"] != 11 || ids["b.go:8"] != 12 {
+		t.Errorf("ids = %v, review = %d", ids, reviewID)
+	}
+}
+
+func TestServerGitHubAPI_HonoursAPIURL(t *testing.T) {
+	dir := initTestRepo(t)
+	runGit(t, dir, "remote", "add", "origin", "git@ghe.example.com:acme/widgets.git")
+	t.Setenv("GH_ENTERPRISE_TOKEN", "tok")
+
+	s, _ := newTestServer(t)
+	api, err := s.githubAPI(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, ok := api.(*githubClient); !ok || c.baseURL != "https://ghe.example.com/api/v3" || c.owner != "acme" || c.repo != "widgets" {
+		t.Errorf("api = %#v, want the enterprise API for acme/widgets", api)
+	}
+
+	s.githubAPIURL = "http://127.0.0.1:1"
+	api, err = s.githubAPI(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, ok := api.(*githubClient); !ok || c.baseURL != "http://127.0.0.1:1" || c.graphQLURL != "http://127.0.0.1:1/graphql" {
+		t.Errorf("api = %#v, want githubAPIURL to win", api)
+	}
+}
+
+func TestGitHubToken_OtherHostsNeverGetGitHubToken(t *testing.T) {
+	t.Setenv("PATH", "") // no gh login
+	t.Setenv("GITHUB_TOKEN", "dotcom-token")
+	t.Setenv("GH_TOKEN", "dotcom-token")
+	t.Setenv("GH_ENTERPRISE_TOKEN", "")
+	t.Setenv("GITHUB_ENTERPRISE_TOKEN", "")
+	cfg := Config{GitHubToken: "dotcom-token"}
+
+	for _, host := range []string{"git.example.com", "ghe.example.com"} {
+		if got := githubToken(host, cfg); got != "" {
+			t.Errorf("%s got %q, want no token", host, got)
+		}
+	}
+	if got := githubToken("github.com", cfg); got != "dotcom-token" {
+		t.Errorf("github.com got %q", got)
+	}
+
+	t.Setenv("GH_ENTERPRISE_TOKEN", "ghe-token")
+	if got := githubToken("ghe.example.com", cfg); got != "ghe-token" {
+		t.Errorf("enterprise host got %q, want GH_ENTERPRISE_TOKEN", got)
+	}
+	t.Setenv("GH_ENTERPRISE_TOKEN", "")
+	cfg.GitHubAPIURL = "https://ghe.example.com/api/v3"
+	if got := githubToken("ghe.example.com", cfg); got != "dotcom-token" {
+		t.Errorf("with github_api_url got %q, want github_token", got)
+	}
+}
+
+func TestNewGitHubAPI_UnknownHostGetsNoGitHubToken(t *testing.T) {
+	dir := initTestRepo(t)
+	runGit(t, dir, "remote", "add", "origin", "https://git.example.com/acme/widgets.git")
+	t.Setenv("GITHUB_TOKEN", "dotcom-token")
+	t.Setenv("GH_TOKEN", "")
+	t.Setenv("GH_ENTERPRISE_TOKEN", "")
+	t.Setenv("GITHUB_ENTERPRISE_TOKEN", "")
+
+	api, _ := newGitHubAPI(dir, Config{})
+	if c, ok := api.(*githubClient); ok && c.token == "dotcom-token" {
+		t.Errorf("GITHUB_TOKEN would be sent to %s", c.baseURL)
+	}
+}
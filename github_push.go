@@ -0,0 +1,287 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// prDiff holds the new-side line range of each hunk in a PR's diff, keyed by
+// path. GitHub only accepts inline comments on lines inside a hunk, and
+// file-level comments on files in the diff. A nil prDiff means the diff is
+// unknown and every line is assumed commentable.
+type prDiff map[string][][2]int
+
+// fetchPRDiff returns the hunks of a PR's diff against its base.
+func fetchPRDiff(api githubAPI, prNumber int) (prDiff, error) {
+	out, err := api.raw(fmt.Sprintf("repos/{owner}/{repo}/pulls/%d", prNumber), "application/vnd.github.diff")
+	if err != nil {
+		return nil, fmt.Errorf("fetching PR diff: %w", err)
+	}
+	return parsePRDiff(string(out)), nil
+}
+
+// parsePRDiff splits a multi-file unified diff
+// (as served with the diff media type) into per-file hunk ranges. Files without hunks (binary files, pure
+// renames) are kept with no ranges, so they still accept file-level comments.
+func parsePRDiff(diff string) prDiff {
+	d := prDiff{}
+	var path string
+	var inHunks bool // past the file header; "+++"/"---" lines are content
+	var section strings.Builder
+	flush := func() {
+		if path == "" {
+			return
+		}
+		ranges := d[path]
+		for _, h := range ParseUnifiedDiff(section.String()) {
+			if h.NewCount > 0 {
+				ranges = append(ranges, [2]int{h.NewStart, h.NewStart + h.NewCount - 1})
+			}
+		}
+		d[path] = ranges
+	}
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			flush()
+			section.Reset()
+			inHunks = false
+			// "diff --git a/x b/y": the new path, refined by "+++ b/y" below.
+			path = ""
+			if i := strings.LastIndex(line, " b/"); i >= 0 {
+				path = line[i+3:]
+			}
+			if path != "" {
+				d[path] = nil
+			}
+		case strings.HasPrefix(line, "@@"):
+			inHunks = true
+			section.WriteString(line)
+			section.WriteByte('\n')
+		case !inHunks && strings.HasPrefix(line, "+++ b/"):
+			path = strings.TrimPrefix(line, "+++ b/")
+			d[path] = nil
+		case !inHunks && (strings.HasPrefix(line, "+++ ") || strings.HasPrefix(line, "--- ")):
+			// "+++ /dev/null" for deletions; keep the header path
+		default:
+			section.WriteString(line)
+			section.WriteByte('\n')
+		}
+	}
+	flush()
+	return d
+}
+
+func (d prDiff) hasFile(path string) bool {
+	if d == nil {
+		return true
+	}
+	_, ok := d[path]
+	return ok
+}
+
+// covers reports whether lines start..end fall inside a single hunk, which
+// GitHub requires for a (multi-line) inline comment.
+func (d prDiff) covers(path string, start, end int) bool {
+	if d == nil {
+		return true
+	}
+	for _, r := range d[path] {
+		if start >= r[0] && end <= r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// ghFileComment is a comment posted on a whole file (subject_type=file).
+type ghFileComment struct {
+	CommentID string // crit comment ID
+	Path      string
+	Body      string
+}
+
+// ghBodyEntry is a comment carried in the review's top-level body because
+// GitHub has nowhere to anchor it.
+type ghBodyEntry struct {
+	CommentID string
+	Text      string
+}
+
+// ghPushPlan is what `crit push` posts to a PR.
+type ghPushPlan struct {
+	Comments     []map[string]any // inline comments for the "create review" API
+	FileComments []ghFileComment
+	BodyEntries  []ghBodyEntry
+}
+
+func (p ghPushPlan) empty() bool {
+	return len(p.Comments) == 0 && len(p.FileComments) == 0 && len(p.BodyEntries) == 0
+}
+
+// reviewBody joins the --message text with the body entries.
+func (p ghPushPlan) reviewBody(message string) string {
+	var parts []string
+	if message != "" {
+		parts = append(parts, message)
+	}
+	for _, e := range p.BodyEntries {
+		parts = append(parts, e.Text)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// planGHPush decides how each new unresolved comment is posted:
+//   - line comments inside a diff hunk become inline comments;
+//   - line comments outside the hunks, and file-scope comments, become
+//     file-level comments when the file is in the PR;
+//   - comments on files outside the PR, and review-scope comments, are
+//     quoted into the review body.
+//
+// Suggestion blocks only work inline, so they're demoted to plain code
+// blocks in the other two cases.
+func planGHPush(cj CritJSON, diff prDiff) ghPushPlan {
+	var plan ghPushPlan
+	for _, path := range slices.Sorted(maps.Keys(cj.Files)) {
+		for _, c := range cj.Files[path].Comments {
+			if c.Resolved {
+				continue // don't post resolved comments
+			}
+			if c.GitHubID != 0 || c.GitHubReviewID != 0 {
+				continue // already pushed
+			}
+			fileScope := c.Scope == "file" || c.EndLine == 0
+			switch {
+			case !fileScope && diff.covers(path, c.StartLine, c.EndLine):
+				comment := map[string]any{
+					"path": path,
+					"line": c.EndLine,
+					"side": "RIGHT",
+					"body": c.Body,
+				}
+				if c.StartLine != c.EndLine {
+					comment["start_line"] = c.StartLine
+					comment["start_side"] = "RIGHT"
+				}
+				plan.Comments = append(plan.Comments, comment)
+			case diff.hasFile(path):
+				body := demoteSuggestion(c.Body)
+				if !fileScope {
+					body = fmt.Sprintf("**%s** (outside the diff)\n\n%s", lineLabel(c.StartLine, c.EndLine), body)
+				}
+				plan.FileComments = append(plan.FileComments, ghFileComment{CommentID: c.ID, Path: path, Body: body})
+			default:
+				heading := "`" + path + "`"
+				if !fileScope {
+					heading += " " + strings.ToLower(lineLabel(c.StartLine, c.EndLine))
+				}
+				plan.BodyEntries = append(plan.BodyEntries, ghBodyEntry{CommentID: c.ID, Text: "**" + heading + "**\n\n" + quoteMarkdown(demoteSuggestion(c.Body))})
+			}
+		}
+	}
+	for _, c := range cj.ReviewComments {
+		if c.Resolved || c.GitHubID != 0 || c.GitHubReviewID != 0 {
+			continue
+		}
+		plan.BodyEntries = append(plan.BodyEntries, ghBodyEntry{CommentID: c.ID, Text: c.Body})
+	}
+	return plan
+}
+
+func lineLabel(start, end int) string {
+	if start == end || start == 0 {
+		return fmt.Sprintf("Line %d", end)
+	}
+	return fmt.Sprintf("Lines %d-%d", start, end)
+}
+
+// quoteMarkdown prefixes every line of s with "> ".
+func quoteMarkdown(s string) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimRight("> "+l, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// fetchPRHeadSHA returns the commit a PR's head points at.
+func fetchPRHeadSHA(api githubAPI, prNumber int) (string, error) {
+	var pr ghPull
+	if err := api.call("GET", fmt.Sprintf("repos/{owner}/{repo}/pulls/%d", prNumber), nil, &pr); err != nil {
+		return "", fmt.Errorf("fetching PR head: %w", err)
+	}
+	return pr.Head.SHA, nil
+}
+
+// postGHFileComments posts file-level comments and returns crit comment ID ->
+// GitHub ID for the ones that succeeded.
+func postGHFileComments(api githubAPI, prNumber int, headSHA string, comments []ghFileComment) map[string]int64 {
+	ids := make(map[string]int64)
+	if len(comments) == 0 {
+		return ids
+	}
+	if headSHA == "" {
+		fmt.Fprintf(os.Stderr, "Warning: skipping %d file-level comments: PR head unknown\n", len(comments))
+		return ids
+	}
+	for _, fc := range comments {
+		id, err := postGHPRComment(api, prNumber, map[string]any{
+			"body":         fc.Body,
+			"commit_id":    headSHA,
+			"path":         fc.Path,
+			"subject_type": "file",
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to post file comment on %s: %v\n", fc.Path, err)
+			continue
+		}
+		ids[fc.CommentID] = id
+	}
+	if len(ids) > 0 {
+		fmt.Printf("Posted %d file-level comments\n", len(ids))
+	}
+	return ids
+}
+
+// recordGHPushExtras writes back what planGHPush routed around inline
+// comments: GitHub IDs of file-level comments, and the review that carried
+// body entries (so they aren't posted again). The review itself is recorded
+// even without body entries, so pulling doesn't import it.
+func recordGHPushExtras(critPath string, fileIDs map[string]int64, reviewID int64, bodyEntries []ghBodyEntry) error {
+	if len(fileIDs) == 0 && reviewID == 0 {
+		return nil
+	}
+	cj, err := loadCritJSON(critPath)
+	if err != nil {
+		return err
+	}
+	if reviewID != 0 && !slices.Contains(cj.GitHubReviewIDs, reviewID) {
+		cj.GitHubReviewIDs = append(cj.GitHubReviewIDs, reviewID)
+	}
+	inBody := make(map[string]bool, len(bodyEntries))
+	for _, e := range bodyEntries {
+		inBody[e.CommentID] = true
+	}
+	mark := func(c *Comment) {
+		if id, ok := fileIDs[c.ID]; ok && c.GitHubID == 0 {
+			c.GitHubID = id
+			c.RemoteResolved = boolPtr(false)
+		}
+		if reviewID != 0 && inBody[c.ID] {
+			c.GitHubReviewID = reviewID
+		}
+	}
+	for path, cf := range cj.Files {
+		for i := range cf.Comments {
+			mark(&cf.Comments[i])
+		}
+		cj.Files[path] = cf
+	}
+	for i := range cj.ReviewComments {
+		mark(&cj.ReviewComments[i])
+	}
+	return saveCritJSON(critPath, cj)
+}
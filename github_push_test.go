@@ -0,0 +1,187 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testPRDiff = `diff --git a/main.go b/main.go
+index 111..222 100644
+--- a/main.go
++++ b/main.go
+@@ -8,6 +8,8 @@ func main() {
+ 	a := 1
+ 	b := 2
++	c := 3
++	d := 4
+ 	e := 5
+ 	f := 6
+ 	g := 7
+@@ -40,3 +42,4 @@ func helper() {
+ 	x := 1
++--- not a header
+ 	y := 2
+ 	z := 3
+diff --git a/old.go b/old.go
+deleted file mode 100644
+index 333..000
+--- a/old.go
++++ /dev/null
+@@ -1,2 +0,0 @@
+-package main
+-var x = 1
+diff --git a/logo.png b/logo.png
+new file mode 100644
+index 000..444
+Binary files /dev/null and b/logo.png differ
+`
+
+func TestParsePRDiff(t *testing.T) {
+	d := parsePRDiff(testPRDiff)
+	if got := d["main.go"]; len(got) != 2 || got[0] != [2]int{8, 15} || got[1] != [2]int{42, 45} {
+		t.Errorf("main.go ranges = %v, want [[8 15] [42 45]]", got)
+	}
+	for _, path := range []string{"old.go", "logo.png"} {
+		if !d.hasFile(path) || len(d[path]) != 0 {
+			t.Errorf("%s: hasFile=%v ranges=%v, want present with no ranges", path, d.hasFile(path), d[path])
+		}
+	}
+	if d.hasFile("other.go") {
+		t.Error("other.go is not in the diff")
+	}
+}
+
+func TestPRDiffCovers(t *testing.T) {
+	d := parsePRDiff(testPRDiff)
+	tests := []struct {
+		start, end int
+		want       bool
+	}{
+		{10, 10, true},
+		{8, 15, true},
+		{14, 16, false}, // runs past the hunk
+		{15, 42, false}, // spans two hunks
+		{30, 30, false},
+	}
+	for _, tt := range tests {
+		if got := d.covers("main.go", tt.start, tt.end); got != tt.want {
+			t.Errorf("covers(%d, %d) = %v, want %v", tt.start, tt.end, got, tt.want)
+		}
+	}
+	if !prDiff(nil).covers("any.go", 1, 99) {
+		t.Error("a nil prDiff should cover every line")
+	}
+}
+
+func TestPlanGHPush(t *testing.T) {
+	cj := CritJSON{
+		Files: map[string]CritJSONFile{
+			"main.go": {Comments: []Comment{
+				{ID: "inline", StartLine: 10, EndLine: 11, Body: "in the hunk"},
+				{ID: "outside", StartLine: 30, EndLine: 30, Body: "not in the diff"},
+				{ID: "file", Scope: "file", Body: "whole file"},
+				{ID: "done", StartLine: 10, EndLine: 10, Body: "resolved", Resolved: true},
+				{ID: "pushed", StartLine: 10, EndLine: 10, Body: "pushed", GitHubID: 9},
+			}},
+			"untouched.go": {Comments: []Comment{
+				{ID: "elsewhere", StartLine: 3, EndLine: 4, Body: "line one\nline two"},
+			}},
+		},
+		ReviewComments: []Comment{
+			{ID: "r1", Scope: "review", Body: "overall looks good"},
+			{ID: "r2", Scope: "review", Body: "already posted", GitHubReviewID: 5},
+		},
+	}
+	plan := planGHPush(cj, parsePRDiff(testPRDiff))
+
+	if len(plan.Comments) != 1 || plan.Comments[0]["start_line"] != 10 || plan.Comments[0]["line"] != 11 {
+		t.Errorf("inline comments = %v, want the one in the hunk", plan.Comments)
+	}
+	if len(plan.FileComments) != 2 {
+		t.Fatalf("file comments = %+v, want 2", plan.FileComments)
+	}
+	if fc := plan.FileComments[0]; fc.CommentID != "outside" || !strings.Contains(fc.Body, "Line 30") || !strings.HasSuffix(fc.Body, "not in the diff") {
+		t.Errorf("outside-hunk file comment = %+v", fc)
+	}
+	if fc := plan.FileComments[1]; fc.CommentID != "file" || fc.Body != "whole file" {
+		t.Errorf("file-scope comment = %+v", fc)
+	}
+	if len(plan.BodyEntries) != 2 {
+		t.Fatalf("body entries = %+v, want 2", plan.BodyEntries)
+	}
+	want := "**`untouched.go` lines 3-4**\n\n> line one\n> line two"
+	if plan.BodyEntries[0].Text != want {
+		t.Errorf("body entry = %q, want %q", plan.BodyEntries[0].Text, want)
+	}
+	if got := plan.reviewBody("Round 2"); got != "Round 2\n\n"+want+"\n\noverall looks good" {
+		t.Errorf("review body = %q", got)
+	}
+}
+
+func TestPlanGHPush_EmptyWhenNothingNew(t *testing.T) {
+	cj := CritJSON{Files: map[string]CritJSONFile{
+		"main.go": {Comments: []Comment{{ID: "c1", StartLine: 1, EndLine: 1, Body: "x", Resolved: true}}},
+	}}
+	if plan := planGHPush(cj, nil); !plan.empty() {
+		t.Errorf("plan = %+v, want empty", plan)
+	}
+}
+
+func TestRecordGHPushExtras(t *testing.T) {
+	critPath := filepath.Join(t.TempDir(), "review.json")
+	cj := CritJSON{
+		Files: map[string]CritJSONFile{
+			"main.go": {Comments: []Comment{
+				{ID: "file", Scope: "file", Body: "whole file"},
+				{ID: "elsewhere", StartLine: 3, EndLine: 3, Body: "x"},
+			}},
+		},
+		ReviewComments: []Comment{{ID: "r1", Scope: "review", Body: "overall"}},
+	}
+	if err := saveCritJSON(critPath, cj); err != nil {
+		t.Fatal(err)
+	}
+	entries := []ghBodyEntry{{CommentID: "elsewhere"}, {CommentID: "r1"}}
+	if err := recordGHPushExtras(critPath, map[string]int64{"file": 77}, 500, entries); err != nil {
+		t.Fatal(err)
+	}
+	got, err := loadCritJSON(critPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	comments := got.Files["main.go"].Comments
+	if comments[0].GitHubID != 77 || comments[0].RemoteResolved == nil {
+		t.Errorf("file comment = %+v, want GitHub ID 77 and a sync record", comments[0])
+	}
+	if comments[1].GitHubReviewID != 500 || got.ReviewComments[0].GitHubReviewID != 500 {
+		t.Error("body entries should record the review that carried them")
+	}
+	if plan := planGHPush(got, nil); !plan.empty() {
+		t.Errorf("after recording, plan = %+v, want nothing left to push", plan)
+	}
+	if len(got.GitHubReviewIDs) != 1 || got.GitHubReviewIDs[0] != 500 {
+		t.Errorf("GitHubReviewIDs = %v, want [500]", got.GitHubReviewIDs)
+	}
+}
+
+func TestRecordGHPushExtras_ReviewWithoutBodyEntries(t *testing.T) {
+	critPath := filepath.Join(t.TempDir(), "review.json")
+	if err := saveCritJSON(critPath, CritJSON{Files: map[string]CritJSONFile{}}); err != nil {
+		t.Fatal(err)
+	}
+	// crit push --approve -m "LGTM": a review with nothing from crit in its body.
+	if err := recordGHPushExtras(critPath, nil, 600, nil); err != nil {
+		t.Fatal(err)
+	}
+	got, err := loadCritJSON(critPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.GitHubReviewIDs) != 1 || got.GitHubReviewIDs[0] != 600 {
+		t.Fatalf("GitHubReviewIDs = %v, want [600]", got.GitHubReviewIDs)
+	}
+	if added := mergeGHReviews(&got, []ghReview{ghTestReview(600, "me", "APPROVED", "LGTM", "2026-01-01T00:00:00Z")}, nil, userNameCache{}); added != 0 {
+		t.Errorf("pull imported crit's own review: %+v", got.ReviewComments)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ghReview is a submitted PR review. Body is the top-level text; inline
+// comments made in the review arrive separately as ghComments.
+type ghReview struct {
+	ID   int64  `json:"id"`
+	Body string `json:"body"`
+	// State is APPROVED, CHANGES_REQUESTED, COMMENTED, DISMISSED or PENDING.
+	State string `json:"state"`
+	User  struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	SubmittedAt string `json:"submitted_at"`
+}
+
+// ghIssueComment is a comment on the PR's conversation tab.
+type ghIssueComment struct {
+	ID   int64  `json:"id"`
+	Body string `json:"body"`
+	User struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	CreatedAt string `json:"created_at"`
+}
+
+func fetchPRReviews(api githubAPI, prNumber int) ([]ghReview, error) {
+	return ghList[ghReview](api, fmt.Sprintf("repos/{owner}/{repo}/pulls/%d/reviews", prNumber))
+}
+
+func fetchPRIssueComments(api githubAPI, prNumber int) ([]ghIssueComment, error) {
+	return ghList[ghIssueComment](api, fmt.Sprintf("repos/{owner}/{repo}/issues/%d/comments", prNumber))
+}
+
+// pullGHReviews fetches a PR's reviews and conversation comments and merges
+// them into cj.
+func pullGHReviews(api githubAPI, prNumber int, cj *CritJSON) (int, error) {
+	reviews, err := fetchPRReviews(api, prNumber)
+	if err != nil {
+		return 0, err
+	}
+	issueComments, err := fetchPRIssueComments(api, prNumber)
+	if err != nil {
+		return 0, err
+	}
+	names := make(userNameCache)
+	for _, r := range reviews {
+		names.fetch(api, r.User.Login)
+	}
+	for _, ic := range issueComments {
+		names.fetch(api, ic.User.Login)
+	}
+	return mergeGHReviews(cj, reviews, issueComments, names), nil
+}
+
+// ghReviewStateText stands in for the body of a review submitted without one.
+var ghReviewStateText = map[string]string{
+	"APPROVED":          "Approved these changes.",
+	"CHANGES_REQUESTED": "Requested changes.",
+}
+
+// mergeGHReviews imports review bodies and conversation comments as
+// review-level comments. Reviews crit pushed itself (CritJSON.GitHubReviewIDs)
+// or already has a comment for (GitHubReviewID on any comment, including
+// file comments that went into a review body) are skipped; conversation
+// comments are matched by GitHubID. Reviews that only carry inline comments
+// have an empty body and are skipped unless they approve or request changes.
+func mergeGHReviews(cj *CritJSON, reviews []ghReview, issueComments []ghIssueComment, names userNameCache) int {
+	seenReviews := make(map[int64]bool)
+	for _, id := range cj.GitHubReviewIDs {
+		seenReviews[id] = true
+	}
+	for _, cf := range cj.Files {
+		for _, c := range cf.Comments {
+			if c.GitHubReviewID != 0 {
+				seenReviews[c.GitHubReviewID] = true
+			}
+		}
+	}
+	seenComments := make(map[int64]bool)
+	for _, c := range cj.ReviewComments {
+		if c.GitHubReviewID != 0 {
+			seenReviews[c.GitHubReviewID] = true
+		}
+		if c.GitHubID != 0 {
+			seenComments[c.GitHubID] = true
+		}
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	var added []Comment
+	for _, r := range reviews {
+		if seenReviews[r.ID] || r.State == "PENDING" {
+			continue
+		}
+		body := strings.TrimSpace(r.Body)
+		if body == "" {
+			body = ghReviewStateText[r.State]
+		}
+		if body == "" {
+			continue
+		}
+		added = append(added, Comment{
+			ID: randomReviewCommentID(), Body: body, Author: names.lookup(r.User.Login),
+			Scope: "review", CreatedAt: r.SubmittedAt, UpdatedAt: now,
+			GitHubReviewID: r.ID, GitHubReviewState: r.State,
+		})
+	}
+	for _, ic := range issueComments {
+		if seenComments[ic.ID] || strings.TrimSpace(ic.Body) == "" {
+			continue
+		}
+		added = append(added, Comment{
+			ID: randomReviewCommentID(), Body: ic.Body, Author: names.lookup(ic.User.Login),
+			Scope: "review", CreatedAt: ic.CreatedAt, UpdatedAt: now, GitHubID: ic.ID,
+		})
+	}
+	if len(added) == 0 {
+		return 0
+	}
+	sort.SliceStable(added, func(i, j int) bool { return added[i].CreatedAt < added[j].CreatedAt })
+	cj.ReviewComments = append(cj.ReviewComments, added...)
+	cj.UpdatedAt = now
+	return len(added)
+}
+
+// githubChangesRequested returns the reviewers whose latest GitHub review
+// requests changes, where that review is still unresolved in crit.
+func githubChangesRequested(reviewComments []Comment) []string {
+	latest := make(map[string]Comment)
+	for _, c := range reviewComments {
+		switch c.GitHubReviewState {
+		case "APPROVED", "CHANGES_REQUESTED", "DISMISSED":
+			if prev, ok := latest[c.Author]; !ok || c.CreatedAt >= prev.CreatedAt {
+				latest[c.Author] = c
+			}
+		}
+	}
+	var authors []string
+	for author, c := range latest {
+		if c.GitHubReviewState == "CHANGES_REQUESTED" && !c.Resolved {
+			authors = append(authors, author)
+		}
+	}
+	sort.Strings(authors)
+	return authors
+}
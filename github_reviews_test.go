@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func ghTestReview(id int64, user, state, body, at string) ghReview {
+	r := ghReview{ID: id, State: state, Body: body, SubmittedAt: at}
+	r.User.Login = user
+	return r
+}
+
+func TestMergeGHReviews(t *testing.T) {
+	reviews := []ghReview{
+		ghTestReview(10, "alice", "CHANGES_REQUESTED", "Please also update the docs", "2026-01-02T00:00:00Z"),
+		ghTestReview(11, "bob", "COMMENTED", "", "2026-01-03T00:00:00Z"), // inline comments only
+		ghTestReview(12, "bob", "APPROVED", "", "2026-01-04T00:00:00Z"),
+		ghTestReview(13, "carol", "PENDING", "draft", "2026-01-05T00:00:00Z"),
+		ghTestReview(14, "me", "COMMENTED", "pushed by crit", "2026-01-01T00:00:00Z"),
+	}
+	ic := ghIssueComment{ID: 20, Body: "Can we split this PR?", CreatedAt: "2026-01-01T12:00:00Z"}
+	ic.User.Login = "dave"
+
+	cj := CritJSON{
+		Files:          map[string]CritJSONFile{},
+		ReviewComments: []Comment{{ID: "r0", Scope: "review", Body: "local", GitHubReviewID: 14}},
+	}
+	names := userNameCache{"alice": "Alice", "bob": "bob", "dave": "dave"}
+	if added := mergeGHReviews(&cj, reviews, []ghIssueComment{ic}, names); added != 3 {
+		t.Errorf("added = %d, want 3", added)
+	}
+
+	got := cj.ReviewComments[1:]
+	if len(got) != 3 {
+		t.Fatalf("got %d new review comments, want 3", len(got))
+	}
+	if got[0].GitHubID != 20 || got[0].Scope != "review" || got[0].Author != "dave" {
+		t.Errorf("conversation comment = %+v", got[0])
+	}
+	if got[1].GitHubReviewID != 10 || got[1].GitHubReviewState != "CHANGES_REQUESTED" || got[1].Author != "Alice" || got[1].Body != "Please also update the docs" {
+		t.Errorf("changes-requested review = %+v", got[1])
+	}
+	if got[2].GitHubReviewState != "APPROVED" || got[2].Body != ghReviewStateText["APPROVED"] {
+		t.Errorf("bodiless approval = %+v", got[2])
+	}
+
+	if added := mergeGHReviews(&cj, reviews, []ghIssueComment{ic}, names); added != 0 {
+		t.Errorf("second merge added %d, want 0", added)
+	}
+}
+
+func TestMergeGHReviews_SkipsReviewsCritPushed(t *testing.T) {
+	reviews := []ghReview{
+		ghTestReview(30, "me", "APPROVED", "Ship it", "2026-01-01T00:00:00Z"),                         // crit push --approve -m
+		ghTestReview(31, "me", "COMMENTED", "**main.go:3** outside the diff", "2026-01-02T00:00:00Z"), // body of file comments
+	}
+	cj := CritJSON{
+		Files: map[string]CritJSONFile{"main.go": {Comments: []Comment{
+			{ID: "c1", StartLine: 3, EndLine: 3, Body: "outside the diff", GitHubReviewID: 31},
+		}}},
+		GitHubReviewIDs: []int64{30},
+	}
+	if added := mergeGHReviews(&cj, reviews, nil, userNameCache{}); added != 0 {
+		t.Errorf("added = %d, want crit's own reviews skipped: %+v", added, cj.ReviewComments)
+	}
+}
+
+func TestGitHubChangesRequested(t *testing.T) {
+	comments := []Comment{
+		{Author: "alice", GitHubReviewState: "CHANGES_REQUESTED", CreatedAt: "2026-01-01"},
+		{Author: "bob", GitHubReviewState: "CHANGES_REQUESTED", CreatedAt: "2026-01-01"},
+		{Author: "bob", GitHubReviewState: "APPROVED", CreatedAt: "2026-01-02"},
+		{Author: "carol", GitHubReviewState: "CHANGES_REQUESTED", CreatedAt: "2026-01-01", Resolved: true},
+		{Author: "dave", GitHubReviewState: "CHANGES_REQUESTED", CreatedAt: "2026-01-01"},
+		{Author: "dave", GitHubReviewState: "COMMENTED", CreatedAt: "2026-01-03"},
+	}
+	got := githubChangesRequested(comments)
+	if strings.Join(got, ",") != "alice,dave" {
+		t.Errorf("got %v, want [alice dave]", got)
+	}
+}
+
+func TestHandleFinish_MentionsGitHubChangesRequested(t *testing.T) {
+	srv, session := newTestServer(t)
+	session.mu.Lock()
+	session.reviewComments = append(session.reviewComments, Comment{
+		ID: "r1", Scope: "review", Body: "Please also update the docs", Author: "alice",
+		GitHubReviewID: 10, GitHubReviewState: "CHANGES_REQUESTED",
+	})
+	session.mu.Unlock()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/finish", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	var resp map[string]any
+	json.NewDecoder(w.Body).Decode(&resp)
+	prompt, _ := resp["prompt"].(string)
+	if !strings.Contains(prompt, "alice requested changes on the GitHub pull request") {
+		t.Errorf("prompt should mention the requested changes, got: %s", prompt)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// reviewStatusName is the check run name and commit status context crit
+// publishes under; branch protection rules refer to it.
+const reviewStatusName = "crit/review"
+
+// reviewOutcome summarizes a review file for a check run or commit status.
+type reviewOutcome struct {
+	Round      int
+	Unresolved []string // one line per unresolved comment, e.g. "main.go:12 — rename this"
+}
+
+func (o reviewOutcome) approved() bool { return len(o.Unresolved) == 0 }
+
+// reviewOutcomeFrom collects the unresolved comments of a review file.
+func reviewOutcomeFrom(cj CritJSON) reviewOutcome {
+	o := reviewOutcome{Round: cj.ReviewRound}
+	if o.Round == 0 {
+		o.Round = 1
+	}
+	paths := make([]string, 0, len(cj.Files))
+	for p := range cj.Files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		for _, c := range cj.Files[p].Comments {
+			if c.Resolved {
+				continue
+			}
+			where := p
+			if c.EndLine > 0 {
+				where = fmt.Sprintf("%s:%d", p, c.EndLine)
+			}
+			o.Unresolved = append(o.Unresolved, where+" — "+firstLine(c.Body))
+		}
+	}
+	for _, c := range cj.ReviewComments {
+		if !c.Resolved {
+			o.Unresolved = append(o.Unresolved, "(review) — "+firstLine(c.Body))
+		}
+	}
+	return o
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return truncateStr(line, 100)
+}
+
+// title is the one-line result, also used as the commit status description.
+func (o reviewOutcome) title() string {
+	if o.approved() {
+		return fmt.Sprintf("Round %d: approved", o.Round)
+	}
+	noun := "comments"
+	if len(o.Unresolved) == 1 {
+		noun = "comment"
+	}
+	return fmt.Sprintf("Round %d: changes requested, %d unresolved %s", o.Round, len(o.Unresolved), noun)
+}
+
+// summary is the check run body: the unresolved comments as a list.
+func (o reviewOutcome) summary() string {
+	if o.approved() {
+		return fmt.Sprintf("All comments from review round %d are resolved.", o.Round)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Unresolved comments after review round %d:\n\n", o.Round)
+	for _, u := range o.Unresolved {
+		fmt.Fprintf(&b, "- %s\n", u)
+	}
+	return b.String()
+}
+
+// checkRunPayload is the body for POST /repos/{owner}/{repo}/check-runs.
+func (o reviewOutcome) checkRunPayload(headSHA string) map[string]any {
+	conclusion := "success"
+	if !o.approved() {
+		conclusion = "failure"
+	}
+	return map[string]any{
+		"name":       reviewStatusName,
+		"head_sha":   headSHA,
+		"status":     "completed",
+		"conclusion": conclusion,
+		"output": map[string]any{
+			"title":   o.title(),
+			"summary": o.summary(),
+		},
+	}
+}
+
+// commitStatusPayload is the body for POST /repos/{owner}/{repo}/statuses/{sha}.
+func (o reviewOutcome) commitStatusPayload() map[string]any {
+	state := "success"
+	if !o.approved() {
+		state = "failure"
+	}
+	return map[string]any{
+		"state":       state,
+		"context":     reviewStatusName,
+		"description": truncateStr(o.title(), 140), // GitHub's limit
+	}
+}
+
+// publishReviewStatus reports the outcome on the commit checked out in dir
+// ("" for the working directory), which api must point at. It creates a check run, which can carry
+// the list of unresolved comments; GitHub only lets apps create check runs,
+// so with a personal token it falls back to a commit status.
+func publishReviewStatus(api githubAPI, dir string, o reviewOutcome) (kind string, err error) {
+	rev := exec.Command("git", "rev-parse", "HEAD")
+	rev.Dir = dir
+	out, err := rev.Output()
+	if err != nil {
+		return "", fmt.Errorf("resolving HEAD: %w", err)
+	}
+	headSHA := strings.TrimSpace(string(out))
+
+	if err := api.call("POST", "repos/{owner}/{repo}/check-runs", o.checkRunPayload(headSHA), nil); err == nil {
+		return "check run", nil
+	}
+	if err := api.call("POST", "repos/{owner}/{repo}/statuses/"+headSHA, o.commitStatusPayload(), nil); err != nil {
+		return "", err
+	}
+	return "commit status", nil
+}
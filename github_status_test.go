@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReviewOutcomeFrom(t *testing.T) {
+	cj := CritJSON{
+		ReviewRound: 2,
+		Files: map[string]CritJSONFile{
+			"b.go": {Comments: []Comment{{ID: "c2", Scope: "file", Body: "split this file"}}},
+			"a.go": {Comments: []Comment{
+				{ID: "c1", StartLine: 3, EndLine: 4, Body: "rename this\nit's confusing"},
+				{ID: "c3", StartLine: 9, EndLine: 9, Body: "done", Resolved: true},
+			}},
+		},
+		ReviewComments: []Comment{{ID: "r1", Scope: "review", Body: "add tests"}},
+	}
+	o := reviewOutcomeFrom(cj)
+	want := []string{"a.go:4 — rename this", "b.go — split this file", "(review) — add tests"}
+	if strings.Join(o.Unresolved, "|") != strings.Join(want, "|") {
+		t.Errorf("unresolved = %q, want %q", o.Unresolved, want)
+	}
+	if got := o.title(); got != "Round 2: changes requested, 3 unresolved comments" {
+		t.Errorf("title = %q", got)
+	}
+	if s := o.summary(); !strings.Contains(s, "- a.go:4 — rename this\n") {
+		t.Errorf("summary should list unresolved comments, got %q", s)
+	}
+
+	run := o.checkRunPayload("abc123")
+	if run["conclusion"] != "failure" || run["head_sha"] != "abc123" || run["name"] != reviewStatusName {
+		t.Errorf("check run = %v", run)
+	}
+	if st := o.commitStatusPayload(); st["state"] != "failure" || st["context"] != reviewStatusName {
+		t.Errorf("commit status = %v", st)
+	}
+}
+
+func TestReviewOutcome_Approved(t *testing.T) {
+	o := reviewOutcomeFrom(CritJSON{Files: map[string]CritJSONFile{
+		"a.go": {Comments: []Comment{{ID: "c1", EndLine: 1, Body: "ok", Resolved: true}}},
+	}})
+	if !o.approved() || o.title() != "Round 1: approved" {
+		t.Errorf("outcome = %+v, title %q", o, o.title())
+	}
+	if o.checkRunPayload("x")["conclusion"] != "success" || o.commitStatusPayload()["state"] != "success" {
+		t.Error("an approved review should publish success")
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Crit's "Suggest" button writes the same ```suggestion fence GitHub uses,
+// so a suggestion survives the round trip as long as it lands on the lines
+// it replaces. These helpers check that against the PR head on push and
+// normalize GitHub's bodies on pull.
+
+const suggestionFence = "```suggestion"
+
+// hasSuggestion reports whether body contains a suggestion block.
+func hasSuggestion(body string) bool {
+	for _, line := range strings.Split(body, "\n") {
+		if strings.TrimSpace(strings.TrimSuffix(line, "\r")) == suggestionFence {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeGHSuggestion rewrites a pulled GitHub body with a suggestion block
+// into crit's form: LF line endings and a bare ```suggestion opener. Bodies
+// without a suggestion are returned unchanged.
+func normalizeGHSuggestion(body string) string {
+	if !hasSuggestion(body) {
+		return body
+	}
+	lines := strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n")
+	for i, l := range lines {
+		if strings.TrimSpace(l) == suggestionFence {
+			lines[i] = suggestionFence
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// demoteSuggestion turns suggestion blocks into plain code blocks, for
+// comments GitHub couldn't apply them from.
+func demoteSuggestion(body string) string {
+	if !hasSuggestion(body) {
+		return body
+	}
+	lines := strings.Split(body, "\n")
+	for i, l := range lines {
+		if strings.TrimSpace(l) == suggestionFence {
+			lines[i] = "```"
+		}
+	}
+	return "Suggested change:\n\n" + strings.Join(lines, "\n")
+}
+
+// alignGHSuggestions checks each new suggestion comment against the PR head
+// before it's pushed. The lines a suggestion replaces (the comment's Anchor,
+// or the working tree lines) must be at the same place in the head version
+// of the file; if they moved, the comment is moved with them, and if they
+// can't be found the suggestion is demoted to a plain code block so GitHub
+// doesn't offer to commit it over the wrong lines.
+//
+// cj is modified in place and must be a copy that isn't saved. The returned
+// map takes "path:line" keys as posted back to the review file's keys, for
+// updateCritJSONWithGitHubIDs.
+func alignGHSuggestions(cj *CritJSON, headLines, localLines func(path string) ([]string, error)) map[string]string {
+	moved := make(map[string]string)
+	for path, cf := range cj.Files {
+		comments := make([]Comment, len(cf.Comments))
+		copy(comments, cf.Comments)
+		for i := range comments {
+			c := &comments[i]
+			if c.Resolved || c.GitHubID != 0 || c.GitHubReviewID != 0 || c.EndLine == 0 || !hasSuggestion(c.Body) {
+				continue
+			}
+			if c.Side == "old" {
+				c.Body = demoteSuggestion(c.Body) // GitHub applies suggestions to the new side only
+				continue
+			}
+			base := c.Anchor
+			if base == "" {
+				if local, err := localLines(path); err == nil && c.StartLine >= 1 && c.EndLine <= len(local) {
+					base = strings.Join(local[c.StartLine-1:c.EndLine], "\n")
+				}
+			}
+			head, err := headLines(path)
+			if base == "" || err != nil {
+				c.Body = demoteSuggestion(c.Body)
+				continue
+			}
+			start, end, drifted := verifyAndCorrectPosition(head, base, c.StartLine, c.EndLine)
+			if drifted != 0 {
+				c.Body = demoteSuggestion(c.Body)
+				continue
+			}
+			if start != c.StartLine || end != c.EndLine {
+				moved[fmt.Sprintf("%s:%d", path, end)] = fmt.Sprintf("%s:%d", path, c.EndLine)
+				c.StartLine, c.EndLine = start, end
+			}
+		}
+		cf.Comments = comments
+		cj.Files[path] = cf
+	}
+	return moved
+}
+
+// escapePathSegments escapes each segment of a slash-separated path for use
+// in a URL, keeping the slashes.
+func escapePathSegments(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+// prHeadLines returns a loader for file contents at the PR head, preferring
+// the local object store and falling back to the contents API.
+func prHeadLines(api githubAPI, headSHA string) func(path string) ([]string, error) {
+	cache := make(map[string][]string)
+	return func(path string) ([]string, error) {
+		if lines, ok := cache[path]; ok {
+			return lines, nil
+		}
+		if headSHA == "" {
+			return nil, fmt.Errorf("PR head unknown")
+		}
+		// "--" stops git from taking the argument as a pathspec, which it
+		// does for paths with glob characters when the commit is missing.
+		out, err := exec.Command("git", "show", headSHA+":"+path, "--").Output()
+		if err != nil {
+			out, err = api.raw(fmt.Sprintf("repos/{owner}/{repo}/contents/%s?ref=%s", escapePathSegments(path), url.QueryEscape(headSHA)), "application/vnd.github.raw")
+			if err != nil {
+				return nil, fmt.Errorf("reading %s at PR head: %w", path, err)
+			}
+		}
+		lines := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+		cache[path] = lines
+		return lines, nil
+	}
+}
+
+// workingTreeLines reads a file relative to the repository root.
+func workingTreeLines(root string) func(path string) ([]string, error) {
+	return func(path string) ([]string, error) {
+		data, err := os.ReadFile(filepath.Join(root, path))
+		if err != nil {
+			return nil, err
+		}
+		return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"), nil
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeGHSuggestion(t *testing.T) {
+	got := normalizeGHSuggestion("Try this:\r\n```suggestion \r\nreturn nil\r\n```")
+	if want := "Try this:\n```suggestion\nreturn nil\n```"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if plain := "no\r\nsuggestion here"; normalizeGHSuggestion(plain) != plain {
+		t.Error("bodies without a suggestion should be unchanged")
+	}
+}
+
+func TestDemoteSuggestion(t *testing.T) {
+	got := demoteSuggestion("```suggestion\nreturn nil\n```")
+	if got != "Suggested change:\n\n```\nreturn nil\n```" {
+		t.Errorf("got %q", got)
+	}
+	if hasSuggestion(got) {
+		t.Error("demoted body should no longer contain a suggestion")
+	}
+}
+
+func TestAlignGHSuggestions(t *testing.T) {
+	local := []string{"package main", "", "func a() {", "\treturn 1", "}"}
+	// At the PR head the function sits two lines lower.
+	head := []string{"package main", "", "// a returns one.", "// It is simple.", "func a() {", "\treturn 1", "}"}
+	sug := "```suggestion\n\treturn 2\n```"
+	cj := CritJSON{Files: map[string]CritJSONFile{
+		"a.go": {Comments: []Comment{
+			{ID: "moved", StartLine: 4, EndLine: 4, Body: sug},
+			{ID: "gone", StartLine: 2, EndLine: 2, Body: sug, Anchor: "not in head"},
+			{ID: "old", StartLine: 4, EndLine: 4, Side: "old", Body: sug},
+			{ID: "plain", StartLine: 1, EndLine: 1, Body: "no suggestion"},
+		}},
+		"missing.go": {Comments: []Comment{
+			{ID: "nohead", StartLine: 1, EndLine: 1, Body: sug, Anchor: "x"},
+		}},
+	}}
+	headLines := func(path string) ([]string, error) {
+		if path == "a.go" {
+			return head, nil
+		}
+		return nil, errors.New("not found")
+	}
+	localLines := func(string) ([]string, error) { return local, nil }
+
+	moved := alignGHSuggestions(&cj, headLines, localLines)
+
+	got := cj.Files["a.go"].Comments
+	if got[0].StartLine != 6 || got[0].EndLine != 6 || got[0].Body != sug {
+		t.Errorf("moved comment = %+v, want lines 6-6 with the suggestion kept", got[0])
+	}
+	if moved["a.go:6"] != "a.go:4" {
+		t.Errorf("moved = %v, want a.go:6 -> a.go:4", moved)
+	}
+	for _, c := range []Comment{got[1], got[2], cj.Files["missing.go"].Comments[0]} {
+		if hasSuggestion(c.Body) || !strings.HasPrefix(c.Body, "Suggested change:") {
+			t.Errorf("%s: body = %q, want the suggestion demoted", c.ID, c.Body)
+		}
+	}
+	if got[3].Body != "no suggestion" {
+		t.Errorf("plain comment changed: %q", got[3].Body)
+	}
+}
+
+func TestPlanGHPush_DemotesSuggestionsOutsideDiff(t *testing.T) {
+	cj := CritJSON{Files: map[string]CritJSONFile{
+		"main.go": {Comments: []Comment{{ID: "c1", StartLine: 30, EndLine: 30, Body: "```suggestion\nx\n```"}}},
+	}}
+	plan := planGHPush(cj, parsePRDiff(testPRDiff))
+	if len(plan.FileComments) != 1 || hasSuggestion(plan.FileComments[0].Body) {
+		t.Errorf("file comments = %+v, want one with the suggestion demoted", plan.FileComments)
+	}
+}
+
+func TestMergeGHComments_NormalizesSuggestion(t *testing.T) {
+	cj := CritJSON{Files: map[string]CritJSONFile{}}
+	gc := ghComment{ID: 1, Path: "a.go", Line: 4, StartLine: 3, Side: "RIGHT", Body: "```suggestion\r\nreturn 2\r\n```"}
+	gc.User.Login = "alice"
+	mergeGHCommentsWithNames(&cj, []ghComment{gc}, userNameCache{"alice": "alice"})
+	c := cj.Files["a.go"].Comments[0]
+	if c.Body != "```suggestion\nreturn 2\n```" || c.StartLine != 3 || c.EndLine != 4 {
+		t.Errorf("comment = %+v", c)
+	}
+}
+
+func TestPRHeadLines_EscapesContentsPath(t *testing.T) {
+	var gotPath, gotRef string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotRef = r.URL.EscapedPath(), r.URL.Query().Get("ref")
+		io.WriteString(w, "line one\n")
+	}))
+	defer srv.Close()
+
+	// The SHA isn't in the local object store, so the contents API is used.
+	load := prHeadLines(newTestGitHubClient(t, srv), "0123456789abcdef0123456789abcdef01234567")
+	lines, err := load("docs/a b#c?%.md")
+	if err != nil || len(lines) != 1 || lines[0] != "line one" {
+		t.Fatalf("lines = %q, %v", lines, err)
+	}
+	if want := "/repos/acme/widgets/contents/docs/a%20b%23c%3F%25.md"; gotPath != want {
+		t.Errorf("path = %s, want %s", gotPath, want)
+	}
+	if gotRef != "0123456789abcdef0123456789abcdef01234567" {
+		t.Errorf("ref = %q, want the head SHA", gotRef)
+	}
+}
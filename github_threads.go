@@ -0,0 +1,293 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// ghThread is a PR review thread from the GraphQL API. RootID is the REST
+// (database) ID of the thread's first comment, which matches Comment.GitHubID.
+type ghThread struct {
+	ID         string
+	IsResolved bool
+	RootID     int64
+}
+
+const reviewThreadsQuery = `query($owner: String!, $name: String!, $number: Int!, $cursor: String) {
+  repository(owner: $owner, name: $name) {
+    pullRequest(number: $number) {
+      reviewThreads(first: 100, after: $cursor) {
+        pageInfo { hasNextPage endCursor }
+        nodes { id isResolved comments(first: 1) { nodes { databaseId } } }
+      }
+    }
+  }
+}`
+
+// fetchReviewThreads returns every review thread on a PR.
+func fetchReviewThreads(api githubAPI, prNumber int) ([]ghThread, error) {
+	var threads []ghThread
+	cursor := ""
+	for {
+		vars := map[string]any{"owner": "{owner}", "name": "{repo}", "number": prNumber}
+		if cursor != "" {
+			vars["cursor"] = cursor
+		}
+		out, err := api.graphQL(reviewThreadsQuery, vars)
+		if err != nil {
+			return nil, fmt.Errorf("fetching review threads: %w", err)
+		}
+		page, next, err := parseReviewThreadsPage(out)
+		if err != nil {
+			return nil, err
+		}
+		threads = append(threads, page...)
+		if next == "" {
+			return threads, nil
+		}
+		cursor = next
+	}
+}
+
+// parseReviewThreadsPage decodes one page of reviewThreadsQuery. next is the
+// cursor for the following page, or "" on the last page.
+func parseReviewThreadsPage(data []byte) (threads []ghThread, next string, err error) {
+	var resp struct {
+		Data struct {
+			Repository struct {
+				PullRequest struct {
+					ReviewThreads struct {
+						PageInfo struct {
+							HasNextPage bool   `json:"hasNextPage"`
+							EndCursor   string `json:"endCursor"`
+						} `json:"pageInfo"`
+						Nodes []struct {
+							ID         string `json:"id"`
+							IsResolved bool   `json:"isResolved"`
+							Comments   struct {
+								Nodes []struct {
+									DatabaseID int64 `json:"databaseId"`
+								} `json:"nodes"`
+							} `json:"comments"`
+						} `json:"nodes"`
+					} `json:"reviewThreads"`
+				} `json:"pullRequest"`
+			} `json:"repository"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, "", fmt.Errorf("parsing review threads: %w", err)
+	}
+	if len(resp.Errors) > 0 {
+		return nil, "", fmt.Errorf("fetching review threads: %s", resp.Errors[0].Message)
+	}
+	rt := resp.Data.Repository.PullRequest.ReviewThreads
+	for _, n := range rt.Nodes {
+		t := ghThread{ID: n.ID, IsResolved: n.IsResolved}
+		if len(n.Comments.Nodes) > 0 {
+			t.RootID = n.Comments.Nodes[0].DatabaseID
+		}
+		threads = append(threads, t)
+	}
+	if rt.PageInfo.HasNextPage {
+		next = rt.PageInfo.EndCursor
+	}
+	return threads, next, nil
+}
+
+// setThreadResolved resolves or unresolves a review thread.
+func setThreadResolved(api githubAPI, threadID string, resolved bool) error {
+	mutation := "resolveReviewThread"
+	if !resolved {
+		mutation = "unresolveReviewThread"
+	}
+	query := fmt.Sprintf(`mutation($id: ID!) { %s(input: {threadId: $id}) { thread { isResolved } } }`, mutation)
+	out, err := api.graphQL(query, map[string]any{"id": threadID})
+	if err != nil {
+		return fmt.Errorf("%s: %w", mutation, err)
+	}
+	var resp struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if json.Unmarshal(out, &resp) == nil && len(resp.Errors) > 0 {
+		return fmt.Errorf("%s: %s", mutation, resp.Errors[0].Message)
+	}
+	return nil
+}
+
+// resolvedAction says how to reconcile one comment's resolved state.
+type resolvedAction int
+
+const (
+	resolvedInSync   resolvedAction = iota // both sides agree
+	resolvedPull                           // GitHub changed since the last sync: update the comment
+	resolvedPush                           // crit changed since the last sync: update the thread
+	resolvedConflict                       // the sides disagree and there is no sync record to say which changed
+)
+
+// resolvedChange is the plan for one comment linked to a review thread.
+type resolvedChange struct {
+	Path      string
+	CommentID string
+	ThreadID  string
+	Local     bool
+	Remote    bool
+	Action    resolvedAction
+}
+
+// planResolvedSync compares each comment's Resolved flag with its GitHub
+// thread. Comment.RemoteResolved records the thread state at the last sync,
+// which tells which side changed. prefer ("local" or "remote") settles
+// comments with no sync record whose sides disagree; otherwise they are
+// reported as conflicts.
+func planResolvedSync(cj CritJSON, threads []ghThread, prefer string) []resolvedChange {
+	byRoot := make(map[int64]ghThread, len(threads))
+	for _, t := range threads {
+		if t.RootID != 0 {
+			byRoot[t.RootID] = t
+		}
+	}
+	var changes []resolvedChange
+	for path, cf := range cj.Files {
+		for _, c := range cf.Comments {
+			t, ok := byRoot[c.GitHubID]
+			if c.GitHubID == 0 || !ok {
+				continue
+			}
+			ch := resolvedChange{Path: path, CommentID: c.ID, ThreadID: t.ID, Local: c.Resolved, Remote: t.IsResolved}
+			switch {
+			case ch.Local == ch.Remote:
+				ch.Action = resolvedInSync
+			case c.RemoteResolved != nil && *c.RemoteResolved == ch.Remote:
+				ch.Action = resolvedPush
+			case c.RemoteResolved != nil && *c.RemoteResolved == ch.Local:
+				ch.Action = resolvedPull
+			case prefer == "local":
+				ch.Action = resolvedPush
+			case prefer == "remote":
+				ch.Action = resolvedPull
+			default:
+				ch.Action = resolvedConflict
+			}
+			changes = append(changes, ch)
+		}
+	}
+	return changes
+}
+
+// resolvedSyncResult counts what applyResolvedSync did.
+type resolvedSyncResult struct {
+	Pulled    int // local comments updated from GitHub
+	Pushed    int // GitHub threads updated from crit
+	Conflicts []resolvedChange
+}
+
+// applyResolvedSync carries out planned changes. pull and push choose which
+// directions may be applied; changes in the other direction are left for a
+// later pull, push or sync. setRemote updates a GitHub thread (setThreadResolved
+// in production). Conflicts are returned for reporting and left untouched.
+func applyResolvedSync(cj *CritJSON, changes []resolvedChange, pull, push bool, setRemote func(threadID string, resolved bool) error) resolvedSyncResult {
+	var res resolvedSyncResult
+	for _, ch := range changes {
+		cf := cj.Files[ch.Path]
+		i := findCommentIndex(cf.Comments, ch.CommentID)
+		if i < 0 {
+			continue
+		}
+		c := &cf.Comments[i]
+		switch ch.Action {
+		case resolvedInSync:
+			c.RemoteResolved = boolPtr(ch.Remote)
+		case resolvedPull:
+			if !pull {
+				continue
+			}
+			c.Resolved = ch.Remote
+			c.RemoteResolved = boolPtr(ch.Remote)
+			res.Pulled++
+		case resolvedPush:
+			if !push {
+				continue
+			}
+			if err := setRemote(ch.ThreadID, ch.Local); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to update review thread for %s: %v\n", ch.CommentID, err)
+				continue
+			}
+			c.RemoteResolved = boolPtr(ch.Local)
+			res.Pushed++
+		case resolvedConflict:
+			res.Conflicts = append(res.Conflicts, ch)
+		}
+		cj.Files[ch.Path] = cf
+	}
+	return res
+}
+
+// displayResolvedPlan prints the planned changes in the allowed directions (dry run).
+func displayResolvedPlan(changes []resolvedChange, pull, push bool) {
+	for _, ch := range changes {
+		switch {
+		case ch.Action == resolvedPull && pull:
+			fmt.Printf("  Would mark %s %s (from GitHub)\n", ch.CommentID, resolvedWord(ch.Remote))
+		case ch.Action == resolvedPush && push:
+			fmt.Printf("  Would mark the GitHub thread for %s %s\n", ch.CommentID, resolvedWord(ch.Local))
+		case ch.Action == resolvedConflict:
+			fmt.Printf("  Conflict: %s is %s in crit, %s on GitHub\n", ch.CommentID, resolvedWord(ch.Local), resolvedWord(ch.Remote))
+		}
+	}
+}
+
+// reportResolvedConflicts prints comments whose resolved state disagrees
+// with GitHub and can't be reconciled automatically.
+func reportResolvedConflicts(conflicts []resolvedChange) {
+	if len(conflicts) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%d comment(s) disagree with GitHub about being resolved:\n", len(conflicts))
+	for _, ch := range conflicts {
+		fmt.Fprintf(os.Stderr, "  %s (%s): %s in crit, %s on GitHub\n", ch.CommentID, ch.Path, resolvedWord(ch.Local), resolvedWord(ch.Remote))
+	}
+	fmt.Fprintln(os.Stderr, "Run 'crit sync --prefer local' or 'crit sync --prefer remote' to settle them.")
+}
+
+func resolvedWord(resolved bool) string {
+	if resolved {
+		return "resolved"
+	}
+	return "open"
+}
+
+func findCommentIndex(comments []Comment, id string) int {
+	for i, c := range comments {
+		if c.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func boolPtr(b bool) *bool { return &b }
+
+// syncResolvedState fetches the PR's review threads and reconciles resolved
+// state in the allowed directions, printing conflicts.
+func syncResolvedState(api githubAPI, prNumber int, cj *CritJSON, prefer string, pull, push bool) (resolvedSyncResult, error) {
+	threads, err := fetchReviewThreads(api, prNumber)
+	if err != nil {
+		return resolvedSyncResult{}, err
+	}
+	return reconcileResolvedState(api, threads, cj, prefer, pull, push), nil
+}
+
+// reconcileResolvedState is syncResolvedState for threads already fetched.
+func reconcileResolvedState(api githubAPI, threads []ghThread, cj *CritJSON, prefer string, pull, push bool) resolvedSyncResult {
+	setRemote := func(threadID string, resolved bool) error { return setThreadResolved(api, threadID, resolved) }
+	res := applyResolvedSync(cj, planResolvedSync(*cj, threads, prefer), pull, push, setRemote)
+	reportResolvedConflicts(res.Conflicts)
+	return res
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseReviewThreadsPage(t *testing.T) {
+	data := []byte(`{"data":{"repository":{"pullRequest":{"reviewThreads":{
+		"pageInfo":{"hasNextPage":true,"endCursor":"abc"},
+		"nodes":[
+			{"id":"T1","isResolved":true,"comments":{"nodes":[{"databaseId":101}]}},
+			{"id":"T2","isResolved":false,"comments":{"nodes":[]}}
+		]}}}}}`)
+	threads, next, err := parseReviewThreadsPage(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != "abc" {
+		t.Errorf("next = %q, want abc", next)
+	}
+	if len(threads) != 2 || threads[0] != (ghThread{ID: "T1", IsResolved: true, RootID: 101}) || threads[1].RootID != 0 {
+		t.Errorf("threads = %+v", threads)
+	}
+
+	if _, _, err := parseReviewThreadsPage([]byte(`{"errors":[{"message":"Could not resolve"}]}`)); err == nil {
+		t.Error("expected an error for a GraphQL error response")
+	}
+}
+
+func TestPlanResolvedSync(t *testing.T) {
+	tests := []struct {
+		name          string
+		local, remote bool
+		base          *bool
+		prefer        string
+		want          resolvedAction
+	}{
+		{"agree", true, true, nil, "", resolvedInSync},
+		{"resolved on GitHub", false, true, boolPtr(false), "", resolvedPull},
+		{"reopened on GitHub", true, false, boolPtr(true), "", resolvedPull},
+		{"resolved in crit", true, false, boolPtr(false), "", resolvedPush},
+		{"no base", true, false, nil, "", resolvedConflict},
+		{"no base, prefer local", true, false, nil, "local", resolvedPush},
+		{"no base, prefer remote", true, false, nil, "remote", resolvedPull},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cj := CritJSON{Files: map[string]CritJSONFile{
+				"main.go": {Comments: []Comment{
+					{ID: "c1", GitHubID: 101, Resolved: tt.local, RemoteResolved: tt.base},
+					{ID: "c2", Resolved: true}, // not on GitHub
+				}},
+			}}
+			changes := planResolvedSync(cj, []ghThread{{ID: "T1", IsResolved: tt.remote, RootID: 101}}, tt.prefer)
+			if len(changes) != 1 {
+				t.Fatalf("got %d changes, want 1", len(changes))
+			}
+			if changes[0].Action != tt.want || changes[0].ThreadID != "T1" {
+				t.Errorf("change = %+v, want action %d", changes[0], tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyResolvedSync(t *testing.T) {
+	cj := CritJSON{Files: map[string]CritJSONFile{
+		"main.go": {Comments: []Comment{
+			{ID: "pull", GitHubID: 1, RemoteResolved: boolPtr(false)},
+			{ID: "push", GitHubID: 2, Resolved: true, RemoteResolved: boolPtr(false)},
+			{ID: "fail", GitHubID: 3, Resolved: true, RemoteResolved: boolPtr(false)},
+			{ID: "conflict", GitHubID: 4, Resolved: true},
+			{ID: "same", GitHubID: 5},
+		}},
+	}}
+	threads := []ghThread{
+		{ID: "T1", IsResolved: true, RootID: 1},
+		{ID: "T2", RootID: 2},
+		{ID: "T3", RootID: 3},
+		{ID: "T4", RootID: 4},
+		{ID: "T5", RootID: 5},
+	}
+	set := map[string]bool{}
+	setRemote := func(id string, resolved bool) error {
+		if id == "T3" {
+			return errors.New("forbidden")
+		}
+		set[id] = resolved
+		return nil
+	}
+
+	// Pull only: thread T2 is left for a later push.
+	res := applyResolvedSync(&cj, planResolvedSync(cj, threads, ""), true, false, setRemote)
+	if res.Pulled != 1 || res.Pushed != 0 || len(set) != 0 {
+		t.Errorf("pull-only result = %+v, set = %v", res, set)
+	}
+
+	res = applyResolvedSync(&cj, planResolvedSync(cj, threads, ""), true, true, setRemote)
+	if res.Pulled != 0 || res.Pushed != 1 || !set["T2"] {
+		t.Errorf("result = %+v, set = %v; want T2 resolved", res, set)
+	}
+	if len(res.Conflicts) != 1 || res.Conflicts[0].CommentID != "conflict" {
+		t.Errorf("conflicts = %+v", res.Conflicts)
+	}
+
+	got := cj.Files["main.go"].Comments
+	if !got[0].Resolved || !*got[0].RemoteResolved {
+		t.Errorf("pulled comment = %+v, want resolved with base true", got[0])
+	}
+	if !*got[1].RemoteResolved {
+		t.Error("pushed comment should record the new thread state")
+	}
+	if *got[2].RemoteResolved {
+		t.Error("a failed push should keep the old base so it's retried")
+	}
+	if got[3].RemoteResolved != nil {
+		t.Error("a conflict should leave the comment untouched")
+	}
+	if got[4].RemoteResolved == nil || *got[4].RemoteResolved {
+		t.Error("an in-sync comment should record its base")
+	}
+}
+
+func TestGitHubPull_ImportsResolvedThreadAsResolved(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/acme/widgets/pulls/7/comments":
+			w.Write([]byte(`[{"id":101,"path":"docs/plan.md","line":1,"side":"RIGHT","body":"Typo","user":{"login":"bob"},"created_at":"2026-01-01T00:00:00Z"}]`))
+		case "/users/bob":
+			w.Write([]byte(`{"login":"bob","name":"Bob"}`))
+		case "/graphql":
+			w.Write([]byte(`{"data":{"repository":{"pullRequest":{"reviewThreads":{
+				"pageInfo":{"hasNextPage":false},
+				"nodes":[{"id":"T1","isResolved":true,"comments":{"nodes":[{"databaseId":101}]}}]}}}}}`))
+		default:
+			w.Write([]byte(`[]`))
+		}
+	}))
+	defer srv.Close()
+	g := githubForge{api: newTestGitHubClient(t, srv)}
+
+	cj := CritJSON{Files: map[string]CritJSONFile{}}
+	if _, err := g.Pull(7, &cj); err != nil {
+		t.Fatal(err)
+	}
+	comments := cj.Files["docs/plan.md"].Comments
+	if len(comments) != 1 || !comments[0].Resolved || comments[0].RemoteResolved == nil || !*comments[0].RemoteResolved {
+		t.Fatalf("pulled comments = %+v, want one resolved comment with base true", comments)
+	}
+	changes := planResolvedSync(cj, []ghThread{{ID: "T1", IsResolved: true, RootID: 101}}, "")
+	if len(changes) != 1 || changes[0].Action != resolvedInSync {
+		t.Errorf("changes on the next pull = %+v, want in sync", changes)
+	}
+}
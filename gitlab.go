@@ -0,0 +1,447 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gitlabForge syncs with GitLab merge request discussions over the REST API
+// (v4). Diff notes on the new side of the diff map to line comments, the
+// remaining notes in a discussion map to replies, and a discussion's resolved
+// state maps to Comment.Resolved.
+type gitlabForge struct {
+	restClient
+	project string // project path, e.g. "group/subgroup/project"
+}
+
+// newGitLabForge returns a GitLab client for the project at host. gitlabURL
+// is the instance configured with gitlab_url, if any. GITLAB_TOKEN is only
+// sent to gitlab.com or that instance: the host comes from the origin remote,
+// which a repository controls. Any other host gets only the token glab
+// stores for it.
+func newGitLabForge(host, project, gitlabURL string) (*gitlabForge, error) {
+	base := "https://" + host
+	token := ""
+	if sameHost(gitlabURL, host) {
+		base = strings.TrimSuffix(gitlabURL, "/")
+		token = os.Getenv("GITLAB_TOKEN")
+	} else if strings.EqualFold(host, "gitlab.com") {
+		token = os.Getenv("GITLAB_TOKEN")
+	}
+	if token == "" {
+		if out, err := exec.Command("glab", "config", "get", "token", "--host", host).Output(); err == nil {
+			token = strings.TrimSpace(string(out))
+		}
+	}
+	if token == "" {
+		return nil, fmt.Errorf("no GitLab token for %s. Run: glab auth login --hostname %s (GITLAB_TOKEN is only used for gitlab.com and gitlab_url)", host, host)
+	}
+	return &gitlabForge{
+		restClient: newRESTClient("gitlab", base+"/api/v4", "PRIVATE-TOKEN", token),
+		project:    project,
+	}, nil
+}
+
+// glDiscussion is a merge request discussion: a thread of notes.
+type glDiscussion struct {
+	ID    string   `json:"id"`
+	Notes []glNote `json:"notes"`
+}
+
+type glNote struct {
+	ID     int64  `json:"id"`
+	Type   string `json:"type"` // "DiffNote" for positioned notes
+	Body   string `json:"body"`
+	Author struct {
+		Username string `json:"username"`
+		Name     string `json:"name"`
+	} `json:"author"`
+	CreatedAt string      `json:"created_at"`
+	System    bool        `json:"system"`
+	Resolved  bool        `json:"resolved"`
+	Position  *glPosition `json:"position"`
+}
+
+// glPosition anchors a diff note. NewLine is 0 for notes on removed lines.
+type glPosition struct {
+	BaseSHA      string       `json:"base_sha"`
+	StartSHA     string       `json:"start_sha"`
+	HeadSHA      string       `json:"head_sha"`
+	PositionType string       `json:"position_type"`
+	OldPath      string       `json:"old_path"`
+	NewPath      string       `json:"new_path"`
+	NewLine      int          `json:"new_line,omitempty"`
+	LineRange    *glLineRange `json:"line_range,omitempty"`
+}
+
+type glLineRange struct {
+	Start struct {
+		NewLine int `json:"new_line"`
+	} `json:"start"`
+}
+
+func (g *gitlabForge) Name() string { return "gitlab" }
+
+func (g *gitlabForge) ChangeLabel(number int) string { return fmt.Sprintf("MR !%d", number) }
+
+// mrPath returns the API path of a merge request, optionally with a suffix.
+func (g *gitlabForge) mrPath(iid int, suffix string) string {
+	return "/projects/" + url.PathEscape(g.project) + "/merge_requests/" + strconv.Itoa(iid) + suffix
+}
+
+// DetectChange returns the open merge request whose source branch is the
+// current branch.
+func (g *gitlabForge) DetectChange(flag int) (int, error) {
+	if flag > 0 {
+		return flag, nil
+	}
+	branch := CurrentBranch()
+	q := url.Values{"state": {"opened"}, "source_branch": {branch}}
+	var mrs []struct {
+		IID int `json:"iid"`
+	}
+	if _, err := g.do(http.MethodGet, "/projects/"+url.PathEscape(g.project)+"/merge_requests", q, nil, &mrs); err != nil {
+		return 0, err
+	}
+	if len(mrs) == 0 {
+		return 0, fmt.Errorf("no open MR found for branch %s (try: crit pull <mr-number>)", branch)
+	}
+	return mrs[0].IID, nil
+}
+
+// fetchDiscussions returns all discussions on a merge request, following pagination.
+func (g *gitlabForge) fetchDiscussions(iid int) ([]glDiscussion, error) {
+	var all []glDiscussion
+	page := "1"
+	for page != "" {
+		var batch []glDiscussion
+		h, err := g.do(http.MethodGet, g.mrPath(iid, "/discussions"), url.Values{"per_page": {"100"}, "page": {page}}, nil, &batch)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, batch...)
+		page = h.Get("X-Next-Page")
+	}
+	return all, nil
+}
+
+func (g *gitlabForge) Pull(iid int, cj *CritJSON) (pullResult, error) {
+	discussions, err := g.fetchDiscussions(iid)
+	if err != nil {
+		return pullResult{}, err
+	}
+	return mergeGLDiscussions(cj, discussions), nil
+}
+
+// mergeGLDiscussions merges GitLab discussions into cj. Only discussions that
+// start with a diff note on the new side of the diff are imported. Existing
+// comments are matched by RemoteID (the discussion ID): new notes become
+// replies and the resolved state is taken from GitLab, unless only crit's
+// side changed since the last sync (see Comment.RemoteResolved).
+func mergeGLDiscussions(cj *CritJSON, discussions []glDiscussion) pullResult {
+	now := time.Now().UTC().Format(time.RFC3339)
+	cj.UpdatedAt = now
+
+	var res pullResult
+	for _, d := range discussions {
+		var notes []glNote
+		for _, n := range d.Notes {
+			if !n.System {
+				notes = append(notes, n)
+			}
+		}
+		if len(notes) == 0 {
+			continue
+		}
+		root := notes[0]
+		if root.Type != "DiffNote" || root.Position == nil || root.Position.NewLine == 0 {
+			continue
+		}
+		path := root.Position.NewPath
+
+		if filePath, ci, found := findCommentByRemoteID(cj, d.ID); found {
+			cf := cj.Files[filePath]
+			c := &cf.Comments[ci]
+			if pullRemoteResolved(c, root.Resolved) {
+				res.Resolved++
+			}
+			res.Added += appendNewGLReplies(c, notes[1:])
+			cj.Files[filePath] = cf
+			continue
+		}
+
+		startLine := root.Position.NewLine
+		if lr := root.Position.LineRange; lr != nil && lr.Start.NewLine > 0 && lr.Start.NewLine < startLine {
+			startLine = lr.Start.NewLine
+		}
+		comment := Comment{
+			ID: randomCommentID(), StartLine: startLine, EndLine: root.Position.NewLine,
+			Body: root.Body, Author: displayName(root.Author.Username, root.Author.Name),
+			CreatedAt: root.CreatedAt, UpdatedAt: now, Resolved: root.Resolved, RemoteID: d.ID,
+			RemoteResolved: boolPtr(root.Resolved),
+		}
+		res.Added += 1 + appendNewGLReplies(&comment, notes[1:])
+
+		cf, ok := cj.Files[path]
+		if !ok {
+			cf = CritJSONFile{Status: "modified", Comments: []Comment{}}
+		}
+		cf.Comments = append(cf.Comments, comment)
+		cj.Files[path] = cf
+	}
+	return res
+}
+
+// appendNewGLReplies adds notes not yet present (by RemoteID) as replies.
+func appendNewGLReplies(c *Comment, notes []glNote) int {
+	added := 0
+	for _, n := range notes {
+		id := strconv.FormatInt(n.ID, 10)
+		if isDuplicateRemoteReply(c.Replies, id) {
+			continue
+		}
+		c.Replies = append(c.Replies, Reply{
+			ID:        randomReplyID(),
+			Body:      n.Body,
+			Author:    displayName(n.Author.Username, n.Author.Name),
+			CreatedAt: n.CreatedAt,
+			RemoteID:  id,
+		})
+		added++
+	}
+	return added
+}
+
+// isDuplicateRemoteReply reports whether a reply with the given RemoteID exists.
+func isDuplicateRemoteReply(replies []Reply, remoteID string) bool {
+	for _, r := range replies {
+		if r.RemoteID == remoteID {
+			return true
+		}
+	}
+	return false
+}
+
+// findCommentByRemoteID searches all files in a CritJSON for a comment with the given RemoteID.
+// Returns the file path, comment index, and true if found.
+func findCommentByRemoteID(cj *CritJSON, remoteID string) (string, int, bool) {
+	for path, cf := range cj.Files {
+		for i, c := range cf.Comments {
+			if c.RemoteID == remoteID {
+				return path, i, true
+			}
+		}
+	}
+	return "", 0, false
+}
+
+// pullRemoteResolved takes a thread's resolved state on the forge into c only
+// if it changed since the last sync, so a comment resolved or reopened in crit
+// and not pushed yet stays that way. It records remote as the new sync state
+// and reports whether c.Resolved changed.
+func pullRemoteResolved(c *Comment, remote bool) bool {
+	changed := false
+	if (c.RemoteResolved == nil || *c.RemoteResolved != remote) && c.Resolved != remote {
+		c.Resolved = remote
+		changed = true
+	}
+	c.RemoteResolved = boolPtr(remote)
+	return changed
+}
+
+// Push creates a discussion for each new unresolved line comment, posts new
+// replies to existing discussions, resolves or reopens discussions whose
+// comment was resolved or reopened in crit since the last sync, and applies
+// the review message and event.
+func (g *gitlabForge) Push(iid int, critPath string, cj CritJSON, opts pushOptions) error {
+	if opts.Event == "REQUEST_CHANGES" {
+		return errors.New("--event request-changes is not supported for GitLab merge requests")
+	}
+	label := g.ChangeLabel(iid)
+
+	type newThread struct {
+		path string
+		c    Comment
+	}
+	type newReply struct {
+		discussion string
+		r          Reply
+	}
+	type resolvedChange struct {
+		discussion string
+		commentID  string
+		resolved   bool
+	}
+	var threads []newThread
+	var replies []newReply
+	var resolve []resolvedChange
+	for _, path := range slices.Sorted(maps.Keys(cj.Files)) {
+		for _, c := range cj.Files[path].Comments {
+			if c.RemoteID == "" {
+				if !c.Resolved && c.EndLine > 0 {
+					threads = append(threads, newThread{path, c})
+				}
+				continue
+			}
+			for _, r := range c.Replies {
+				if r.RemoteID == "" {
+					replies = append(replies, newReply{c.RemoteID, r})
+				}
+			}
+			// A discussion without a sync record is taken to be open, as
+			// GitLab starts them.
+			if c.Resolved != (c.RemoteResolved != nil && *c.RemoteResolved) {
+				resolve = append(resolve, resolvedChange{c.RemoteID, c.ID, c.Resolved})
+			}
+		}
+	}
+	if len(threads) == 0 && len(replies) == 0 && len(resolve) == 0 && opts.Message == "" && opts.Event == "COMMENT" {
+		fmt.Println("No unresolved comments to push.")
+		return nil
+	}
+
+	if opts.DryRun {
+		fmt.Printf("Would post %d comments to %s:\n\n", len(threads), label)
+		if opts.Message != "" {
+			fmt.Printf("  Review body: %s\n\n", opts.Message)
+		}
+		for _, t := range threads {
+			if t.c.StartLine != t.c.EndLine {
+				fmt.Printf("  %s:%d-%d\n", t.path, t.c.StartLine, t.c.EndLine)
+			} else {
+				fmt.Printf("  %s:%d\n", t.path, t.c.EndLine)
+			}
+			fmt.Printf("    %s\n\n", t.c.Body)
+		}
+		for _, r := range replies {
+			fmt.Printf("  Would reply to GitLab discussion %s: %.60s\n", r.discussion, r.r.Body)
+		}
+		for _, r := range resolve {
+			verb := "reopen"
+			if r.resolved {
+				verb = "resolve"
+			}
+			fmt.Printf("  Would %s GitLab discussion %s\n", verb, r.discussion)
+		}
+		if opts.Event == "APPROVE" {
+			fmt.Printf("  Would approve %s\n", label)
+		}
+		return nil
+	}
+
+	commentIDs := make(map[string]string)
+	replyIDs := make(map[string]string)
+	remoteResolved := make(map[string]bool) // comment ID → the discussion's state after this push
+	if len(threads) > 0 {
+		var mr struct {
+			DiffRefs struct {
+				BaseSHA  string `json:"base_sha"`
+				HeadSHA  string `json:"head_sha"`
+				StartSHA string `json:"start_sha"`
+			} `json:"diff_refs"`
+		}
+		if _, err := g.do(http.MethodGet, g.mrPath(iid, ""), nil, nil, &mr); err != nil {
+			return err
+		}
+		fmt.Printf("Pushing %d comments to %s...\n", len(threads), label)
+		posted := 0
+		for _, t := range threads {
+			// GitLab needs per-line codes for multi-line positions; a range
+			// is anchored at its last line, as GitHub shows it.
+			body := map[string]any{
+				"body": t.c.Body,
+				"position": glPosition{
+					PositionType: "text",
+					BaseSHA:      mr.DiffRefs.BaseSHA,
+					StartSHA:     mr.DiffRefs.StartSHA,
+					HeadSHA:      mr.DiffRefs.HeadSHA,
+					OldPath:      t.path,
+					NewPath:      t.path,
+					NewLine:      t.c.EndLine,
+				},
+			}
+			var d glDiscussion
+			if _, err := g.do(http.MethodPost, g.mrPath(iid, "/discussions"), nil, body, &d); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to post comment on %s:%d: %v\n", t.path, t.c.EndLine, err)
+				continue
+			}
+			commentIDs[t.c.ID] = d.ID
+			remoteResolved[t.c.ID] = false
+			posted++
+		}
+		fmt.Printf("Posted %d review comments to %s\n", posted, label)
+	}
+
+	replyCount := 0
+	for _, r := range replies {
+		var n glNote
+		if _, err := g.do(http.MethodPost, g.mrPath(iid, "/discussions/"+url.PathEscape(r.discussion)+"/notes"), nil, map[string]string{"body": r.r.Body}, &n); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to post reply: %v\n", err)
+			continue
+		}
+		replyIDs[r.r.ID] = strconv.FormatInt(n.ID, 10)
+		replyCount++
+	}
+	if replyCount > 0 {
+		fmt.Printf("Posted %d replies\n", replyCount)
+	}
+
+	for _, r := range resolve {
+		if _, err := g.do(http.MethodPut, g.mrPath(iid, "/discussions/"+url.PathEscape(r.discussion)), url.Values{"resolved": {strconv.FormatBool(r.resolved)}}, nil, nil); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to update discussion %s: %v\n", r.discussion, err)
+			continue
+		}
+		remoteResolved[r.commentID] = r.resolved
+	}
+
+	if opts.Message != "" {
+		if _, err := g.do(http.MethodPost, g.mrPath(iid, "/notes"), nil, map[string]string{"body": opts.Message}, nil); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to post review message: %v\n", err)
+		}
+	}
+	if opts.Event == "APPROVE" {
+		if _, err := g.do(http.MethodPost, g.mrPath(iid, "/approve"), nil, nil, nil); err != nil {
+			return fmt.Errorf("approving %s: %w", label, err)
+		}
+		fmt.Printf("Approved %s\n", label)
+	}
+
+	if len(commentIDs) > 0 || len(replyIDs) > 0 {
+		if err := updateCritJSONRemoteIDs(critPath, commentIDs, replyIDs); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to update review file with GitLab IDs: %v\n", err)
+		}
+	}
+	if len(remoteResolved) > 0 {
+		if err := recordGLResolved(critPath, remoteResolved); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to update review file with GitLab state: %v\n", err)
+		}
+	}
+	return nil
+}
+
+// recordGLResolved stores the discussions' resolved state after a push as
+// the comments' sync record, keyed by comment ID.
+func recordGLResolved(critPath string, resolved map[string]bool) error {
+	cj, err := loadCritJSON(critPath)
+	if err != nil {
+		return err
+	}
+	for path, cf := range cj.Files {
+		for i, c := range cf.Comments {
+			if r, ok := resolved[c.ID]; ok {
+				cf.Comments[i].RemoteResolved = boolPtr(r)
+			}
+		}
+		cj.Files[path] = cf
+	}
+	return saveCritJSON(critPath, cj)
+}
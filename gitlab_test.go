@@ -0,0 +1,281 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeGitLab is a minimal stand-in for the GitLab v4 merge request API.
+type fakeGitLab struct {
+	mu          sync.Mutex
+	discussions []glDiscussion
+	posted      []map[string]any // bodies of POST .../discussions
+	notes       []string         // bodies of POST .../discussions/{id}/notes
+	resolved    []string         // discussion IDs resolved via PUT
+	reopened    []string         // discussion IDs reopened via PUT
+	approved    bool
+}
+
+func (f *fakeGitLab) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("PRIVATE-TOKEN") != "tok" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.EscapedPath(), "/api/v4/projects/group%2Fproj/merge_requests")
+	body, _ := io.ReadAll(r.Body)
+	switch {
+	case r.Method == "GET" && path == "" && r.URL.Query().Get("source_branch") != "":
+		json.NewEncoder(w).Encode([]map[string]int{{"iid": 7}})
+	case r.Method == "GET" && path == "/7":
+		json.NewEncoder(w).Encode(map[string]any{"diff_refs": map[string]string{"base_sha": "b", "head_sha": "h", "start_sha": "s"}})
+	case r.Method == "GET" && path == "/7/discussions":
+		// Serve one discussion per page to exercise pagination.
+		page := r.URL.Query().Get("page")
+		i := 0
+		if page == "2" {
+			i = 1
+		}
+		if i+1 < len(f.discussions) {
+			w.Header().Set("X-Next-Page", "2")
+		}
+		json.NewEncoder(w).Encode(f.discussions[i : i+1])
+	case r.Method == "POST" && path == "/7/discussions":
+		var m map[string]any
+		json.Unmarshal(body, &m)
+		f.posted = append(f.posted, m)
+		json.NewEncoder(w).Encode(map[string]string{"id": "new-disc"})
+	case r.Method == "POST" && strings.HasSuffix(path, "/notes") && strings.HasPrefix(path, "/7/discussions/"):
+		var m map[string]string
+		json.Unmarshal(body, &m)
+		f.notes = append(f.notes, m["body"])
+		json.NewEncoder(w).Encode(map[string]int64{"id": 900})
+	case r.Method == "PUT" && strings.HasPrefix(path, "/7/discussions/") && r.URL.Query().Get("resolved") == "true":
+		f.resolved = append(f.resolved, strings.TrimPrefix(path, "/7/discussions/"))
+		w.Write([]byte("{}"))
+	case r.Method == "PUT" && strings.HasPrefix(path, "/7/discussions/") && r.URL.Query().Get("resolved") == "false":
+		f.reopened = append(f.reopened, strings.TrimPrefix(path, "/7/discussions/"))
+		w.Write([]byte("{}"))
+	case r.Method == "POST" && path == "/7/approve":
+		f.approved = true
+		w.Write([]byte("{}"))
+	default:
+		http.Error(w, "unexpected "+r.Method+" "+path, http.StatusNotFound)
+	}
+}
+
+func newFakeGitLabForge(t *testing.T, fake *fakeGitLab) *gitlabForge {
+	t.Helper()
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	rc := newRESTClient("gitlab", srv.URL+"/api/v4", "PRIVATE-TOKEN", "tok")
+	rc.client = srv.Client()
+	return &gitlabForge{restClient: rc, project: "group/proj"}
+}
+
+func glTestNote(id int64, user, body string, pos *glPosition) glNote {
+	n := glNote{ID: id, Body: body, CreatedAt: "2026-01-01T00:00:00Z", Position: pos}
+	n.Author.Username = user
+	if pos != nil {
+		n.Type = "DiffNote"
+	}
+	return n
+}
+
+func TestGitLabForge_Pull(t *testing.T) {
+	pos := &glPosition{NewPath: "main.go", NewLine: 12, LineRange: &glLineRange{}}
+	pos.LineRange.Start.NewLine = 10
+	root := glTestNote(1, "alice", "rename this", pos)
+	root.Resolved = true
+	fake := &fakeGitLab{discussions: []glDiscussion{
+		{ID: "d1", Notes: []glNote{root, glTestNote(2, "bob", "done", pos), {ID: 3, System: true, Body: "changed this line"}}},
+		{ID: "d2", Notes: []glNote{glTestNote(4, "carol", "general note", nil)}},
+	}}
+	g := newFakeGitLabForge(t, fake)
+
+	cj := CritJSON{Files: map[string]CritJSONFile{}}
+	res, err := g.Pull(7, &cj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Added != 2 {
+		t.Errorf("added = %d, want 2 (root + reply)", res.Added)
+	}
+	comments := cj.Files["main.go"].Comments
+	if len(comments) != 1 {
+		t.Fatalf("got %d comments, want 1", len(comments))
+	}
+	c := comments[0]
+	if c.StartLine != 10 || c.EndLine != 12 || c.RemoteID != "d1" || !c.Resolved || c.Author != "alice" {
+		t.Errorf("comment = %+v", c)
+	}
+	if len(c.Replies) != 1 || c.Replies[0].RemoteID != "2" || c.Replies[0].Body != "done" {
+		t.Errorf("replies = %+v, want one reply with remote ID 2", c.Replies)
+	}
+
+	// A second pull adds only new notes and takes the remote resolved state.
+	fake.discussions[0].Notes[0].Resolved = false
+	fake.discussions[0].Notes = append(fake.discussions[0].Notes, glTestNote(5, "alice", "thanks", pos))
+	res, err = g.Pull(7, &cj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = cj.Files["main.go"].Comments[0]
+	if res.Added != 1 || res.Resolved != 1 || len(c.Replies) != 2 || c.Resolved {
+		t.Errorf("after second pull: %+v replies=%d resolved=%v, want 1 added, 1 resolved, 2, false", res, len(c.Replies), c.Resolved)
+	}
+}
+
+func TestGitLabForge_Push(t *testing.T) {
+	fake := &fakeGitLab{}
+	g := newFakeGitLabForge(t, fake)
+
+	critPath := filepath.Join(t.TempDir(), "review.json")
+	cj := CritJSON{Files: map[string]CritJSONFile{
+		"main.go": {Comments: []Comment{
+			{ID: "c_new", StartLine: 3, EndLine: 5, Body: "new comment"},
+			{ID: "c_old", StartLine: 8, EndLine: 8, Body: "old", RemoteID: "d1", Resolved: true,
+				Replies: []Reply{{ID: "rp_1", Body: "pulled", RemoteID: "2"}, {ID: "rp_2", Body: "local reply"}}},
+			{ID: "c_done", StartLine: 9, EndLine: 9, Body: "resolved locally", Resolved: true},
+		}},
+	}}
+	if err := saveCritJSON(critPath, cj); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g.Push(7, critPath, cj, pushOptions{Event: "APPROVE"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fake.posted) != 1 {
+		t.Fatalf("posted %d discussions, want 1", len(fake.posted))
+	}
+	pos, _ := fake.posted[0]["position"].(map[string]any)
+	if pos["new_path"] != "main.go" || pos["new_line"] != float64(5) || pos["head_sha"] != "h" || pos["position_type"] != "text" {
+		t.Errorf("position = %v", pos)
+	}
+	if len(fake.notes) != 1 || fake.notes[0] != "local reply" {
+		t.Errorf("notes = %v, want the one local reply", fake.notes)
+	}
+	if len(fake.resolved) != 1 || fake.resolved[0] != "d1" {
+		t.Errorf("resolved = %v, want [d1]", fake.resolved)
+	}
+	if !fake.approved {
+		t.Error("expected the MR to be approved")
+	}
+
+	saved, err := loadCritJSON(critPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := saved.Files["main.go"].Comments
+	if got[0].RemoteID != "new-disc" {
+		t.Errorf("new comment remote ID = %q, want new-disc", got[0].RemoteID)
+	}
+	if got[1].Replies[1].RemoteID != "900" {
+		t.Errorf("reply remote ID = %q, want 900", got[1].Replies[1].RemoteID)
+	}
+	if got[1].RemoteResolved == nil || !*got[1].RemoteResolved {
+		t.Errorf("resolved comment's sync state = %v, want true", got[1].RemoteResolved)
+	}
+
+	// Pushing again leaves the already-resolved discussion alone.
+	fake.resolved = nil
+	if err := g.Push(7, critPath, saved, pushOptions{Event: "COMMENT"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.resolved) != 0 {
+		t.Errorf("second push resolved %v, want nothing", fake.resolved)
+	}
+}
+
+func TestGitLabForge_PushSyncsResolvedChangesOnly(t *testing.T) {
+	fake := &fakeGitLab{}
+	g := newFakeGitLabForge(t, fake)
+
+	critPath := filepath.Join(t.TempDir(), "review.json")
+	cj := CritJSON{Files: map[string]CritJSONFile{
+		"main.go": {Comments: []Comment{
+			{ID: "c_same", StartLine: 1, EndLine: 1, Body: "still resolved", RemoteID: "d1", Resolved: true, RemoteResolved: boolPtr(true)},
+			{ID: "c_reopen", StartLine: 2, EndLine: 2, Body: "reopened here", RemoteID: "d2", Resolved: false, RemoteResolved: boolPtr(true)},
+			{ID: "c_resolve", StartLine: 3, EndLine: 3, Body: "resolved here", RemoteID: "d3", Resolved: true, RemoteResolved: boolPtr(false)},
+		}},
+	}}
+	if err := saveCritJSON(critPath, cj); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Push(7, critPath, cj, pushOptions{Event: "COMMENT"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.resolved) != 1 || fake.resolved[0] != "d3" {
+		t.Errorf("resolved = %v, want [d3]", fake.resolved)
+	}
+	if len(fake.reopened) != 1 || fake.reopened[0] != "d2" {
+		t.Errorf("reopened = %v, want [d2]", fake.reopened)
+	}
+
+	// A pull must not undo the local change while it waits to be pushed.
+	fake.discussions = []glDiscussion{{ID: "d2", Notes: []glNote{glTestNote(1, "alice", "reopened here", &glPosition{NewPath: "main.go", NewLine: 2})}}}
+	fake.discussions[0].Notes[0].Resolved = true
+	pending := CritJSON{Files: map[string]CritJSONFile{
+		"main.go": {Comments: []Comment{{ID: "c_reopen", StartLine: 2, EndLine: 2, Body: "reopened here", RemoteID: "d2", RemoteResolved: boolPtr(true)}}},
+	}}
+	if _, err := g.Pull(7, &pending); err != nil {
+		t.Fatal(err)
+	}
+	if pending.Files["main.go"].Comments[0].Resolved {
+		t.Error("pull overwrote an unpushed local reopen")
+	}
+}
+
+func TestGitLabForge_PushRejectsRequestChanges(t *testing.T) {
+	g := newFakeGitLabForge(t, &fakeGitLab{})
+	if err := g.Push(7, "", CritJSON{}, pushOptions{Event: "REQUEST_CHANGES", Message: "no"}); err == nil {
+		t.Error("expected an error for request-changes")
+	}
+}
+
+func TestGitLabForge_DetectChange(t *testing.T) {
+	g := newFakeGitLabForge(t, &fakeGitLab{})
+	if n, err := g.DetectChange(3); err != nil || n != 3 {
+		t.Errorf("DetectChange(3) = %d, %v; want 3", n, err)
+	}
+	if n, err := g.DetectChange(0); err != nil || n != 7 {
+		t.Errorf("DetectChange(0) = %d, %v; want 7", n, err)
+	}
+}
+
+func TestGitLabForge_BadToken(t *testing.T) {
+	g := newFakeGitLabForge(t, &fakeGitLab{})
+	g.token = "wrong"
+	if _, err := g.Pull(7, &CritJSON{Files: map[string]CritJSONFile{}}); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("err = %v, want a 401 error", err)
+	}
+}
+
+func TestNewGitLabForge_TokenOnlyForTrustedHosts(t *testing.T) {
+	t.Setenv("GITLAB_TOKEN", "secret")
+	t.Setenv("PATH", "") // no glab
+
+	if _, err := newGitLabForge("gitlab.attacker.example", "a/b", ""); err == nil {
+		t.Error("GITLAB_TOKEN was used for an unconfigured host")
+	}
+	if _, err := newGitLabForge("gitlab.attacker.example", "a/b", "https://gitlab.corp.example"); err == nil {
+		t.Error("GITLAB_TOKEN was used for a host other than gitlab_url")
+	}
+	g, err := newGitLabForge("gitlab.com", "a/b", "")
+	if err != nil || g.token != "secret" || g.baseURL != "https://gitlab.com/api/v4" {
+		t.Errorf("gitlab.com: %+v, %v", g, err)
+	}
+	g, err = newGitLabForge("gitlab.corp.example", "a/b", "https://gitlab.corp.example/")
+	if err != nil || g.token != "secret" || g.baseURL != "https://gitlab.corp.example/api/v4" {
+		t.Errorf("gitlab_url host: %+v, %v", g, err)
+	}
+}
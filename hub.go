@@ -0,0 +1,674 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// The hub is an optional long-lived daemon that puts every review on this
+// machine behind one port: each session is served under /s/<key>/ and a
+// dashboard at / lists them all. With the hub on, startDaemon has the hub host
+// new sessions in its own process, each with a VCS bound to the directory crit
+// was run in, instead of forking a daemon per review. Daemons that need a port
+// or listener of their own still run separately, and the hub proxies to them.
+// Every session, hosted or not, has a session file in ~/.crit/sessions/; the
+// hub finds the others through them and keeps no other state, so restarting
+// it ends only the sessions it hosts.
+
+// hubKey names the hub's lock and log files in ~/.crit/sessions/.
+const hubKey = "hub"
+
+// hubEntry records the running hub daemon in ~/.crit/hub.json.
+type hubEntry struct {
+	PID       int    `json:"pid"`
+	Port      int    `json:"port"`
+	StartedAt string `json:"started_at"`
+	Token     string `json:"token,omitempty"` // access token for the hub's port; see socket.go
+}
+
+// hubFilePath returns the path to ~/.crit/hub.json.
+func hubFilePath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("finding home directory: %w", err)
+	}
+	return filepath.Join(home, ".crit", "hub.json"), nil
+}
+
+func writeHubFile(entry hubEntry) error {
+	path, err := hubFilePath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicWriteFile(path, data, 0600)
+}
+
+func readHubFile() (hubEntry, error) {
+	path, err := hubFilePath()
+	if err != nil {
+		return hubEntry{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return hubEntry{}, err
+	}
+	var entry hubEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return hubEntry{}, err
+	}
+	return entry, nil
+}
+
+// findAliveHub returns the running hub, if any.
+func findAliveHub() (hubEntry, bool) {
+	entry, err := readHubFile()
+	if err != nil {
+		return hubEntry{}, false
+	}
+	if !isDaemonAlive(entry.daemon()) {
+		return hubEntry{}, false
+	}
+	return entry, true
+}
+
+// ensureHub returns the running hub, starting one on port (0 for a random
+// port) if there is none.
+func ensureHub(port int) (hubEntry, error) {
+	lock, err := acquireSessionLock(hubKey)
+	if err != nil {
+		return hubEntry{}, err
+	}
+	defer releaseSessionLock(lock)
+
+	if entry, alive := findAliveHub(); alive {
+		return entry, nil
+	}
+
+	var args []string
+	if port != 0 {
+		args = []string{"--port", fmt.Sprint(port)}
+	}
+	cmd, readEnd, writeEnd, logFile, err := setupBackgroundCmd("_hub", hubKey, args)
+	if err != nil {
+		return hubEntry{}, err
+	}
+	if err := cmd.Start(); err != nil {
+		logFile.Close()
+		readEnd.Close()
+		writeEnd.Close()
+		return hubEntry{}, fmt.Errorf("starting hub: %w", err)
+	}
+	writeEnd.Close()
+	logFile.Close()
+	defer readEnd.Close()
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	portCh, errCh := readPortFromPipe(readEnd)
+
+	select {
+	case port := <-portCh:
+		if entry, err := readHubFile(); err == nil && entry.PID == cmd.Process.Pid {
+			return entry, nil
+		}
+		return hubEntry{PID: cmd.Process.Pid, Port: port}, nil
+	case err := <-errCh:
+		if msg := readDaemonLog(hubKey); msg != "" {
+			return hubEntry{}, fmt.Errorf("hub exited: %s", msg)
+		}
+		return hubEntry{}, fmt.Errorf("hub startup failed: %w", err)
+	case err := <-exited:
+		return hubEntry{}, fmt.Errorf("hub exited: %w", err)
+	case <-time.After(10 * time.Second):
+		cmd.Process.Kill()
+		return hubEntry{}, fmt.Errorf("hub did not start within 10 seconds")
+	}
+}
+
+// reviewURL is the browser address of a review session, with the access
+// token: its page on the hub when one is running, otherwise the session
+// daemon's own port.
+func reviewURL(key string, s sessionEntry) string {
+	if s.Path != "" {
+		return withAccessToken(fmt.Sprintf("http://localhost:%d%s/", s.Port, s.Path), s.Token)
+	}
+	if hub, ok := findAliveHub(); ok {
+		return withAccessToken(fmt.Sprintf("http://localhost:%d/s/%s/", hub.Port, key), hub.Token)
+	}
+	return withAccessToken(fmt.Sprintf("http://localhost:%d/", s.Port), s.Token)
+}
+
+// hubHostsSession reports whether the hub should host the session
+// `crit _serve <args>` would serve, and the hub_port to start the hub on: it
+// does when the config turns the hub on and the session needs no port or
+// listener of its own.
+func hubHostsSession(args []string) (int, bool) {
+	sf, err := parseServerFlagSet(args, flag.ContinueOnError)
+	if err != nil || sf.listen != "" {
+		return 0, false
+	}
+	cfg := LoadConfig(serverConfigDir("", sf.vcsOverride))
+	if !cfg.Hub || resolvePort(sf.port, cfg.Port) != 0 {
+		return 0, false
+	}
+	return cfg.HubPort, true
+}
+
+// daemon describes the hub's port the way a session file does, so that
+// daemonClient and daemonURL reach the hub's own API.
+func (h hubEntry) daemon() sessionEntry {
+	return sessionEntry{PID: h.PID, Port: h.Port, Token: h.Token}
+}
+
+// hostInHub has the hub, started on hubPort if need be, host the session
+// key for `crit _serve <args>` in the working directory.
+func hostInHub(hubPort int, key string, args []string) (sessionEntry, error) {
+	h, err := ensureHub(hubPort)
+	if err != nil {
+		return sessionEntry{}, err
+	}
+	cwd, err := resolvedCWD()
+	if err != nil {
+		return sessionEntry{}, err
+	}
+	body, err := json.Marshal(hubHostRequest{Key: key, CWD: cwd, Args: args})
+	if err != nil {
+		return sessionEntry{}, err
+	}
+	// Loading the review can take a while on a large repo; the hub answers
+	// once the session file is written, before that.
+	resp, err := daemonClient(h.daemon(), 10*time.Second).Post(daemonURL(h.daemon(), "/api/hub/sessions"), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return sessionEntry{}, fmt.Errorf("reaching hub: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return sessionEntry{}, fmt.Errorf("hub: %s", strings.TrimSpace(string(msg)))
+	}
+	var entry sessionEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entry); err != nil {
+		return sessionEntry{}, fmt.Errorf("reading hub response: %w", err)
+	}
+	return entry, nil
+}
+
+// unhostSession asks the hub hosting entry to stop the session, and waits
+// until its review is saved.
+func unhostSession(key string, entry sessionEntry) error {
+	h := hubEntry{PID: entry.PID, Port: entry.Port, Token: entry.Token}.daemon()
+	req, err := http.NewRequest(http.MethodDelete, daemonURL(h, "/api/hub/sessions/"+key), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := daemonClient(h, 10*time.Second).Do(req)
+	if err != nil {
+		return fmt.Errorf("reaching hub: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("hub: %s", resp.Status)
+	}
+	return nil
+}
+
+// hubSession is one row of the dashboard.
+type hubSession struct {
+	Key       string          `json:"key"`
+	URL       string          `json:"url"`
+	CWD       string          `json:"cwd"`
+	Branch    string          `json:"branch,omitempty"`
+	Files     []string        `json:"files,omitempty"`
+	PID       int             `json:"pid"`
+	Port      int             `json:"port"`
+	StartedAt string          `json:"started_at"`
+	Summary   *sessionSummary `json:"summary,omitempty"` // nil while the session is still loading
+}
+
+// hub routes requests to the sessions and serves the dashboard.
+type hub struct {
+	handler http.Handler
+	assets  fs.FS
+
+	// list and lookup find sessions; tests replace them to avoid ~/.crit.
+	list   func() ([]sessionEntry, []string)
+	lookup func(key string) (sessionEntry, error)
+
+	metrics bool // serve /metrics
+
+	// Hosted sessions run until ctx is done; port and token are what their
+	// session files record, since the browser reaches them through the hub.
+	ctx   context.Context
+	port  int
+	token string
+
+	hostedMu sync.Mutex
+	hosted   map[string]*hostedSession
+}
+
+// hostedSession is a review session running in the hub's process.
+type hostedSession struct {
+	handler http.Handler
+	stop    context.CancelFunc
+	done    chan struct{} // closed once the session is saved and its file removed
+}
+
+func newHub(assets fs.FS) *hub {
+	h := &hub{
+		assets: assets,
+		list:   listAllSessions,
+		lookup: readSessionFile,
+		ctx:    context.Background(),
+		hosted: make(map[string]*hostedSession),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/health", h.handleHealth)
+	mux.HandleFunc("/api/hub/sessions", h.handleSessions)
+	mux.HandleFunc("/api/hub/sessions/", h.handleHostedSession)
+	mux.HandleFunc("/metrics", h.handleMetrics)
+	mux.HandleFunc("/s/", h.handleSession)
+	mux.HandleFunc("/", h.handleDashboard)
+	// The access token is a cookie in the browser, so a page on another
+	// origin must not be able to start or stop sessions with it.
+	h.handler = http.NewCrossOriginProtection().Handler(mux)
+	return h
+}
+
+func (h *hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.handler.ServeHTTP(w, r)
+}
+
+func (h *hub) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]any{"status": "ok", "hub": true})
+}
+
+// hubHostRequest asks the hub to host the session `crit _serve <Args>`
+// run in CWD would serve. Key is the session key the CLI expects.
+type hubHostRequest struct {
+	Key  string   `json:"key"`
+	CWD  string   `json:"cwd"`
+	Args []string `json:"args"`
+}
+
+func (h *hub) handleSessions(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, h.sessions())
+	case http.MethodPost:
+		var req hubHostRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if !filepath.IsAbs(req.CWD) || !isSessionKey(req.Key) {
+			http.Error(w, "cwd must be absolute and key a session key", http.StatusBadRequest)
+			return
+		}
+		entry, err := h.host(req.Key, req.CWD, req.Args)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, entry)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleHostedSession serves DELETE /api/hub/sessions/<key>, which stops a
+// hosted session once its review is saved.
+func (h *hub) handleHostedSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, "/api/hub/sessions/")
+	h.hostedMu.Lock()
+	hs := h.hosted[key]
+	h.hostedMu.Unlock()
+	if hs == nil {
+		http.Error(w, "No hosted session "+key, http.StatusNotFound)
+		return
+	}
+	hs.stop()
+	<-hs.done
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// host starts serving the session that `crit _serve <args>` run in dir would
+// serve, unless it is already running, and returns its session file.
+func (h *hub) host(key, dir string, args []string) (sessionEntry, error) {
+	if h.ctx.Err() != nil {
+		return sessionEntry{}, fmt.Errorf("hub is shutting down")
+	}
+	sf, err := parseServerFlagSet(args, flag.ContinueOnError)
+	if err != nil {
+		return sessionEntry{}, err
+	}
+	if sf.listen != "" {
+		return sessionEntry{}, fmt.Errorf("the hub can't host a session with --listen")
+	}
+	sc, err := resolveServerFlags(dir, sf)
+	if err != nil {
+		return sessionEntry{}, err
+	}
+	sc.quiet = true
+	if got := serveSessionKey(sc); got != key {
+		return sessionEntry{}, fmt.Errorf("session key is %s here, not %s", got, key)
+	}
+
+	h.hostedMu.Lock()
+	defer h.hostedMu.Unlock()
+	if h.hosted[key] != nil {
+		return readSessionFile(key)
+	}
+
+	srv, entry, err := newSessionServer(sc, key, h.port)
+	if err != nil {
+		return sessionEntry{}, fmt.Errorf("creating server: %w", err)
+	}
+	entry.Token = h.token
+	entry.Path = "/s/" + key
+	// The CLI talks to the session over its own socket, as with a daemon.
+	socketPath, err := sessionSocketPath(key)
+	if err != nil {
+		return sessionEntry{}, err
+	}
+	var cliListener net.Listener
+	if socketPath != "" {
+		if cliListener, err = listenUnix(socketPath, 0600); err != nil {
+			return sessionEntry{}, fmt.Errorf("listening on %s: %w", socketPath, err)
+		}
+		entry.Socket = socketPath
+	}
+	if err := writeSessionFile(key, entry); err != nil {
+		if cliListener != nil {
+			cliListener.Close()
+		}
+		return sessionEntry{}, fmt.Errorf("writing session file: %w", err)
+	}
+
+	ctx, stop := context.WithCancel(h.ctx)
+	var idleMu sync.Mutex
+	lastActivity := time.Now()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if countsAsActivity(r) {
+			idleMu.Lock()
+			lastActivity = time.Now()
+			idleMu.Unlock()
+		}
+		srv.ServeHTTP(w, r)
+	})
+	hs := &hostedSession{handler: handler, stop: stop, done: make(chan struct{})}
+	h.hosted[key] = hs
+
+	var socketServer *http.Server
+	if cliListener != nil {
+		socketServer = &http.Server{Handler: handler, ReadTimeout: 15 * time.Second, IdleTimeout: 60 * time.Second}
+		go func() {
+			if err := socketServer.Serve(cliListener); err != http.ErrServerClosed {
+				log.Printf("Socket error for %s: %v", key, err)
+				stop()
+			}
+		}()
+	}
+	go runIdleTimeoutChecker(ctx, stop, &idleMu, &lastActivity, configIdleTimeout(sc.cfg))
+	go func() {
+		defer close(hs.done)
+		serveSession(ctx, sc, srv, key, newReviewMetrics())
+		h.hostedMu.Lock()
+		delete(h.hosted, key)
+		h.hostedMu.Unlock()
+		shutCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		shutdownServers(shutCtx, socketServer)
+	}()
+
+	if !sc.noOpen {
+		go openBrowser(reviewURL(key, entry))
+	}
+	return entry, nil
+}
+
+// waitHosted stops every hosted session and waits until they are saved.
+func (h *hub) waitHosted() {
+	h.hostedMu.Lock()
+	var sessions []*hostedSession
+	for _, hs := range h.hosted {
+		hs.stop()
+		sessions = append(sessions, hs)
+	}
+	h.hostedMu.Unlock()
+	for _, hs := range sessions {
+		<-hs.done
+	}
+}
+
+// hosting reports whether the hub hosts any sessions.
+func (h *hub) hosting() bool {
+	h.hostedMu.Lock()
+	defer h.hostedMu.Unlock()
+	return len(h.hosted) > 0
+}
+
+func (h *hub) sessions() []hubSession {
+	return sessionRows(h.list())
+}
+
+// sessionRows describes live sessions with their summaries, most recently
+// started first. URL is relative to the hub. It backs the dashboard and
+// `crit list`.
+func sessionRows(entries []sessionEntry, keys []string) []hubSession {
+	rows := make([]hubSession, len(entries))
+	var wg sync.WaitGroup
+	for i, e := range entries {
+		rows[i] = hubSession{
+			Key:       keys[i],
+			URL:       "/s/" + keys[i] + "/",
+			CWD:       e.CWD,
+			Branch:    e.Branch,
+			Files:     e.Args,
+			PID:       e.PID,
+			Port:      e.Port,
+			StartedAt: e.StartedAt,
+		}
+		wg.Add(1)
+		go func(row *hubSession, e sessionEntry) {
+			defer wg.Done()
+			row.Summary = fetchSummary(e)
+		}(&rows[i], e)
+	}
+	wg.Wait()
+	sort.Slice(rows, func(i, j int) bool { return rows[i].StartedAt > rows[j].StartedAt })
+	return rows
+}
+
+func fetchSummary(e sessionEntry) *sessionSummary {
+	resp, err := daemonClient(e, time.Second).Get(daemonURL(e, "/api/summary"))
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+	var s sessionSummary
+	if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+		return nil
+	}
+	return &s
+}
+
+// handleSession serves /s/<key>/<path> as <path> on a hosted session, or
+// proxies it to the session's daemon.
+func (h *hub) handleSession(w http.ResponseWriter, r *http.Request) {
+	key, _, hasSlash := strings.Cut(strings.TrimPrefix(r.URL.Path, "/s/"), "/")
+	if !isSessionKey(key) {
+		http.NotFound(w, r)
+		return
+	}
+	if !hasSlash {
+		// The app loads its assets relative to the page, so it needs the slash.
+		http.Redirect(w, r, "/s/"+key+"/", http.StatusMovedPermanently)
+		return
+	}
+	h.hostedMu.Lock()
+	hs := h.hosted[key]
+	h.hostedMu.Unlock()
+	if hs != nil {
+		http.StripPrefix("/s/"+key, hs.handler).ServeHTTP(w, r)
+		return
+	}
+	entry, err := h.lookup(key)
+	if err != nil {
+		http.Error(w, "No review session "+key, http.StatusNotFound)
+		return
+	}
+	rest := strings.TrimPrefix(r.URL.EscapedPath(), "/s/"+key)
+	target, _ := url.Parse(daemonURL(entry, ""))
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			pr.Out.URL.Path, _ = url.PathUnescape(rest)
+			pr.Out.URL.RawPath = rest
+		},
+		Transport:     daemonTransport(entry), // the socket, or the port with the session's token
+		FlushInterval: -1,                     // stream /api/events as it arrives
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			http.Error(w, "Review session "+key+" is not responding", http.StatusBadGateway)
+		},
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+// handleDashboard serves dashboard.html at / and the shared assets it uses.
+func (h *hub) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/" || r.URL.Path == "/index.html" {
+		http.ServeFileFS(w, r, h.assets, "dashboard.html")
+		return
+	}
+	http.FileServer(http.FS(h.assets)).ServeHTTP(w, r)
+}
+
+// runHub is the _hub subcommand: it serves the hub until signalled, or until
+// it has had neither requests nor live sessions for the idle timeout. The
+// sessions it hosts are saved before it exits.
+func runHub(args []string) {
+	pipe := openReadyPipe()
+
+	flags := flag.NewFlagSet("_hub", flag.ExitOnError)
+	port := flags.Int("port", 0, "Port to listen on (default: random available port)")
+	flags.Parse(args)
+
+	listener, err := bindListener(*port)
+	if err != nil {
+		daemonFatal(pipe, "Error starting hub: %v", err)
+	}
+	addr := listener.Addr().(*net.TCPAddr)
+	token, err := loopbackToken()
+	if err != nil {
+		daemonFatal(pipe, "Error: %v", err)
+	}
+	if err := writeHubFile(hubEntry{
+		PID:       os.Getpid(),
+		Port:      addr.Port,
+		StartedAt: time.Now().UTC().Format(time.RFC3339),
+		Token:     token,
+	}); err != nil {
+		daemonFatal(pipe, "Error writing hub file: %v", err)
+	}
+
+	assets, err := fs.Sub(frontendFS, "frontend")
+	if err != nil {
+		daemonFatal(pipe, "Error loading frontend assets: %v", err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer stop()
+
+	h := newHub(assets)
+	cfg := LoadConfig("")
+	h.metrics = cfg.Metrics
+	h.ctx, h.port, h.token = ctx, addr.Port, token
+	authed := requireLoopbackToken(addr.Port, token, h)
+
+	var idleMu sync.Mutex
+	lastActivity := time.Now()
+	resetActivity := func() {
+		idleMu.Lock()
+		lastActivity = time.Now()
+		idleMu.Unlock()
+	}
+	httpServer := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if countsAsActivity(r) {
+				resetActivity()
+			}
+			// A scraper on the fixed hub_port can't know the token of
+			// this run of the hub; metrics only hold counts.
+			if r.URL.Path == "/metrics" {
+				h.ServeHTTP(w, r)
+				return
+			}
+			authed.ServeHTTP(w, r)
+		}),
+		IdleTimeout: 60 * time.Second,
+	}
+
+	go func() {
+		if err := httpServer.Serve(listener); err != http.ErrServerClosed {
+			log.Printf("Hub error: %v", err)
+			stop()
+		}
+	}()
+	signalReadiness(pipe, addr.Port)
+
+	// Live sessions keep the hub up even when nobody has the dashboard open.
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if sessions, _ := h.list(); len(sessions) > 0 {
+					resetActivity()
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go runIdleTimeoutChecker(ctx, stop, &idleMu, &lastActivity, configIdleTimeout(cfg))
+
+	<-ctx.Done()
+	h.waitHosted()
+	if entry, err := readHubFile(); err == nil && entry.PID == os.Getpid() {
+		if path, err := hubFilePath(); err == nil {
+			os.Remove(path)
+		}
+	}
+	shutCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_ = httpServer.Shutdown(shutCtx)
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testHubKey = "0123456789ab"
+
+// newTestHub returns a hub whose only session is the test server s.
+func newTestHub(t *testing.T, s *Server) *hub {
+	t.Helper()
+	// Like a real daemon's loopback port, it wants the session's token.
+	daemon := httptest.NewServer(requireLoopbackToken(0, "daemon-token", s))
+	t.Cleanup(daemon.Close)
+	u, _ := url.Parse(daemon.URL)
+	port, _ := strconv.Atoi(u.Port())
+	entry := sessionEntry{PID: os.Getpid(), Port: port, CWD: "/src/widgets", Branch: "feature", StartedAt: "2026-03-01T10:00:00Z", Token: "daemon-token"}
+
+	assets, err := fs.Sub(frontendFS, "frontend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newHub(assets)
+	h.list = func() ([]sessionEntry, []string) { return []sessionEntry{entry}, []string{testHubKey} }
+	h.lookup = func(key string) (sessionEntry, error) {
+		if key != testHubKey {
+			return sessionEntry{}, os.ErrNotExist
+		}
+		return entry, nil
+	}
+	return h
+}
+
+func TestIsSessionKey(t *testing.T) {
+	if !isSessionKey(sessionKey("/src", "main", nil)) || !isSessionKey(planSessionKey("/src", "plan")) {
+		t.Error("generated keys should be valid")
+	}
+	for _, bad := range []string{"", "..", "0123456789AB", "0123456789abc", "../../etc/pw"} {
+		if isSessionKey(bad) {
+			t.Errorf("isSessionKey(%q) = true", bad)
+		}
+	}
+}
+
+func TestHub_ProxiesSession(t *testing.T) {
+	s, _ := newTestServer(t)
+	h := newTestHub(t, s)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/s/"+testHubKey+"/api/file?path=test.md", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "line1") {
+		t.Fatalf("proxied file = %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/s/"+testHubKey+"/", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "app.js") {
+		t.Errorf("proxied index = %d", w.Code)
+	}
+}
+
+func TestHub_SessionRoutes(t *testing.T) {
+	s, _ := newTestServer(t)
+	h := newTestHub(t, s)
+
+	tests := []struct {
+		path     string
+		code     int
+		location string
+	}{
+		{"/s/" + testHubKey, http.StatusMovedPermanently, "/s/" + testHubKey + "/"},
+		{"/s/ffffffffffff/", http.StatusNotFound, ""},
+		{"/s/not-a-key/", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+		if w.Code != tt.code || w.Header().Get("Location") != tt.location {
+			t.Errorf("%s: got %d %q, want %d %q", tt.path, w.Code, w.Header().Get("Location"), tt.code, tt.location)
+		}
+	}
+}
+
+func TestHub_ListsSessionsWithSummary(t *testing.T) {
+	s, sess := newTestServer(t)
+	sess.AddComment("test.md", 1, 1, "", "open", "", "", "")
+	c, _ := sess.AddComment("test.md", 2, 2, "", "done", "", "", "")
+	sess.SetCommentResolved("test.md", c.ID, true)
+	sess.setWaitingForAgent(true)
+	h := newTestHub(t, s)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/hub/sessions", nil))
+	var rows []hubSession
+	if err := json.Unmarshal(w.Body.Bytes(), &rows); err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Key != testHubKey || rows[0].URL != "/s/"+testHubKey+"/" || rows[0].Branch != "feature" {
+		t.Fatalf("rows = %+v", rows)
+	}
+	want := sessionSummary{Mode: "files", Round: 1, Unresolved: 1, Resolved: 1, WaitingForAgent: true}
+	if rows[0].Summary == nil || *rows[0].Summary != want {
+		t.Errorf("summary = %+v, want %+v", rows[0].Summary, want)
+	}
+}
+
+func TestHub_ServesDashboard(t *testing.T) {
+	s, _ := newTestServer(t)
+	h := newTestHub(t, s)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	body, _ := io.ReadAll(w.Body)
+	if w.Code != http.StatusOK || !strings.Contains(string(body), "/api/hub/sessions") {
+		t.Errorf("dashboard = %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/theme.css", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("theme.css = %d", w.Code)
+	}
+}
+
+func TestHub_HostsSessionForDirectory(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("CRIT_NO_UPDATE_CHECK", "1")
+	t.Setenv("CRIT_NO_INTEGRATION_CHECK", "1")
+	dir := initTestRepo(t)
+	writeFile(t, filepath.Join(dir, "notes.md"), "hosted line")
+
+	assets, err := fs.Sub(frontendFS, "frontend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newHub(assets)
+	h.token = "hub-token"
+	t.Cleanup(h.waitHosted)
+
+	args := []string{"--no-open", "notes.md"}
+	sf, err := parseServerFlagSet(args, flag.ContinueOnError)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc, err := resolveServerFlags(dir, sf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := serveSessionKey(sc)
+
+	if _, err := h.host(testHubKey, dir, args); err == nil {
+		t.Error("host with the wrong key should fail")
+	}
+	body, _ := json.Marshal(hubHostRequest{Key: key, CWD: dir, Args: args})
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/api/hub/sessions", bytes.NewReader(body)))
+	var entry sessionEntry
+	if err := json.Unmarshal(w.Body.Bytes(), &entry); w.Code != http.StatusOK || err != nil {
+		t.Fatalf("POST /api/hub/sessions = %d %s", w.Code, w.Body.String())
+	}
+	if entry.Path != "/s/"+key || entry.Token != "hub-token" || entry.CWD != dir {
+		t.Errorf("entry = %+v", entry)
+	}
+	if saved, err := readSessionFile(key); err != nil || saved.Path != entry.Path {
+		t.Errorf("session file = %+v, %v", saved, err)
+	}
+
+	// The session loads in the background; the file is read relative to dir.
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/s/"+key+"/api/file?path=notes.md", nil))
+		if w.Code == http.StatusOK && strings.Contains(w.Body.String(), "hosted line") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("hosted file = %d %s", w.Code, w.Body.String())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("DELETE", "/api/hub/sessions/"+key, nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("DELETE = %d", w.Code)
+	}
+	if h.hosting() {
+		t.Error("session still hosted after DELETE")
+	}
+	if _, err := readSessionFile(key); err == nil {
+		t.Error("session file left behind")
+	}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("DELETE", "/api/hub/sessions/"+key, nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("second DELETE = %d, want 404", w.Code)
+	}
+}
+
+func TestHub_RejectsCrossOriginHostRequest(t *testing.T) {
+	assets, err := fs.Sub(frontendFS, "frontend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newHub(assets)
+	req := httptest.NewRequest("POST", "/api/hub/sessions", strings.NewReader(`{}`))
+	req.Header.Set("Origin", "https://evil.example")
+	req.Header.Set("Sec-Fetch-Site", "cross-site")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("cross-origin POST = %d, want 403", w.Code)
+	}
+}
+
+func TestReviewURL_HostedSession(t *testing.T) {
+	e := sessionEntry{Port: 4100, Path: "/s/" + testHubKey, Token: "tok"}
+	if got, want := reviewURL(testHubKey, e), "http://localhost:4100/s/"+testHubKey+"/?token=tok"; got != want {
+		t.Errorf("reviewURL = %q, want %q", got, want)
+	}
+	if got, want := daemonURL(e, "/api/health"), "http://localhost:4100/s/"+testHubKey+"/api/health"; got != want {
+		t.Errorf("daemonURL = %q, want %q", got, want)
+	}
+}
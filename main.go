@@ -52,6 +52,7 @@ var commandDispatch = map[string]func([]string){
 	"check":     func([]string) { runCheck() },
 	"pull":      runPull,
 	"push":      runPush,
+	"sync":      runSync,
 	"comment":   runComment,
 	"review":    runReview,
 	"plan":      runPlan,
@@ -59,8 +60,12 @@ var commandDispatch = map[string]func([]string){
 	"auth":      runAuth,
 	"stop":      runStop,
 	"status":    runStatus,
+	"list":      runList,
+	"attach":    runAttach,
+	"logs":      runLogs,
 	"cleanup":   runCleanup,
 	"_serve":    runServe,
+	"_hub":      runHub,
 }
 
 func main() {
@@ -509,20 +514,15 @@ func parsePullFlags(args []string) pullFlags {
 }
 
 func runPull(args []string) {
-	if err := requireGH(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
-	}
-
 	f := parsePullFlags(args)
 
-	prNumber, err := detectPR(f.prFlag)
+	forge, err := detectForge(loadForgeConfig())
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	ghComments, err := fetchPRComments(prNumber)
+	number, err := forge.DetectChange(f.prFlag)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
@@ -551,10 +551,14 @@ func runPull(args []string) {
 		cj.ReviewRound = 1
 	}
 
-	added := mergeGHComments(&cj, ghComments)
+	res, err := forge.Pull(number, &cj)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 
-	if added == 0 {
-		fmt.Printf("No new inline comments found on PR #%d\n", prNumber)
+	if res.Added == 0 && res.Resolved == 0 {
+		fmt.Printf("No new inline comments found on %s\n", forge.ChangeLabel(number))
 		return
 	}
 
@@ -563,16 +567,32 @@ func runPull(args []string) {
 		os.Exit(1)
 	}
 
-	fmt.Printf("Pulled %d comments from PR #%d into %s\n", added, prNumber, critPath)
+	if res.Added > 0 {
+		fmt.Printf("Pulled %d comments from %s into %s\n", res.Added, forge.ChangeLabel(number), critPath)
+	}
+	if res.Resolved > 0 {
+		fmt.Printf("Updated resolved state of %d comments from %s\n", res.Resolved, forge.ChangeLabel(number))
+	}
 	fmt.Println("Run 'crit' to view them in the browser.")
 }
 
+// loadForgeConfig returns the config for the current repository, for detectForge.
+func loadForgeConfig() Config {
+	dir := ""
+	if vcs := DetectVCS(""); vcs != nil {
+		dir, _ = vcs.RepoRoot()
+	}
+	return LoadConfig(dir)
+}
+
 type pushFlags struct {
 	prFlag    int
 	dryRun    bool
 	message   string
 	outputDir string
 	eventFlag string
+	status    bool
+	draft     bool
 }
 
 func parsePushFlags(args []string) pushFlags {
@@ -583,6 +603,14 @@ func parsePushFlags(args []string) pushFlags {
 			f.dryRun = true
 			continue
 		}
+		if arg == "--status" {
+			f.status = true
+			continue
+		}
+		if arg == "--draft" {
+			f.draft = true
+			continue
+		}
 		if arg == "--message" || arg == "-m" {
 			if i+1 >= len(args) {
 				fmt.Fprintf(os.Stderr, "Error: --message requires a value\n")
@@ -612,7 +640,7 @@ func parsePushFlags(args []string) pushFlags {
 		}
 		n, err := strconv.Atoi(arg)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Usage: crit push [--dry-run] [--event <type>] [--message <msg>] [--output <dir>] [pr-number]\n")
+			fmt.Fprintf(os.Stderr, "Usage: crit push [--dry-run] [--event <type>] [--message <msg>] [--output <dir>] [--status] [--draft] [pr-number]\n")
 			os.Exit(1)
 		}
 		f.prFlag = n
@@ -620,13 +648,13 @@ func parsePushFlags(args []string) pushFlags {
 	return f
 }
 
-func displayPushDryRun(ghComments []map[string]interface{}, allReplies []ghReplyForPush, prNumber int, event, message string) {
+func displayPushDryRun(plan ghPushPlan, allReplies []ghReplyForPush, prNumber int, event, message string) {
 	displayEvent := strings.ToLower(strings.ReplaceAll(event, "_", "-"))
-	fmt.Printf("Would post %d comments to PR #%d (event: %s):\n\n", len(ghComments), prNumber, displayEvent)
-	if message != "" {
-		fmt.Printf("  Review body: %s\n\n", message)
+	fmt.Printf("Would post %d comments to PR #%d (event: %s):\n\n", len(plan.Comments), prNumber, displayEvent)
+	if body := plan.reviewBody(message); body != "" {
+		fmt.Printf("  Review body: %s\n\n", strings.ReplaceAll(body, "\n", "\n    "))
 	}
-	for _, c := range ghComments {
+	for _, c := range plan.Comments {
 		path, _ := c["path"].(string)
 		line, _ := c["line"].(int)
 		body, _ := c["body"].(string)
@@ -637,16 +665,20 @@ func displayPushDryRun(ghComments []map[string]interface{}, allReplies []ghReply
 		}
 		fmt.Printf("    %s\n\n", body)
 	}
+	for _, fc := range plan.FileComments {
+		fmt.Printf("  %s (file-level)\n", fc.Path)
+		fmt.Printf("    %s\n\n", strings.ReplaceAll(fc.Body, "\n", "\n    "))
+	}
 	for _, reply := range allReplies {
 		fmt.Printf("  Would reply to GitHub comment %d: %.60s\n", reply.ParentGHID, reply.Body)
 	}
 }
 
-func postPushReplies(prNumber int, allReplies []ghReplyForPush) map[replyKey]int64 {
+func postPushReplies(api githubAPI, prNumber int, allReplies []ghReplyForPush) map[replyKey]int64 {
 	replyCount := 0
 	replyIDs := make(map[replyKey]int64)
 	for _, reply := range allReplies {
-		replyID, err := postGHReply(prNumber, reply.ParentGHID, reply.Body)
+		replyID, err := postGHReply(api, prNumber, reply.ParentGHID, reply.Body)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Warning: failed to post reply: %v\n", err)
 		} else {
@@ -663,11 +695,6 @@ func postPushReplies(prNumber int, allReplies []ghReplyForPush) map[replyKey]int
 }
 
 func runPush(args []string) {
-	if err := requireGH(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
-	}
-
 	f := parsePushFlags(args)
 
 	event, err := parsePushEvent(f.eventFlag)
@@ -680,7 +707,13 @@ func runPush(args []string) {
 		os.Exit(1)
 	}
 
-	prNumber, err := detectPR(f.prFlag)
+	forge, err := detectForge(loadForgeConfig())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	number, err := forge.DetectChange(f.prFlag)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
@@ -702,35 +735,113 @@ func runPush(args []string) {
 		os.Exit(1)
 	}
 
-	ghComments := critJSONToGHComments(cj)
-	if len(ghComments) == 0 && event == "COMMENT" {
-		fmt.Println("No unresolved comments to push.")
-		return
+	gh, isGitHub := forge.(githubForge)
+	if f.status && !isGitHub {
+		fmt.Fprintf(os.Stderr, "Error: --status is only supported for GitHub\n")
+		os.Exit(1)
 	}
 
-	var allReplies []ghReplyForPush
-	for _, cf := range cj.Files {
-		allReplies = append(allReplies, collectNewRepliesForPush(cf)...)
+	if f.draft && forge.Name() != "gerrit" {
+		fmt.Fprintf(os.Stderr, "Error: --draft is only supported for Gerrit\n")
+		os.Exit(1)
 	}
 
-	if f.dryRun {
-		displayPushDryRun(ghComments, allReplies, prNumber, event, f.message)
-		return
+	opts := pushOptions{DryRun: f.dryRun, Message: f.message, Event: event, Draft: f.draft}
+	if err := forge.Push(number, critPath, cj, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
 
-	displayEvent := strings.ToLower(strings.ReplaceAll(event, "_", "-"))
-	fmt.Printf("Pushing %d comments to PR #%d (%s)...\n", len(ghComments), prNumber, displayEvent)
-	commentIDs, err := createGHReview(prNumber, ghComments, f.message, event)
+	if f.status {
+		outcome := reviewOutcomeFrom(cj)
+		if f.dryRun {
+			fmt.Printf("Would publish %s on HEAD: %s\n", reviewStatusName, outcome.title())
+			return
+		}
+		kind, err := publishReviewStatus(gh.api, "", outcome)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: publishing review status: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Published %s %s on HEAD: %s\n", reviewStatusName, kind, outcome.title())
+	}
+}
+
+type syncFlags struct {
+	prFlag    int
+	dryRun    bool
+	prefer    string
+	outputDir string
+}
+
+func parseSyncFlags(args []string) syncFlags {
+	var f syncFlags
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--dry-run":
+			f.dryRun = true
+		case arg == "--prefer":
+			if i+1 >= len(args) || (args[i+1] != "local" && args[i+1] != "remote") {
+				fmt.Fprintf(os.Stderr, "Error: --prefer requires local or remote\n")
+				os.Exit(1)
+			}
+			i++
+			f.prefer = args[i]
+		case arg == "--output" || arg == "-o":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "Error: --output requires a value\n")
+				os.Exit(1)
+			}
+			i++
+			f.outputDir = args[i]
+		default:
+			n, err := strconv.Atoi(arg)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Usage: crit sync [--dry-run] [--prefer local|remote] [--output <dir>] [pr-number]\n")
+				os.Exit(1)
+			}
+			f.prFlag = n
+		}
+	}
+	return f
+}
+
+// runSync pulls new comments and reconciles resolved state with the forge in
+// both directions. Only forges implementing forgeSyncer are supported.
+func runSync(args []string) {
+	f := parseSyncFlags(args)
+
+	forge, err := detectForge(loadForgeConfig())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	syncer, ok := forge.(forgeSyncer)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: crit sync is not supported for %s (use crit pull and crit push)\n", forge.Name())
+		os.Exit(1)
+	}
+
+	number, err := forge.DetectChange(f.prFlag)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
-	fmt.Printf("Posted %d review comments to PR #%d (%s)\n", len(ghComments), prNumber, displayEvent)
 
-	replyIDs := postPushReplies(prNumber, allReplies)
+	critPath, err := resolveReviewPath(f.outputDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if _, err := os.Stat(critPath); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: no review file found. Run a crit review or crit pull first.\n")
+		os.Exit(1)
+	}
 
-	if err := updateCritJSONWithGitHubIDs(critPath, commentIDs, replyIDs); err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: failed to update review file with GitHub IDs: %v\n", err)
+	if err := syncer.Sync(number, critPath, f.prefer, f.dryRun); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
 }
 
@@ -1138,7 +1249,7 @@ func connectOrStartDaemon(key string, args []string, noOpen bool) (sessionEntry,
 	if alive {
 		fmt.Fprintf(os.Stderr, "Connected to crit daemon on port %d\n", entry.Port)
 		if !noOpen && !daemonHasBrowser(entry) {
-			go openBrowser(fmt.Sprintf("http://localhost:%d", entry.Port))
+			go openBrowser(reviewURL(key, entry))
 		}
 		return entry, false
 	}
@@ -1153,23 +1264,19 @@ func connectOrStartDaemon(key string, args []string, noOpen bool) (sessionEntry,
 	return entry, true
 }
 
-func installDaemonSignalHandler(pid int) {
+func installDaemonSignalHandler(key string, entry sessionEntry) {
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
 		<-sigCh
-		if proc, err := os.FindProcess(pid); err == nil {
-			proc.Signal(syscall.SIGTERM)
-		}
+		terminateDaemon(key, entry)
 		os.Exit(0)
 	}()
 }
 
-func killDaemonOnApproval(approved bool, pid int) {
+func killDaemonOnApproval(approved bool, key string, entry sessionEntry) {
 	if approved {
-		if proc, err := os.FindProcess(pid); err == nil {
-			proc.Signal(syscall.SIGTERM)
-		}
+		terminateDaemon(key, entry)
 	}
 }
 
@@ -1194,6 +1301,7 @@ func deleteStaleReviewsSilent(stale []staleReview) {
 		if os.Remove(s.path) != nil {
 			continue
 		}
+		os.Remove(roundStatePathFor(s.path))
 		if sessDir != "" {
 			os.Remove(filepath.Join(sessDir, s.key+".json"))
 			os.Remove(filepath.Join(sessDir, s.key+".lock"))
@@ -1207,6 +1315,7 @@ func deleteStaleReviewsSilent(stale []staleReview) {
 func cleanupOnApproval(approved bool, reviewPath string, cleanupEnabled bool) {
 	if approved && cleanupEnabled && reviewPath != "" {
 		os.Remove(reviewPath)
+		os.Remove(roundStatePathFor(reviewPath))
 	}
 }
 
@@ -1238,11 +1347,11 @@ func runPlan(args []string) {
 	entry, weStartedDaemon := connectOrStartDaemon(key, daemonArgs, pc.noOpen)
 
 	if weStartedDaemon {
-		installDaemonSignalHandler(entry.PID)
+		installDaemonSignalHandler(key, entry)
 	}
 
-	approved := runReviewClient(entry)
-	killDaemonOnApproval(approved, entry.PID)
+	approved := runReviewClient(key, &entry, func() (sessionEntry, error) { return startDaemon(key, daemonArgs) })
+	killDaemonOnApproval(approved, key, entry)
 	cleanupOnApproval(approved, entry.ReviewPath, LoadConfig(cwd).CleanupOnApproveEnabled())
 }
 
@@ -1337,7 +1446,7 @@ func runPlanHook() {
 	if alive {
 		fmt.Fprintf(os.Stderr, "crit plan-hook: connected to daemon on port %d\n", entry.Port)
 		if !daemonHasBrowser(entry) {
-			go openBrowser(fmt.Sprintf("http://localhost:%d", entry.Port))
+			go openBrowser(reviewURL(key, entry))
 		}
 	} else {
 		entry, err = startDaemon(key, daemonArgs)
@@ -1350,11 +1459,11 @@ func runPlanHook() {
 	}
 
 	if weStartedDaemon {
-		installDaemonSignalHandler(entry.PID)
+		installDaemonSignalHandler(key, entry)
 	}
 
 	approved, prompt := runReviewClientRaw(entry)
-	killDaemonOnApproval(approved, entry.PID)
+	killDaemonOnApproval(approved, key, entry)
 	cleanupOnApproval(approved, entry.ReviewPath, LoadConfig(cwd).CleanupOnApproveEnabled())
 	emitHookDecision(approved, prompt)
 }
@@ -1363,12 +1472,12 @@ func runPlanHook() {
 // returning 503 Service Unavailable (session not yet initialized). Returns the
 // last response status code and body, or an error if the daemon is unreachable
 // or the 5-minute deadline expires.
-func waitForDaemonReady(client *http.Client, port int) (statusCode int, body []byte, err error) {
+func waitForDaemonReady(client *http.Client, entry sessionEntry) (statusCode int, body []byte, err error) {
 	deadline := time.Now().Add(5 * time.Minute)
 	for {
-		resp, reqErr := client.Get(fmt.Sprintf("http://localhost:%d/api/session", port))
+		resp, reqErr := client.Get(daemonURL(entry, "/api/session"))
 		if reqErr != nil {
-			return 0, nil, fmt.Errorf("could not reach daemon on port %d: %w", port, reqErr)
+			return 0, nil, fmt.Errorf("could not reach daemon on %s: %w", daemonAddr(entry), reqErr)
 		}
 		respBody, _ := io.ReadAll(resp.Body)
 		resp.Body.Close()
@@ -1385,16 +1494,16 @@ func waitForDaemonReady(client *http.Client, port int) (statusCode int, body []b
 // runReviewClientRaw is like runReviewClient but returns (approved, prompt)
 // without writing to stdout — used by runPlanHook to construct hookSpecificOutput.
 func runReviewClientRaw(entry sessionEntry) (approved bool, prompt string) {
-	client := &http.Client{Timeout: 24 * time.Hour}
+	client := daemonClient(entry, 24*time.Hour)
 
 	// Wait for the server to finish initializing before calling review-cycle.
-	if _, _, err := waitForDaemonReady(client, entry.Port); err != nil {
+	if _, _, err := waitForDaemonReady(client, entry); err != nil {
 		fmt.Fprintf(os.Stderr, "crit plan-hook: %v\n", err)
 		return true, ""
 	}
 
 	resp, err := client.Post(
-		fmt.Sprintf("http://localhost:%d/api/review-cycle", entry.Port),
+		daemonURL(entry, "/api/review-cycle"),
 		"application/json",
 		nil,
 	)
@@ -1425,6 +1534,10 @@ func runReviewClientRaw(entry sessionEntry) (approved bool, prompt string) {
 func runReview(args []string) {
 	go backgroundCleanup()
 
+	// --pre-review is handled here, not by the daemon, so it must not reach
+	// resolveServerConfig or the session key.
+	args, preReview := cutFlag(args, "--pre-review")
+
 	// Parse args to extract file args (stripping flags like --port, --no-open).
 	// The session key must use only file args to match what runServe computes.
 	sc, err := resolveServerConfig(args)
@@ -1451,7 +1564,7 @@ func runReview(args []string) {
 		fmt.Fprintf(os.Stderr, "Connected to crit daemon on port %d\n", entry.Port)
 		// Re-open browser if no browser tab is connected (user closed it)
 		if !sc.noOpen && !daemonHasBrowser(entry) {
-			go openBrowser(fmt.Sprintf("http://localhost:%d", entry.Port))
+			go openBrowser(reviewURL(key, entry))
 		}
 	} else {
 		// Pass raw args to startDaemon — the _serve process parses them itself
@@ -1466,14 +1579,57 @@ func runReview(args []string) {
 
 	// If we started the daemon, clean it up on Ctrl+C
 	if weStartedDaemon {
-		installDaemonSignalHandler(entry.PID)
+		installDaemonSignalHandler(key, entry)
+	}
+	printRemoteAccess(entry)
+
+	if preReview {
+		requestPreReview(entry)
 	}
 
-	approved := runReviewClient(entry)
-	killDaemonOnApproval(approved, entry.PID)
+	approved := runReviewClient(key, &entry, func() (sessionEntry, error) { return startDaemon(key, args) })
+	killDaemonOnApproval(approved, key, entry)
 	cleanupOnApproval(approved, entry.ReviewPath, LoadConfig(cwd).CleanupOnApproveEnabled())
 }
 
+// cutFlag removes every occurrence of a boolean flag from args and reports
+// whether it was present.
+func cutFlag(args []string, name string) ([]string, bool) {
+	var rest []string
+	found := false
+	for _, a := range args {
+		if a == name {
+			found = true
+			continue
+		}
+		rest = append(rest, a)
+	}
+	return rest, found
+}
+
+// requestPreReview asks the daemon to run the agent over the diff. Findings
+// arrive as comments while the review is open; failures are reported but
+// don't block the review.
+func requestPreReview(entry sessionEntry) {
+	client := daemonClient(entry, 30*time.Second)
+	if _, _, err := waitForDaemonReady(client, entry); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: pre-review skipped: %v\n", err)
+		return
+	}
+	resp, err := client.Post(daemonURL(entry, "/api/agent/pre-review"), "application/json", nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: pre-review skipped: %v\n", err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		msg, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Warning: pre-review skipped: %s\n", strings.TrimSpace(string(msg)))
+		return
+	}
+	fmt.Fprintln(os.Stderr, "Agent pre-review started; its comments will appear as it finishes")
+}
+
 // readReviewCycleResponse reads and closes the response body, returning an
 // error for non-success status codes. This avoids exitAfterDefer by ensuring
 // the body is closed before the caller decides to os.Exit.
@@ -1486,19 +1642,93 @@ func readReviewCycleResponse(resp *http.Response) ([]byte, error) {
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("reading response: %w", err)
+		return nil, fmt.Errorf("%w: reading response: %v", errDaemonConnLost, err)
+	}
+	if resp.StatusCode == http.StatusServiceUnavailable {
+		var status struct {
+			Status string `json:"status"`
+		}
+		json.Unmarshal(body, &status)
+		if status.Status == "stopped" {
+			return nil, fmt.Errorf("the crit daemon stopped before the review was finished")
+		}
+		return nil, fmt.Errorf("%w: daemon not ready", errDaemonConnLost)
 	}
 	return body, nil
 }
 
+// errDaemonConnLost means the connection to the daemon went away before it
+// answered, as when the daemon crashes.
+var errDaemonConnLost = errors.New("lost connection to the crit daemon")
+
+// A review client looks for its daemon for up to reviewReconnectTimeout
+// after losing it, trying every reviewReconnectInterval.
+var (
+	reviewReconnectTimeout  = time.Minute
+	reviewReconnectInterval = time.Second
+)
+
+// awaitReview waits on the daemon's review cycle and returns its answer. If
+// the connection drops first, it finds the session's daemon again, starting
+// it with restart when it is gone and restart is not nil, and carries on
+// waiting where it left off. It also returns the daemon that answered.
+func awaitReview(key string, entry sessionEntry, restart func() (sessionEntry, error)) ([]byte, sessionEntry, error) {
+	path := "/api/review-cycle"
+	for {
+		body, err := postReviewCycle(entry, path)
+		if !errors.Is(err, errDaemonConnLost) {
+			return body, entry, err
+		}
+		fmt.Fprintf(os.Stderr, "%v; reconnecting...\n", err)
+		if entry, err = reconnectDaemon(key, restart); err != nil {
+			return nil, entry, err
+		}
+		fmt.Fprintf(os.Stderr, "Reconnected to crit daemon on port %d\n", entry.Port)
+		path = "/api/review-cycle?resume=1"
+	}
+}
+
+func postReviewCycle(entry sessionEntry, path string) ([]byte, error) {
+	resp, err := daemonClient(entry, 24*time.Hour).Post(daemonURL(entry, path), "application/json", nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w on %s: %v", errDaemonConnLost, daemonAddr(entry), err)
+	}
+	return readReviewCycleResponse(resp)
+}
+
+// reconnectDaemon waits for the session's daemon to be up and ready again.
+func reconnectDaemon(key string, restart func() (sessionEntry, error)) (sessionEntry, error) {
+	deadline := time.Now().Add(reviewReconnectTimeout)
+	for {
+		entry, ok := findAliveSession(key)
+		if !ok && restart != nil {
+			var err error
+			entry, err = restart()
+			ok = err == nil
+		}
+		if ok {
+			code, _, err := waitForDaemonReady(daemonClient(entry, 24*time.Hour), entry)
+			if err == nil && code == http.StatusOK {
+				return entry, nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return sessionEntry{}, fmt.Errorf("could not reconnect to the crit daemon within %s", reviewReconnectTimeout)
+		}
+		time.Sleep(reviewReconnectInterval)
+	}
+}
+
 // runReviewClient connects to a running daemon/server, blocks until the user
 // finishes reviewing, prints feedback to stdout, and returns whether the
-// review was approved (no unresolved comments).
-func runReviewClient(entry sessionEntry) (approved bool) {
-	client := &http.Client{Timeout: 24 * time.Hour}
+// review was approved (no unresolved comments). If the daemon goes away
+// meanwhile, it is found again or started with restart, and entry is updated
+// to the daemon that answered; see awaitReview.
+func runReviewClient(key string, entry *sessionEntry, restart func() (sessionEntry, error)) (approved bool) {
+	client := daemonClient(*entry, 24*time.Hour)
 
 	// Wait for the server to finish initializing before calling review-cycle.
-	statusCode, body, err := waitForDaemonReady(client, entry.Port)
+	statusCode, body, err := waitForDaemonReady(client, *entry)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
@@ -1515,17 +1745,7 @@ func runReviewClient(entry sessionEntry) (approved bool) {
 		os.Exit(1)
 	}
 
-	resp, err := client.Post(
-		fmt.Sprintf("http://localhost:%d/api/review-cycle", entry.Port),
-		"application/json",
-		nil,
-	)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: could not reach crit daemon on port %d: %v\n", entry.Port, err)
-		os.Exit(1)
-	}
-
-	body, err = readReviewCycleResponse(resp)
+	body, *entry, err = awaitReview(key, *entry, restart)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
@@ -1645,7 +1865,30 @@ type serverConfig struct {
 	planName           string // display name for plan content
 	reviewPath         string // centralized review file path (~/.crit/reviews/<key>.json)
 	vcsOverride        string // "git", "sl"/"sapling", or "" for auto-detect
+	listen             string // --listen address for remote access, "" for loopback only
+	tls                bool   // serve the --listen address over TLS
+	tlsCert            string // --tls-cert, "" for a self-signed certificate
+	tlsKey             string // --tls-key
 	cfg                Config // full resolved config for the settings panel
+	dir                string // directory crit was started in; "" for the working directory (see hub.go)
+}
+
+// cwd returns the directory crit was started in, with symlinks resolved.
+func (sc *serverConfig) cwd() string {
+	if sc.dir != "" {
+		return sc.dir
+	}
+	cwd, _ := resolvedCWD()
+	return cwd
+}
+
+// absPath resolves path against the directory crit was started in.
+func (sc *serverConfig) absPath(path string) string {
+	if sc.dir != "" && !filepath.IsAbs(path) {
+		return filepath.Join(sc.dir, path)
+	}
+	abs, _ := filepath.Abs(path)
+	return abs
 }
 
 // serverFlagSet holds the parsed flag values before config resolution.
@@ -1661,11 +1904,23 @@ type serverFlagSet struct {
 	vcsOverride string
 	planDir     string
 	planName    string
+	listen      string
+	tls         bool
+	tlsCert     string
+	tlsKey      string
 	fileArgs    []string
 }
 
 func parseServerFlags(args []string) serverFlagSet {
-	fs := flag.NewFlagSet("crit", flag.ExitOnError)
+	sf, _ := parseServerFlagSet(args, flag.ExitOnError)
+	return sf
+}
+
+// parseServerFlagSet is parseServerFlags with the given error handling. With
+// flag.ContinueOnError it prints nothing and returns the error instead: the
+// hub parses the args of the sessions it hosts and must not exit on a bad one.
+func parseServerFlagSet(args []string, handling flag.ErrorHandling) (serverFlagSet, error) {
+	fs := flag.NewFlagSet("crit", handling)
 	port := fs.Int("port", 0, "Port to listen on (default: random available port)")
 	fs.IntVar(port, "p", 0, "Port to listen on (shorthand)")
 	noOpen := fs.Bool("no-open", false, "Don't auto-open browser")
@@ -1681,10 +1936,20 @@ func parseServerFlags(args []string) serverFlagSet {
 	vcsFlag := fs.String("vcs", "", "VCS backend to use: git, sl/sapling (default: auto-detect)")
 	planDir := fs.String("plan-dir", "", "")
 	planName := fs.String("name", "", "")
+	listen := fs.String("listen", "", "Also serve the review on this address, e.g. 0.0.0.0:8443 (requires the access token), or on unix:<path> for a reverse proxy")
+	useTLS := fs.Bool("tls", false, "Serve the --listen address over HTTPS")
+	tlsCert := fs.String("tls-cert", "", "TLS certificate for --tls (default: self-signed)")
+	tlsKey := fs.String("tls-key", "", "TLS private key for --tls-cert")
 	fs.Usage = func() {
 		printHelp()
 	}
-	fs.Parse(args)
+	if handling == flag.ContinueOnError {
+		fs.SetOutput(io.Discard)
+		fs.Usage = func() {}
+	}
+	if err := fs.Parse(args); err != nil {
+		return serverFlagSet{}, err
+	}
 
 	return serverFlagSet{
 		port:        *port,
@@ -1698,8 +1963,12 @@ func parseServerFlags(args []string) serverFlagSet {
 		vcsOverride: *vcsFlag,
 		planDir:     *planDir,
 		planName:    *planName,
+		listen:      *listen,
+		tls:         *useTLS || *tlsCert != "",
+		tlsCert:     *tlsCert,
+		tlsKey:      *tlsKey,
 		fileArgs:    fs.Args(),
-	}
+	}, nil
 }
 
 func resolvePort(flagPort, cfgPort int) int {
@@ -1729,16 +1998,11 @@ func applyConfigDefaults(sf *serverFlagSet, cfg Config) {
 	if sf.baseBranch == "" && cfg.BaseBranch != "" {
 		sf.baseBranch = cfg.BaseBranch
 	}
-	if sf.baseBranch != "" {
-		setDefaultBranchOverride(sf.baseBranch)
-	}
 }
 
 // resolveServerConfig parses flags, loads config files, and resolves the
 // final server configuration from all sources (CLI > env > config > defaults).
 // Returns nil when the command should exit early (e.g. --version).
-//
-//nolint:unparam // error return is future-proofing for config validation
 func resolveServerConfig(args []string) (*serverConfig, error) {
 	sf := parseServerFlags(args)
 
@@ -1747,16 +2011,28 @@ func resolveServerConfig(args []string) (*serverConfig, error) {
 		return nil, nil
 	}
 
-	configDir := ""
-	if vcs := DetectVCS(sf.vcsOverride); vcs != nil {
-		configDir, _ = vcs.RepoRoot()
+	sc, err := resolveServerFlags("", sf)
+	if err != nil {
+		return nil, err
 	}
-	if configDir == "" {
-		configDir, _ = os.Getwd()
+	if sc.baseBranch != "" {
+		setDefaultBranchOverride(sc.baseBranch)
 	}
-	cfg := LoadConfig(configDir)
+	return sc, nil
+}
+
+// resolveServerFlags resolves parsed flags for a session started in dir, or
+// in the working directory when dir is empty. Unlike resolveServerConfig it
+// leaves the process-wide default branch alone, so the hub can use it for
+// the sessions it hosts; createSession applies --base-branch to the
+// session's own VCS.
+func resolveServerFlags(dir string, sf serverFlagSet) (*serverConfig, error) {
+	cfg := LoadConfig(serverConfigDir(dir, sf.vcsOverride))
 
 	applyConfigDefaults(&sf, cfg)
+	if sf.tls && sf.listen == "" {
+		return nil, fmt.Errorf("--tls needs --listen")
+	}
 
 	var ignorePatterns []string
 	if !sf.noIgnore {
@@ -1780,10 +2056,30 @@ func resolveServerConfig(args []string) (*serverConfig, error) {
 		planDir:            sf.planDir,
 		planName:           sf.planName,
 		vcsOverride:        resolveVCSOverride(sf.vcsOverride, cfg.VCS),
+		listen:             sf.listen,
+		tls:                sf.tls,
+		tlsCert:            sf.tlsCert,
+		tlsKey:             sf.tlsKey,
 		cfg:                cfg,
+		dir:                dir,
 	}, nil
 }
 
+// serverConfigDir returns the directory whose .crit.config.json applies to
+// a session started in dir ("" for the working directory): the repository
+// root, or dir itself outside a repository.
+func serverConfigDir(dir, vcsOverride string) string {
+	if vcs := DetectVCSIn(dir, vcsOverride); vcs != nil {
+		if root, _ := vcs.RepoRoot(); root != "" {
+			return root
+		}
+	}
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+	return dir
+}
+
 // resolveVCSOverride returns the effective VCS override.
 // --vcs flag takes precedence over config "vcs" field.
 func resolveVCSOverride(flag, config string) string {
@@ -1797,7 +2093,7 @@ func createSession(sc *serverConfig) (*Session, error) {
 	var session *Session
 	var err error
 	if len(sc.files) == 0 {
-		vcs := DetectVCS(sc.vcsOverride)
+		vcs := DetectVCSIn(sc.dir, sc.vcsOverride)
 		if vcs == nil {
 			return nil, fmt.Errorf("not in a version-controlled repository and no files specified")
 		}
@@ -1806,17 +2102,14 @@ func createSession(sc *serverConfig) (*Session, error) {
 		}
 		session, err = NewSessionFromVCS(vcs, sc.ignorePatterns)
 	} else {
-		session, err = NewSessionFromFiles(sc.files, sc.ignorePatterns)
+		// NewSessionFromFilesIn applies --base-branch to the VCS it detects.
+		// For Sapling, and for a hosted session's git, the instance-level
+		// override is the only one.
+		session, err = NewSessionFromFilesIn(sc.dir, sc.baseBranch, sc.files, sc.ignorePatterns)
 	}
 	if err != nil {
 		return nil, err
 	}
-	// Apply --base-branch override to the session's VCS instance. This covers
-	// files mode where resolveGitContext creates a fresh VCS that doesn't have
-	// the override yet. For Sapling, the instance-level field must be set.
-	if sc.baseBranch != "" && session.VCS != nil {
-		session.VCS.SetDefaultBranchOverride(sc.baseBranch)
-	}
 	// Set ReviewFilePath before loadCritJSON so it reads from the centralized
 	// review file.
 	if sc.reviewPath != "" {
@@ -1836,8 +2129,7 @@ func applySessionOverrides(session *Session, sc *serverConfig) {
 		session.loadCritJSON()
 	}
 	if sc.outputDir != "" {
-		abs, _ := filepath.Abs(sc.outputDir)
-		session.OutputDir = abs
+		session.OutputDir = sc.absPath(sc.outputDir)
 	}
 }
 
@@ -1858,12 +2150,12 @@ func bindListener(port int) (net.Listener, error) {
 }
 
 func serveSessionKey(sc *serverConfig) string {
-	cwd, _ := resolvedCWD()
+	cwd := sc.cwd()
 	if sc.planDir != "" {
 		return planSessionKey(cwd, sc.planName)
 	}
 	branch := ""
-	if vcs := DetectVCS(sc.vcsOverride); vcs != nil {
+	if vcs := DetectVCSIn(sc.dir, sc.vcsOverride); vcs != nil {
 		branch = vcs.CurrentBranch()
 	}
 	return sessionKey(cwd, branch, sc.files)
@@ -1882,9 +2174,13 @@ func checkStaleIntegrations(sc *serverConfig, srv *Server, cwd string) {
 	}
 }
 
-func runIdleTimeoutChecker(ctx context.Context, stop context.CancelFunc, idleMu *sync.Mutex, lastActivity *time.Time) {
-	const idleTimeout = 1 * time.Hour
-	ticker := time.NewTicker(5 * time.Minute)
+// runIdleTimeoutChecker calls stop once nothing has happened for
+// idleTimeout. With an idleTimeout of zero it returns at once.
+func runIdleTimeoutChecker(ctx context.Context, stop context.CancelFunc, idleMu *sync.Mutex, lastActivity *time.Time, idleTimeout time.Duration) {
+	if idleTimeout <= 0 {
+		return
+	}
+	ticker := time.NewTicker(min(5*time.Minute, idleTimeout))
 	defer ticker.Stop()
 	for {
 		select {
@@ -1902,6 +2198,27 @@ func runIdleTimeoutChecker(ctx context.Context, stop context.CancelFunc, idleMu
 	}
 }
 
+// countsAsActivity reports whether r should keep a daemon or the hub from
+// idling out. Metrics scrapes and the liveness probes that crit itself sends
+// (isDaemonAlive, the hub's session list) don't: otherwise the hub polling
+// its daemons every minute would keep all of them, and so itself, up forever.
+func countsAsActivity(r *http.Request) bool {
+	switch r.URL.Path {
+	case "/metrics", "/api/health", "/api/summary":
+		return false
+	}
+	return true
+}
+
+// configIdleTimeout is cfg's idle_timeout, or the default if it is invalid.
+func configIdleTimeout(cfg Config) time.Duration {
+	d, err := cfg.IdleTimeoutDuration()
+	if err != nil {
+		log.Printf("Warning: %v; using %s", err, defaultIdleTimeout)
+	}
+	return d
+}
+
 func runServe(args []string) {
 	pipe := openReadyPipe()
 
@@ -1919,40 +2236,44 @@ func runServe(args []string) {
 		daemonFatal(pipe, "Error starting server: %v", err)
 	}
 	addr := listener.Addr().(*net.TCPAddr)
-
-	srv, err := NewServer(nil, frontendFS, sc.shareURL, sc.authToken, sc.author, version, addr.Port, sc.agentCmd)
+	token, err := loopbackToken()
 	if err != nil {
-		daemonFatal(pipe, "Error creating server: %v", err)
+		daemonFatal(pipe, "Error: %v", err)
 	}
 
-	// Set config-dependent fields for the settings panel
-	srv.cfg = sc.cfg
-	cwd, _ := resolvedCWD()
-	srv.projectDir = cwd
-	if home, err := os.UserHomeDir(); err == nil {
-		srv.homeDir = home
+	var remote *remoteListener
+	if sc.listen != "" {
+		if remote, err = openRemoteListener(sc.listen, sc.tls, sc.tlsCert, sc.tlsKey); err != nil {
+			daemonFatal(pipe, "Error listening on %s: %v", sc.listen, err)
+		}
 	}
+
+	// The CLI talks to the daemon over a private socket; see socket.go.
 	key := serveSessionKey(sc)
-	branch := ""
-	if vcs := DetectVCS(sc.vcsOverride); vcs != nil {
-		branch = vcs.CurrentBranch()
+	socketPath, err := sessionSocketPath(key)
+	if err != nil {
+		daemonFatal(pipe, "Error: %v", err)
 	}
-	if sc.outputDir != "" {
-		abs, _ := filepath.Abs(sc.outputDir)
-		sc.reviewPath = filepath.Join(abs, ".crit.json")
-	} else {
-		sc.reviewPath, _ = reviewFilePath(key)
+	var cliListener net.Listener
+	if socketPath != "" {
+		if cliListener, err = listenUnix(socketPath, 0600); err != nil {
+			daemonFatal(pipe, "Error listening on %s: %v", socketPath, err)
+		}
 	}
-	srv.reviewPath = sc.reviewPath
-	if err := writeSessionFile(key, sessionEntry{
-		PID:        os.Getpid(),
-		Port:       addr.Port,
-		CWD:        cwd,
-		Args:       sc.files,
-		Branch:     branch,
-		ReviewPath: sc.reviewPath,
-		StartedAt:  time.Now().UTC().Format(time.RFC3339),
-	}); err != nil {
+
+	srv, entry, err := newSessionServer(sc, key, addr.Port)
+	if err != nil {
+		daemonFatal(pipe, "Error creating server: %v", err)
+	}
+	entry.Token = token
+	if remote != nil {
+		srv.remoteURL = remote.url
+		entry.RemoteURL, entry.TLSCert = remote.url, remote.fingerprint
+	}
+	if cliListener != nil {
+		entry.Socket = socketPath
+	}
+	if err := writeSessionFile(key, entry); err != nil {
 		daemonFatal(pipe, "Error writing session file: %v", err)
 	}
 
@@ -1964,11 +2285,14 @@ func runServe(args []string) {
 		idleMu.Unlock()
 	}
 
-	httpServer := &http.Server{
-		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if countsAsActivity(r) {
 			resetActivity()
-			srv.ServeHTTP(w, r)
-		}),
+		}
+		srv.ServeHTTP(w, r)
+	})
+	httpServer := &http.Server{
+		Handler:     requireLoopbackToken(addr.Port, token, handler),
 		ReadTimeout: 15 * time.Second,
 		IdleTimeout: 60 * time.Second,
 	}
@@ -1982,15 +2306,93 @@ func runServe(args []string) {
 			stop()
 		}
 	}()
+	// Only the owner can open the socket, so it needs no token.
+	var socketServer *http.Server
+	if cliListener != nil {
+		socketServer = &http.Server{Handler: handler, ReadTimeout: 15 * time.Second, IdleTimeout: 60 * time.Second}
+		go func() {
+			if err := socketServer.Serve(cliListener); err != http.ErrServerClosed {
+				log.Printf("Socket error: %v", err)
+				stop()
+			}
+		}()
+	}
+	if remote != nil {
+		remoteServer := &http.Server{
+			Handler:     remote.handler(handler),
+			ReadTimeout: 15 * time.Second,
+			IdleTimeout: 60 * time.Second,
+		}
+		defer remoteServer.Close()
+		go func() {
+			if err := remoteServer.Serve(remote); err != http.ErrServerClosed {
+				log.Printf("Remote listener error: %v", err)
+				stop()
+			}
+		}()
+	}
 
 	signalReadiness(pipe, addr.Port)
 
+	if sc.cfg.Hub {
+		if _, err := ensureHub(sc.cfg.HubPort); err != nil {
+			log.Printf("Warning: hub unavailable, serving on port %d only: %v", addr.Port, err)
+		}
+	}
 	if !sc.noOpen {
-		go openBrowser(fmt.Sprintf("http://localhost:%d", addr.Port))
+		go openBrowser(reviewURL(key, entry))
 	}
 
-	go runIdleTimeoutChecker(ctx, stop, &idleMu, &lastActivity)
+	go runIdleTimeoutChecker(ctx, stop, &idleMu, &lastActivity, configIdleTimeout(sc.cfg))
+
+	serveSession(ctx, sc, srv, key, nil)
+
+	shutCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	shutdownServers(shutCtx, httpServer, socketServer)
+}
 
+// newSessionServer creates the server for sc's review and the entry that
+// describes it in ~/.crit/sessions/. The browser reaches it on port.
+func newSessionServer(sc *serverConfig, key string, port int) (*Server, sessionEntry, error) {
+	srv, err := NewServer(nil, frontendFS, sc.shareURL, sc.authToken, sc.author, version, port, sc.agentCmd)
+	if err != nil {
+		return nil, sessionEntry{}, err
+	}
+
+	// Set config-dependent fields for the settings panel
+	srv.cfg = sc.cfg
+	cwd := sc.cwd()
+	srv.projectDir = cwd
+	if home, err := os.UserHomeDir(); err == nil {
+		srv.homeDir = home
+	}
+	branch := ""
+	if vcs := DetectVCSIn(sc.dir, sc.vcsOverride); vcs != nil {
+		branch = vcs.CurrentBranch()
+	}
+	if sc.outputDir != "" {
+		sc.reviewPath = filepath.Join(sc.absPath(sc.outputDir), ".crit.json")
+	} else {
+		sc.reviewPath, _ = reviewFilePath(key)
+	}
+	srv.reviewPath = sc.reviewPath
+	return srv, sessionEntry{
+		PID:        os.Getpid(),
+		Port:       port,
+		CWD:        cwd,
+		Args:       sc.files,
+		Branch:     branch,
+		ReviewPath: sc.reviewPath,
+		StartedAt:  time.Now().UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// serveSession loads sc's review into srv and runs it until ctx is done,
+// then removes the session file and saves the review. If the review can't be
+// loaded, srv reports the error until then instead. metrics is the session's
+// own counters, or nil for the process-wide ones.
+func serveSession(ctx context.Context, sc *serverConfig, srv *Server, key string, metrics *reviewMetrics) {
 	type sessionResult struct {
 		session *Session
 		err     error
@@ -1998,8 +2400,8 @@ func runServe(args []string) {
 	ch := make(chan sessionResult, 1)
 	// NOTE: On timeout, the createSession goroutine will leak until its git
 	// operations finish (no context is threaded into the git calls). This is
-	// acceptable because the timeout path sets initErr, which triggers a full
-	// server shutdown and process exit shortly after, cleaning up all goroutines.
+	// acceptable because the timeout path sets initErr and the session is
+	// stopped shortly after; in the hub the goroutine exits once git does.
 	go func() {
 		s, err := createSession(sc)
 		ch <- sessionResult{s, err}
@@ -2018,24 +2420,30 @@ func runServe(args []string) {
 		srv.SetInitErr(initErr)
 		<-ctx.Done()
 		removeSessionFile(key)
-		shutCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-		defer cancel()
-		_ = httpServer.Shutdown(shutCtx)
 		return
 	}
+	session.metrics = metrics
 	applySessionOverrides(session, sc)
 	session.CLIArgs = sc.files
+	agentRequests := session.loadRoundState()
+	session.startWebhooks(newWebhookSender(sc.cfg.Webhooks, key))
 
-	checkStaleIntegrations(sc, srv, cwd)
+	checkStaleIntegrations(sc, srv, sc.cwd())
 
 	if !sc.noUpdateCheck && os.Getenv("CRIT_NO_UPDATE_CHECK") == "" {
 		go srv.CheckForUpdates()
 	}
 	srv.SetSession(session)
+	srv.resumeAgentRequests(agentRequests)
 
 	if session.Mode == "git" {
+		root, branch := session.RepoRoot, session.Branch
 		go func() {
-			if prInfo := detectPRInfo(); prInfo != nil {
+			api, err := srv.githubAPI(root)
+			if err != nil {
+				return
+			}
+			if prInfo := detectPRInfo(api, branch); prInfo != nil {
 				srv.SetPRInfo(prInfo)
 			}
 		}()
@@ -2049,15 +2457,30 @@ func runServe(args []string) {
 
 	removeSessionFile(key)
 	session.Shutdown()
+	srv.closeAgents()
 	session.WriteFiles()
+	session.closeWebhooks(5 * time.Second)
 
 	if session.ReviewFilePath != "" {
 		fmt.Fprintf(os.Stderr, "Review file: %s\n", session.ReviewFilePath)
 	}
+}
 
-	shutCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-	defer cancel()
-	_ = httpServer.Shutdown(shutCtx)
+// shutdownServers shuts the daemon's servers down together; nil ones are
+// skipped.
+func shutdownServers(ctx context.Context, servers ...*http.Server) {
+	var wg sync.WaitGroup
+	for _, s := range servers {
+		if s == nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = s.Shutdown(ctx)
+		}()
+	}
+	wg.Wait()
 }
 
 func runStatus(args []string) {
@@ -2366,6 +2789,7 @@ func deleteStaleReviews(stale []staleReview) int {
 			continue
 		}
 		deleted++
+		os.Remove(roundStatePathFor(s.path))
 		if sessDir != "" {
 			os.Remove(filepath.Join(sessDir, s.key+".json"))
 			os.Remove(filepath.Join(sessDir, s.key+".lock"))
@@ -2390,8 +2814,9 @@ Usage:
   crit share <file> [file...]                Share files to crit-web and print the URL
   crit fetch [--output <dir>]               Fetch comments from crit-web into the review file
   crit unpublish                             Remove a shared review from crit-web
-  crit pull [--output <dir>] [pr-number]     Fetch GitHub PR comments into the review file
-  crit push [--dry-run] [--event <type>] [-m <msg>] [-o <dir>] [pr-number]  Post review comments to a GitHub PR
+  crit pull [--output <dir>] [pr-number]     Fetch GitHub PR / GitLab MR / Gerrit change comments into the review file
+  crit push [--dry-run] [--event <type>] [-m <msg>] [-o <dir>] [--status] [--draft] [pr-number]  Post review comments to a GitHub PR / GitLab MR / Gerrit change
+  crit sync [--dry-run] [--prefer local|remote] [-o <dir>] [pr-number]  Reconcile comments and resolved state with a GitHub PR
   crit plan --name <slug> <file>             Review a plan file (manages versioned copies)
   crit plan --name <slug>                    Read plan from stdin
   crit auth login                            Log in to crit-web via browser
@@ -2399,6 +2824,9 @@ Usage:
   crit auth whoami                           Show current user info
   crit install <agent>                       Install integration files for an AI coding tool
   crit status [--json]                        Print session info (review file, daemon, comments)
+  crit list [--json]                          List every running session on this machine
+  crit attach [--wait] [--no-open] <key>      Open a running session in the browser (--wait: also wait for its review)
+  crit logs [-f] [-n <lines>] <key>           Print a session daemon's log (-f: keep following it)
   crit cleanup [--days N] [--force]           Delete stale review files (default: 7 days)
   crit check                                 Check if installed integrations are up to date
   crit config [--generate]                    Show resolved configuration
@@ -2415,6 +2843,10 @@ Options:
   -q, --quiet                 Suppress status output
       --share-url <url>       Share service URL (e.g. https://crit.md or self-hosted)
       --base-branch <branch>  Base branch to diff against (overrides auto-detection)
+      --pre-review            Have the agent comment on the diff first (crit review)
+      --listen <addr>         Also serve the review on addr (e.g. 0.0.0.0:8443), behind an access token,
+                              or on unix:<path> for a reverse proxy
+      --tls                   Serve --listen over HTTPS (self-signed unless --tls-cert/--tls-key are given)
       --qr                    Print QR code of share URL (with crit share)
   -v, --version               Print version
 
@@ -2424,6 +2856,11 @@ Environment:
   CRIT_NO_UPDATE_CHECK        Disable update check on startup
   CRIT_AUTH_TOKEN              Override the auth token (skip login)
   CRIT_NO_INTEGRATION_CHECK   Disable integration staleness check
+  GITHUB_TOKEN, GH_TOKEN      GitHub token for crit pull/push (when github_token is not set)
+  GITLAB_TOKEN                GitLab token for gitlab.com or gitlab_url (crit pull/push)
+  GITEA_TOKEN                 Gitea/Forgejo token (when gitea_token is not set)
+  GERRIT_USER                 Gerrit username (when gerrit_user is not set)
+  GERRIT_PASSWORD             Gerrit HTTP password (when gerrit_password is not set)
 
 Configuration:
   Global config:   ~/.crit.config.json
@@ -2459,12 +2896,31 @@ Available keys:
   output            string    Output directory for review file
   author            string    Your name for comments (default: git config user.name)
   base_branch       string    Base branch to diff against (overrides auto-detection)
+  forge             string    Review host for pull/push: "github", "gitlab", "gitea", "forgejo" or "gerrit" (default: from origin remote)
+  github_status     bool      Publish a crit/review check on HEAD when you finish a review (default: false)
   ignore_patterns        []string  Gitignore-style patterns to exclude files from review
   no_integration_check   bool      Skip integration staleness check (default: false)
   agent_cmd              string    Shell command to send comments to an AI agent (e.g. "claude -p")
+  agent_concurrency      int       Maximum simultaneous agent runs (default: 2)
+  agent_serialize_files  bool      Don't run two agent jobs on the same file at once (default: false)
+  agent_worktree         bool      Run agents in an isolated git worktree; edits become proposals (default: false)
+  agent_profiles         object    Named agents: {"name": {"cmd", "name", "timeout", "workdir", "protocol"}}
+  agent_default_profile  string    Profile used when a request names none
+  agent_profile_rules    []object  Per-path default profile: [{"pattern", "profile"}], first match wins
+  github_token           string    GitHub API token (default: GITHUB_TOKEN, GH_TOKEN or gh's login)
+  github_api_url         string    GitHub API root (default: from the origin remote's host)
+  gitlab_url             string    Self-hosted GitLab instance URL, e.g. "https://gitlab.example.com"
+  gitea_url              string    Gitea/Forgejo instance URL, e.g. "https://git.example.com"
+  gitea_token            string    Gitea/Forgejo API token for crit pull/push
+  gerrit_url             string    Gerrit instance URL, e.g. "https://review.example.com"
+  gerrit_user            string    Gerrit username for crit pull/push
+  gerrit_password        string    Gerrit HTTP password (Settings > HTTP Credentials)
+  hub                    bool      Serve all reviews from one port with a dashboard (default: false)
+  hub_port               int       Port for the hub (default: random)
+  idle_timeout           string    Stop an idle daemon or hub after this Go duration, or "never" (default: 1h)
   auth_token             string    Authentication token for crit-web share service
 
-Note: agent_cmd and auth_token are global-only (~/.crit.config.json).
+Note: agent_* settings, github_token, github_api_url, gitlab_url, gitea_*, gerrit_* settings, hub, hub_port, idle_timeout and auth_token are global-only (~/.crit.config.json).
 Project-level .crit.config.json cannot override them for security reasons.
 
 Ignore pattern syntax:
@@ -1391,3 +1391,29 @@ func TestParseCommentFlags(t *testing.T) {
 		})
 	}
 }
+
+func TestCutFlag(t *testing.T) {
+	rest, found := cutFlag([]string{"--pre-review", "a.go", "--no-open"}, "--pre-review")
+	if !found || strings.Join(rest, " ") != "a.go --no-open" {
+		t.Errorf("cutFlag = %v, %v", rest, found)
+	}
+	if _, found := cutFlag([]string{"a.go"}, "--pre-review"); found {
+		t.Error("expected flag not found")
+	}
+}
+
+func TestCountsAsActivity(t *testing.T) {
+	for path, want := range map[string]bool{
+		"/":                 true,
+		"/api/comments":     true,
+		"/api/review-cycle": true,
+		"/metrics":          false,
+		"/api/health":       false, // isDaemonAlive
+		"/api/summary":      false, // the hub's session list
+	} {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		if got := countsAsActivity(r); got != want {
+			t.Errorf("countsAsActivity(%s) = %v, want %v", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// With "metrics": true in the config, session daemons and the hub serve
+// /metrics in the Prometheus text format. A daemon reports its own review;
+// the hub reports every session on the machine, each sample labelled with
+// its session key, so one scrape target covers a shared agent box. The
+// format is simple enough that crit writes it itself rather than pulling in
+// a client library.
+
+// reviewMetrics holds one review's counters.
+type reviewMetrics struct {
+	roundsCompleted  *counterVec
+	commentsCreated  *counterVec
+	commentsResolved *counterVec
+	agentFailures    *counterVec
+	agentDuration    *histogramVec
+}
+
+func newReviewMetrics() *reviewMetrics {
+	return &reviewMetrics{
+		roundsCompleted:  newCounterVec("crit_rounds_completed_total", "Review rounds completed by the agent.", ""),
+		commentsCreated:  newCounterVec("crit_comments_created_total", "Comments made in the browser, through the API or in the review file.", ""),
+		commentsResolved: newCounterVec("crit_comments_resolved_total", "Comments marked resolved.", ""),
+		agentFailures:    newCounterVec("crit_agent_run_failures_total", "Agent runs that failed, by agent profile.", "profile"),
+		agentDuration: newHistogramVec("crit_agent_run_duration_seconds", "How long agent runs took, by agent profile.", "profile",
+			[]float64{1, 5, 15, 30, 60, 120, 300, 600, 1800}),
+	}
+}
+
+// critMetrics holds the daemon's counters. A daemon serves one review, so
+// they are process-wide; a session the hub hosts has its own (see
+// Session.metrics).
+var critMetrics = newReviewMetrics()
+
+// diffDuration is process-wide even in the hub: diffs are computed deep in
+// the VCS code, away from any session. The hub reports it for the sessions
+// it hosts under its own key.
+var diffDuration = newHistogramVec("crit_diff_duration_seconds", "Time to compute one file's diff, by when it was loaded: eager at startup or on refresh, lazy when first opened.", "loading",
+	[]float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5})
+
+// observeDiff records a diff computation that started at start.
+func observeDiff(loading string, start time.Time) {
+	diffDuration.observe(loading, time.Since(start))
+}
+
+// counters returns the session's counters: its own in the hub, otherwise
+// the process-wide ones.
+func (s *Session) counters() *reviewMetrics {
+	if s.metrics != nil {
+		return s.metrics
+	}
+	return critMetrics
+}
+
+// countResolved counts a comment becoming resolved.
+func (s *Session) countResolved(resolved bool) {
+	if resolved {
+		s.counters().commentsResolved.inc("")
+	}
+}
+
+// counterVec is a counter, split by the values of one label unless label is
+// empty.
+type counterVec struct {
+	name, help, label string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func newCounterVec(name, help, label string) *counterVec {
+	c := &counterVec{name: name, help: help, label: label, values: map[string]float64{}}
+	if label == "" {
+		c.values[""] = 0 // report 0 before the first event
+	}
+	return c
+}
+
+func (c *counterVec) inc(labelValue string) {
+	c.mu.Lock()
+	c.values[labelValue]++
+	c.mu.Unlock()
+}
+
+func (c *counterVec) get(labelValue string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[labelValue]
+}
+
+func (c *counterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	for _, v := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, metricLabels(c.label, v), formatMetric(c.values[v]))
+	}
+}
+
+// histogramVec is a histogram of durations in seconds, split by one label.
+type histogramVec struct {
+	name, help, label string
+	buckets           []float64 // upper bounds, ascending
+
+	mu     sync.Mutex
+	series map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+func newHistogramVec(name, help, label string, buckets []float64) *histogramVec {
+	return &histogramVec{name: name, help: help, label: label, buckets: buckets, series: map[string]*histogram{}}
+}
+
+func (h *histogramVec) observe(labelValue string, d time.Duration) {
+	secs := d.Seconds()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.series[labelValue]
+	if s == nil {
+		s = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.series[labelValue] = s
+	}
+	if i := sort.SearchFloat64s(h.buckets, secs); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.sum += secs
+	s.count++
+}
+
+func (h *histogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for _, v := range sortedKeys(h.series) {
+		s := h.series[v]
+		labels := metricLabels(h.label, v)
+		var cumulative uint64
+		for i, le := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, metricLabels(h.label, v, "le", formatMetric(le)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, metricLabels(h.label, v, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, labels, formatMetric(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, labels, s.count)
+	}
+}
+
+func writeGauge(w io.Writer, name, help string, value float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %s\n", name, help, name, name, formatMetric(value))
+}
+
+// metricLabels formats name/value pairs as {a="1",b="2"}, skipping pairs with
+// an empty name.
+func metricLabels(pairs ...string) string {
+	var parts []string
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i] != "" {
+			parts = append(parts, pairs[i]+`="`+escapeLabelValue(pairs[i+1])+`"`)
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(v string) string { return labelValueEscaper.Replace(v) }
+
+func formatMetric(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sessionsActiveMetric is reported by the hub only: counting the sessions
+// means probing every daemon, which a scrape of each daemon through the hub
+// would repeat for all of them. The hub drops the gauge from the metrics of
+// daemons that still report it.
+const sessionsActiveMetric = "crit_sessions_active"
+
+// writeDaemonMetrics writes a session daemon's metrics. sess is nil while
+// the session is loading.
+func writeDaemonMetrics(w io.Writer, sess *Session) {
+	clients := 0
+	m := critMetrics
+	if sess != nil {
+		clients = sess.browserClientCount()
+		m = sess.counters()
+	}
+	writeGauge(w, "crit_browser_clients", "Browser tabs connected to the review.", float64(clients))
+	m.roundsCompleted.write(w)
+	m.commentsCreated.write(w)
+	m.commentsResolved.write(w)
+	m.agentDuration.write(w)
+	m.agentFailures.write(w)
+	if m == critMetrics {
+		diffDuration.write(w)
+	}
+}
+
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// handleMetrics serves GET /metrics when metrics are enabled in the config.
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if !s.cfg.Metrics {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", metricsContentType)
+	writeDaemonMetrics(w, s.session.Load())
+}
+
+// handleMetrics serves the hub's GET /metrics: every live session's metrics
+// labelled with its key, and how many sessions there are.
+func (h *hub) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if !h.metrics {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	entries, keys := h.list()
+	texts := make([]string, len(entries))
+	var wg sync.WaitGroup
+	for i, e := range entries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			texts[i] = fetchMetrics(e)
+		}()
+	}
+	wg.Wait()
+
+	var merged metricFamilies
+	for i, text := range texts {
+		merged.add(keys[i], text)
+	}
+	if h.hosting() {
+		// Hosted sessions share the hub's process, and with it diffDuration.
+		var b strings.Builder
+		diffDuration.write(&b)
+		merged.add(hubKey, b.String())
+	}
+	w.Header().Set("Content-Type", metricsContentType)
+	writeGauge(w, sessionsActiveMetric, "Review sessions running on this machine.", float64(len(entries)))
+	merged.write(w)
+}
+
+func fetchMetrics(e sessionEntry) string {
+	resp, err := daemonClient(e, time.Second).Get(daemonURL(e, "/metrics"))
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ""
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// metricFamilies merges several daemons' metrics, keeping each family's
+// samples together as the text format requires.
+type metricFamilies struct {
+	order    []string
+	families map[string]*metricFamily
+}
+
+type metricFamily struct {
+	header  []string // HELP and TYPE, from the first daemon that has them
+	samples []string
+}
+
+// add merges one daemon's metrics, adding a session label to its samples.
+func (m *metricFamilies) add(key, text string) {
+	if m.families == nil {
+		m.families = map[string]*metricFamily{}
+	}
+	var current *metricFamily
+	ownHeader := map[string]bool{} // families whose header comes from this text
+	sc := bufio.NewScanner(strings.NewReader(text))
+	for sc.Scan() {
+		line := sc.Text()
+		if fields := strings.Fields(line); len(fields) >= 3 && fields[0] == "#" && (fields[1] == "HELP" || fields[1] == "TYPE") {
+			name := fields[2]
+			if name == sessionsActiveMetric {
+				current = nil
+				continue
+			}
+			current = m.family(name)
+			if len(current.header) == 0 || ownHeader[name] {
+				current.header = append(current.header, line)
+				ownHeader[name] = true
+			}
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") || current == nil {
+			continue
+		}
+		current.samples = append(current.samples, labelSample(line, key))
+	}
+}
+
+func (m *metricFamilies) family(name string) *metricFamily {
+	f, ok := m.families[name]
+	if !ok {
+		f = &metricFamily{}
+		m.families[name] = f
+		m.order = append(m.order, name)
+	}
+	return f
+}
+
+func (m *metricFamilies) write(w io.Writer) {
+	for _, name := range m.order {
+		f := m.families[name]
+		for _, l := range f.header {
+			fmt.Fprintln(w, l)
+		}
+		for _, l := range f.samples {
+			fmt.Fprintln(w, l)
+		}
+	}
+}
+
+// labelSample adds session="key" to a sample line.
+func labelSample(line, key string) string {
+	label := `session="` + escapeLabelValue(key) + `"`
+	i := strings.IndexAny(line, "{ ")
+	if i < 0 {
+		return line
+	}
+	if line[i] == '{' {
+		return line[:i+1] + label + "," + line[i+1:]
+	}
+	return line[:i] + "{" + label + "}" + line[i:]
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetrics_OffByDefault(t *testing.T) {
+	s, _ := newTestServer(t)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", w.Code)
+	}
+}
+
+func TestMetrics_Daemon(t *testing.T) {
+	s, sess := newTestServer(t)
+	s.cfg.Metrics = true
+	created := critMetrics.commentsCreated.get("")
+	resolved := critMetrics.commentsResolved.get("")
+
+	c, _ := sess.AddComment("test.md", 1, 1, "", "Why?", "", "", "")
+	sess.SetCommentResolved("test.md", c.ID, true)
+	sess.SetCommentResolved("test.md", c.ID, true) // already resolved: not counted again
+	if got := critMetrics.commentsCreated.get("") - created; got != 1 {
+		t.Errorf("comments created += %v, want 1", got)
+	}
+	if got := critMetrics.commentsResolved.get("") - resolved; got != 1 {
+		t.Errorf("comments resolved += %v, want 1", got)
+	}
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		t.Fatalf("status = %d, content type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if strings.Contains(w.Body.String(), "crit_sessions_active") {
+		t.Errorf("a daemon shouldn't report crit_sessions_active, the hub does:\n%s", w.Body.String())
+	}
+	for _, want := range []string{
+		"crit_browser_clients 0",
+		"# TYPE crit_comments_created_total counter",
+		"# TYPE crit_rounds_completed_total counter",
+		"# TYPE crit_agent_run_duration_seconds histogram",
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("metrics missing %q:\n%s", want, w.Body.String())
+		}
+	}
+}
+
+func TestHistogramVec(t *testing.T) {
+	h := newHistogramVec("d_seconds", "help", "kind", []float64{0.1, 1})
+	h.observe("a", 50*time.Millisecond)
+	h.observe("a", 500*time.Millisecond)
+	h.observe("a", 5*time.Second)
+	var b strings.Builder
+	h.write(&b)
+	for _, want := range []string{
+		`d_seconds_bucket{kind="a",le="0.1"} 1`,
+		`d_seconds_bucket{kind="a",le="1"} 2`,
+		`d_seconds_bucket{kind="a",le="+Inf"} 3`,
+		`d_seconds_sum{kind="a"} 5.55`,
+		`d_seconds_count{kind="a"} 3`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("histogram missing %q:\n%s", want, b.String())
+		}
+	}
+}
+
+func TestLabelSample(t *testing.T) {
+	cases := map[string]string{
+		"crit_browser_clients 2":             `crit_browser_clients{session="k1"} 2`,
+		`crit_failures_total{profile="x"} 1`: `crit_failures_total{session="k1",profile="x"} 1`,
+	}
+	for in, want := range cases {
+		if got := labelSample(in, "k1"); got != want {
+			t.Errorf("labelSample(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestMetricFamilies_Merge(t *testing.T) {
+	daemon := "# HELP crit_sessions_active x\n# TYPE crit_sessions_active gauge\ncrit_sessions_active 2\n" +
+		"# HELP crit_browser_clients Tabs.\n# TYPE crit_browser_clients gauge\ncrit_browser_clients 1\n"
+	var m metricFamilies
+	m.add("k1", daemon)
+	m.add("k2", strings.Replace(daemon, "clients 1", "clients 3", 1))
+	var b strings.Builder
+	m.write(&b)
+	want := "# HELP crit_browser_clients Tabs.\n# TYPE crit_browser_clients gauge\n" +
+		"crit_browser_clients{session=\"k1\"} 1\ncrit_browser_clients{session=\"k2\"} 3\n"
+	if b.String() != want {
+		t.Errorf("merged =\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestHub_Metrics(t *testing.T) {
+	s, _ := newTestServer(t)
+	s.cfg.Metrics = true
+	h := newTestHub(t, s)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status with metrics off = %d, want 404", w.Code)
+	}
+
+	h.metrics = true
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+	if !strings.Contains(body, "crit_sessions_active 1\n") {
+		t.Errorf("hub should report one session:\n%s", body)
+	}
+	if strings.Count(body, "# TYPE crit_sessions_active") != 1 {
+		t.Errorf("crit_sessions_active reported more than once:\n%s", body)
+	}
+	if !strings.Contains(body, `crit_browser_clients{session="`+testHubKey+`"} 0`) {
+		t.Errorf("session sample not labelled:\n%s", body)
+	}
+}
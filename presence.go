@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// Presence lets reviewers sharing a session see each other. Every
+// /api/events connection is a client; it tells the others its name and the
+// file it is looking at with a "presence" action, and the daemon answers
+// every change with the whole roster as a transient "presence" event. The
+// hello event carries the roster too, so a new tab doesn't wait for the next
+// change. "typing" actions are relayed as they come; the roster supplies the
+// name to show next to them.
+
+// maxPresenceName caps the display name a client can claim.
+const maxPresenceName = 80
+
+// presence is one connected client as the others see it.
+type presence struct {
+	Client string `json:"client"`
+	Name   string `json:"name,omitempty"`
+	File   string `json:"file,omitempty"`
+	Since  string `json:"since"`
+
+	joined time.Time
+}
+
+// connectClient registers an /api/events stream so it can send client actions.
+func (s *Session) connectClient(id string) {
+	s.subMu.Lock()
+	if s.clients == nil {
+		s.clients = make(map[string]*presence)
+	}
+	now := time.Now().UTC()
+	s.clients[id] = &presence{Client: id, Since: now.Format(time.RFC3339), joined: now}
+	s.subMu.Unlock()
+	s.broadcastPresence()
+}
+
+func (s *Session) disconnectClient(id string) {
+	s.subMu.Lock()
+	delete(s.clients, id)
+	s.subMu.Unlock()
+	s.broadcastPresence()
+}
+
+func (s *Session) hasClient(id string) bool {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+	_, ok := s.clients[id]
+	return ok
+}
+
+// setPresence records the name and current file a client announced.
+func (s *Session) setPresence(id, name, file string) bool {
+	if r := []rune(name); len(r) > maxPresenceName {
+		name = string(r[:maxPresenceName])
+	}
+	s.subMu.Lock()
+	p, ok := s.clients[id]
+	if ok {
+		p.Name, p.File = name, file
+	}
+	s.subMu.Unlock()
+	if ok {
+		s.broadcastPresence()
+	}
+	return ok
+}
+
+// Presence returns the connected clients, longest connected first.
+func (s *Session) Presence() []presence {
+	s.subMu.Lock()
+	roster := make([]presence, 0, len(s.clients))
+	for _, p := range s.clients {
+		roster = append(roster, *p)
+	}
+	s.subMu.Unlock()
+	sort.Slice(roster, func(i, j int) bool { return roster[i].joined.Before(roster[j].joined) })
+	return roster
+}
+
+// presenceJSON is the roster as an event's content.
+func (s *Session) presenceJSON() string {
+	data, _ := json.Marshal(s.Presence())
+	return string(data)
+}
+
+func (s *Session) broadcastPresence() {
+	s.broadcast(SSEEvent{Type: "presence", Content: s.presenceJSON()})
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func roster(t *testing.T, m sseMessage) []presence {
+	t.Helper()
+	var r []presence
+	if err := json.Unmarshal([]byte(m.data.Content), &r); err != nil {
+		t.Fatalf("roster %q: %v", m.data.Content, err)
+	}
+	return r
+}
+
+func TestPresence(t *testing.T) {
+	s, sess := newTestServer(t)
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close) // after the streams are cancelled
+
+	alice := openEvents(t, ts.URL, "")
+	hello := alice.nextOf("hello")
+	if r := roster(t, hello); len(r) != 1 || r[0].Client != hello.data.Client {
+		t.Fatalf("hello roster = %+v", r)
+	}
+	me := hello.data.Client
+
+	bob := openEvents(t, ts.URL, "")
+	if r := roster(t, bob.nextOf("hello")); len(r) != 2 {
+		t.Fatalf("second client's roster = %+v", r)
+	}
+	if r := roster(t, alice.nextOf("presence")); len(r) != 2 {
+		t.Fatalf("roster after bob joined = %+v", r)
+	}
+
+	post := func(body string) {
+		t.Helper()
+		resp, err := http.Post(ts.URL+"/api/events/action", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("%s = %d", body, resp.StatusCode)
+		}
+	}
+	post(`{"client":"` + me + `","type":"presence","filename":"test.md","content":"Alice"}`)
+	r := roster(t, bob.nextOf("presence"))
+	if r[0].Client != me || r[0].Name != "Alice" || r[0].File != "test.md" {
+		t.Errorf("roster after presence = %+v", r)
+	}
+	if got := sess.Presence(); got[0].Name != "Alice" {
+		t.Errorf("Presence() = %+v", got)
+	}
+
+	post(`{"client":"` + me + `","type":"typing","filename":"test.md","content":"{\"line\":2}"}`)
+	if m := bob.next(); m.event != "typing" || m.data.Client != me || m.data.Content != `{"line":2}` {
+		t.Errorf("typing relayed as %+v", m)
+	}
+}
+
+func TestPresence_Disconnect(t *testing.T) {
+	sess := &Session{}
+	sess.connectClient("sc_a")
+	sess.connectClient("sc_b")
+	if !sess.setPresence("sc_a", strings.Repeat("x", 200), "a.go") {
+		t.Fatal("setPresence on a connected client failed")
+	}
+	sess.disconnectClient("sc_b")
+	r := sess.Presence()
+	if len(r) != 1 || len([]rune(r[0].Name)) != maxPresenceName {
+		t.Errorf("roster = %+v", r)
+	}
+	if sess.setPresence("sc_b", "Bob", "") {
+		t.Error("setPresence should fail for a disconnected client")
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	qrterminal "github.com/mdp/qrterminal/v3"
+)
+
+// Remote access (--listen) exposes a review beyond 127.0.0.1, e.g. to a
+// tablet or to a laptop when the agent runs on a dev box. The daemon keeps
+// its loopback listener and socket (see socket.go); the extra listener has
+// an access token of its own, wanted on every request, taken from the
+// ?token= link once and then from a cookie, or from an Authorization:
+// Bearer header.
+// --listen unix:<path> instead serves the review on a socket for a reverse
+// proxy; access is then governed by the socket's permissions and whatever
+// authentication the proxy does, so no token is asked for.
+
+// accessCookie names the cookie that holds the access token in the browser
+// after the first visit. Cookies are shared by every port on a host, so
+// each listener gets its own; otherwise opening one session's link would
+// sign the browser out of another's.
+func accessCookie(port int) string {
+	return "crit_access_" + strconv.Itoa(port)
+}
+
+// newAccessToken returns a random token for one session's remote listener.
+func newAccessToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating access token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// requireAccessToken wraps the handler served on the remote listener and on
+// the loopback ports of the daemon and the hub.
+type requireAccessToken struct {
+	token  string
+	cookie string // see accessCookie
+	secure bool   // serving over TLS, so the cookie can be Secure
+	denied string // tells the user where to find the link
+	next   http.Handler
+}
+
+func (ra requireAccessToken) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if t := r.URL.Query().Get("token"); t != "" && r.Method == http.MethodGet {
+		if !ra.valid(t) {
+			http.Error(w, "Invalid access token", http.StatusUnauthorized)
+			return
+		}
+		// Swap the link's token for a cookie so it doesn't stay in the
+		// address bar and history.
+		http.SetCookie(w, &http.Cookie{
+			Name:     ra.cookie,
+			Value:    t,
+			Path:     "/",
+			HttpOnly: true,
+			Secure:   ra.secure,
+			SameSite: http.SameSiteLaxMode,
+		})
+		q := r.URL.Query()
+		q.Del("token")
+		u := *r.URL
+		u.RawQuery = q.Encode()
+		http.Redirect(w, r, u.RequestURI(), http.StatusFound)
+		return
+	}
+	if t, ok := bearerToken(r); ok && ra.valid(t) {
+		ra.next.ServeHTTP(w, r)
+		return
+	}
+	if c, err := r.Cookie(ra.cookie); err == nil && ra.valid(c.Value) {
+		ra.next.ServeHTTP(w, r)
+		return
+	}
+	http.Error(w, ra.denied, http.StatusUnauthorized)
+}
+
+func (ra requireAccessToken) valid(t string) bool {
+	return subtle.ConstantTimeCompare([]byte(t), []byte(ra.token)) == 1
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	const prefix = "Bearer "
+	h := r.Header.Get("Authorization")
+	if len(h) <= len(prefix) || h[:len(prefix)] != prefix {
+		return "", false
+	}
+	return h[len(prefix):], true
+}
+
+// remoteListener is the --listen listener of a session daemon.
+type remoteListener struct {
+	net.Listener
+	token       string
+	url         string // tokenized link to the review
+	secure      bool
+	fingerprint string // SHA-256 of the TLS certificate, "" without TLS
+	socket      string // path of a unix:<path> listener for a reverse proxy
+}
+
+// proxySocketPerm lets a proxy running as another user in the owner's group
+// connect to a unix:<path> listener.
+const proxySocketPerm = 0660
+
+// openRemoteListener binds addr, over TLS when useTLS is set, and creates
+// the session's access token. An addr of unix:<path> makes a socket for a
+// reverse proxy instead.
+func openRemoteListener(addr string, useTLS bool, certFile, keyFile string) (*remoteListener, error) {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		if useTLS {
+			return nil, fmt.Errorf("--tls does not apply to a unix socket; terminate TLS at the proxy")
+		}
+		l, err := listenUnix(path, proxySocketPerm)
+		if err != nil {
+			return nil, err
+		}
+		return &remoteListener{Listener: l, socket: path}, nil
+	}
+	token, err := newAccessToken()
+	if err != nil {
+		return nil, err
+	}
+	rl := &remoteListener{token: token, secure: useTLS}
+	var tlsCfg *tls.Config
+	if useTLS {
+		if tlsCfg, rl.fingerprint, err = remoteTLSConfig(certFile, keyFile); err != nil {
+			return nil, err
+		}
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	rl.url = remoteURL(addr, l.Addr(), useTLS, token)
+	if tlsCfg != nil {
+		l = tls.NewListener(l, tlsCfg)
+	}
+	rl.Listener = l
+	return rl, nil
+}
+
+// handler puts next behind the access token, except on a proxy socket.
+func (rl *remoteListener) handler(next http.Handler) http.Handler {
+	if rl.socket != "" {
+		return next
+	}
+	port := rl.Addr().(*net.TCPAddr).Port
+	return requireAccessToken{
+		token:  rl.token,
+		cookie: accessCookie(port),
+		secure: rl.secure,
+		denied: "This review needs its access token: open the link crit printed when it started.",
+		next:   next,
+	}
+}
+
+// remoteURL is the tokenized link to a review on the remote listener. When
+// it listens on every interface, the link uses this machine's LAN address.
+func remoteURL(listenAddr string, bound net.Addr, secure bool, token string) string {
+	host, _, _ := net.SplitHostPort(listenAddr)
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = lanAddress()
+	}
+	scheme := "http"
+	if secure {
+		scheme = "https"
+	}
+	port := strconv.Itoa(bound.(*net.TCPAddr).Port)
+	u := url.URL{Scheme: scheme, Host: net.JoinHostPort(host, port), Path: "/", RawQuery: "token=" + token}
+	return u.String()
+}
+
+// lanAddress returns the address other machines most likely reach this one
+// on: the source address of the default route, or the hostname.
+func lanAddress() string {
+	// UDP "dial" sends nothing; it just picks a route.
+	if c, err := net.Dial("udp", "192.0.2.1:9"); err == nil {
+		defer c.Close()
+		return c.LocalAddr().(*net.UDPAddr).IP.String()
+	}
+	if h, err := os.Hostname(); err == nil {
+		return h
+	}
+	return "localhost"
+}
+
+// remoteTLSConfig loads the --tls-cert/--tls-key pair, or crit's own
+// self-signed certificate when neither is given. It also returns the SHA-256
+// fingerprint of the certificate so it can be checked on first connect.
+func remoteTLSConfig(certFile, keyFile string) (*tls.Config, string, error) {
+	if (certFile == "") != (keyFile == "") {
+		return nil, "", fmt.Errorf("--tls-cert and --tls-key must be given together")
+	}
+	if certFile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, "", fmt.Errorf("finding home directory: %w", err)
+		}
+		dir := filepath.Join(home, ".crit", "tls")
+		certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+		if err := ensureSelfSignedCert(certFile, keyFile, time.Now()); err != nil {
+			return nil, "", err
+		}
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, "", fmt.Errorf("loading TLS certificate: %w", err)
+	}
+	sum := sha256.Sum256(cert.Certificate[0])
+	return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, hex.EncodeToString(sum[:]), nil
+}
+
+// ensureSelfSignedCert writes a self-signed certificate for this machine's
+// names and addresses, unless a valid one is already there.
+func ensureSelfSignedCert(certFile, keyFile string, now time.Time) error {
+	if cert, err := tls.LoadX509KeyPair(certFile, keyFile); err == nil {
+		if leaf, err := x509.ParseCertificate(cert.Certificate[0]); err == nil && now.Add(24*time.Hour).Before(leaf.NotAfter) {
+			return nil
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("generating TLS key: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return fmt.Errorf("generating certificate serial: %w", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "crit"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.AddDate(1, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"localhost"},
+	}
+	if h, err := os.Hostname(); err == nil {
+		tmpl.DNSNames = append(tmpl.DNSNames, h)
+	}
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, a := range addrs {
+			if ipNet, ok := a.(*net.IPNet); ok {
+				tmpl.IPAddresses = append(tmpl.IPAddresses, ipNet.IP)
+			}
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return fmt.Errorf("creating certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("encoding TLS key: %w", err)
+	}
+	if err := atomicWriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return err
+	}
+	return atomicWriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+}
+
+// printRemoteAccess shows how to open the review from another device: the
+// tokenized link, as text and as a QR code for phones.
+func printRemoteAccess(entry sessionEntry) {
+	if entry.RemoteURL == "" {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Remote review: %s\n", entry.RemoteURL)
+	if entry.TLSCert != "" {
+		fmt.Fprintf(os.Stderr, "TLS certificate SHA-256: %s\n", entry.TLSCert)
+	}
+	qrterminal.GenerateWithConfig(entry.RemoteURL, qrterminal.Config{
+		Level:      qrterminal.L,
+		Writer:     os.Stderr,
+		HalfBlocks: true,
+		QuietZone:  1,
+	})
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequireAccessToken(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("review")) })
+	h := requireAccessToken{token: "s3cret", cookie: accessCookie(8443), next: ok}
+
+	serve := func(r *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := serve(httptest.NewRequest("GET", "/", nil)); w.Code != http.StatusUnauthorized {
+		t.Errorf("no token: %d, want 401", w.Code)
+	}
+	if w := serve(httptest.NewRequest("GET", "/?token=wrong", nil)); w.Code != http.StatusUnauthorized {
+		t.Errorf("wrong token: %d, want 401", w.Code)
+	}
+
+	w := serve(httptest.NewRequest("GET", "/api/session?scope=all&token=s3cret", nil))
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/api/session?scope=all" {
+		t.Fatalf("token link: %d to %q, want a redirect without the token", w.Code, w.Header().Get("Location"))
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "crit_access_8443" || !cookies[0].HttpOnly {
+		t.Fatalf("cookies = %+v", cookies)
+	}
+
+	r := httptest.NewRequest("POST", "/api/comments", nil)
+	r.AddCookie(cookies[0])
+	if w := serve(r); w.Code != http.StatusOK {
+		t.Errorf("cookie: %d, want 200", w.Code)
+	}
+	r = httptest.NewRequest("GET", "/api/session", nil)
+	r.Header.Set("Authorization", "Bearer s3cret")
+	if w := serve(r); w.Code != http.StatusOK {
+		t.Errorf("bearer: %d, want 200", w.Code)
+	}
+	r = httptest.NewRequest("GET", "/api/session", nil)
+	r.Header.Set("Authorization", "Bearer wrong")
+	if w := serve(r); w.Code != http.StatusUnauthorized {
+		t.Errorf("wrong bearer: %d, want 401", w.Code)
+	}
+}
+
+func TestServer_RejectsCrossOriginWrites(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	post := func(site string) int {
+		r := httptest.NewRequest("POST", "/api/comments", strings.NewReader(`{"body":"hi"}`))
+		if site != "" {
+			r.Header.Set("Sec-Fetch-Site", site)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		return w.Code
+	}
+	if code := post("cross-site"); code != http.StatusForbidden {
+		t.Errorf("cross-site POST = %d, want 403", code)
+	}
+	if code := post("same-origin"); code == http.StatusForbidden {
+		t.Error("same-origin POST was rejected")
+	}
+	if code := post(""); code == http.StatusForbidden {
+		t.Error("CLI POST without browser headers was rejected")
+	}
+}
+
+func TestEnsureSelfSignedCert(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	now := time.Now()
+
+	if err := ensureSelfSignedCert(certFile, keyFile, now); err != nil {
+		t.Fatal(err)
+	}
+	first, _ := os.ReadFile(certFile)
+	if info, _ := os.Stat(keyFile); info.Mode().Perm() != 0600 {
+		t.Errorf("key mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	if err := ensureSelfSignedCert(certFile, keyFile, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := os.ReadFile(certFile); !bytes.Equal(first, again) {
+		t.Error("a valid certificate should be reused")
+	}
+
+	if err := ensureSelfSignedCert(certFile, keyFile, now.AddDate(1, 0, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if renewed, _ := os.ReadFile(certFile); bytes.Equal(first, renewed) {
+		t.Error("an expiring certificate should be replaced")
+	}
+}
+
+func TestRemoteTLSConfig_NeedsBothFiles(t *testing.T) {
+	if _, _, err := remoteTLSConfig("cert.pem", ""); err == nil {
+		t.Error("expected an error for a certificate without a key")
+	}
+}
+
+func TestRemoteURL(t *testing.T) {
+	bound := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 5), Port: 8443}
+	if got := remoteURL("10.0.0.5:0", bound, true, "tok"); got != "https://10.0.0.5:8443/?token=tok" {
+		t.Errorf("got %s", got)
+	}
+	if got := remoteURL(":8443", bound, false, "tok"); strings.Contains(got, "//:") || !strings.HasPrefix(got, "http://") {
+		t.Errorf("got %s, want a concrete host", got)
+	}
+}
+
+func TestOpenRemoteListener_ServesOverTLS(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	rl, err := openRemoteListener("127.0.0.1:0", true, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := newTestServer(t)
+	srv := &http.Server{Handler: rl.handler(s)}
+	go srv.Serve(rl)
+	defer srv.Close()
+
+	if !strings.HasPrefix(rl.url, "https://127.0.0.1:") || len(rl.fingerprint) != 64 {
+		t.Fatalf("url = %s, fingerprint = %q", rl.url, rl.fingerprint)
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	base := strings.SplitN(rl.url, "?", 2)[0]
+
+	resp, err := client.Get(base + "api/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("without token: %d, want 401", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest("GET", base+"api/health", nil)
+	req.Header.Set("Authorization", "Bearer "+rl.token)
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("with token: %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestRemoteListener_CookiePerPort(t *testing.T) {
+	open := func() (*remoteListener, string) {
+		rl, err := openRemoteListener("127.0.0.1:0", false, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { rl.Close() })
+		w := httptest.NewRecorder()
+		rl.handler(http.NotFoundHandler()).ServeHTTP(w, httptest.NewRequest("GET", "/?token="+rl.token, nil))
+		cookies := w.Result().Cookies()
+		if len(cookies) != 1 {
+			t.Fatalf("cookies = %+v", cookies)
+		}
+		return rl, cookies[0].Name
+	}
+	a, cookieA := open()
+	_, cookieB := open()
+	if cookieA == cookieB {
+		t.Errorf("two sessions share the cookie %q", cookieA)
+	}
+	if want := accessCookie(a.Addr().(*net.TCPAddr).Port); cookieA != want {
+		t.Errorf("cookie = %q, want %q", cookieA, want)
+	}
+}
+
+func TestHandleQR_Remote(t *testing.T) {
+	s, _ := newTestServer(t)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/api/qr?remote=1", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("remote off: %d, want 404", w.Code)
+	}
+
+	s.remoteURL = "https://10.0.0.5:8443/?token=tok"
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/api/qr?remote=1", nil))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Body.String(), "<svg") {
+		t.Errorf("remote on: %d", w.Code)
+	}
+}
+
+func TestOpenRemoteListener_UnixSocket(t *testing.T) {
+	if _, err := openRemoteListener("unix:"+filepath.Join(t.TempDir(), "p.sock"), true, "", ""); err == nil {
+		t.Error("expected an error for --tls on a unix socket")
+	}
+
+	path := filepath.Join(t.TempDir(), "proxy.sock")
+	rl, err := openRemoteListener("unix:"+path, false, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != proxySocketPerm {
+		t.Errorf("socket mode = %v, want %v", info.Mode().Perm(), os.FileMode(proxySocketPerm))
+	}
+	if rl.url != "" {
+		t.Errorf("url = %q, want none for a proxy socket", rl.url)
+	}
+	s, _ := newTestServer(t)
+	srv := &http.Server{Handler: rl.handler(s)}
+	go srv.Serve(rl)
+	defer srv.Close()
+
+	// The proxy's requests carry no token.
+	resp, err := daemonClient(sessionEntry{Socket: path}, time.Second).Get("http://proxy/api/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+}
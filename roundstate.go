@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+)
+
+// Some of a review's state lives only in the daemon: the agent's edits this
+// round, whether the reviewer is waiting for the agent, the agent requests
+// in flight and a finish no review client has picked up yet. The daemon
+// saves it next to the review file as it changes, so a daemon restarted
+// after a crash or a reboot carries on where the last one stopped. A review
+// client whose connection drops asks again with ?resume=1 and gets the
+// finish it missed, or keeps waiting, without starting a new round.
+
+// roundState is the state file's content.
+type roundState struct {
+	PendingEdits        int            `json:"pending_edits"`
+	LastRoundEdits      int            `json:"last_round_edits"`
+	AwaitingFirstReview bool           `json:"awaiting_first_review"`
+	WaitingForAgent     bool           `json:"waiting_for_agent"`
+	AgentRequests       []agentRequest `json:"agent_requests,omitempty"`
+	Finish              *roundFinish   `json:"finish,omitempty"`
+}
+
+// roundFinish is a finished review that no review client has received.
+type roundFinish struct {
+	Prompt   string `json:"prompt"`
+	Approved bool   `json:"approved"`
+}
+
+// agentRequest is a comment sent to the agent whose run hadn't finished.
+type agentRequest struct {
+	CommentID string `json:"comment_id"`
+	FilePath  string `json:"file_path"`
+	Profile   string `json:"profile,omitempty"`
+}
+
+// roundStatePathFor returns the state file that goes with a review file. It
+// doesn't end in .json, so nothing mistakes it for a review file.
+func roundStatePathFor(reviewPath string) string {
+	return strings.TrimSuffix(reviewPath, ".json") + ".state"
+}
+
+// roundStatePathLocked is "" for sessions without a review file of their
+// own, such as in tests. Caller must hold s.mu.
+func (s *Session) roundStatePathLocked() string {
+	if s.ReviewFilePath == "" && s.OutputDir == "" {
+		return ""
+	}
+	return roundStatePathFor(s.critJSONPath())
+}
+
+// saveRoundState writes the round state file. It takes s.mu, so call it
+// after releasing the lock.
+func (s *Session) saveRoundState() {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	s.mu.RLock()
+	path := s.roundStatePathLocked()
+	frozen := s.stateFrozen
+	st := roundState{
+		PendingEdits:        s.pendingEdits,
+		LastRoundEdits:      s.lastRoundEdits,
+		AwaitingFirstReview: s.awaitingFirstReview,
+		WaitingForAgent:     s.waitingForAgent,
+		Finish:              s.finish,
+	}
+	for _, id := range sortedKeys(s.agentRequests) {
+		st.AgentRequests = append(st.AgentRequests, s.agentRequests[id])
+	}
+	s.mu.RUnlock()
+	if path == "" || frozen {
+		return
+	}
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := atomicWriteFile(path, data, 0600); err != nil {
+		log.Printf("Warning: saving round state: %v", err)
+	}
+}
+
+// loadRoundState restores the state a previous daemon saved and returns its
+// agent requests, which the caller should send to the agent again.
+func (s *Session) loadRoundState() []agentRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path := s.roundStatePathLocked()
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var st roundState
+	if err := json.Unmarshal(data, &st); err != nil {
+		log.Printf("Warning: ignoring round state %s: %v", path, err)
+		return nil
+	}
+	s.pendingEdits = st.PendingEdits
+	s.lastRoundEdits = st.LastRoundEdits
+	s.awaitingFirstReview = st.AwaitingFirstReview
+	s.waitingForAgent = st.WaitingForAgent
+	s.finish = st.Finish
+	return st.AgentRequests
+}
+
+// removeRoundState deletes the state file, for a review that is over.
+func (s *Session) removeRoundState() {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	s.mu.RLock()
+	path := s.roundStatePathLocked()
+	s.mu.RUnlock()
+	if path != "" {
+		os.Remove(path)
+	}
+}
+
+// freezeRoundState stops saving the round state, so that what a shutting
+// down daemon tears down is not recorded as the state to resume.
+func (s *Session) freezeRoundState() {
+	s.mu.Lock()
+	s.stateFrozen = true
+	s.mu.Unlock()
+}
+
+// recordFinish keeps a finished review until a review client takes it.
+func (s *Session) recordFinish(prompt string, approved bool) {
+	s.mu.Lock()
+	s.finish = &roundFinish{Prompt: prompt, Approved: approved}
+	s.mu.Unlock()
+	s.saveRoundState()
+}
+
+// takeFinish returns the finish no review client has received yet, if any,
+// and forgets it. Handing one over completes the first review; once an
+// approval has been handed over the review is done and its state file goes.
+func (s *Session) takeFinish() *roundFinish {
+	s.mu.Lock()
+	f := s.finish
+	s.finish = nil
+	if f != nil {
+		s.awaitingFirstReview = false
+	}
+	s.mu.Unlock()
+	if f == nil {
+		return nil
+	}
+	if f.Approved {
+		s.removeRoundState()
+	} else {
+		s.saveRoundState()
+	}
+	return f
+}
+
+// trackAgentJob records whether a comment's agent job is still to finish.
+// Pre-review jobs are not resumed, since their findings would be repeated.
+func (s *Session) trackAgentJob(job agentJob) {
+	if job.Kind != "" {
+		return
+	}
+	s.mu.Lock()
+	_, had := s.agentRequests[job.ID]
+	pending := !job.finished()
+	if pending {
+		if s.agentRequests == nil {
+			s.agentRequests = make(map[string]agentRequest)
+		}
+		s.agentRequests[job.ID] = agentRequest{CommentID: job.CommentID, FilePath: job.FilePath, Profile: job.Profile}
+	} else {
+		delete(s.agentRequests, job.ID)
+	}
+	s.mu.Unlock()
+	if had != pending {
+		s.saveRoundState()
+	}
+}
+
+// resumeAgentRequests sends the comments a previous daemon had sent to the
+// agent to it again.
+func (s *Server) resumeAgentRequests(reqs []agentRequest) {
+	if len(reqs) == 0 || !s.agentEnabled() {
+		return
+	}
+	sess := s.session.Load()
+	for _, req := range reqs {
+		comment, filePath, found := sess.FindCommentByID(req.CommentID, req.FilePath)
+		if !found {
+			continue
+		}
+		if _, err := s.agentQueue().Submit(comment.ID, filePath, req.Profile, buildAgentPrompt(comment, filePath)); err != nil {
+			log.Printf("Warning: resuming agent request for %s: %v", comment.ID, err)
+		}
+	}
+}
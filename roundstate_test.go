@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func newStatefulTestServer(t *testing.T) (*Server, *Session) {
+	t.Helper()
+	s, sess := newTestServer(t)
+	sess.ReviewFilePath = filepath.Join(t.TempDir(), "review.json")
+	return s, sess
+}
+
+func TestRoundState_SaveAndLoad(t *testing.T) {
+	_, sess := newStatefulTestServer(t)
+	sess.SetAwaitingFirstReview(true)
+	sess.IncrementEdits()
+	sess.trackAgentJob(agentJob{ID: "job1", CommentID: "c1", FilePath: "test.md", Status: agentJobRunning})
+	sess.recordFinish("fix it", false)
+
+	statePath := roundStatePathFor(sess.ReviewFilePath)
+	if filepath.Ext(statePath) != ".state" {
+		t.Errorf("state path = %q", statePath)
+	}
+
+	restored := &Session{ReviewFilePath: sess.ReviewFilePath}
+	reqs := restored.loadRoundState()
+	if restored.pendingEdits != 1 || !restored.awaitingFirstReview {
+		t.Errorf("restored edits = %d, awaiting = %v", restored.pendingEdits, restored.awaitingFirstReview)
+	}
+	if len(reqs) != 1 || reqs[0].CommentID != "c1" || reqs[0].FilePath != "test.md" {
+		t.Errorf("agent requests = %+v", reqs)
+	}
+	if restored.finish == nil || restored.finish.Prompt != "fix it" {
+		t.Errorf("finish = %+v", restored.finish)
+	}
+
+	sess.trackAgentJob(agentJob{ID: "job1", CommentID: "c1", FilePath: "test.md", Status: agentJobDone})
+	if reqs := (&Session{ReviewFilePath: sess.ReviewFilePath}).loadRoundState(); len(reqs) != 0 {
+		t.Errorf("finished job still saved: %+v", reqs)
+	}
+}
+
+func TestRoundState_TakeFinish(t *testing.T) {
+	_, sess := newStatefulTestServer(t)
+	statePath := roundStatePathFor(sess.ReviewFilePath)
+	sess.SetAwaitingFirstReview(true)
+
+	sess.recordFinish("fix it", false)
+	if f := sess.takeFinish(); f == nil || f.Prompt != "fix it" {
+		t.Fatalf("takeFinish = %+v", f)
+	}
+	if sess.takeFinish() != nil {
+		t.Error("a finish should only be handed over once")
+	}
+	if _, err := os.Stat(statePath); err != nil {
+		t.Errorf("state file should be kept mid-review: %v", err)
+	}
+
+	sess.recordFinish("", true)
+	sess.takeFinish()
+	if _, err := os.Stat(statePath); !os.IsNotExist(err) {
+		t.Errorf("state file should be removed once approved: %v", err)
+	}
+}
+
+func TestRoundState_FrozenOnShutdown(t *testing.T) {
+	_, sess := newStatefulTestServer(t)
+	sess.IncrementEdits()
+	sess.Shutdown()
+	sess.SignalRoundComplete()
+
+	restored := &Session{ReviewFilePath: sess.ReviewFilePath}
+	restored.loadRoundState()
+	if restored.pendingEdits != 1 {
+		t.Errorf("pending edits = %d, want the state from before shutdown", restored.pendingEdits)
+	}
+}
+
+func TestReviewCycle_ReturnsUnclaimedFinish(t *testing.T) {
+	s, sess := newTestServer(t)
+	sess.IncrementEdits()
+	sess.recordFinish("fix it", false)
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("POST", "/api/review-cycle", nil))
+	var resp map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp["prompt"] != "fix it" || resp["approved"] != false {
+		t.Errorf("response = %v", resp)
+	}
+	if sess.GetPendingEdits() != 1 {
+		t.Error("handing over a missed finish should not start a new round")
+	}
+}
+
+func TestReviewCycle_ResumeKeepsRound(t *testing.T) {
+	s, sess := newTestServer(t)
+	sess.IncrementEdits()
+
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("POST", "/api/review-cycle?resume=1", nil))
+		done <- w
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if sess.GetPendingEdits() != 1 {
+		t.Error("resuming should not complete the round")
+	}
+
+	sess.Shutdown()
+	select {
+	case w := <-done:
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("status on shutdown = %d, want 503", w.Code)
+		}
+		if _, err := readReviewCycleResponse(w.Result()); err == nil || errors.Is(err, errDaemonConnLost) {
+			t.Errorf("shutdown should stop the client, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("review-cycle did not return on shutdown")
+	}
+}
+
+func TestAwaitReview_Reconnects(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	oldInterval := reviewReconnectInterval
+	reviewReconnectInterval = 10 * time.Millisecond
+	t.Cleanup(func() { reviewReconnectInterval = oldInterval })
+
+	// The first daemon dies while the client waits.
+	crashed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	}))
+	t.Cleanup(crashed.Close)
+
+	// The restarted one has the finish the reviewer sent in the meantime.
+	s, sess := newTestServer(t)
+	sess.recordFinish("fix it", false)
+	restarted := httptest.NewServer(s)
+	t.Cleanup(restarted.Close)
+
+	restarts := 0
+	restart := func() (sessionEntry, error) {
+		restarts++
+		return sessionEntry{Port: testServerPort(t, restarted)}, nil
+	}
+	body, entry, err := awaitReview(testHubKey, sessionEntry{Port: testServerPort(t, crashed)}, restart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Port != testServerPort(t, restarted) {
+		t.Errorf("answered by port %d, want the restarted daemon", entry.Port)
+	}
+	var resp map[string]any
+	json.Unmarshal(body, &resp)
+	if resp["prompt"] != "fix it" || restarts != 1 {
+		t.Errorf("response = %v after %d restarts", resp, restarts)
+	}
+}
+
+func testServerPort(t *testing.T, ts *httptest.Server) int {
+	t.Helper()
+	u, _ := url.Parse(ts.URL)
+	_, p, _ := net.SplitHostPort(u.Host)
+	port, err := strconv.Atoi(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return port
+}
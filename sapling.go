@@ -9,38 +9,50 @@ import (
 	"sync"
 )
 
-// SaplingVCS implements VCS for Sapling SCM repositories.
+// SaplingVCS implements VCS for Sapling SCM repositories. Like GitVCS, it
+// runs sl in Dir, or in the working directory when Dir is empty.
 type SaplingVCS struct {
+	Dir string // checkout to run sl in; "" for the working directory
+
 	defaultBranchOnce sync.Once
 	defaultBranch     string       // auto-detected value (set via sync.Once)
 	overrideBranch    string       // explicit override (empty = no override)
 	defaultBranchMu   sync.RWMutex // protects defaultBranch and overrideBranch
 }
 
+// in returns the directory for a method called with dir: dir itself, or the
+// bound checkout when dir is empty.
+func (s *SaplingVCS) in(dir string) string {
+	if dir == "" {
+		return s.Dir
+	}
+	return dir
+}
+
 func (s *SaplingVCS) Name() string { return "sl" }
 
 // RepoRoot returns the absolute path to the Sapling repository root.
 func (s *SaplingVCS) RepoRoot() (string, error) {
-	out, err := exec.Command("sl", "root").Output()
+	out, err := slCommandInDir(s.Dir, "root")
 	if err != nil {
-		return "", fmt.Errorf("sl root: %w", err)
+		return "", err
 	}
-	return strings.TrimSpace(string(out)), nil
+	return strings.TrimSpace(out), nil
 }
 
 // CurrentBranch returns the active bookmark or short node hash.
 func (s *SaplingVCS) CurrentBranch() string {
-	out, err := exec.Command("sl", "log", "-r", ".", "-T", "{activebookmark}").Output()
+	out, err := slCommandInDir(s.Dir, "log", "-r", ".", "-T", "{activebookmark}")
 	if err == nil {
-		if b := strings.TrimSpace(string(out)); b != "" {
+		if b := strings.TrimSpace(out); b != "" {
 			return b
 		}
 	}
-	out, err = exec.Command("sl", "log", "-r", ".", "-T", "{node|short}").Output()
+	out, err = slCommandInDir(s.Dir, "log", "-r", ".", "-T", "{node|short}")
 	if err != nil {
 		return ""
 	}
-	return strings.TrimSpace(string(out))
+	return strings.TrimSpace(out)
 }
 
 // DefaultBranch returns the cached default branch, detecting it on first call.
@@ -56,7 +68,7 @@ func (s *SaplingVCS) DefaultBranch() string {
 		s.defaultBranchMu.Lock()
 		// Double-check: an override may have been set between RUnlock and Do.
 		if s.overrideBranch == "" {
-			s.defaultBranch = detectSaplingDefaultBranch()
+			s.defaultBranch = detectSaplingDefaultBranch(s.Dir)
 		}
 		s.defaultBranchMu.Unlock()
 	})
@@ -86,7 +98,9 @@ func (s *SaplingVCS) GetDefaultBranchOverride() string {
 // MergeBase returns the common ancestor between the working copy and ref.
 func (s *SaplingVCS) MergeBase(ref string) (string, error) {
 	revset := fmt.Sprintf("ancestor(., %s)", ref)
-	out, err := exec.Command("sl", "log", "-r", revset, "-T", "{node}").Output()
+	cmd := exec.Command("sl", "log", "-r", revset, "-T", "{node}")
+	cmd.Dir = s.Dir
+	out, err := cmd.Output()
 	if err != nil {
 		return "", fmt.Errorf("sl ancestor: %w", err)
 	}
@@ -95,7 +109,7 @@ func (s *SaplingVCS) MergeBase(ref string) (string, error) {
 
 // ChangedFilesOnDefaultInDir returns changed files when on the default branch.
 func (s *SaplingVCS) ChangedFilesOnDefaultInDir(dir string) ([]FileChange, error) {
-	out, err := slCommandInDir(dir, "status")
+	out, err := slCommandInDir(s.in(dir), "status")
 	if err != nil {
 		return nil, err
 	}
@@ -104,7 +118,7 @@ func (s *SaplingVCS) ChangedFilesOnDefaultInDir(dir string) ([]FileChange, error
 
 // ChangedFilesFromBaseInDir returns files changed between baseRef and the working copy.
 func (s *SaplingVCS) ChangedFilesFromBaseInDir(baseRef, dir string) ([]FileChange, error) {
-	out, err := slCommandInDir(dir, "status", "--rev", baseRef)
+	out, err := slCommandInDir(s.in(dir), "status", "--rev", baseRef)
 	if err != nil {
 		return nil, err
 	}
@@ -123,7 +137,7 @@ func (s *SaplingVCS) ChangedFilesScoped(scope, baseRef string) ([]FileChange, er
 
 // ChangedFilesForCommit returns the files changed in a single commit.
 func (s *SaplingVCS) ChangedFilesForCommit(sha, dir string) ([]FileChange, error) {
-	out, err := slCommandInDir(dir, "status", "--change", sha)
+	out, err := slCommandInDir(s.in(dir), "status", "--change", sha)
 	if err != nil {
 		return nil, err
 	}
@@ -139,9 +153,7 @@ func (s *SaplingVCS) FileDiffUnified(path, baseRef, dir string) ([]DiffHunk, err
 func (s *SaplingVCS) FileDiffUnifiedCtx(ctx context.Context, path, baseRef, dir string) ([]DiffHunk, error) {
 	args := buildDiffArgs(baseRef, path)
 	cmd := exec.CommandContext(ctx, "sl", args...)
-	if dir != "" {
-		cmd.Dir = dir
-	}
+	cmd.Dir = s.in(dir)
 	out, err := cmd.Output()
 	if err != nil {
 		// sl diff exits 1 when there is a diff; check for actual errors.
@@ -166,9 +178,7 @@ func (s *SaplingVCS) FileDiffScoped(path, scope, baseRef, dir string) ([]DiffHun
 // Uses --change which handles initial commits (no parent) correctly.
 func (s *SaplingVCS) FileDiffForCommit(path, sha, dir string) ([]DiffHunk, error) {
 	cmd := exec.Command("sl", "diff", "--change", sha, path)
-	if dir != "" {
-		cmd.Dir = dir
-	}
+	cmd.Dir = s.in(dir)
 	out, err := cmd.Output()
 	if err != nil {
 		if len(out) > 0 {
@@ -198,7 +208,7 @@ func (s *SaplingVCS) CommitLog(baseRef, dir string) ([]CommitInfo, error) {
 	// template engine interprets as newlines (field separators in the output).
 	tpl := "{node}\\n{node|short}\\n{desc|firstline}\\n{author|user}\\n{date|isodate}\\n---\\n"
 	args := []string{"log", "-r", revset, "-T", tpl}
-	out, err := slCommandInDir(dir, args...)
+	out, err := slCommandInDir(s.in(dir), args...)
 	if err != nil {
 		return nil, err
 	}
@@ -210,16 +220,16 @@ func (s *SaplingVCS) CommitLog(baseRef, dir string) ([]CommitInfo, error) {
 // This is acceptable for change-detection (comparing consecutive calls) but
 // should not be used as a stable hash key.
 func (s *SaplingVCS) WorkingTreeFingerprint() string {
-	out, err := exec.Command("sl", "status").Output()
+	out, err := slCommandInDir(s.Dir, "status")
 	if err != nil {
 		return ""
 	}
-	return string(out)
+	return out
 }
 
 // UntrackedFiles returns untracked files in the given directory.
 func (s *SaplingVCS) UntrackedFiles(dir string) ([]FileChange, error) {
-	out, err := slCommandInDir(dir, "status", "-u")
+	out, err := slCommandInDir(s.in(dir), "status", "-u")
 	if err != nil {
 		return nil, err
 	}
@@ -228,13 +238,13 @@ func (s *SaplingVCS) UntrackedFiles(dir string) ([]FileChange, error) {
 
 // AllTrackedFiles returns all tracked files plus untracked non-ignored files.
 func (s *SaplingVCS) AllTrackedFiles(dir string) ([]string, error) {
-	trackedOut, err := slCommandInDir(dir, "files")
+	trackedOut, err := slCommandInDir(s.in(dir), "files")
 	if err != nil {
 		return nil, err
 	}
 	files := splitNonEmpty(trackedOut)
 
-	untrackedOut, err := slCommandInDir(dir, "status", "-u")
+	untrackedOut, err := slCommandInDir(s.in(dir), "status", "-u")
 	if err != nil {
 		return files, nil //nolint:nilerr // graceful: return tracked files even if untracked listing fails
 	}
@@ -247,7 +257,7 @@ func (s *SaplingVCS) AllTrackedFiles(dir string) ([]string, error) {
 // RemoteBranches returns remote bookmark names. Returns nil on error
 // since remote bookmarks may not be available.
 func (s *SaplingVCS) RemoteBranches(dir string) ([]string, error) {
-	out, err := slCommandInDir(dir, "bookmark", "--list", "--remote")
+	out, err := slCommandInDir(s.in(dir), "bookmark", "--list", "--remote")
 	if err != nil {
 		return nil, nil //nolint:nilerr // graceful: remote bookmarks may not be available
 	}
@@ -259,7 +269,7 @@ func (s *SaplingVCS) DiffNumstat(baseRef, dir string) (map[string]NumstatEntry,
 	if baseRef == "" {
 		return nil, nil
 	}
-	out, err := slCommandInDir(dir, "diff", "--stat", "-r", baseRef)
+	out, err := slCommandInDir(s.in(dir), "diff", "--stat", "-r", baseRef)
 	if err != nil {
 		return nil, err
 	}
@@ -268,11 +278,11 @@ func (s *SaplingVCS) DiffNumstat(baseRef, dir string) (map[string]NumstatEntry,
 
 // UserName returns the Sapling-configured user name.
 func (s *SaplingVCS) UserName() string {
-	out, err := exec.Command("sl", "config", "ui.username").Output()
+	out, err := slCommandInDir(s.Dir, "config", "ui.username")
 	if err != nil {
 		return ""
 	}
-	name := strings.TrimSpace(string(out))
+	name := strings.TrimSpace(out)
 	// Strip email suffix: "Name <email>" -> "Name"
 	if idx := strings.Index(name, " <"); idx >= 0 {
 		name = name[:idx]
@@ -286,9 +296,7 @@ func (s *SaplingVCS) FileContentAtRef(path, ref, dir string) (string, error) {
 		return "", nil
 	}
 	cmd := exec.Command("sl", "cat", "-r", ref, path)
-	if dir != "" {
-		cmd.Dir = dir
-	}
+	cmd.Dir = s.in(dir)
 	out, err := cmd.Output()
 	if err != nil {
 		return "", fmt.Errorf("sl cat -r %s %s: %w", ref, path, err)
@@ -301,7 +309,7 @@ func (s *SaplingVCS) FileStatusInRepo(path, baseRef, dir string) string {
 	if baseRef == "" {
 		return "modified"
 	}
-	out, err := slCommandInDir(dir, "status", "--rev", baseRef, path)
+	out, err := slCommandInDir(s.in(dir), "status", "--rev", baseRef, path)
 	if err != nil {
 		return ""
 	}
@@ -319,11 +327,11 @@ func (s *SaplingVCS) HasStagingArea() bool { return false }
 // Includes .git because Sapling often operates on git-backed repos.
 func (s *SaplingVCS) SkipDirNames() []string { return []string{".sl", ".git"} }
 
-// detectSaplingDefaultBranch probes for "main" then "master" bookmarks.
-func detectSaplingDefaultBranch() string {
+// detectSaplingDefaultBranch probes for "main" then "master" bookmarks in
+// the checkout in dir.
+func detectSaplingDefaultBranch(dir string) string {
 	for _, branch := range []string{"main", "master"} {
-		err := exec.Command("sl", "log", "-r", branch, "-T", "{node|short}").Run()
-		if err == nil {
+		if _, err := slCommandInDir(dir, "log", "-r", branch, "-T", "{node|short}"); err == nil {
 			return branch
 		}
 	}
@@ -26,6 +26,7 @@ import (
 type Server struct {
 	session           atomic.Pointer[Session]
 	mux               *http.ServeMux
+	handler           http.Handler // mux behind cross-origin request protection
 	assets            fs.FS
 	shareURL          string
 	authMu            sync.RWMutex // guards authToken + cfg.Auth* fields
@@ -34,6 +35,9 @@ type Server struct {
 	prInfoMu          sync.RWMutex
 	author            string
 	agentCmd          string
+	agentJobs         *agentJobManager
+	agentJobsOnce     sync.Once
+	acp               acpState // persistent ACP agent processes and sessions
 	currentVersion    string
 	latestVersion     string
 	versionMu         sync.RWMutex
@@ -46,6 +50,7 @@ type Server struct {
 	homeDir           string
 	cfg               Config
 	reviewPath        string
+	remoteURL         string // tokenized --listen link, "" when remote access is off
 }
 
 // NewServer creates a Server with the given session and configuration.
@@ -65,19 +70,25 @@ func NewServer(session *Session, frontendFS embed.FS, shareURL string, authToken
 	// Endpoints that work without a ready session
 	mux.HandleFunc("/api/health", s.handleHealth)
 	mux.HandleFunc("/api/qr", s.handleQR)
+	mux.HandleFunc("/metrics", s.handleMetrics)
 
 	// Session-dependent endpoints (guarded by withReady middleware)
 	mux.HandleFunc("/api/review-cycle", s.withReady(s.handleReviewCycle))
 	mux.HandleFunc("/api/config", s.withReady(s.handleConfig))
 	mux.HandleFunc("/api/session", s.withReady(s.handleSession))
+	mux.HandleFunc("/api/summary", s.withReady(s.handleSummary))
 	mux.HandleFunc("/api/share", s.withReady(s.handleShare))
 	mux.HandleFunc("/api/share-url", s.withReady(s.handleShareURL))
 	mux.HandleFunc("/api/finish", s.withReady(s.handleFinish))
 	mux.HandleFunc("/api/events", s.withReady(s.handleEvents))
+	mux.HandleFunc("/api/events/action", s.withReady(s.handleEventAction))
 	mux.HandleFunc("/api/wait-for-event", s.withReady(s.handleWaitForEvent))
 	mux.HandleFunc("/api/round-complete", s.withReady(s.handleRoundComplete))
 
 	mux.HandleFunc("/api/agent/request", s.withReady(s.handleAgentRequest))
+	mux.HandleFunc("/api/agent/request/", s.withReady(s.handleAgentJobByID))
+	mux.HandleFunc("/api/agent/jobs", s.withReady(s.handleAgentJobs))
+	mux.HandleFunc("/api/agent/pre-review", s.withReady(s.handlePreReview))
 	mux.HandleFunc("/api/branches", s.withReady(s.handleBranches))
 	mux.HandleFunc("/api/base-branch", s.withReady(s.handleBaseBranch))
 	mux.HandleFunc("/api/commits", s.withReady(s.handleCommits))
@@ -91,16 +102,22 @@ func NewServer(session *Session, frontendFS embed.FS, shareURL string, authToken
 	mux.HandleFunc("/api/file/comments", s.withReady(s.handleFileComments))
 	mux.HandleFunc("/api/comment/", s.withReady(s.handleCommentByID))
 
+	// Stable API for scripts and editors (see api_v1.go)
+	s.registerAPIV1(mux)
+
 	// Static file serving (repo files need session; embedded assets do not)
 	mux.HandleFunc("/files/", s.withReady(s.handleFiles))
 	mux.Handle("/", http.FileServer(http.FS(assets)))
 
 	s.mux = mux
+	// Every mutating endpoint is same-origin only, so another site open in
+	// the browser can't post to the review.
+	s.handler = http.NewCrossOriginProtection().Handler(mux)
 	return s, nil
 }
 
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	s.mux.ServeHTTP(w, r)
+	s.handler.ServeHTTP(w, r)
 }
 
 // requireReady returns false and writes a 503 or 500 response if the server
@@ -159,6 +176,16 @@ func (s *Server) SetInitErr(err error) {
 	s.initErr.Store(&e)
 }
 
+// githubAPI returns a GitHub client for the checkout in dir. githubAPIURL,
+// when set, takes precedence over the configured or host-derived API root.
+func (s *Server) githubAPI(dir string) (githubAPI, error) {
+	cfg := s.cfg
+	if s.githubAPIURL != "" {
+		cfg.GitHubAPIURL = s.githubAPIURL
+	}
+	return newGitHubAPI(dir, cfg)
+}
+
 // CheckForUpdates fetches the latest release tag from GitHub and stores
 // it so the frontend can display an update notification. Safe to call
 // from a goroutine — the result is written under versionMu.
@@ -203,6 +230,10 @@ func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
 	latestVersion := s.latestVersion
 	s.versionMu.RUnlock()
 	sess := s.session.Load()
+	defaultAgent, err := s.resolveAgent("", "")
+	if err != nil {
+		defaultAgent.Name = agentName("")
+	}
 	resp := map[string]interface{}{
 		"share_url":         s.shareURL,
 		"hosted_url":        sess.GetSharedURL(),
@@ -210,9 +241,11 @@ func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
 		"version":           s.currentVersion,
 		"latest_version":    latestVersion,
 		"author":            s.author,
-		"agent_cmd_enabled": s.agentCmd != "",
-		"agent_name":        agentName(s.agentCmd),
-		"agent_cmd":         s.agentCmd,
+		"agent_cmd_enabled": s.agentEnabled(),
+		"agent_name":        defaultAgent.Name,
+		"agent_cmd":         defaultAgent.Cmd,
+		"agent_profile":     defaultAgent.Profile,
+		"agent_profiles":    s.agentProfileList(),
 
 		// Auth status
 		"auth_logged_in":  s.authLoggedIn(),
@@ -498,13 +531,13 @@ func (s *Server) handleFileComments(w http.ResponseWriter, r *http.Request) {
 // DELETE     /api/comment/{id}/replies/{rid}?path=server.go
 // commentRoute holds the parsed components of a comment-by-ID URL path.
 type commentRoute struct {
-	kind string // "reply", "resolve", or "comment"
+	kind string // "reply", "resolve", "proposal", "ai-suggestion", or "comment"
 	id   string // the comment ID
-	sub  string // for replies: the reply ID (may be empty for POST)
+	sub  string // for replies: the reply ID (may be empty for POST); for proposals and AI suggestions: the action
 }
 
 // routeCommentByID parses a URL suffix like "c5", "c5/replies", "c5/replies/r2",
-// or "c5/resolve" and returns the route components. Returns false if the suffix is empty.
+// "c5/resolve", "c5/proposal/accept" or "c5/ai-suggestion/dismiss" and returns the route components. Returns false if the suffix is empty.
 func routeCommentByID(trimmed string) (commentRoute, bool) {
 	if trimmed == "" {
 		return commentRoute{}, false
@@ -516,6 +549,12 @@ func routeCommentByID(trimmed string) (commentRoute, bool) {
 			sub:  strings.TrimPrefix(parts[1], "/"),
 		}, true
 	}
+	if parts := strings.SplitN(trimmed, "/proposal/", 2); len(parts) == 2 {
+		return commentRoute{kind: "proposal", id: parts[0], sub: parts[1]}, true
+	}
+	if parts := strings.SplitN(trimmed, "/ai-suggestion/", 2); len(parts) == 2 {
+		return commentRoute{kind: "ai-suggestion", id: parts[0], sub: parts[1]}, true
+	}
 	if parts := strings.SplitN(trimmed, "/resolve", 2); len(parts) == 2 && parts[1] == "" {
 		return commentRoute{kind: "resolve", id: parts[0]}, true
 	}
@@ -540,11 +579,59 @@ func (s *Server) handleCommentByID(w http.ResponseWriter, r *http.Request) {
 		s.handleReplyRoute(w, r, path, route.id, route.sub)
 	case "resolve":
 		s.handleFileCommentResolve(w, r, path, route.id)
+	case "proposal":
+		s.handleCommentProposal(w, r, path, route.id, route.sub)
+	case "ai-suggestion":
+		s.handleCommentAISuggestion(w, r, path, route.id, route.sub)
 	case "comment":
 		s.handleFileCommentUpdate(w, r, path, route.id)
 	}
 }
 
+// handleCommentProposal accepts or rejects an agent's proposed change.
+// POST /api/comment/{id}/proposal/accept?path=X applies the patch to the working tree.
+// POST /api/comment/{id}/proposal/reject?path=X discards it.
+func (s *Server) handleCommentProposal(w http.ResponseWriter, r *http.Request, path, commentID, action string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if action != "accept" && action != "reject" {
+		http.Error(w, "Unknown proposal action", http.StatusNotFound)
+		return
+	}
+	sess := s.session.Load()
+	comment, _, found := sess.FindCommentByID(commentID, path)
+	if !found {
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return
+	}
+	if comment.Proposal == nil {
+		http.Error(w, "Comment has no proposed change", http.StatusNotFound)
+		return
+	}
+
+	if action == "accept" {
+		if err := applyProposedPatch(sess.RepoRoot, comment.Proposal.Patch); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		sess.RefreshFileContent()
+		if sess.Mode == "git" {
+			sess.RefreshFileList()
+			sess.RefreshDiffs()
+		}
+	}
+
+	c, ok := sess.SetCommentProposal(path, commentID, nil)
+	if !ok {
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return
+	}
+	sess.notify(SSEEvent{Type: "comments-changed"})
+	writeJSON(w, c)
+}
+
 // handleFileCommentResolve handles PUT /api/comment/{id}/resolve?path=X.
 func (s *Server) handleFileCommentResolve(w http.ResponseWriter, r *http.Request, path, commentID string) {
 	if r.Method != http.MethodPut {
@@ -553,18 +640,15 @@ func (s *Server) handleFileCommentResolve(w http.ResponseWriter, r *http.Request
 	}
 	var req struct {
 		Resolved bool `json:"resolved"`
+		Version  *int `json:"version"`
 	}
 	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1MB
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-	c, ok := s.session.Load().SetCommentResolved(path, commentID, req.Resolved)
-	if !ok {
-		http.Error(w, "Comment not found", http.StatusNotFound)
-		return
-	}
-	writeJSON(w, c)
+	c, err := s.session.Load().EditComment(path, commentID, CommentEdit{Version: req.Version, Resolved: &req.Resolved})
+	s.writeCommentEdit(w, r, c, err)
 }
 
 // handleFileCommentUpdate handles PUT and DELETE on /api/comment/{id}?path=X.
@@ -573,7 +657,8 @@ func (s *Server) handleFileCommentUpdate(w http.ResponseWriter, r *http.Request,
 	case http.MethodPut:
 		r.Body = http.MaxBytesReader(w, r.Body, 10<<20) // 10MB
 		var req struct {
-			Body string `json:"body"`
+			Body    string `json:"body"`
+			Version *int   `json:"version"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, "Invalid request body", http.StatusBadRequest)
@@ -583,12 +668,8 @@ func (s *Server) handleFileCommentUpdate(w http.ResponseWriter, r *http.Request,
 			http.Error(w, "Comment body is required", http.StatusBadRequest)
 			return
 		}
-		c, ok := s.session.Load().UpdateComment(path, id, req.Body)
-		if !ok {
-			http.Error(w, "Comment not found", http.StatusNotFound)
-			return
-		}
-		writeJSON(w, c)
+		c, err := s.session.Load().EditComment(path, id, CommentEdit{Version: req.Version, Body: &req.Body})
+		s.writeCommentEdit(w, r, c, err)
 
 	case http.MethodDelete:
 		if !s.session.Load().DeleteComment(path, id) {
@@ -792,6 +873,8 @@ func (s *Server) handleReviewCommentByID(w http.ResponseWriter, r *http.Request)
 		s.handleReviewCommentReplyRoute(w, r, route.id, route.sub)
 	case "resolve":
 		s.handleReviewCommentResolve(w, r, route.id)
+	case "ai-suggestion":
+		s.handleCommentAISuggestion(w, r, "", route.id, route.sub)
 	case "comment":
 		s.handleReviewCommentUpdate(w, r, route.id)
 	}
@@ -805,18 +888,15 @@ func (s *Server) handleReviewCommentResolve(w http.ResponseWriter, r *http.Reque
 	}
 	var req struct {
 		Resolved bool `json:"resolved"`
+		Version  *int `json:"version"`
 	}
 	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1MB
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-	c, ok := s.session.Load().ResolveReviewComment(commentID, req.Resolved)
-	if !ok {
-		http.Error(w, "Comment not found", http.StatusNotFound)
-		return
-	}
-	writeJSON(w, c)
+	c, err := s.session.Load().EditComment("", commentID, CommentEdit{Version: req.Version, Resolved: &req.Resolved})
+	s.writeCommentEdit(w, r, c, err)
 }
 
 // handleReviewCommentUpdate handles PUT and DELETE on /api/review-comment/{id}.
@@ -825,7 +905,8 @@ func (s *Server) handleReviewCommentUpdate(w http.ResponseWriter, r *http.Reques
 	case http.MethodPut:
 		r.Body = http.MaxBytesReader(w, r.Body, 10<<20)
 		var req struct {
-			Body string `json:"body"`
+			Body    string `json:"body"`
+			Version *int   `json:"version"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, "Invalid request body", http.StatusBadRequest)
@@ -835,12 +916,8 @@ func (s *Server) handleReviewCommentUpdate(w http.ResponseWriter, r *http.Reques
 			http.Error(w, "Comment body is required", http.StatusBadRequest)
 			return
 		}
-		c, ok := s.session.Load().UpdateReviewComment(id, req.Body)
-		if !ok {
-			http.Error(w, "Comment not found", http.StatusNotFound)
-			return
-		}
-		writeJSON(w, c)
+		c, err := s.session.Load().EditComment("", id, CommentEdit{Version: req.Version, Body: &req.Body})
+		s.writeCommentEdit(w, r, c, err)
 
 	case http.MethodDelete:
 		if !s.session.Load().DeleteReviewComment(id) {
@@ -892,6 +969,11 @@ func (s *Server) handleFinish(w http.ResponseWriter, r *http.Request) {
 					"For each comment, reply explaining what you did using `crit comment --reply-to <comment-id> --author <your-name> \"<explanation>\"`. "+
 					"When done run: `%s`",
 				critJSON, sess.ReinvokeCommand())
+			if reviewers := githubChangesRequested(sess.GetReviewComments()); len(reviewers) > 0 {
+				prompt += fmt.Sprintf(
+					" Note: %s requested changes on the GitHub pull request — their review is in review_comments with github_review_state CHANGES_REQUESTED; address it as well.",
+					strings.Join(reviewers, ", "))
+			}
 		}
 	} else if totalComments > 0 && unresolvedComments == 0 {
 		prompt = "All comments are resolved — no changes needed, please proceed."
@@ -902,6 +984,14 @@ func (s *Server) handleFinish(w http.ResponseWriter, r *http.Request) {
 		sess.setWaitingForAgent(true)
 	}
 
+	if s.cfg.GitHubStatus && sess.Mode != "plan" {
+		go s.publishFinishStatus(sess)
+	}
+	sess.webhook(webhookPayload{Event: "finish", Approved: &approved})
+	if approved {
+		sess.webhook(webhookPayload{Event: "approved"})
+	}
+
 	writeJSON(w, map[string]any{
 		"status":      "finished",
 		"review_file": critJSON,
@@ -910,11 +1000,14 @@ func (s *Server) handleFinish(w http.ResponseWriter, r *http.Request) {
 	})
 
 	// Encode approved status into SSE event content as JSON so review-cycle
-	// clients can extract it without string matching on the prompt.
+	// clients can extract it without string matching on the prompt. The
+	// finish is also kept until a review client takes it, in case none is
+	// connected right now.
 	eventData, _ := json.Marshal(map[string]any{
 		"prompt":   prompt,
 		"approved": approved,
 	})
+	sess.recordFinish(prompt, approved)
 	sess.notify(SSEEvent{
 		Type:    "finish",
 		Content: string(eventData),
@@ -930,6 +1023,28 @@ func (s *Server) handleFinish(w http.ResponseWriter, r *http.Request) {
 
 }
 
+// publishFinishStatus publishes the finished round's outcome on HEAD
+// (github_status). Runs in the background; failures are only logged.
+func (s *Server) publishFinishStatus(sess *Session) {
+	cj, err := loadCritJSON(sess.critJSONPath())
+	if err != nil {
+		log.Printf("github status: %v", err)
+		return
+	}
+	api, err := s.githubAPI(sess.RepoRoot)
+	if err != nil {
+		log.Printf("github status: %v", err)
+		return
+	}
+	outcome := reviewOutcomeFrom(cj)
+	kind, err := publishReviewStatus(api, sess.RepoRoot, outcome)
+	if err != nil {
+		log.Printf("github status: %v", err)
+		return
+	}
+	log.Printf("github status: published %s %s: %s", reviewStatusName, kind, outcome.title())
+}
+
 // buildPlanFeedback formats review feedback for plan mode.
 // Points to the review file and hints at crit-cli skill, without inlining every comment.
 func (s *Server) buildPlanFeedback(critJSON string) string {
@@ -959,10 +1074,44 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// sessionSummary is the one-line state of a review, as listed on the hub
+// dashboard.
+type sessionSummary struct {
+	Mode            string `json:"mode"`
+	Branch          string `json:"branch,omitempty"`
+	Round           int    `json:"round"`
+	Unresolved      int    `json:"unresolved"`
+	Resolved        int    `json:"resolved"`
+	WaitingForAgent bool   `json:"waiting_for_agent"`
+	BrowserClients  bool   `json:"browser_clients"`
+}
+
+func (s *Server) handleSummary(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sess := s.session.Load()
+	unresolved := sess.UnresolvedCommentCount()
+	writeJSON(w, sessionSummary{
+		Mode:            sess.Mode,
+		Branch:          sess.Branch,
+		Round:           sess.GetReviewRound(),
+		Unresolved:      unresolved,
+		Resolved:        sess.TotalCommentCount() - unresolved,
+		WaitingForAgent: sess.isWaitingForAgent(),
+		BrowserClients:  sess.HasBrowserClients(),
+	})
+}
+
 // handleReviewCycle is the unified endpoint for the daemon-client pattern.
 // On first call (awaitingFirstReview=true): just blocks until user finishes review.
 // On subsequent calls: signals round-complete first, then blocks.
-// Returns the same feedback payload as handleFinish.
+// With ?resume=1, a client whose connection dropped mid-wait carries on
+// waiting without starting a new round. Any call first gets a finish that no
+// client has received yet, such as one made while the daemon was restarting.
+// Returns the same feedback payload as handleFinish, or 503 with status
+// "stopped" if the daemon shuts down first.
 func (s *Server) handleReviewCycle(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -977,7 +1126,11 @@ func (s *Server) handleReviewCycle(w http.ResponseWriter, r *http.Request) {
 	ch := sess.Subscribe()
 	defer sess.Unsubscribe(ch)
 
-	if !sess.IsAwaitingFirstReview() {
+	if f := sess.takeFinish(); f != nil {
+		writeReviewCycleFinish(w, sess, *f)
+		return
+	}
+	if r.URL.Query().Get("resume") == "" && !sess.IsAwaitingFirstReview() {
 		// Agent finished changes — signal round-complete so browser refreshes
 		sess.SignalRoundComplete()
 	}
@@ -985,20 +1138,20 @@ func (s *Server) handleReviewCycle(w http.ResponseWriter, r *http.Request) {
 	for {
 		select {
 		case event := <-ch:
-			if event.Type == "finish" {
-				sess.SetAwaitingFirstReview(false)
-				// Parse the structured finish event data
-				var finishData struct {
-					Prompt   string `json:"prompt"`
-					Approved bool   `json:"approved"`
+			switch event.Type {
+			case "finish":
+				f := sess.takeFinish()
+				if f == nil {
+					// Another waiting client took it; the event has the same data.
+					sess.SetAwaitingFirstReview(false)
+					f = &roundFinish{}
+					json.Unmarshal([]byte(event.Content), f)
 				}
-				json.Unmarshal([]byte(event.Content), &finishData)
-				writeJSON(w, map[string]any{
-					"status":      "finished",
-					"review_file": sess.critJSONPath(),
-					"prompt":      finishData.Prompt,
-					"approved":    finishData.Approved,
-				})
+				writeReviewCycleFinish(w, sess, *f)
+				return
+			case "server-shutdown":
+				w.WriteHeader(http.StatusServiceUnavailable)
+				writeJSON(w, map[string]string{"status": "stopped"})
 				return
 			}
 		case <-r.Context().Done():
@@ -1008,6 +1161,15 @@ func (s *Server) handleReviewCycle(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func writeReviewCycleFinish(w http.ResponseWriter, sess *Session, f roundFinish) {
+	writeJSON(w, map[string]any{
+		"status":      "finished",
+		"review_file": sess.critJSONPath(),
+		"prompt":      f.Prompt,
+		"approved":    f.Approved,
+	})
+}
+
 func (s *Server) handleWaitForEvent(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -1032,6 +1194,10 @@ func (s *Server) handleWaitForEvent(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleEvents streams session events. A reconnecting EventSource sends the
+// last id it saw as Last-Event-ID and gets what it missed, or a resync event
+// when that is gone. The first event, hello, carries the connection's client
+// ID for /api/events/action and the presence roster; see presence.go.
 func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -1053,9 +1219,40 @@ func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
 	sess.BrowserConnect()
 	defer sess.BrowserDisconnect()
 
-	ch := sess.Subscribe()
+	// Registered before subscribing: the roster in hello includes this
+	// client, and the presence broadcast about it goes only to the others.
+	client := newStreamClientID()
+	sess.connectClient(client)
+	defer sess.disconnectClient(client)
+
+	var ch chan SSEEvent
+	resync := false
+	if lastID := r.Header.Get("Last-Event-ID"); lastID != "" {
+		if seq, ok := sess.events.parseID(lastID); ok {
+			ch, ok = sess.SubscribeSince(seq)
+			resync = !ok
+		} else {
+			resync = true // from before a daemon restart
+		}
+	}
+	if ch == nil {
+		ch = sess.Subscribe()
+	}
 	defer sess.Unsubscribe(ch)
 
+	writeEvent := func(event SSEEvent) {
+		data, _ := json.Marshal(event)
+		if event.Seq != 0 {
+			fmt.Fprintf(w, "id: %s\n", sess.events.id(event.Seq))
+		}
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+		flusher.Flush()
+	}
+	writeEvent(SSEEvent{Type: "hello", Client: client, Content: sess.presenceJSON()})
+	if resync {
+		writeEvent(SSEEvent{Type: "resync"})
+	}
+
 	for {
 		select {
 		case <-r.Context().Done():
@@ -1064,13 +1261,52 @@ func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
 			if !ok {
 				return
 			}
-			data, _ := json.Marshal(event)
-			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
-			flusher.Flush()
+			if event.Client == client {
+				continue // the sender's own action
+			}
+			writeEvent(event)
 		}
 	}
 }
 
+// handleEventAction relays an action from one browser, such as its cursor or
+// that it is typing a comment, to the others on the event stream. A presence
+// action updates the roster instead: its content is the reviewer's name and
+// filename the file they are looking at.
+// POST /api/events/action {"client": "sc_...", "type": "cursor", "content": "..."}
+func (s *Server) handleEventAction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Client  string `json:"client"`
+		Type    string `json:"type"`
+		File    string `json:"filename"`
+		Content string `json:"content"`
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, 64<<10)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !clientActions[req.Type] {
+		http.Error(w, "Unknown action", http.StatusBadRequest)
+		return
+	}
+	sess := s.session.Load()
+	if !sess.hasClient(req.Client) {
+		http.Error(w, "Unknown client; reconnect to /api/events", http.StatusConflict)
+		return
+	}
+	if req.Type == "presence" {
+		sess.setPresence(req.Client, req.Content, req.File)
+	} else {
+		sess.broadcast(SSEEvent{Type: req.Type, Filename: req.File, Content: req.Content, Client: req.Client})
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (s *Server) handleFiles(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -1109,6 +1345,14 @@ func (s *Server) handleQR(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	url := r.URL.Query().Get("url")
+	if r.URL.Query().Get("remote") != "" {
+		// The tokenized --listen link, for opening the review on a phone.
+		if s.remoteURL == "" {
+			http.Error(w, "Remote access is off (start crit with --listen)", http.StatusNotFound)
+			return
+		}
+		url = s.remoteURL
+	}
 	if url == "" {
 		http.Error(w, "Missing url parameter", http.StatusBadRequest)
 		return
@@ -1260,6 +1504,7 @@ func fuzzyScore(query, text string) float64 {
 type agentRequestBody struct {
 	CommentID string `json:"comment_id"`
 	FilePath  string `json:"file_path"`
+	Profile   string `json:"profile,omitempty"` // agent profile name; empty selects the default for the file
 }
 
 // agentName extracts the binary name from the agent command string.
@@ -1271,14 +1516,14 @@ func agentName(cmd string) string {
 	return filepath.Base(parts[0])
 }
 
-// handleAgentRequest dispatches a comment to the configured agent command.
+// handleAgentRequest queues a comment for the configured agent command.
 // POST /api/agent/request
 func (s *Server) handleAgentRequest(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	if s.agentCmd == "" {
+	if !s.agentEnabled() {
 		http.Error(w, "agent_cmd not configured", http.StatusBadRequest)
 		return
 	}
@@ -1296,19 +1541,144 @@ func (s *Server) handleAgentRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.session.Load().SetCommentLive(filePath, comment.ID)
-
-	prompt := buildAgentPrompt(comment, filePath)
+	agent, err := s.resolveAgent(body.Profile, filePath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	// Run agent command asynchronously
-	go s.runAgentCmd(prompt, comment.ID, filePath)
+	job, err := s.agentQueue().Submit(comment.ID, filePath, agent.Profile, buildAgentPrompt(comment, filePath))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	s.session.Load().SetCommentLive(filePath, comment.ID)
 
 	w.WriteHeader(http.StatusAccepted)
 	writeJSON(w, map[string]any{
 		"status":     "accepted",
 		"comment_id": body.CommentID,
 		"file_path":  filePath,
+		"job_id":     job.ID,
+		"job_status": job.Status,
+		"profile":    job.Profile,
+	})
+}
+
+// handleAgentJobs lists queued, running and recently finished agent jobs.
+// GET /api/agent/jobs
+func (s *Server) handleAgentJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, s.agentQueue().List())
+}
+
+// handleAgentJobByID inspects, cancels or retries a single agent job.
+// GET    /api/agent/request/{id}
+// DELETE /api/agent/request/{id}
+// POST   /api/agent/request/{id}/retry
+func (s *Server) handleAgentJobByID(w http.ResponseWriter, r *http.Request) {
+	trimmed := strings.TrimPrefix(r.URL.Path, "/api/agent/request/")
+	id, action, _ := strings.Cut(trimmed, "/")
+	if id == "" {
+		http.Error(w, "Job ID required", http.StatusBadRequest)
+		return
+	}
+	queue := s.agentQueue()
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		job, ok := queue.Get(id)
+		if !ok {
+			http.Error(w, "Job not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, job)
+
+	case action == "" && r.Method == http.MethodDelete:
+		job, err := queue.Cancel(id)
+		switch {
+		case errors.Is(err, errAgentJobNotFound):
+			http.Error(w, "Job not found", http.StatusNotFound)
+		case errors.Is(err, errAgentJobFinished):
+			http.Error(w, "Job already finished", http.StatusConflict)
+		default:
+			writeJSON(w, job)
+		}
+
+	case action == "retry" && r.Method == http.MethodPost:
+		old, ok := queue.Get(id)
+		if !ok {
+			http.Error(w, "Job not found", http.StatusNotFound)
+			return
+		}
+		// Rebuild the prompt so the retry sees replies added since the first run.
+		comment, filePath, found := s.session.Load().FindCommentByID(old.CommentID, old.FilePath)
+		if !found {
+			http.Error(w, "Comment not found", http.StatusNotFound)
+			return
+		}
+		job, err := queue.Retry(id, buildAgentPrompt(comment, filePath))
+		switch {
+		case errors.Is(err, errAgentQueueFull):
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			w.WriteHeader(http.StatusAccepted)
+			writeJSON(w, job)
+		}
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// agentQueue returns the server's agent job manager, creating it on first use
+// so that it picks up s.cfg after the daemon has finished configuring the server.
+func (s *Server) agentQueue() *agentJobManager {
+	s.agentJobsOnce.Do(func() {
+		s.agentJobs = newAgentJobManager(s.cfg.AgentConcurrency, s.cfg.AgentSerialize, s.runAgentJob, s.notifyAgentJob)
 	})
+	return s.agentJobs
+}
+
+// runAgentJob adapts runAgentCmd (or runPreReview) to the job manager's run
+// signature, and records the run in the metrics. A cancelled run is timed but
+// not counted as a failure.
+func (s *Server) runAgentJob(ctx context.Context, job agentJob) error {
+	start := time.Now()
+	var err error
+	filePath := job.FilePath
+	if job.Kind == agentJobPreReview {
+		filePath = ""
+		err = s.runPreReview(ctx, job.Profile, job.prompt)
+	} else {
+		err = s.runAgentCmd(ctx, job.Profile, job.prompt, job.CommentID, job.FilePath)
+	}
+	profile := job.Profile
+	if agent, rerr := s.resolveAgent(job.Profile, filePath); rerr == nil {
+		profile = agent.Profile
+	}
+	m := s.session.Load().counters()
+	m.agentDuration.observe(profile, time.Since(start))
+	if err != nil && ctx.Err() == nil {
+		m.agentFailures.inc(profile)
+	}
+	return err
+}
+
+// notifyAgentJob broadcasts an agent job status change to SSE subscribers.
+func (s *Server) notifyAgentJob(job agentJob) {
+	sess := s.session.Load()
+	if sess == nil {
+		return
+	}
+	sess.trackAgentJob(job)
+	data, _ := json.Marshal(job)
+	sess.notify(SSEEvent{Type: "agent-job", Filename: job.FilePath, Content: string(data)})
 }
 
 // buildAgentPrompt constructs a prompt string from a comment for the agent.
@@ -1340,18 +1710,23 @@ func buildAgentPrompt(c Comment, filePath string) string {
 	return b.String()
 }
 
-// runAgentCmd executes the configured agent command with the given prompt.
-// If agent_cmd contains {prompt}, the placeholder is replaced with the prompt
-// as a single argument. Otherwise, the prompt is piped via stdin.
-func (s *Server) runAgentCmd(prompt string, commentID string, filePath string) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+// agentTimeout bounds a single agent run unless the profile sets its own timeout.
+const agentTimeout = 10 * time.Minute
+
+// execAgent runs the agent's command in dir with the given prompt and returns
+// its stdout and stderr. If the command contains {prompt}, the placeholder is
+// replaced with the prompt as a single argument. Otherwise, the prompt is
+// piped via stdin. The run is bounded by the profile's timeout; cancelling
+// ctx kills the command. logID prefixes log lines.
+func execAgent(ctx context.Context, agent resolvedAgent, dir, prompt, logID string) (string, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, agent.Timeout)
 	defer cancel()
 
-	parts := strings.Fields(s.agentCmd)
+	parts := strings.Fields(agent.Cmd)
 	if len(parts) == 0 {
-		return
+		return "", "", fmt.Errorf("agent_cmd not configured")
 	}
-	log.Printf("agent-request %s: running %q", commentID, s.agentCmd)
+	log.Printf("%s: running %q (profile %s)", logID, agent.Cmd, agent.Profile)
 
 	// Replace {prompt} placeholder with the actual prompt as a single argument.
 	hasPlaceholder := false
@@ -1366,48 +1741,96 @@ func (s *Server) runAgentCmd(prompt string, commentID string, filePath string) {
 	if !hasPlaceholder {
 		cmd.Stdin = strings.NewReader(prompt)
 	}
-	sess := s.session.Load()
-	cmd.Dir = sess.RepoRoot
-
+	cmd.Dir = dir
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
-	err := cmd.Run()
+	if err := cmd.Run(); err != nil {
+		log.Printf("%s: error: %v\nStderr: %s", logID, err, stderr.String())
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return "", stderr.String(), fmt.Errorf("agent timed out after %s", agent.Timeout)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", stderr.String(), fmt.Errorf("%w: %s", err, truncateStr(msg, 200))
+		}
+		return "", stderr.String(), err
+	}
+	return stdout.String(), stderr.String(), nil
+}
+
+// runAgentCmd runs the named agent profile (an empty profile selects the
+// default for filePath) on a comment's prompt and posts its output as a reply.
+func (s *Server) runAgentCmd(ctx context.Context, profile, prompt, commentID, filePath string) error {
+	agent, err := s.resolveAgent(profile, filePath)
 	if err != nil {
-		log.Printf("agent-request %s: error: %v\nStderr: %s", commentID, err, stderr.String())
-		return
+		return err
+	}
+	if agent.ACP {
+		return s.runAgentACP(ctx, agent, commentID, filePath)
+	}
+	sess := s.session.Load()
+	dir := sess.RepoRoot
+
+	// With agent_worktree (or workdir "worktree"), the agent edits a throwaway
+	// copy of the tree and its changes come back as a proposal instead of
+	// landing in RepoRoot.
+	var wt *agentWorktree
+	if agent.Worktree {
+		if wt, err = newAgentWorktree(ctx, sess.RepoRoot); err != nil {
+			log.Printf("agent-request %s: %v", commentID, err)
+			return err
+		}
+		defer wt.Remove()
+		dir = wt.WorkDir()
 	}
 
-	response := strings.TrimSpace(stdout.String())
-	if response == "" {
-		log.Printf("agent-request %s: completed (no output)", commentID)
-		return
+	stdout, stderr, err := execAgent(ctx, agent, dir, prompt, "agent-request "+commentID)
+	if err != nil {
+		return err
 	}
 
-	author := agentName(s.agentCmd)
-	log.Printf("agent-request %s: completed, posting reply (%d bytes)\nResponse: %s\nStderr: %s", commentID, len(response), response, stderr.String())
-	// Try original path first, then search all files (path may have changed during agent run)
-	_, ok := sess.AddReply(filePath, commentID, response, author, "")
-	if !ok {
-		if _, actualPath, found := sess.FindCommentByID(commentID, ""); found {
-			_, ok = sess.AddReply(actualPath, commentID, response, author, "")
-			if ok {
-				filePath = actualPath
-			}
+	var proposal *ProposedChange
+	if wt != nil {
+		if proposal, err = wt.Diff(ctx); err != nil {
+			log.Printf("agent-request %s: %v", commentID, err)
+			return err
 		}
 	}
-	if !ok {
+
+	response := strings.TrimSpace(stdout)
+	if response == "" && proposal == nil {
+		log.Printf("agent-request %s: completed (no output)", commentID)
+		return nil
+	}
+
+	// Path may have changed during the agent run; look the comment up again.
+	_, actualPath, found := sess.FindCommentByID(commentID, filePath)
+	if !found {
 		log.Printf("agent-request %s: failed to add reply (comment not found in file %q)", commentID, filePath)
-	} else {
-		// Re-read content (and file list/diffs in git mode) so next fetch returns updated data
-		sess.RefreshFileContent()
-		if sess.Mode == "git" {
-			sess.RefreshFileList()
-			sess.RefreshDiffs()
-		}
-		sess.notify(SSEEvent{Type: "comments-changed"})
+		return fmt.Errorf("comment %s no longer exists", commentID)
 	}
+	filePath = actualPath
+
+	author := agent.Name
+	if response != "" {
+		log.Printf("agent-request %s: completed, posting reply (%d bytes)\nResponse: %s\nStderr: %s", commentID, len(response), response, stderr)
+		sess.addAgentReply(filePath, commentID, response, author)
+	}
+	if proposal != nil {
+		log.Printf("agent-request %s: proposing changes to %d file(s)", commentID, len(proposal.Files))
+		proposal.Author = author
+		proposal.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+		sess.SetCommentProposal(filePath, commentID, proposal)
+	}
+	// Re-read content (and file list/diffs in git mode) so next fetch returns updated data
+	sess.RefreshFileContent()
+	if sess.Mode == "git" {
+		sess.RefreshFileList()
+		sess.RefreshDiffs()
+	}
+	sess.notify(SSEEvent{Type: "comments-changed"})
+	return nil
 }
 
 func (s *Server) authTokenSnapshot() string {
@@ -1450,6 +1873,27 @@ func (s *Server) clearAuthState() {
 	s.cfg.AuthUserEmail = ""
 }
 
+// eventClientHeader names the /api/events client a browser request comes
+// from, so the events it causes aren't echoed back to it.
+const eventClientHeader = "X-Crit-Client"
+
+// writeCommentEdit responds with the result of Session.EditComment and tells
+// the other browsers about a successful edit. A stale version gets 409 with
+// the comment as it is now, so the client can show the other reviewer's
+// change before trying again.
+func (s *Server) writeCommentEdit(w http.ResponseWriter, r *http.Request, c Comment, err error) {
+	switch {
+	case errors.Is(err, errCommentNotFound):
+		http.Error(w, "Comment not found", http.StatusNotFound)
+	case errors.Is(err, errCommentConflict):
+		w.WriteHeader(http.StatusConflict)
+		writeJSON(w, c)
+	default:
+		s.session.Load().notify(SSEEvent{Type: "comments-changed", Client: r.Header.Get(eventClientHeader)})
+		writeJSON(w, c)
+	}
+}
+
 func writeJSON(w http.ResponseWriter, v any) {
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(v); err != nil {
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -116,7 +117,7 @@ func TestRunAgentCmd_PromptPlaceholder(t *testing.T) {
 	}
 	session.mu.Unlock()
 
-	s.runAgentCmd("hello from placeholder", "c1", session.Files[0].Path)
+	s.runAgentCmd(context.Background(), "", "hello from placeholder", "c1", session.Files[0].Path)
 
 	// runAgentCmd is synchronous — reply is already added when it returns.
 	session.mu.Lock()
@@ -150,7 +151,7 @@ func TestRunAgentCmd_StdinFallback(t *testing.T) {
 	}
 	session.mu.Unlock()
 
-	s.runAgentCmd("hello from stdin", "c1", session.Files[0].Path)
+	s.runAgentCmd(context.Background(), "", "hello from stdin", "c1", session.Files[0].Path)
 
 	session.mu.Lock()
 	replies := session.Files[0].Comments[0].Replies
@@ -165,3 +166,177 @@ func TestRunAgentCmd_StdinFallback(t *testing.T) {
 		t.Errorf("reply author = %q, want 'cat'", replies[0].Author)
 	}
 }
+
+func TestHandleAgentJobs_CancelAndList(t *testing.T) {
+	s, session := newTestServer(t)
+	s.agentCmd = "sleep 30"
+
+	session.mu.Lock()
+	session.Files[0].Comments = []Comment{
+		{ID: "c1", StartLine: 1, EndLine: 1, Body: "slow", Author: "reviewer", Scope: "line"},
+	}
+	session.mu.Unlock()
+
+	req := httptest.NewRequest("POST", "/api/agent/request", strings.NewReader(`{"comment_id":"c1"}`))
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	jobID, _ := resp["job_id"].(string)
+	if jobID == "" {
+		t.Fatalf("expected job_id in response, got %v", resp)
+	}
+
+	waitForJobStatus(t, s.agentQueue(), jobID, agentJobRunning)
+
+	req = httptest.NewRequest("DELETE", "/api/agent/request/"+jobID, nil)
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("cancel: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	waitForJobStatus(t, s.agentQueue(), jobID, agentJobCancelled)
+
+	// Cancelling again conflicts.
+	req = httptest.NewRequest("DELETE", "/api/agent/request/"+jobID, nil)
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusConflict {
+		t.Errorf("second cancel: expected 409, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/api/agent/jobs", nil)
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	var jobs []agentJob
+	if err := json.Unmarshal(w.Body.Bytes(), &jobs); err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 || jobs[0].ID != jobID || jobs[0].Status != agentJobCancelled {
+		t.Errorf("jobs = %+v, want one cancelled job %s", jobs, jobID)
+	}
+}
+
+func TestHandleAgentJobs_Retry(t *testing.T) {
+	s, session := newTestServer(t)
+	s.agentCmd = "false"
+
+	session.mu.Lock()
+	session.Files[0].Comments = []Comment{
+		{ID: "c1", StartLine: 1, EndLine: 1, Body: "fail", Author: "reviewer", Scope: "line"},
+	}
+	session.mu.Unlock()
+
+	job, err := s.agentQueue().Submit("c1", "test.md", "", "prompt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForJobStatus(t, s.agentQueue(), job.ID, agentJobFailed)
+
+	req := httptest.NewRequest("POST", "/api/agent/request/"+job.ID+"/retry", nil)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("retry: expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	var retried agentJob
+	if err := json.Unmarshal(w.Body.Bytes(), &retried); err != nil {
+		t.Fatal(err)
+	}
+	if retried.ID == job.ID || retried.CommentID != "c1" {
+		t.Errorf("retried = %+v, want new job for c1", retried)
+	}
+
+	req = httptest.NewRequest("GET", "/api/agent/request/job_missing", nil)
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("missing job: expected 404, got %d", w.Code)
+	}
+}
+
+func TestRunAgentCmd_WorktreeProposal(t *testing.T) {
+	dir := initTestRepo(t)
+	s, session := newTestServer(t)
+	session.RepoRoot = dir
+	s.cfg.AgentWorktree = true
+	script := filepath.Join(t.TempDir(), "agent.sh")
+	os.WriteFile(script, []byte("#!/bin/sh\necho agent > README.md\necho done\n"), 0o755)
+	s.agentCmd = script
+
+	session.mu.Lock()
+	session.Files[0].Comments = []Comment{
+		{ID: "c1", StartLine: 1, EndLine: 1, Body: "edit it", Author: "reviewer", Scope: "line"},
+	}
+	session.mu.Unlock()
+
+	if err := s.runAgentCmd(context.Background(), "", "prompt", "c1", session.Files[0].Path); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "README.md")); string(got) != "# Test" {
+		t.Errorf("main tree README = %q, want untouched", got)
+	}
+
+	comments := session.GetComments(session.Files[0].Path)
+	if comments[0].Proposal == nil {
+		t.Fatal("expected a proposal on the comment")
+	}
+	if len(comments[0].Proposal.Files) != 1 || comments[0].Proposal.Files[0] != "README.md" {
+		t.Errorf("proposal files = %v, want [README.md]", comments[0].Proposal.Files)
+	}
+	if len(comments[0].Replies) != 1 || comments[0].Replies[0].Body != "done" {
+		t.Errorf("replies = %+v, want one reply 'done'", comments[0].Replies)
+	}
+
+	req := httptest.NewRequest("POST", "/api/comment/c1/proposal/accept?path="+session.Files[0].Path, nil)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("accept: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "README.md")); string(got) != "agent\n" {
+		t.Errorf("README after accept = %q, want agent edit", got)
+	}
+	if c := session.GetComments(session.Files[0].Path); c[0].Proposal != nil {
+		t.Error("proposal should be cleared after accept")
+	}
+}
+
+func TestHandleCommentProposal_Reject(t *testing.T) {
+	s, session := newTestServer(t)
+	session.mu.Lock()
+	session.Files[0].Comments = []Comment{
+		{ID: "c1", StartLine: 1, EndLine: 1, Body: "x", Author: "reviewer", Scope: "line",
+			Proposal: &ProposedChange{Patch: "diff --git a/x b/x\n", Files: []string{"x"}}},
+	}
+	session.mu.Unlock()
+
+	req := httptest.NewRequest("GET", "/api/comment/c1/proposal/reject?path=test.md", nil)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: expected 405, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/api/comment/c1/proposal/reject?path=test.md", nil)
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("reject: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if c := session.GetComments("test.md"); c[0].Proposal != nil {
+		t.Error("proposal should be cleared after reject")
+	}
+
+	req = httptest.NewRequest("POST", "/api/comment/c1/proposal/accept?path=test.md", nil)
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("accept without proposal: expected 404, got %d", w.Code)
+	}
+}
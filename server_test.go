@@ -248,6 +248,32 @@ func TestAPIUpdateComment_NotFound(t *testing.T) {
 	}
 }
 
+func TestAPIUpdateComment_StaleVersion(t *testing.T) {
+	s, session := newTestServer(t)
+	c, _ := session.AddComment("test.md", 1, 1, "", "original", "", "", "")
+	session.UpdateComment("test.md", c.ID, "someone else's edit")
+
+	body := `{"body":"mine","version":0}`
+	req := httptest.NewRequest("PUT", "/api/comment/"+c.ID+"?path=test.md", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want 409", w.Code)
+	}
+	var current Comment
+	json.Unmarshal(w.Body.Bytes(), &current)
+	if current.Body != "someone else's edit" || current.Version != 1 {
+		t.Errorf("409 body = %+v, want the current comment", current)
+	}
+
+	req = httptest.NewRequest("PUT", "/api/comment/"+c.ID+"/resolve?path=test.md", strings.NewReader(`{"resolved":true,"version":1}`))
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("resolve at the current version = %d", w.Code)
+	}
+}
+
 func TestAPIDeleteComment(t *testing.T) {
 	s, session := newTestServer(t)
 	c, _ := session.AddComment("test.md", 1, 1, "", "to delete", "", "", "")
@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -51,30 +52,49 @@ type Reply struct {
 	UserID    string `json:"user_id,omitempty"`
 	CreatedAt string `json:"created_at"`
 	GitHubID  int64  `json:"github_id,omitempty"`
+	RemoteID  string `json:"remote_id,omitempty"` // ID on a non-GitHub forge (see Forge)
 }
 
 // Comment represents a single inline review comment.
 type Comment struct {
-	ID             string  `json:"id"`
-	StartLine      int     `json:"start_line"`
-	EndLine        int     `json:"end_line"`
-	Side           string  `json:"side,omitempty"`
-	Body           string  `json:"body"`
-	Quote          string  `json:"quote,omitempty"`
-	QuoteOffset    *int    `json:"quote_offset,omitempty"`
-	Anchor         string  `json:"anchor,omitempty"`
-	Drifted        bool    `json:"drifted,omitempty"`
-	Author         string  `json:"author,omitempty"`
-	UserID         string  `json:"user_id,omitempty"`
-	Scope          string  `json:"scope,omitempty"`
-	CreatedAt      string  `json:"created_at"`
-	UpdatedAt      string  `json:"updated_at"`
-	Resolved       bool    `json:"resolved,omitempty"`
-	Live           bool    `json:"live,omitempty"`
-	CarriedForward bool    `json:"carried_forward,omitempty"`
-	ReviewRound    int     `json:"review_round,omitempty"`
-	Replies        []Reply `json:"replies,omitempty"`
-	GitHubID       int64   `json:"github_id,omitempty"`
+	ID                string  `json:"id"`
+	StartLine         int     `json:"start_line"`
+	EndLine           int     `json:"end_line"`
+	Side              string  `json:"side,omitempty"`
+	Body              string  `json:"body"`
+	Quote             string  `json:"quote,omitempty"`
+	QuoteOffset       *int    `json:"quote_offset,omitempty"`
+	Anchor            string  `json:"anchor,omitempty"`
+	Drifted           bool    `json:"drifted,omitempty"`
+	Author            string  `json:"author,omitempty"`
+	UserID            string  `json:"user_id,omitempty"`
+	Scope             string  `json:"scope,omitempty"`
+	CreatedAt         string  `json:"created_at"`
+	UpdatedAt         string  `json:"updated_at"`
+	Resolved          bool    `json:"resolved,omitempty"`
+	Live              bool    `json:"live,omitempty"`
+	CarriedForward    bool    `json:"carried_forward,omitempty"`
+	ReviewRound       int     `json:"review_round,omitempty"`
+	Replies           []Reply `json:"replies,omitempty"`
+	Version           int     `json:"version,omitempty"` // bumped by every edit; see EditComment
+	GitHubID          int64   `json:"github_id,omitempty"`
+	GitHubReviewID    int64   `json:"github_review_id,omitempty"`    // review whose body carried this comment (see planGHPush, mergeGHReviews)
+	GitHubReviewState string  `json:"github_review_state,omitempty"` // state of that review when pulled: APPROVED, CHANGES_REQUESTED, ...
+	RemoteID          string  `json:"remote_id,omitempty"`           // thread ID on a non-GitHub forge (see Forge)
+	RemoteResolved    *bool   `json:"remote_resolved,omitempty"`     // forge thread's resolved state at the last sync, so pull and push only apply changes
+
+	Proposal     *ProposedChange `json:"proposal,omitempty"`
+	AISuggestion bool            `json:"ai_suggestion,omitempty"` // added by an agent pre-review; cleared when the reviewer accepts it
+}
+
+// ProposedChange is a patch produced by an agent run in an isolated worktree.
+// It stays attached to the comment until the reviewer accepts (applies it to
+// the working tree) or rejects it.
+type ProposedChange struct {
+	Patch     string   `json:"patch"`
+	Files     []string `json:"files,omitempty"`
+	Author    string   `json:"author,omitempty"`
+	CreatedAt string   `json:"created_at"`
 }
 
 // SSEEvent is sent to the browser via server-sent events.
@@ -82,6 +102,8 @@ type SSEEvent struct {
 	Type     string `json:"type"`
 	Filename string `json:"filename"`
 	Content  string `json:"content"`
+	Seq      uint64 `json:"seq,omitempty"`    // position in the session's event log; 0 for transient events
+	Client   string `json:"client,omitempty"` // stream client that sent a client action
 }
 
 // FileEntry holds the state for a single file in a review session.
@@ -152,6 +174,7 @@ func (fe *FileEntry) ensureLoaded(repoRoot, baseRef string, vcs VCS) error {
 
 // loadDiff computes diff hunks via the VCS interface or git package-level fallback.
 func (fe *FileEntry) loadDiff(ctx context.Context, repoRoot, baseRef string, vcs VCS) {
+	defer observeDiff("lazy", time.Now())
 	if vcs != nil {
 		hunks, err := vcs.FileDiffUnifiedCtx(ctx, fe.Path, baseRef, repoRoot)
 		if err != nil {
@@ -195,6 +218,8 @@ type Session struct {
 	mu          sync.RWMutex
 	subscribers map[chan SSEEvent]struct{}
 	subMu       sync.Mutex
+	events      eventLog
+	clients     map[string]*presence // connected /api/events streams, under subMu; see presence.go
 	writeTimer  *time.Timer
 	writeGen    int
 	// writeMu serializes debounced WriteFiles() calls with ClearAllComments
@@ -208,6 +233,8 @@ type Session struct {
 	deleteToken         string
 	shareScope          string
 	status              *Status
+	webhooks            *webhookSender // nil when none are configured; see webhooks.go
+	metrics             *reviewMetrics // nil for the process-wide critMetrics; see counters
 	roundComplete       chan struct{}
 	pendingEdits        int
 	lastRoundEdits      int
@@ -216,6 +243,12 @@ type Session struct {
 	waitingForAgent     bool      // true between finish (with unresolved comments) and round-complete
 	browserClients      int32     // number of connected SSE browser clients (atomic)
 
+	// Round state that survives a restart; see roundstate.go.
+	finish        *roundFinish            // a finish no review client has received
+	agentRequests map[string]agentRequest // unfinished comment agent jobs by job ID
+	stateFrozen   bool                    // shutting down: stop saving the round state
+	stateMu       sync.Mutex              // serializes writes of the state file
+
 }
 
 // CritJSON is the on-disk format for review files.
@@ -231,6 +264,9 @@ type CritJSON struct {
 	ReviewComments []Comment               `json:"review_comments,omitempty"`
 	CliArgs        []string                `json:"cli_args,omitempty"`
 	Files          map[string]CritJSONFile `json:"files"`
+	// GitHubReviewIDs are the PR reviews crit pushed, so that pulling
+	// doesn't import them back.
+	GitHubReviewIDs []int64 `json:"github_review_ids,omitempty"`
 }
 
 // CritJSONFile is the per-file section in review files.
@@ -284,6 +320,7 @@ func populateEagerFile(fe *FileEntry, fc FileChange, baseRef, root string, vcs V
 
 // populateEagerFileDiff computes diff hunks for an eager-loaded file.
 func populateEagerFileDiff(fe *FileEntry, fc FileChange, baseRef, root string, vcs VCS) {
+	defer observeDiff("eager", time.Now())
 	if vcs != nil {
 		hunks, err := vcs.FileDiffUnified(fc.Path, baseRef, root)
 		if err != nil {
@@ -420,10 +457,19 @@ func expandAndDedupPaths(paths []string, ignorePatterns []string) ([]string, err
 
 // resolveGitContext returns VCS repo state for file-mode sessions.
 func resolveGitContext() (root, branch, baseRef, baseBranchName string, vcs VCS) {
-	vcs = DetectVCS("")
+	return resolveGitContextIn("", "")
+}
+
+// resolveGitContextIn is resolveGitContext for the checkout in dir, diffing
+// against baseBranch when it is set.
+func resolveGitContextIn(dir, baseBranch string) (root, branch, baseRef, baseBranchName string, vcs VCS) {
+	vcs = DetectVCSIn(dir, "")
 	if vcs == nil {
 		return "", "", "", "", nil
 	}
+	if baseBranch != "" {
+		vcs.SetDefaultBranchOverride(baseBranch)
+	}
 	root, _ = vcs.RepoRoot()
 	branch = vcs.CurrentBranch()
 	resolvedBase := vcs.DefaultBranch()
@@ -439,9 +485,26 @@ func resolveGitContext() (root, branch, baseRef, baseBranchName string, vcs VCS)
 // The base branch is read from DefaultBranch(), which respects defaultBranchOverride
 // set by resolveServerConfig(). See NewSessionFromGit for rationale.
 func NewSessionFromFiles(paths []string, ignorePatterns []string) (*Session, error) {
+	return NewSessionFromFilesIn("", "", paths, ignorePatterns)
+}
+
+// NewSessionFromFilesIn is NewSessionFromFiles for a caller in dir (the
+// current directory when empty): relative paths are resolved against it, and
+// baseBranch, when set, overrides the detected base branch.
+func NewSessionFromFilesIn(dir, baseBranch string, paths []string, ignorePatterns []string) (*Session, error) {
 	if len(paths) == 0 {
 		return nil, fmt.Errorf("no files provided")
 	}
+	if dir != "" {
+		resolved := make([]string, len(paths))
+		for i, p := range paths {
+			resolved[i] = p
+			if !filepath.IsAbs(p) {
+				resolved[i] = filepath.Join(dir, p)
+			}
+		}
+		paths = resolved
+	}
 
 	expandedPaths, err := expandAndDedupPaths(paths, ignorePatterns)
 	if err != nil {
@@ -451,7 +514,7 @@ func NewSessionFromFiles(paths []string, ignorePatterns []string) (*Session, err
 		return nil, fmt.Errorf("no files found")
 	}
 
-	root, branch, baseRef, baseBranchName, vcs := resolveGitContext()
+	root, branch, baseRef, baseBranchName, vcs := resolveGitContextIn(dir, baseBranch)
 	if root == "" {
 		root = filepath.Dir(expandedPaths[0])
 	}
@@ -653,6 +716,7 @@ func (s *Session) AddComment(filePath string, startLine, endLine int, side, body
 	}
 	f.Comments = append(f.Comments, c)
 	s.scheduleWrite()
+	s.commentAdded(filePath, c)
 	return c, true
 }
 
@@ -677,6 +741,7 @@ func (s *Session) AddFileComment(filePath, body, author, userID string) (Comment
 	}
 	f.Comments = append(f.Comments, c)
 	s.scheduleWrite()
+	s.commentAdded(filePath, c)
 	return c, true
 }
 
@@ -697,9 +762,17 @@ func (s *Session) AddReviewComment(body, author, userID string) Comment {
 	}
 	s.reviewComments = append(s.reviewComments, c)
 	s.scheduleWrite()
+	s.commentAdded("", c)
 	return c
 }
 
+// commentAdded reports a new comment to the webhooks and metrics. filePath is
+// empty for review comments. Caller must hold s.mu.
+func (s *Session) commentAdded(filePath string, c Comment) {
+	s.counters().commentsCreated.inc("")
+	s.webhookLocked(webhookPayload{Event: "comment-added", File: filePath, Comment: &c})
+}
+
 // GetReviewComments returns a copy of all review-level comments.
 func (s *Session) GetReviewComments() []Comment {
 	s.mu.RLock()
@@ -711,17 +784,8 @@ func (s *Session) GetReviewComments() []Comment {
 
 // UpdateReviewComment updates a review-level comment by ID.
 func (s *Session) UpdateReviewComment(id, body string) (Comment, bool) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	for i, c := range s.reviewComments {
-		if c.ID == id {
-			s.reviewComments[i].Body = body
-			s.reviewComments[i].UpdatedAt = time.Now().UTC().Format(time.RFC3339)
-			s.scheduleWrite()
-			return s.reviewComments[i], true
-		}
-	}
-	return Comment{}, false
+	c, err := s.EditComment("", id, CommentEdit{Body: &body})
+	return c, err == nil
 }
 
 // DeleteReviewComment deletes a review-level comment by ID.
@@ -740,17 +804,8 @@ func (s *Session) DeleteReviewComment(id string) bool {
 
 // ResolveReviewComment sets or clears the resolved flag on a review-level comment.
 func (s *Session) ResolveReviewComment(id string, resolved bool) (Comment, bool) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	for i, c := range s.reviewComments {
-		if c.ID == id {
-			s.reviewComments[i].Resolved = resolved
-			s.reviewComments[i].UpdatedAt = time.Now().UTC().Format(time.RFC3339)
-			s.scheduleWrite()
-			return s.reviewComments[i], true
-		}
-	}
-	return Comment{}, false
+	c, err := s.EditComment("", id, CommentEdit{Resolved: &resolved})
+	return c, err == nil
 }
 
 // AddReviewCommentReply adds a reply to a review-level comment.
@@ -819,25 +874,96 @@ func (s *Session) DeleteReviewCommentReply(commentID, replyID string) bool {
 
 // UpdateComment updates a comment in a specific file.
 func (s *Session) UpdateComment(filePath, id, body string) (Comment, bool) {
+	c, err := s.EditComment(filePath, id, CommentEdit{Body: &body})
+	return c, err == nil
+}
+
+// SetCommentResolved sets or clears the resolved flag on a comment.
+func (s *Session) SetCommentResolved(filePath, id string, resolved bool) (Comment, bool) {
+	c, err := s.EditComment(filePath, id, CommentEdit{Resolved: &resolved})
+	return c, err == nil
+}
+
+var (
+	errCommentNotFound = errors.New("comment not found")
+	errCommentConflict = errors.New("comment was changed since that version")
+)
+
+// CommentEdit changes a comment's body, resolved flag, or both. When Version
+// is set the edit only applies if the comment is still at that version, so
+// two reviewers editing the same comment don't silently overwrite each other.
+type CommentEdit struct {
+	Version  *int
+	Body     *string
+	Resolved *bool
+}
+
+// EditComment applies edit to a comment in filePath, or to a review-level
+// comment when filePath is empty, and bumps its version. It returns
+// errCommentNotFound, or errCommentConflict together with the comment as it
+// currently is when edit.Version is stale.
+func (s *Session) EditComment(filePath, id string, edit CommentEdit) (Comment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.commentLocked(filePath, id)
+	if c == nil {
+		return Comment{}, errCommentNotFound
+	}
+	if edit.Version != nil && *edit.Version != c.Version {
+		return *c, errCommentConflict
+	}
+	if edit.Body != nil {
+		c.Body = *edit.Body
+	}
+	if edit.Resolved != nil {
+		s.countResolved(*edit.Resolved && !c.Resolved)
+		c.Resolved = *edit.Resolved
+	}
+	c.Version++
+	c.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	s.scheduleWrite()
+	return *c, nil
+}
+
+// commentLocked returns the comment with the given ID in filePath, or among
+// the review-level comments when filePath is empty. Caller must hold s.mu.
+func (s *Session) commentLocked(filePath, id string) *Comment {
+	comments := s.reviewComments
+	if filePath != "" {
+		f := s.fileByPathLocked(filePath)
+		if f == nil {
+			return nil
+		}
+		comments = f.Comments
+	}
+	for i := range comments {
+		if comments[i].ID == id {
+			return &comments[i]
+		}
+	}
+	return nil
+}
+
+// SetCommentLive marks a comment as live (sent to an agent).
+func (s *Session) SetCommentLive(filePath, id string) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	f := s.fileByPathLocked(filePath)
 	if f == nil {
-		return Comment{}, false
+		return false
 	}
 	for i, c := range f.Comments {
 		if c.ID == id {
-			f.Comments[i].Body = body
-			f.Comments[i].UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+			f.Comments[i].Live = true
 			s.scheduleWrite()
-			return f.Comments[i], true
+			return true
 		}
 	}
-	return Comment{}, false
+	return false
 }
 
-// SetCommentResolved sets or clears the resolved flag on a comment.
-func (s *Session) SetCommentResolved(filePath, id string, resolved bool) (Comment, bool) {
+// SetCommentProposal attaches (or, with nil, clears) an agent's proposed change.
+func (s *Session) SetCommentProposal(filePath, id string, p *ProposedChange) (Comment, bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	f := s.fileByPathLocked(filePath)
@@ -846,7 +972,7 @@ func (s *Session) SetCommentResolved(filePath, id string, resolved bool) (Commen
 	}
 	for i, c := range f.Comments {
 		if c.ID == id {
-			f.Comments[i].Resolved = resolved
+			f.Comments[i].Proposal = p
 			f.Comments[i].UpdatedAt = time.Now().UTC().Format(time.RFC3339)
 			s.scheduleWrite()
 			return f.Comments[i], true
@@ -855,22 +981,28 @@ func (s *Session) SetCommentResolved(filePath, id string, resolved bool) (Commen
 	return Comment{}, false
 }
 
-// SetCommentLive marks a comment as live (sent to an agent).
-func (s *Session) SetCommentLive(filePath, id string) bool {
+// SetCommentAISuggestion sets or clears the AI-suggestion mark on a comment.
+// An empty filePath addresses a review-level comment.
+func (s *Session) SetCommentAISuggestion(filePath, id string, v bool) (Comment, bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	f := s.fileByPathLocked(filePath)
-	if f == nil {
-		return false
+	comments := s.reviewComments
+	if filePath != "" {
+		f := s.fileByPathLocked(filePath)
+		if f == nil {
+			return Comment{}, false
+		}
+		comments = f.Comments
 	}
-	for i, c := range f.Comments {
-		if c.ID == id {
-			f.Comments[i].Live = true
+	for i := range comments {
+		if comments[i].ID == id {
+			comments[i].AISuggestion = v
+			comments[i].UpdatedAt = time.Now().UTC().Format(time.RFC3339)
 			s.scheduleWrite()
-			return true
+			return comments[i], true
 		}
 	}
-	return false
+	return Comment{}, false
 }
 
 // DeleteComment deletes a comment from a specific file.
@@ -924,6 +1056,18 @@ func (s *Session) RefreshFileContent() {
 	}
 }
 
+// addAgentReply adds the reply of an agent run to a comment and reports it
+// to the webhooks.
+func (s *Session) addAgentReply(filePath, commentID, body, author string) {
+	r, ok := s.AddReply(filePath, commentID, body, author, "")
+	if !ok {
+		return
+	}
+	if c, _, found := s.FindCommentByID(commentID, filePath); found {
+		s.webhook(webhookPayload{Event: "agent-reply", File: filePath, Comment: &c, Reply: &r})
+	}
+}
+
 // AddReply adds a reply to a specific comment on a file.
 func (s *Session) AddReply(filePath, commentID, body, author, userID string) (Reply, bool) {
 	s.mu.Lock()
@@ -1288,8 +1432,9 @@ func (s *Session) GetReviewRound() int {
 // IncrementEdits increments the pending edit counter.
 func (s *Session) IncrementEdits() {
 	s.mu.Lock()
-	defer s.mu.Unlock()
 	s.pendingEdits++
+	s.mu.Unlock()
+	s.saveRoundState()
 }
 
 // GetPendingEdits returns the pending edit count.
@@ -1316,15 +1461,17 @@ func (s *Session) IsAwaitingFirstReview() bool {
 // SetAwaitingFirstReview sets the awaitingFirstReview flag.
 func (s *Session) SetAwaitingFirstReview(v bool) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
 	s.awaitingFirstReview = v
+	s.mu.Unlock()
+	s.saveRoundState()
 }
 
 // setWaitingForAgent marks whether the session is in the "waiting for agent edits" phase.
 func (s *Session) setWaitingForAgent(v bool) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
 	s.waitingForAgent = v
+	s.mu.Unlock()
+	s.saveRoundState()
 }
 
 // isWaitingForAgent returns true if the session is waiting for agent edits.
@@ -1350,12 +1497,14 @@ func (s *Session) SignalRoundComplete() {
 	s.lastRoundEdits = s.pendingEdits
 	s.pendingEdits = 0
 	s.waitingForAgent = false
+	s.finish = nil // a new round makes any unclaimed finish moot
 	// Clear comments on all files.
 	// ReviewRound is incremented later by the watcher after carry-forward.
 	for _, f := range s.Files {
 		f.Comments = []Comment{}
 	}
 	s.mu.Unlock()
+	s.saveRoundState()
 	select {
 	case s.roundComplete <- struct{}{}:
 	default:
@@ -1396,10 +1545,12 @@ func (s *Session) ClearAllComments() {
 	s.lastCritJSONMtime = time.Time{}
 	s.pendingWrite = false
 	s.waitingForAgent = false
+	s.finish = nil
 	critPath := s.critJSONPath()
 	s.mu.Unlock()
 	// Delete the review file from disk (centralized or legacy path).
 	os.Remove(critPath) //nolint:errcheck
+	s.removeRoundState()
 }
 
 // ChangeBaseBranch changes the diff base to the given branch, recomputes merge-base,
@@ -1788,9 +1939,10 @@ func (s *Session) mergeFileCommentsFromDisk(f *FileEntry, diskFile CritJSONFile)
 	for _, dc := range diskFile.Comments {
 		if _, exists := memIDs[dc.ID]; !exists {
 			f.Comments = append(f.Comments, dc)
+			s.commentAdded(f.Path, dc)
 			changed = true
 		} else {
-			changed = s.mergeCommentRepliesAndState(f.Comments, dc) || changed
+			changed = s.mergeCommentRepliesAndState(f.Path, f.Comments, dc) || changed
 		}
 	}
 
@@ -1802,7 +1954,10 @@ func (s *Session) mergeFileCommentsFromDisk(f *FileEntry, diskFile CritJSONFile)
 	return changed
 }
 
-func (s *Session) mergeCommentRepliesAndState(comments []Comment, dc Comment) bool {
+// mergeCommentRepliesAndState adds the replies and resolved state of dc
+// from disk to the matching comment. Replies written to the review file come
+// from `crit comment --reply-to`, which is how agents answer.
+func (s *Session) mergeCommentRepliesAndState(path string, comments []Comment, dc Comment) bool {
 	changed := false
 	for i, mc := range comments {
 		if mc.ID != dc.ID {
@@ -1815,11 +1970,13 @@ func (s *Session) mergeCommentRepliesAndState(comments []Comment, dc Comment) bo
 		for _, dr := range dc.Replies {
 			if _, exists := memReplyIDs[dr.ID]; !exists {
 				comments[i].Replies = append(comments[i].Replies, dr)
+				s.webhookLocked(webhookPayload{Event: "agent-reply", File: path, Comment: &dc, Reply: &dr})
 				changed = true
 			}
 		}
 		if dc.Resolved != mc.Resolved {
 			comments[i].Resolved = dc.Resolved
+			s.countResolved(dc.Resolved)
 			changed = true
 		}
 		break
@@ -1855,6 +2012,7 @@ func (s *Session) mergeReviewCommentsFromDisk(diskComments []Comment) bool {
 	for _, dc := range diskComments {
 		if _, exists := memReviewIDs[dc.ID]; !exists {
 			s.reviewComments = append(s.reviewComments, dc)
+			s.commentAdded("", dc)
 			changed = true
 		} else {
 			changed = s.mergeReviewCommentRepliesAndState(dc) || changed
@@ -1875,6 +2033,7 @@ func (s *Session) mergeReviewCommentRepliesAndState(dc Comment) bool {
 		}
 		if dc.Resolved != mc.Resolved {
 			s.reviewComments[i].Resolved = dc.Resolved
+			s.countResolved(dc.Resolved)
 			changed = true
 		}
 		memRIDs := make(map[string]struct{}, len(mc.Replies))
@@ -1884,6 +2043,7 @@ func (s *Session) mergeReviewCommentRepliesAndState(dc Comment) bool {
 		for _, dr := range dc.Replies {
 			if _, exists := memRIDs[dr.ID]; !exists {
 				s.reviewComments[i].Replies = append(s.reviewComments[i].Replies, dr)
+				s.webhookLocked(webhookPayload{Event: "agent-reply", Comment: &dc, Reply: &dr})
 				changed = true
 			}
 		}
@@ -2081,32 +2241,52 @@ func (s *Session) appendOrphanedFiles(critFiles map[string]CritJSONFile) {
 
 // SSE subscriber management
 
-// Subscribe registers a new SSE subscriber.
+// Subscribe registers a new SSE subscriber. It receives every event from
+// now on, in order.
 func (s *Session) Subscribe() chan SSEEvent {
-	ch := make(chan SSEEvent, 4)
+	wake, seq := s.events.watch()
+	return s.subscribeFrom(wake, seq)
+}
+
+// SubscribeSince is Subscribe for a client that has seen the events up to
+// seq. It returns false when some later events are no longer kept.
+func (s *Session) SubscribeSince(seq uint64) (chan SSEEvent, bool) {
+	wake, _ := s.events.watch()
+	if _, ok := s.events.since(seq); !ok {
+		s.events.unwatch(wake)
+		return nil, false
+	}
+	return s.subscribeFrom(wake, seq), true
+}
+
+func (s *Session) subscribeFrom(wake chan struct{}, seq uint64) chan SSEEvent {
+	ch := make(chan SSEEvent, 16)
 	s.subMu.Lock()
+	if s.subscribers == nil {
+		s.subscribers = make(map[chan SSEEvent]struct{})
+	}
 	s.subscribers[ch] = struct{}{}
 	s.subMu.Unlock()
+	go s.pumpEvents(ch, wake, seq)
 	return ch
 }
 
-// Unsubscribe removes an SSE subscriber.
+// Unsubscribe removes an SSE subscriber. Its channel is closed once the
+// goroutine feeding it has stopped.
 func (s *Session) Unsubscribe(ch chan SSEEvent) {
 	s.subMu.Lock()
 	delete(s.subscribers, ch)
 	s.subMu.Unlock()
-	close(ch)
+	s.events.wake()
 }
 
+// notify publishes an event to every subscriber; see events.go.
 func (s *Session) notify(event SSEEvent) {
-	s.subMu.Lock()
-	defer s.subMu.Unlock()
-	for ch := range s.subscribers {
-		select {
-		case ch <- event:
-		default:
-		}
+	if transientEvents[event.Type] {
+		s.broadcast(event)
+		return
 	}
+	s.events.append(event)
 }
 
 // BrowserConnect increments the browser client count.
@@ -2126,6 +2306,10 @@ func (s *Session) HasBrowserClients() bool {
 	return atomic.LoadInt32(&s.browserClients) > 0
 }
 
+func (s *Session) browserClientCount() int {
+	return int(atomic.LoadInt32(&s.browserClients))
+}
+
 // ReinvokeCommand returns the crit command the agent should run to trigger the next round.
 // For file-mode sessions it includes the original file arguments; for git-mode it's bare "crit".
 func (s *Session) ReinvokeCommand() string {
@@ -2135,8 +2319,10 @@ func (s *Session) ReinvokeCommand() string {
 	return "crit " + strings.Join(s.CLIArgs, " ")
 }
 
-// Shutdown sends a server-shutdown event to all SSE subscribers.
+// Shutdown stops saving the round state and sends a server-shutdown event to
+// all SSE subscribers, which tells waiting review clients the daemon stopped.
 func (s *Session) Shutdown() {
+	s.freezeRoundState()
 	s.notify(SSEEvent{Type: "server-shutdown"})
 }
 
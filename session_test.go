@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -110,6 +111,34 @@ func TestSession_UpdateComment_NotFound(t *testing.T) {
 	}
 }
 
+func TestSession_EditComment_Version(t *testing.T) {
+	s := newTestSession(t)
+	c, _ := s.AddComment("plan.md", 1, 1, "", "original", "", "", "")
+	seen := c.Version
+
+	mine, theirs := "mine", "theirs"
+	edited, err := s.EditComment("plan.md", c.ID, CommentEdit{Version: &seen, Body: &theirs})
+	if err != nil || edited.Version != seen+1 {
+		t.Fatalf("first edit = %+v, %v", edited, err)
+	}
+	current, err := s.EditComment("plan.md", c.ID, CommentEdit{Version: &seen, Body: &mine})
+	if !errors.Is(err, errCommentConflict) {
+		t.Fatalf("stale edit err = %v, want errCommentConflict", err)
+	}
+	if current.Body != "theirs" || current.Version != edited.Version {
+		t.Errorf("conflict returned %+v, want the current comment", current)
+	}
+
+	resolved := true
+	if _, err := s.EditComment("", c.ID, CommentEdit{Resolved: &resolved}); !errors.Is(err, errCommentNotFound) {
+		t.Errorf("file comment addressed as a review comment: err = %v", err)
+	}
+	r, err := s.EditComment("plan.md", c.ID, CommentEdit{Resolved: &resolved})
+	if err != nil || !r.Resolved || r.Version != edited.Version+1 {
+		t.Errorf("unversioned resolve = %+v, %v", r, err)
+	}
+}
+
 func TestSession_DeleteComment(t *testing.T) {
 	s := newTestSession(t)
 	c, _ := s.AddComment("plan.md", 1, 1, "", "to delete", "", "", "")
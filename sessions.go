@@ -0,0 +1,303 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// crit list, attach and logs work on any session on the machine, not only
+// the one for the current directory. They name a session by the key crit
+// list prints; like a git commit, a unique prefix of it is enough.
+
+func runList(args []string) {
+	jsonOutput := false
+	for _, arg := range args {
+		if arg != "--json" {
+			fmt.Fprintln(os.Stderr, "Usage: crit list [--json]")
+			os.Exit(1)
+		}
+		jsonOutput = true
+	}
+
+	entries, keys := listAllSessions()
+	byKey := make(map[string]sessionEntry, len(keys))
+	for i, k := range keys {
+		byKey[k] = entries[i]
+	}
+	rows := sessionRows(entries, keys)
+	for i := range rows {
+		rows[i].URL = reviewURL(rows[i].Key, byKey[rows[i].Key])
+	}
+	if jsonOutput {
+		data, _ := json.MarshalIndent(rows, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+	if len(rows) == 0 {
+		fmt.Println("No crit sessions running.")
+		return
+	}
+	printSessionList(os.Stdout, rows, time.Now())
+}
+
+// printSessionList writes rows as a table. Round is "-" while a session is
+// still loading.
+func printSessionList(w io.Writer, rows []hubSession, now time.Time) {
+	home, _ := os.UserHomeDir()
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tREPO\tBRANCH\tPORT\tPID\tUPTIME\tROUND")
+	for _, r := range rows {
+		repo := r.CWD
+		if home != "" && (repo == home || strings.HasPrefix(repo, home+string(filepath.Separator))) {
+			repo = "~" + strings.TrimPrefix(repo, home)
+		}
+		branch := r.Branch
+		if branch == "" {
+			branch = "-"
+		}
+		uptime := "-"
+		if started, err := time.Parse(time.RFC3339, r.StartedAt); err == nil {
+			uptime = formatUptime(now.Sub(started))
+		}
+		round := "-"
+		if r.Summary != nil {
+			round = strconv.Itoa(r.Summary.Round)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%s\t%s\n", r.Key, repo, branch, r.Port, r.PID, uptime, round)
+	}
+	tw.Flush()
+}
+
+// formatUptime shortens d to its two largest units, e.g. 3h12m or 2d4h.
+func formatUptime(d time.Duration) string {
+	d = d.Truncate(time.Second)
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh%dm", int(d.Hours()), int(d.Minutes())%60)
+	default:
+		return fmt.Sprintf("%dd%dh", int(d.Hours())/24, int(d.Hours())%24)
+	}
+}
+
+// findSessionByKey returns the live session whose key starts with prefix.
+func findSessionByKey(prefix string) (sessionEntry, string, error) {
+	entries, keys := listAllSessions()
+	i, err := matchSessionKey(keys, prefix)
+	if err != nil {
+		return sessionEntry{}, "", err
+	}
+	return entries[i], keys[i], nil
+}
+
+// matchSessionKey returns the index of the one key starting with prefix.
+func matchSessionKey(keys []string, prefix string) (int, error) {
+	if prefix == "" {
+		return -1, fmt.Errorf("no session key given (see crit list)")
+	}
+	var matches []int
+	for i, k := range keys {
+		if strings.HasPrefix(k, prefix) {
+			matches = append(matches, i)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return -1, fmt.Errorf("no running session matches %q (see crit list)", prefix)
+	case 1:
+		return matches[0], nil
+	}
+	var ambiguous []string
+	for _, i := range matches {
+		ambiguous = append(ambiguous, keys[i])
+	}
+	return -1, fmt.Errorf("%q matches several sessions: %s", prefix, strings.Join(ambiguous, ", "))
+}
+
+// runAttach opens the browser on a running session, and with --wait also
+// blocks as its review client, the way `crit` does in the session's
+// directory: the next round's prompt is printed when the reviewer finishes.
+func runAttach(args []string) {
+	noOpen, wait := false, false
+	var key string
+	for _, arg := range args {
+		switch {
+		case arg == "--no-open":
+			noOpen = true
+		case arg == "--wait":
+			wait = true
+		case strings.HasPrefix(arg, "-") || key != "":
+			fmt.Fprintln(os.Stderr, "Usage: crit attach [--wait] [--no-open] <key>")
+			os.Exit(1)
+		default:
+			key = arg
+		}
+	}
+	entry, key, err := findSessionByKey(key)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	url := reviewURL(key, entry)
+	fmt.Fprintf(os.Stderr, "Attached to crit daemon on port %d: %s\n", entry.Port, url)
+	printRemoteAccess(entry)
+	// Without --wait, opening the browser is the point, so open a tab even
+	// if one is already connected.
+	if !noOpen && (!wait || !daemonHasBrowser(entry)) {
+		openBrowser(url)
+	}
+	if !wait {
+		return
+	}
+	// attach doesn't know how the daemon was started, so if it goes away
+	// the wait resumes only when something else starts it again.
+	approved := runReviewClient(key, &entry, nil)
+	killDaemonOnApproval(approved, key, entry)
+	cleanupOnApproval(approved, entry.ReviewPath, LoadConfig(entry.CWD).CleanupOnApproveEnabled())
+}
+
+// Follow mode reads new log output every logPollInterval and stops once the
+// daemon has exited, checked every logAliveInterval.
+var (
+	logPollInterval  = 250 * time.Millisecond
+	logAliveInterval = 2 * time.Second
+)
+
+// runLogs prints a daemon's log, which crit otherwise only shows when the
+// daemon fails to start. The log of a daemon that has exited stays readable
+// by its full key until crit next cleans up stale sessions.
+func runLogs(args []string) {
+	follow, lines, key, err := parseLogsArgs(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\nUsage: crit logs [-f] [-n <lines>] <key>\n", err)
+		os.Exit(1)
+	}
+	// A full key is used as is: listing sessions would clean up the log of
+	// a daemon that has exited.
+	if !isSessionKey(key) {
+		_, k, err := findSessionByKey(key)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		key = k
+	}
+	logKey := key
+	if entry, err := readSessionFile(key); err == nil && entry.Path != "" {
+		// Sessions the hub hosts write to the hub's log.
+		logKey = hubKey
+	}
+	logPath, err := sessionLogPath(logKey)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	f, err := os.Open(logPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: no log for session %s: %v\n", key, err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: reading %s: %v\n", logPath, err)
+		os.Exit(1)
+	}
+	os.Stdout.Write(lastLines(data, lines))
+	if !follow {
+		return
+	}
+	// Polled every couple of seconds for as long as the log is followed, so
+	// it checks the PID only: a health probe would keep the daemon from ever
+	// idling out.
+	alive := func() bool {
+		entry, err := readSessionFile(key)
+		return err == nil && processRunning(entry.PID)
+	}
+	if err := followLog(f, os.Stdout, alive); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: reading %s: %v\n", logPath, err)
+		os.Exit(1)
+	}
+}
+
+func parseLogsArgs(args []string) (follow bool, lines int, key string, err error) {
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "-f" || arg == "--follow":
+			follow = true
+		case arg == "-n" || arg == "--lines":
+			if i+1 >= len(args) {
+				return false, 0, "", fmt.Errorf("%s needs a number", arg)
+			}
+			i++
+			if lines, err = strconv.Atoi(args[i]); err != nil || lines < 0 {
+				return false, 0, "", fmt.Errorf("invalid line count %q", args[i])
+			}
+		case strings.HasPrefix(arg, "-") || key != "":
+			return false, 0, "", fmt.Errorf("unexpected argument %q", arg)
+		default:
+			key = arg
+		}
+	}
+	if key == "" {
+		return false, 0, "", fmt.Errorf("no session key given (see crit list)")
+	}
+	return follow, lines, key, nil
+}
+
+// lastLines returns the last n lines of data, or all of it when n is 0.
+func lastLines(data []byte, n int) []byte {
+	if n == 0 {
+		return data
+	}
+	end := len(data)
+	if end > 0 && data[end-1] == '\n' {
+		end--
+	}
+	for i := end - 1; i >= 0; i-- {
+		if data[i] == '\n' {
+			n--
+			if n == 0 {
+				return data[i+1:]
+			}
+		}
+	}
+	return data
+}
+
+// followLog copies what is appended to f to w until alive reports that the
+// daemon writing it has exited.
+func followLog(f *os.File, w io.Writer, alive func() bool) error {
+	buf := make([]byte, 32*1024)
+	lastCheck := time.Now()
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			w.Write(buf[:n])
+			continue
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if time.Since(lastCheck) >= logAliveInterval {
+			if !alive() {
+				_, err := io.Copy(w, f) // its last words
+				return err
+			}
+			lastCheck = time.Now()
+		}
+		time.Sleep(logPollInterval)
+	}
+}
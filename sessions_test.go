@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrintSessionList(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	now := time.Date(2026, 3, 1, 13, 12, 0, 0, time.UTC)
+	rows := []hubSession{
+		{Key: "0123456789ab", CWD: filepath.Join(home, "src", "widgets"), Branch: "feature", Port: 51234, PID: 4242,
+			StartedAt: "2026-03-01T10:00:00Z", Summary: &sessionSummary{Round: 3}},
+		{Key: "ba9876543210", CWD: "/srv/plans", Port: 51235, PID: 4243, StartedAt: "2026-03-01T13:11:30Z"},
+	}
+	var b bytes.Buffer
+	printSessionList(&b, rows, now)
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("output:\n%s", b.String())
+	}
+	if got := strings.Fields(lines[1]); strings.Join(got, " ") != "0123456789ab ~/src/widgets feature 51234 4242 3h12m 3" {
+		t.Errorf("first row = %q", lines[1])
+	}
+	if got := strings.Fields(lines[2]); strings.Join(got, " ") != "ba9876543210 /srv/plans - 51235 4243 30s -" {
+		t.Errorf("loading row = %q", lines[2])
+	}
+}
+
+func TestFormatUptime(t *testing.T) {
+	cases := map[time.Duration]string{
+		42 * time.Second:              "42s",
+		7*time.Minute + 3*time.Second: "7m",
+		3*time.Hour + 12*time.Minute:  "3h12m",
+		52*time.Hour + 30*time.Minute: "2d4h",
+		1500 * time.Millisecond:       "1s",
+	}
+	for d, want := range cases {
+		if got := formatUptime(d); got != want {
+			t.Errorf("formatUptime(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestMatchSessionKey(t *testing.T) {
+	keys := []string{"0123456789ab", "01ffffffffff", "abcdefabcdef"}
+	if i, err := matchSessionKey(keys, "abc"); err != nil || i != 2 {
+		t.Errorf("unique prefix = %d, %v", i, err)
+	}
+	if _, err := matchSessionKey(keys, "01"); err == nil || !strings.Contains(err.Error(), "0123456789ab") {
+		t.Errorf("ambiguous prefix error = %v", err)
+	}
+	if _, err := matchSessionKey(keys, "ff"); err == nil {
+		t.Error("expected an error for an unknown key")
+	}
+	if _, err := matchSessionKey(keys, ""); err == nil {
+		t.Error("expected an error for an empty key")
+	}
+}
+
+func TestFindSessionByKey_Live(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	s, _ := newTestServer(t)
+	daemon := httptest.NewServer(s)
+	t.Cleanup(daemon.Close)
+	u, _ := url.Parse(daemon.URL)
+	port, _ := strconv.Atoi(u.Port())
+	writeSessionFile(testHubKey, sessionEntry{PID: os.Getpid(), Port: port, CWD: "/src/widgets"})
+	writeSessionFile("0123456789ff", sessionEntry{PID: 999999999, Port: 1111, CWD: "/src/widgets"}) // dead
+
+	entry, key, err := findSessionByKey("0123")
+	if err != nil || key != testHubKey || entry.Port != port {
+		t.Errorf("findSessionByKey = %+v, %q, %v", entry, key, err)
+	}
+}
+
+func TestParseLogsArgs(t *testing.T) {
+	follow, lines, key, err := parseLogsArgs([]string{"-f", "-n", "20", "0123"})
+	if err != nil || !follow || lines != 20 || key != "0123" {
+		t.Errorf("parseLogsArgs = %v, %d, %q, %v", follow, lines, key, err)
+	}
+	for _, args := range [][]string{{}, {"-n"}, {"-n", "x", "0123"}, {"0123", "4567"}, {"--bogus", "0123"}} {
+		if _, _, _, err := parseLogsArgs(args); err == nil {
+			t.Errorf("parseLogsArgs(%q) should fail", args)
+		}
+	}
+}
+
+func TestLastLines(t *testing.T) {
+	data := []byte("one\ntwo\nthree\n")
+	cases := map[int]string{0: "one\ntwo\nthree\n", 1: "three\n", 2: "two\nthree\n", 5: "one\ntwo\nthree\n"}
+	for n, want := range cases {
+		if got := string(lastLines(data, n)); got != want {
+			t.Errorf("lastLines(%d) = %q, want %q", n, got, want)
+		}
+	}
+	if got := string(lastLines([]byte("a\nb"), 1)); got != "b" {
+		t.Errorf("without a trailing newline = %q", got)
+	}
+}
+
+func TestFollowLog(t *testing.T) {
+	oldPoll, oldAlive := logPollInterval, logAliveInterval
+	logPollInterval, logAliveInterval = time.Millisecond, 5*time.Millisecond
+	t.Cleanup(func() { logPollInterval, logAliveInterval = oldPoll, oldAlive })
+
+	path := filepath.Join(t.TempDir(), "daemon.log")
+	if err := os.WriteFile(path, []byte("started\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.Seek(0, io.SeekEnd) // the existing output has already been printed
+
+	checks := 0
+	alive := func() bool {
+		checks++
+		if checks == 1 {
+			w, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+			w.WriteString("round 2\nshutting down\n")
+			w.Close()
+			return true
+		}
+		return false
+	}
+	var out bytes.Buffer
+	if err := followLog(f, &out, alive); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "round 2\nshutting down\n" {
+		t.Errorf("followed %q", out.String())
+	}
+}
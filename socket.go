@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// The CLI reaches its session daemon over a unix socket in ~/.crit/sessions/
+// that only the owner can open. The loopback TCP port, there for the
+// browser, is open to every user on the machine, so it wants the session's
+// access token on every request (see requireAccessToken): crit opens the
+// browser on a link that carries it, and clients without the socket send it
+// from the session file, which only the owner can read. The hub does the
+// same with a token of its own.
+
+// maxSocketPath is the longest unix socket path every platform accepts
+// (sun_path is 104 bytes on macOS and the BSDs, 108 on Linux).
+const maxSocketPath = 103
+
+// socketHost stands in for the host in URLs of requests sent over a socket.
+const socketHost = "crit"
+
+// sessionSocketPath returns the path to ~/.crit/sessions/<key>.sock, or ""
+// when it would be too long to bind.
+func sessionSocketPath(key string) (string, error) {
+	dir, err := sessionsDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, key+".sock")
+	if len(path) > maxSocketPath {
+		return "", nil
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("creating sessions directory: %w", err)
+	}
+	return path, nil
+}
+
+// listenUnix listens on a unix socket at path that is created with perm, so
+// there is no window in which it is open to everyone. A socket left behind by
+// a daemon that died is replaced; one that still answers is not.
+func listenUnix(path string, perm os.FileMode) (net.Listener, error) {
+	if _, err := os.Stat(path); err == nil {
+		if c, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			c.Close()
+			return nil, fmt.Errorf("%s is in use", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("removing stale socket: %w", err)
+		}
+	}
+	old := syscall.Umask(int(0777 &^ perm))
+	l, err := net.Listen("unix", path)
+	syscall.Umask(old)
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// loopbackToken returns the access token for a daemon's or the hub's
+// loopback port: CRIT_ACCESS_TOKEN when set, so scripts and the e2e suite
+// that start crit themselves know it, otherwise a random one.
+func loopbackToken() (string, error) {
+	if t := os.Getenv("CRIT_ACCESS_TOKEN"); t != "" {
+		return t, nil
+	}
+	return newAccessToken()
+}
+
+// requireLoopbackToken puts next behind token on the loopback port.
+func requireLoopbackToken(port int, token string, next http.Handler) http.Handler {
+	return requireAccessToken{
+		token:  token,
+		cookie: accessCookie(port),
+		denied: "This page needs crit's access token: open the review with `crit attach <key>` (keys are in `crit list`).",
+		next:   next,
+	}
+}
+
+// withAccessToken adds token to a browser link as ?token=, which the
+// listener swaps for a cookie on the first visit.
+func withAccessToken(link, token string) string {
+	if token == "" {
+		return link
+	}
+	return link + "?token=" + url.QueryEscape(token)
+}
+
+// daemonClient returns an HTTP client for the session's daemon. Use it with
+// daemonURL.
+func daemonClient(s sessionEntry, timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: daemonTransport(s)}
+}
+
+// daemonTransport dials the session's socket when it has one. Otherwise it
+// goes to the loopback port, with the access token.
+func daemonTransport(s sessionEntry) http.RoundTripper {
+	if s.Socket == "" {
+		if s.Token == "" { // a daemon from before the token
+			return http.DefaultTransport
+		}
+		return bearerTransport{token: s.Token, next: http.DefaultTransport}
+	}
+	return &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", s.Socket)
+		},
+		// Transports are made per call; don't leave idle connections behind.
+		DisableKeepAlives: true,
+	}
+}
+
+// bearerTransport sends token as an Authorization: Bearer header.
+type bearerTransport struct {
+	token string
+	next  http.RoundTripper
+}
+
+func (t bearerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Header.Set("Authorization", "Bearer "+t.token)
+	return t.next.RoundTrip(r)
+}
+
+// daemonURL returns the URL of path on the session's daemon, for use with
+// daemonClient.
+func daemonURL(s sessionEntry, path string) string {
+	if s.Socket == "" {
+		return fmt.Sprintf("http://localhost:%d%s%s", s.Port, s.Path, path)
+	}
+	return "http://" + socketHost + path
+}
+
+// daemonAddr describes where the daemon is reached, for error messages.
+func daemonAddr(s sessionEntry) string {
+	if s.Socket == "" {
+		return fmt.Sprintf("port %d", s.Port)
+	}
+	return s.Socket
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// serveSocket serves h on a fresh 0600 socket and returns its path.
+func serveSocket(t *testing.T, h http.Handler) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "s.sock")
+	l, err := listenUnix(path, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: h}
+	go srv.Serve(l)
+	t.Cleanup(func() { srv.Close() })
+	return path
+}
+
+func TestListenUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s.sock")
+	l, err := listenUnix(path, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("socket mode = %v, want 0600", info.Mode().Perm())
+	}
+	if _, err := listenUnix(path, 0600); err == nil {
+		t.Error("expected an error for a socket that is in use")
+	}
+
+	// A socket left behind by a dead daemon is replaced.
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+	l, err = listenUnix(path, 0600)
+	if err != nil {
+		t.Fatalf("stale socket: %v", err)
+	}
+	l.Close()
+}
+
+func TestSessionSocketPath_TooLong(t *testing.T) {
+	home := t.TempDir()
+	for len(home) < maxSocketPath {
+		home = filepath.Join(home, "deep-directory")
+	}
+	t.Setenv("HOME", home)
+	path, err := sessionSocketPath("0123456789ab")
+	if err != nil || path != "" {
+		t.Errorf("got %q, %v; want no socket", path, err)
+	}
+}
+
+func TestIsDaemonAlive_OverSocket(t *testing.T) {
+	s, _ := newTestServer(t)
+	// The port is never dialed when the entry has a socket.
+	entry := sessionEntry{PID: os.Getpid(), Port: 1, Socket: serveSocket(t, s)}
+	if !isDaemonAlive(entry) {
+		t.Error("daemon should be alive over its socket")
+	}
+	if daemonHasBrowser(entry) {
+		t.Error("no browser is connected")
+	}
+	entry.Socket = filepath.Join(t.TempDir(), "gone.sock")
+	if isDaemonAlive(entry) {
+		t.Error("daemon with a missing socket should not be alive")
+	}
+}
+
+func TestRunReviewClientRaw_OverSocket(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/session", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("/api/review-cycle", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"approved": false, "prompt": "fix this"})
+	})
+
+	approved, prompt := runReviewClientRaw(sessionEntry{Port: 1, Socket: serveSocket(t, mux)})
+	if approved || prompt != "fix this" {
+		t.Errorf("got approved=%v prompt=%q", approved, prompt)
+	}
+}
+
+func TestLoopbackPort_WantsAccessToken(t *testing.T) {
+	s, _ := newTestServer(t)
+	daemon := httptest.NewServer(requireLoopbackToken(0, "tok", s))
+	t.Cleanup(daemon.Close)
+	u, _ := url.Parse(daemon.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	resp, err := http.Get(daemonURL(sessionEntry{Port: port}, "/api/session"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("without the token: status %d, want 401", resp.StatusCode)
+	}
+
+	// The CLI sends the token from the session file.
+	entry := sessionEntry{PID: os.Getpid(), Port: port, Token: "tok"}
+	if !isDaemonAlive(entry) {
+		t.Error("daemon should be alive with the token")
+	}
+	entry.Token = "wrong"
+	if isDaemonAlive(entry) {
+		t.Error("daemon should turn away the wrong token")
+	}
+}
+
+func TestReviewURL_CarriesAccessToken(t *testing.T) {
+	t.Setenv("HOME", t.TempDir()) // no hub
+	got := reviewURL("0123456789ab", sessionEntry{Port: 1234, Token: "a b"})
+	if want := "http://localhost:1234/?token=a+b"; got != want {
+		t.Errorf("reviewURL = %q, want %q", got, want)
+	}
+}
+
+func TestLoopbackToken_FromEnvironment(t *testing.T) {
+	t.Setenv("CRIT_ACCESS_TOKEN", "")
+	a, _ := loopbackToken()
+	b, _ := loopbackToken()
+	if a == "" || a == b {
+		t.Errorf("random tokens %q and %q", a, b)
+	}
+	t.Setenv("CRIT_ACCESS_TOKEN", "fixed")
+	if got, _ := loopbackToken(); got != "fixed" {
+		t.Errorf("token = %q, want fixed", got)
+	}
+}
@@ -20,6 +20,10 @@
 
 set -e
 
+# crit's loopback ports want an access token; fix it so curl can send it.
+export CRIT_ACCESS_TOKEN="${CRIT_ACCESS_TOKEN:-crit-test}"
+AUTH="Authorization: Bearer $CRIT_ACCESS_TOKEN"
+
 SCRIPT_DIR="$(cd "$(dirname "$0")" && pwd)"
 ROOT="$(cd "$SCRIPT_DIR/.." && pwd)"
 
@@ -85,7 +89,7 @@ PLAN_FILE="plan.md"
 wait_for_server() {
   local port=$1
   for i in $(seq 1 30); do
-    if curl -sf "http://127.0.0.1:$port/api/health" > /dev/null 2>&1; then
+    if curl -sf -H "$AUTH" "http://127.0.0.1:$port/api/health" > /dev/null 2>&1; then
       return 0
     fi
     sleep 0.3
@@ -97,7 +101,7 @@ wait_for_server() {
 wait_for_port_free() {
   local port=$1
   for i in $(seq 1 30); do
-    if ! curl -sf "http://127.0.0.1:$port/api/health" > /dev/null 2>&1; then
+    if ! curl -sf -H "$AUTH" "http://127.0.0.1:$port/api/health" > /dev/null 2>&1; then
       return 0
     fi
     sleep 0.3
@@ -107,7 +111,7 @@ wait_for_port_free() {
 }
 
 api() {
-  curl -sf "$@"
+  curl -sf -H "$AUTH" "$@"
 }
 
 PASS=0
@@ -240,7 +244,7 @@ fi
 
 # Check daemon is still on the same port (reused, not a new one)
 check "Same daemon still running on port $PORT" \
-  "$(curl -sf "http://127.0.0.1:$PORT/api/health" > /dev/null 2>&1 && echo true || echo false)"
+  "$(curl -sf -H "$AUTH" "http://127.0.0.1:$PORT/api/health" > /dev/null 2>&1 && echo true || echo false)"
 
 # Check PID is still the same
 check "Same daemon PID ($DAEMON_PID)" \
@@ -269,7 +273,7 @@ api -X POST "http://127.0.0.1:$PORT/api/finish" > /dev/null
 wait_for_port_free "$PORT"
 
 check "Daemon shut down after approve" \
-  "$(! curl -sf "http://127.0.0.1:$PORT/api/health" > /dev/null 2>&1 && echo true || echo false)"
+  "$(! curl -sf -H "$AUTH" "http://127.0.0.1:$PORT/api/health" > /dev/null 2>&1 && echo true || echo false)"
 
 check "Daemon process exited" \
   "$(! kill -0 $DAEMON_PID 2>/dev/null && echo true || echo false)"
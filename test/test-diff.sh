@@ -19,6 +19,10 @@
 
 set -e
 
+# crit's loopback ports want an access token; fix it so curl can send it.
+export CRIT_ACCESS_TOKEN="${CRIT_ACCESS_TOKEN:-crit-test}"
+AUTH="Authorization: Bearer $CRIT_ACCESS_TOKEN"
+
 # Always run from the repo root regardless of where the script is called from
 SCRIPT_DIR="$(cd "$(dirname "$0")" && pwd)"
 ROOT="$(cd "$SCRIPT_DIR/.." && pwd)"
@@ -403,7 +407,7 @@ trap cleanup EXIT INT TERM
 # Wait for servers to be ready (poll until /api/session returns 200, not 503)
 for port_to_wait in "$PORT" "$WORD_DIFF_PORT" "$CF_FILE_PORT" "$CF_GIT_PORT"; do
   for i in $(seq 1 40); do
-    if curl -sf "http://127.0.0.1:$port_to_wait/api/session" > /dev/null 2>&1; then
+    if curl -sf -H "$AUTH" "http://127.0.0.1:$port_to_wait/api/session" > /dev/null 2>&1; then
       break
     fi
     sleep 0.5
@@ -412,10 +416,10 @@ done
 
 # Clear any leftover comments from previous runs (the daemon persists
 # reviews to ~/.crit/reviews/ — re-running without this accumulates dupes)
-curl -sf -X DELETE "http://127.0.0.1:$PORT/api/comments" > /dev/null
+curl -sf -H "$AUTH" -X DELETE "http://127.0.0.1:$PORT/api/comments" > /dev/null
 
 # Determine the file path as the server sees it
-FILE_PATH=$(curl -sf "http://127.0.0.1:$PORT/api/session" | python3 -c "
+FILE_PATH=$(curl -sf -H "$AUTH" "http://127.0.0.1:$PORT/api/session" | python3 -c "
 import json, sys
 s = json.load(sys.stdin)
 for f in s['files']:
@@ -426,28 +430,28 @@ for f in s['files']:
 ENCODED_PATH=$(python3 -c "import urllib.parse; print(urllib.parse.quote('$FILE_PATH'))")
 
 # Seed 5 comments via the API — capture IDs for threading replies
-C1=$(curl -sf -X POST "http://127.0.0.1:$PORT/api/file/comments?path=$ENCODED_PATH" \
+C1=$(curl -sf -H "$AUTH" -X POST "http://127.0.0.1:$PORT/api/file/comments?path=$ENCODED_PATH" \
   -H 'Content-Type: application/json' \
   -d '{
     "start_line": 20, "end_line": 20,
     "body": "Redis Streams will lose the queue on restart if AOF isn'\''t enabled. Worth checking before we commit. We'\''re already on AWS — SQS gives us durable delivery without needing to think about Redis persistence config."
   }' | python3 -c "import json,sys; print(json.load(sys.stdin)['id'])")
 
-C2=$(curl -sf -X POST "http://127.0.0.1:$PORT/api/file/comments?path=$ENCODED_PATH" \
+C2=$(curl -sf -H "$AUTH" -X POST "http://127.0.0.1:$PORT/api/file/comments?path=$ENCODED_PATH" \
   -H 'Content-Type: application/json' \
   -d '{
     "start_line": 61, "end_line": 62,
     "body": "Even on the internal network we should have some protection on this endpoint. A buggy upstream service could spam `/send` and flood user inboxes with no rate limiting in place.\n\nAt minimum the MVP checklist should include:\n\n- A shared secret header (e.g. `X-Internal-Token`)\n- Rate limiting per caller\n\n**These are not optional** — a single misconfigured upstream can take down the notification pipeline."
   }' | python3 -c "import json,sys; print(json.load(sys.stdin)['id'])")
 
-C3=$(curl -sf -X POST "http://127.0.0.1:$PORT/api/file/comments?path=$ENCODED_PATH" \
+C3=$(curl -sf -H "$AUTH" -X POST "http://127.0.0.1:$PORT/api/file/comments?path=$ENCODED_PATH" \
   -H 'Content-Type: application/json' \
   -d '{
     "start_line": 121, "end_line": 121,
     "body": "2 hours is a long tail for webhook consumers. If my endpoint is down I'\''d want a failure signal faster so I can investigate. Most webhook systems cap at 30-60 minutes. Recommend dropping this to 30 minutes max."
   }' | python3 -c "import json,sys; print(json.load(sys.stdin)['id'])")
 
-C4=$(curl -sf -X POST "http://127.0.0.1:$PORT/api/file/comments?path=$ENCODED_PATH" \
+C4=$(curl -sf -H "$AUTH" -X POST "http://127.0.0.1:$PORT/api/file/comments?path=$ENCODED_PATH" \
   -H 'Content-Type: application/json' \
   -d '{
     "start_line": 158, "end_line": 159,
@@ -456,7 +460,7 @@ C4=$(curl -sf -X POST "http://127.0.0.1:$PORT/api/file/comments?path=$ENCODED_PA
 
 # Comment on the Code Standards heading — replicates the screenshot scenario
 # where comments + deletion markers interrupt formatted markdown sections
-C5=$(curl -sf -X POST "http://127.0.0.1:$PORT/api/file/comments?path=$ENCODED_PATH" \
+C5=$(curl -sf -H "$AUTH" -X POST "http://127.0.0.1:$PORT/api/file/comments?path=$ENCODED_PATH" \
   -H 'Content-Type: application/json' \
   -d '{
     "start_line": 162, "end_line": 162,
@@ -464,28 +468,28 @@ C5=$(curl -sf -X POST "http://127.0.0.1:$PORT/api/file/comments?path=$ENCODED_PA
   }' | python3 -c "import json,sys; print(json.load(sys.stdin)['id'])")
 
 # Seed replies on comments to exercise threading (use captured IDs)
-curl -sf -X POST "http://127.0.0.1:$PORT/api/comment/$C2/replies?path=$ENCODED_PATH" \
+curl -sf -H "$AUTH" -X POST "http://127.0.0.1:$PORT/api/comment/$C2/replies?path=$ENCODED_PATH" \
   -H 'Content-Type: application/json' \
   -d '{
     "body": "Agreed on the shared secret. I'\''ll add `X-Internal-Token` validation to the middleware before the endpoint goes live.",
     "author": "agent"
   }' > /dev/null
 
-curl -sf -X POST "http://127.0.0.1:$PORT/api/comment/$C2/replies?path=$ENCODED_PATH" \
+curl -sf -H "$AUTH" -X POST "http://127.0.0.1:$PORT/api/comment/$C2/replies?path=$ENCODED_PATH" \
   -H 'Content-Type: application/json' \
   -d '{
     "body": "Rate limiting is done — added a per-caller sliding window (100 req/min). The token header is enforced in middleware now too.\n\nSee the updated endpoint spec at line 62.",
     "author": "agent"
   }' > /dev/null
 
-curl -sf -X POST "http://127.0.0.1:$PORT/api/comment/$C4/replies?path=$ENCODED_PATH" \
+curl -sf -H "$AUTH" -X POST "http://127.0.0.1:$PORT/api/comment/$C4/replies?path=$ENCODED_PATH" \
   -H 'Content-Type: application/json' \
   -d '{
     "body": "64KB sounds right. I'\''ll add a CHECK constraint in the migration. Should we also add an application-level validation in the changeset?",
     "author": "agent"
   }' > /dev/null
 
-curl -sf -X POST "http://127.0.0.1:$PORT/api/comment/$C4/replies?path=$ENCODED_PATH" \
+curl -sf -H "$AUTH" -X POST "http://127.0.0.1:$PORT/api/comment/$C4/replies?path=$ENCODED_PATH" \
   -H 'Content-Type: application/json' \
   -d '{
     "body": "Yes — belt and suspenders. CHECK constraint in Postgres + `validate_length(:metadata_json, max: 65536)` in the changeset. The DB constraint is the safety net if someone bypasses the app layer.",
@@ -493,12 +497,12 @@ curl -sf -X POST "http://127.0.0.1:$PORT/api/comment/$C4/replies?path=$ENCODED_P
   }' > /dev/null
 
 # Finish the review to write the review file
-REVIEW_FILE=$(curl -sf -X POST "http://127.0.0.1:$PORT/api/finish" | python3 -c "import json, sys; print(json.load(sys.stdin)['review_file'])")
+REVIEW_FILE=$(curl -sf -H "$AUTH" -X POST "http://127.0.0.1:$PORT/api/finish" | python3 -c "import json, sys; print(json.load(sys.stdin)['review_file'])")
 
 # --- Seed carry-forward comments (file-mode) ---
-curl -sf -X DELETE "http://127.0.0.1:$CF_FILE_PORT/api/comments" > /dev/null
+curl -sf -H "$AUTH" -X DELETE "http://127.0.0.1:$CF_FILE_PORT/api/comments" > /dev/null
 
-CF_FILE_PATH=$(curl -sf "http://127.0.0.1:$CF_FILE_PORT/api/session" | python3 -c "
+CF_FILE_PATH=$(curl -sf -H "$AUTH" "http://127.0.0.1:$CF_FILE_PORT/api/session" | python3 -c "
 import json, sys
 s = json.load(sys.stdin)
 for f in s['files']:
@@ -509,7 +513,7 @@ for f in s['files']:
 CF_FILE_ENCODED=$(python3 -c "import urllib.parse; print(urllib.parse.quote('$CF_FILE_PATH'))")
 
 # C1: Lines 31-32 — sessions table description. Should shift to 39-40 in v2.
-curl -sf -X POST "http://127.0.0.1:$CF_FILE_PORT/api/file/comments?path=$CF_FILE_ENCODED" \
+curl -sf -H "$AUTH" -X POST "http://127.0.0.1:$CF_FILE_PORT/api/file/comments?path=$CF_FILE_ENCODED" \
   -H 'Content-Type: application/json' \
   -d '{
     "start_line": 31, "end_line": 32,
@@ -517,7 +521,7 @@ curl -sf -X POST "http://127.0.0.1:$CF_FILE_PORT/api/file/comments?path=$CF_FILE
   }' > /dev/null
 
 # C2: Line 67 — Backfill step. Should shift to 76 in v2.
-curl -sf -X POST "http://127.0.0.1:$CF_FILE_PORT/api/file/comments?path=$CF_FILE_ENCODED" \
+curl -sf -H "$AUTH" -X POST "http://127.0.0.1:$CF_FILE_PORT/api/file/comments?path=$CF_FILE_ENCODED" \
   -H 'Content-Type: application/json' \
   -d '{
     "start_line": 67, "end_line": 67,
@@ -525,7 +529,7 @@ curl -sf -X POST "http://127.0.0.1:$CF_FILE_PORT/api/file/comments?path=$CF_FILE
   }' > /dev/null
 
 # C3: Lines 75-79 — Rollback plan. This section is REMOVED in v2 → should be outdated.
-curl -sf -X POST "http://127.0.0.1:$CF_FILE_PORT/api/file/comments?path=$CF_FILE_ENCODED" \
+curl -sf -H "$AUTH" -X POST "http://127.0.0.1:$CF_FILE_PORT/api/file/comments?path=$CF_FILE_ENCODED" \
   -H 'Content-Type: application/json' \
   -d '{
     "start_line": 75, "end_line": 79,
@@ -533,7 +537,7 @@ curl -sf -X POST "http://127.0.0.1:$CF_FILE_PORT/api/file/comments?path=$CF_FILE
   }' > /dev/null
 
 # C4: Line 85 — Performance section. Content is REWRITTEN in v2 → should be drifted.
-curl -sf -X POST "http://127.0.0.1:$CF_FILE_PORT/api/file/comments?path=$CF_FILE_ENCODED" \
+curl -sf -H "$AUTH" -X POST "http://127.0.0.1:$CF_FILE_PORT/api/file/comments?path=$CF_FILE_ENCODED" \
   -H 'Content-Type: application/json' \
   -d '{
     "start_line": 85, "end_line": 85,
@@ -541,7 +545,7 @@ curl -sf -X POST "http://127.0.0.1:$CF_FILE_PORT/api/file/comments?path=$CF_FILE
   }' > /dev/null
 
 # C5: Line 103 — Risks. Should shift to 112 in v2.
-curl -sf -X POST "http://127.0.0.1:$CF_FILE_PORT/api/file/comments?path=$CF_FILE_ENCODED" \
+curl -sf -H "$AUTH" -X POST "http://127.0.0.1:$CF_FILE_PORT/api/file/comments?path=$CF_FILE_ENCODED" \
   -H 'Content-Type: application/json' \
   -d '{
     "start_line": 103, "end_line": 103,
@@ -549,63 +553,63 @@ curl -sf -X POST "http://127.0.0.1:$CF_FILE_PORT/api/file/comments?path=$CF_FILE
   }' > /dev/null
 
 # Finish to persist
-curl -sf -X POST "http://127.0.0.1:$CF_FILE_PORT/api/finish" > /dev/null
+curl -sf -H "$AUTH" -X POST "http://127.0.0.1:$CF_FILE_PORT/api/finish" > /dev/null
 
 # --- Seed carry-forward comments (git-mode) — same lines, same content ---
 CF_GIT_PATH="plan.md"
 CF_GIT_ENCODED=$(python3 -c "import urllib.parse; print(urllib.parse.quote('$CF_GIT_PATH'))")
 
-curl -sf -X POST "http://127.0.0.1:$CF_GIT_PORT/api/file/comments?path=$CF_GIT_ENCODED" \
+curl -sf -H "$AUTH" -X POST "http://127.0.0.1:$CF_GIT_PORT/api/file/comments?path=$CF_GIT_ENCODED" \
   -H 'Content-Type: application/json' \
   -d '{
     "start_line": 31, "end_line": 32,
     "body": "[git-mode] Sessions table: same comment as file-mode. Should shift to 39-40."
   }' > /dev/null
 
-curl -sf -X POST "http://127.0.0.1:$CF_GIT_PORT/api/file/comments?path=$CF_GIT_ENCODED" \
+curl -sf -H "$AUTH" -X POST "http://127.0.0.1:$CF_GIT_PORT/api/file/comments?path=$CF_GIT_ENCODED" \
   -H 'Content-Type: application/json' \
   -d '{
     "start_line": 67, "end_line": 67,
     "body": "[git-mode] Backfill step: should shift to 76."
   }' > /dev/null
 
-curl -sf -X POST "http://127.0.0.1:$CF_GIT_PORT/api/file/comments?path=$CF_GIT_ENCODED" \
+curl -sf -H "$AUTH" -X POST "http://127.0.0.1:$CF_GIT_PORT/api/file/comments?path=$CF_GIT_ENCODED" \
   -H 'Content-Type: application/json' \
   -d '{
     "start_line": 75, "end_line": 79,
     "body": "[git-mode] Rollback plan: this section is REMOVED in v2 — should be outdated."
   }' > /dev/null
 
-curl -sf -X POST "http://127.0.0.1:$CF_GIT_PORT/api/file/comments?path=$CF_GIT_ENCODED" \
+curl -sf -H "$AUTH" -X POST "http://127.0.0.1:$CF_GIT_PORT/api/file/comments?path=$CF_GIT_ENCODED" \
   -H 'Content-Type: application/json' \
   -d '{
     "start_line": 85, "end_line": 85,
     "body": "[git-mode] Performance: content is REWRITTEN in v2 — should be drifted."
   }' > /dev/null
 
-curl -sf -X POST "http://127.0.0.1:$CF_GIT_PORT/api/file/comments?path=$CF_GIT_ENCODED" \
+curl -sf -H "$AUTH" -X POST "http://127.0.0.1:$CF_GIT_PORT/api/file/comments?path=$CF_GIT_ENCODED" \
   -H 'Content-Type: application/json' \
   -d '{
     "start_line": 103, "end_line": 103,
     "body": "[git-mode] Risks: should shift to 112."
   }' > /dev/null
 
-curl -sf -X POST "http://127.0.0.1:$CF_GIT_PORT/api/finish" > /dev/null
+curl -sf -H "$AUTH" -X POST "http://127.0.0.1:$CF_GIT_PORT/api/finish" > /dev/null
 
 # --- Folded-line comment on the code diff instance (#317) ---
 # server.go has spacer gaps between hunks. Line 59 (respondJSON call in /version
 # handler) falls in the gap between the /health hunk and the startup-code hunk.
 # The fix auto-expands that spacer so the comment appears at its correct position.
-curl -sf -X DELETE "http://127.0.0.1:$WORD_DIFF_PORT/api/comments" > /dev/null
+curl -sf -H "$AUTH" -X DELETE "http://127.0.0.1:$WORD_DIFF_PORT/api/comments" > /dev/null
 
-FOLDED_C=$(curl -sf -X POST "http://127.0.0.1:$WORD_DIFF_PORT/api/file/comments?path=server.go" \
+FOLDED_C=$(curl -sf -H "$AUTH" -X POST "http://127.0.0.1:$WORD_DIFF_PORT/api/file/comments?path=server.go" \
   -H 'Content-Type: application/json' \
   -d '{
     "start_line": 59, "end_line": 59,
     "body": "Should we version this via a build-time variable instead of hardcoding `1.0.0`? We already inject the version in main.go via ldflags."
   }' | python3 -c "import json,sys; print(json.load(sys.stdin)['id'])")
 
-curl -sf -X POST "http://127.0.0.1:$WORD_DIFF_PORT/api/comment/$FOLDED_C/replies?path=server.go" \
+curl -sf -H "$AUTH" -X POST "http://127.0.0.1:$WORD_DIFF_PORT/api/comment/$FOLDED_C/replies?path=server.go" \
   -H 'Content-Type: application/json' \
   -d '{
     "body": "Good catch — I'\''ll wire it up to the same `version` var. The `/version` endpoint will return the real build version instead of a hardcoded string.",
@@ -665,7 +669,7 @@ with open(path, 'w') as f:
 PYEOF
 
 echo "Signalling round-complete..."
-curl -sf -X POST "http://127.0.0.1:$PORT/api/round-complete" > /dev/null
+curl -sf -H "$AUTH" -X POST "http://127.0.0.1:$PORT/api/round-complete" > /dev/null
 
 # --- Carry-forward: swap to v2 and round-complete ---
 echo "Swapping carry-forward content to v2..."
@@ -673,13 +677,13 @@ cp test/carry-forward-v2.md "$CF_FILE"
 cp test/carry-forward-v2.md "$CF_GIT_DIR/plan.md"
 sleep 1.5
 
-curl -sf -X POST "http://127.0.0.1:$CF_FILE_PORT/api/round-complete" > /dev/null
-curl -sf -X POST "http://127.0.0.1:$CF_GIT_PORT/api/round-complete" > /dev/null
+curl -sf -H "$AUTH" -X POST "http://127.0.0.1:$CF_FILE_PORT/api/round-complete" > /dev/null
+curl -sf -H "$AUTH" -X POST "http://127.0.0.1:$CF_GIT_PORT/api/round-complete" > /dev/null
 
 # --- Orphaned comments on the word-diff (git-mode) instance ---
 # Wait for the word-diff server to be ready
 for i in $(seq 1 20); do
-  if curl -sf "http://127.0.0.1:$WORD_DIFF_PORT/api/session" > /dev/null 2>&1; then
+  if curl -sf -H "$AUTH" "http://127.0.0.1:$WORD_DIFF_PORT/api/session" > /dev/null 2>&1; then
     break
   fi
   sleep 0.5
@@ -706,26 +710,26 @@ GOEOF
 git -C "$WORD_DIFF_DIR" add helpers.go && git -C "$WORD_DIFF_DIR" commit -q -m "add helpers"
 
 # Signal round-complete so crit picks up the new file
-curl -sf -X POST "http://127.0.0.1:$WORD_DIFF_PORT/api/round-complete" > /dev/null
+curl -sf -H "$AUTH" -X POST "http://127.0.0.1:$WORD_DIFF_PORT/api/round-complete" > /dev/null
 sleep 1
 
 # Add comments on the helpers file: one file-level, one line-scoped
-curl -sf -X POST "http://127.0.0.1:$WORD_DIFF_PORT/api/file/comments?path=helpers.go" \
+curl -sf -H "$AUTH" -X POST "http://127.0.0.1:$WORD_DIFF_PORT/api/file/comments?path=helpers.go" \
   -H 'Content-Type: application/json' \
   -d '{"body": "Do we really need a custom byte formatter? There are stdlib options.", "scope": "file"}' > /dev/null
 
-curl -sf -X POST "http://127.0.0.1:$WORD_DIFF_PORT/api/file/comments?path=helpers.go" \
+curl -sf -H "$AUTH" -X POST "http://127.0.0.1:$WORD_DIFF_PORT/api/file/comments?path=helpers.go" \
   -H 'Content-Type: application/json' \
   -d '{"start_line": 5, "end_line": 8, "body": "This will overflow for values above exabyte range. Use math.Log instead of the loop."}' > /dev/null
 
 # Finish to persist comments to the review file
-curl -sf -X POST "http://127.0.0.1:$WORD_DIFF_PORT/api/finish" > /dev/null
+curl -sf -H "$AUTH" -X POST "http://127.0.0.1:$WORD_DIFF_PORT/api/finish" > /dev/null
 
 # Now delete the file and amend the commit so there is no net diff
 git -C "$WORD_DIFF_DIR" rm -q helpers.go && git -C "$WORD_DIFF_DIR" commit -q -m "remove helpers"
 
 # Signal round-complete — helpers.go disappears from git diff, comments become orphaned
-curl -sf -X POST "http://127.0.0.1:$WORD_DIFF_PORT/api/round-complete" > /dev/null
+curl -sf -H "$AUTH" -X POST "http://127.0.0.1:$WORD_DIFF_PORT/api/round-complete" > /dev/null
 
 echo ""
 echo "Four views running:"
@@ -15,6 +15,10 @@
 
 set -e
 
+# crit's loopback ports want an access token; fix it so curl can send it.
+export CRIT_ACCESS_TOKEN="${CRIT_ACCESS_TOKEN:-crit-test}"
+AUTH="Authorization: Bearer $CRIT_ACCESS_TOKEN"
+
 SCRIPT_DIR="$(cd "$(dirname "$0")" && pwd)"
 ROOT="$(cd "$SCRIPT_DIR/.." && pwd)"
 
@@ -46,7 +50,7 @@ trap 'kill $(jobs -p) 2>/dev/null || true; rm -rf "$WORKDIR"; rm -rf "$PLAN_DIR"
 wait_for_server() {
   local port=$1
   for i in $(seq 1 30); do
-    if curl -sf "http://127.0.0.1:$port/api/health" > /dev/null 2>&1; then
+    if curl -sf -H "$AUTH" "http://127.0.0.1:$port/api/health" > /dev/null 2>&1; then
       return 0
     fi
     sleep 0.3
@@ -58,7 +62,7 @@ wait_for_server() {
 wait_for_port_free() {
   local port=$1
   for i in $(seq 1 30); do
-    if ! curl -sf "http://127.0.0.1:$port/api/health" > /dev/null 2>&1; then
+    if ! curl -sf -H "$AUTH" "http://127.0.0.1:$port/api/health" > /dev/null 2>&1; then
       return 0
     fi
     sleep 0.3
@@ -68,7 +72,7 @@ wait_for_port_free() {
 }
 
 api() {
-  curl -sf "$@"
+  curl -sf -H "$AUTH" "$@"
 }
 
 PASS=0
@@ -152,7 +156,7 @@ sleep 2
 
 # Check daemon is still the same PID (reused, not restarted)
 check "Same daemon still running on port $PORT" \
-  "$(curl -sf "http://127.0.0.1:$PORT/api/health" > /dev/null 2>&1 && echo true || echo false)"
+  "$(curl -sf -H "$AUTH" "http://127.0.0.1:$PORT/api/health" > /dev/null 2>&1 && echo true || echo false)"
 
 CURRENT_DAEMON_PID=$(lsof -ti ":$PORT" -sTCP:LISTEN 2>/dev/null | head -1 || true)
 check "Same daemon PID ($DAEMON_PID)" \
@@ -179,7 +183,7 @@ api -X POST "http://127.0.0.1:$PORT/api/finish" > /dev/null
 wait_for_port_free "$PORT"
 
 check "Daemon shut down after approve" \
-  "$(! curl -sf "http://127.0.0.1:$PORT/api/health" > /dev/null 2>&1 && echo true || echo false)"
+  "$(! curl -sf -H "$AUTH" "http://127.0.0.1:$PORT/api/health" > /dev/null 2>&1 && echo true || echo false)"
 
 check "Daemon process exited" \
   "$(! kill -0 $DAEMON_PID 2>/dev/null && echo true || echo false)"
@@ -101,44 +101,53 @@ type VCS interface {
 // Otherwise, auto-detection checks for .sl/ first (Sapling on git repos has both),
 // then falls back to git. Returns nil if no VCS is detected.
 func DetectVCS(vcsOverride string) VCS {
+	return DetectVCSIn("", vcsOverride)
+}
+
+// DetectVCSIn is DetectVCS for dir, and the backend it returns runs there.
+// An empty dir means the current directory.
+func DetectVCSIn(dir, vcsOverride string) VCS {
 	switch vcsOverride {
 	case "git":
-		return &GitVCS{}
+		return &GitVCS{Dir: dir}
 	case "sl", "sapling":
 		if _, err := exec.LookPath("sl"); err == nil {
-			return &SaplingVCS{}
+			return &SaplingVCS{Dir: dir}
 		}
 		fmt.Fprintf(os.Stderr, "Warning: vcs=%q requested but sl not in PATH, falling back to git\n", vcsOverride)
-		if IsGitRepo() {
-			return &GitVCS{}
+		if isGitRepoInDir(dir) {
+			return &GitVCS{Dir: dir}
 		}
 		return nil
 	}
 
 	// Auto-detect: check for .sl/ first since Sapling repos on top of git have both.
-	if hasSLDir() {
+	if hasSLDir(dir) {
 		if _, err := exec.LookPath("sl"); err == nil {
-			return &SaplingVCS{}
+			return &SaplingVCS{Dir: dir}
 		}
 	}
 
-	if IsGitRepo() {
+	if isGitRepoInDir(dir) {
 		// Check if Sapling metadata exists under .git/sl — this means sl was used
 		// here but it's primarily a git repo. Hint but don't switch automatically.
-		if hasGitSLDir() {
+		if hasGitSLDir(dir) {
 			fmt.Fprintf(os.Stderr, "Hint: Sapling detected. Use --vcs sl or set \"vcs\": \"sl\" in config to use Sapling.\n")
 		}
-		return &GitVCS{}
+		return &GitVCS{Dir: dir}
 	}
 
 	return nil
 }
 
-// hasSLDir checks whether a .sl/ directory exists at or above the current directory.
-func hasSLDir() bool {
-	dir, err := os.Getwd()
-	if err != nil {
-		return false
+// hasSLDir checks whether a .sl/ directory exists at or above dir, or the
+// current directory when dir is empty.
+func hasSLDir(dir string) bool {
+	if dir == "" {
+		var err error
+		if dir, err = os.Getwd(); err != nil {
+			return false
+		}
 	}
 	return hasSLDirFrom(dir)
 }
@@ -158,12 +167,14 @@ func hasSLDirFrom(dir string) bool {
 	return false
 }
 
-// hasGitSLDir checks whether .git/sl/ exists at or above the current directory,
-// indicating Sapling has been used in a git repo.
-func hasGitSLDir() bool {
-	dir, err := os.Getwd()
-	if err != nil {
-		return false
+// hasGitSLDir checks whether .git/sl/ exists at or above dir (the current
+// directory when empty), indicating Sapling has been used in a git repo.
+func hasGitSLDir(dir string) bool {
+	if dir == "" {
+		var err error
+		if dir, err = os.Getwd(); err != nil {
+			return false
+		}
 	}
 	for {
 		if info, err := os.Stat(filepath.Join(dir, ".git", "sl")); err == nil && info.IsDir() {
@@ -45,7 +45,9 @@ func (s *Session) RefreshDiffs() {
 		if snap.status == "added" || snap.status == "untracked" {
 			hunks = FileDiffUnifiedNewFile(snap.content)
 		} else if vcs != nil {
+			start := time.Now()
 			h, err := vcs.FileDiffUnified(snap.path, baseRef, repoRoot)
+			observeDiff("eager", start)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Warning: diff failed for %s: %v\n", snap.path, err)
 			} else {
@@ -310,24 +312,30 @@ func (s *Session) watchFileMtimes(stop <-chan struct{}) {
 
 func carryForwardComment(old Comment, newID string, now string) Comment {
 	return Comment{
-		ID:             newID,
-		StartLine:      old.StartLine,
-		EndLine:        old.EndLine,
-		Side:           old.Side,
-		Body:           old.Body,
-		Quote:          old.Quote,
-		QuoteOffset:    old.QuoteOffset,
-		Anchor:         old.Anchor,
-		Author:         old.Author,
-		Scope:          old.Scope,
-		CreatedAt:      old.CreatedAt,
-		UpdatedAt:      now,
-		Resolved:       old.Resolved,
-		CarriedForward: true,
-		Live:           old.Live,
-		ReviewRound:    old.ReviewRound,
-		Replies:        old.Replies,
-		GitHubID:       old.GitHubID,
+		ID:                newID,
+		StartLine:         old.StartLine,
+		EndLine:           old.EndLine,
+		Side:              old.Side,
+		Body:              old.Body,
+		Quote:             old.Quote,
+		QuoteOffset:       old.QuoteOffset,
+		Anchor:            old.Anchor,
+		Author:            old.Author,
+		Scope:             old.Scope,
+		CreatedAt:         old.CreatedAt,
+		UpdatedAt:         now,
+		Resolved:          old.Resolved,
+		CarriedForward:    true,
+		Live:              old.Live,
+		ReviewRound:       old.ReviewRound,
+		Replies:           old.Replies,
+		GitHubID:          old.GitHubID,
+		GitHubReviewID:    old.GitHubReviewID,
+		GitHubReviewState: old.GitHubReviewState,
+		RemoteID:          old.RemoteID,
+		RemoteResolved:    old.RemoteResolved,
+		Proposal:          old.Proposal,
+		AISuggestion:      old.AISuggestion,
 	}
 }
 
@@ -373,6 +381,8 @@ func (s *Session) rereadFileContents(snapshotMarkdown bool) {
 // finishRoundComplete emits terminal status and notifies SSE subscribers.
 func (s *Session) finishRoundComplete(edits int) {
 	s.emitRoundStatus(edits)
+	s.counters().roundsCompleted.inc("")
+	s.webhook(webhookPayload{Event: "round-complete"})
 	s.notify(SSEEvent{
 		Type:    "file-changed",
 		Content: "session",
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Webhooks tell other programs about a review as it happens: a chat bot
+// behind a local relay, a team dashboard. Each configured webhook gets a JSON
+// POST for the events it subscribes to, signed with its secret. A session
+// delivers its events in order from one goroutine, retrying a webhook that
+// is unreachable or answers 429 or 5xx; an event that still fails is logged
+// and dropped.
+
+// webhookEvents are the events a webhook can subscribe to.
+var webhookEvents = []string{
+	"finish",         // the reviewer finished a round, approved or not
+	"approved",       // the reviewer finished with nothing unresolved
+	"round-complete", // the agent finished its edits and a new round started
+	"comment-added",  // a comment was made, in the browser, by the API or in the review file
+	"agent-reply",    // an agent run or `crit comment --reply-to` replied to a comment
+}
+
+// Webhook is one entry of webhooks in ~/.crit.config.json.
+type Webhook struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret,omitempty"` // HMAC-SHA256 key for X-Crit-Signature
+	Events []string `json:"events,omitempty"` // subscribed events; empty for all
+}
+
+func (h Webhook) wants(event string) bool {
+	return len(h.Events) == 0 || slices.Contains(h.Events, event)
+}
+
+// webhookPayload is the body of a delivery. Round and counts describe the
+// session when the event happened, however long the delivery waits.
+type webhookPayload struct {
+	Event     string        `json:"event"`
+	Delivery  string        `json:"delivery"`
+	Timestamp string        `json:"timestamp"`
+	Session   string        `json:"session"`
+	Repo      string        `json:"repo,omitempty"`
+	Branch    string        `json:"branch,omitempty"`
+	Mode      string        `json:"mode"`
+	Round     int           `json:"round"`
+	Counts    webhookCounts `json:"counts"`
+	Approved  *bool         `json:"approved,omitempty"` // finish
+	File      string        `json:"file,omitempty"`     // comment-added and agent-reply; empty for review comments
+	Comment   *Comment      `json:"comment,omitempty"`  // comment-added, and the thread for agent-reply
+	Reply     *Reply        `json:"reply,omitempty"`    // agent-reply
+}
+
+type webhookCounts struct {
+	Comments   int `json:"comments"`
+	Unresolved int `json:"unresolved"`
+	Files      int `json:"files"`
+}
+
+// webhookQueueSize bounds the events waiting for delivery. Past it, new
+// events are dropped rather than slowing the session down.
+const webhookQueueSize = 64
+
+// webhookSender delivers one session's events to the configured webhooks.
+type webhookSender struct {
+	hooks       []Webhook
+	session     string
+	mu          sync.Mutex // guards sending on queue against closing it
+	closed      bool
+	queue       chan webhookPayload
+	done        chan struct{}
+	client      *http.Client
+	retryDelays []time.Duration // between attempts; its length is the number of retries
+}
+
+// newWebhookSender returns nil when no webhook is configured. Unknown event
+// names in a filter are reported, since they would never match.
+func newWebhookSender(hooks []Webhook, sessionKey string) *webhookSender {
+	if len(hooks) == 0 {
+		return nil
+	}
+	for _, h := range hooks {
+		for _, e := range h.Events {
+			if !slices.Contains(webhookEvents, e) {
+				log.Printf("Warning: webhook %s: unknown event %q (known: %v)", h.URL, e, webhookEvents)
+			}
+		}
+	}
+	return &webhookSender{
+		hooks:       hooks,
+		session:     sessionKey,
+		queue:       make(chan webhookPayload, webhookQueueSize),
+		done:        make(chan struct{}),
+		client:      &http.Client{Timeout: 10 * time.Second},
+		retryDelays: []time.Duration{time.Second, 5 * time.Second, 30 * time.Second},
+	}
+}
+
+// startWebhooks delivers the session's webhook events until closeWebhooks.
+func (s *Session) startWebhooks(w *webhookSender) {
+	s.webhooks = w
+	if w != nil {
+		go w.run()
+	}
+}
+
+// closeWebhooks delivers what is queued, waiting at most timeout.
+func (s *Session) closeWebhooks(timeout time.Duration) {
+	w := s.webhooks
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+	select {
+	case <-w.done:
+	case <-time.After(timeout):
+		log.Printf("Warning: webhook deliveries still pending at shutdown")
+	}
+}
+
+// webhook queues an event for delivery, with the session's state as it is
+// now. It doesn't block, but it takes s.mu; with the lock held, use
+// webhookLocked.
+func (s *Session) webhook(p webhookPayload) {
+	if !s.wantsWebhook(p.Event) {
+		return
+	}
+	s.mu.RLock()
+	s.fillWebhookPayloadLocked(&p)
+	s.mu.RUnlock()
+	s.queueWebhook(p)
+}
+
+// webhookLocked is webhook for callers holding s.mu.
+func (s *Session) webhookLocked(p webhookPayload) {
+	if !s.wantsWebhook(p.Event) {
+		return
+	}
+	s.fillWebhookPayloadLocked(&p)
+	s.queueWebhook(p)
+}
+
+func (s *Session) wantsWebhook(event string) bool {
+	w := s.webhooks
+	return w != nil && slices.ContainsFunc(w.hooks, func(h Webhook) bool { return h.wants(event) })
+}
+
+// fillWebhookPayloadLocked adds the session's state to p. Caller must hold
+// s.mu.
+func (s *Session) fillWebhookPayloadLocked(p *webhookPayload) {
+	p.Timestamp = time.Now().UTC().Format(time.RFC3339)
+	p.Repo, p.Branch, p.Mode = s.RepoRoot, s.Branch, s.Mode
+	p.Round = s.ReviewRound
+	p.Counts.Files = len(s.Files)
+	count := func(comments []Comment) {
+		for _, c := range comments {
+			p.Counts.Comments++
+			if !c.Resolved {
+				p.Counts.Unresolved++
+			}
+		}
+	}
+	count(s.reviewComments)
+	for _, f := range s.Files {
+		count(f.Comments)
+	}
+}
+
+// queueWebhook hands p to the delivery goroutine.
+func (s *Session) queueWebhook(p webhookPayload) {
+	w := s.webhooks
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	select {
+	case w.queue <- p:
+	default:
+		log.Printf("Warning: webhook queue full, dropping %s event", p.Event)
+	}
+}
+
+func (w *webhookSender) run() {
+	defer close(w.done)
+	for p := range w.queue {
+		p.Session = w.session
+		p.Delivery = randomID("wh_")
+		body, err := json.Marshal(p)
+		if err != nil {
+			log.Printf("webhook %s: %v", p.Event, err)
+			continue
+		}
+		for _, h := range w.hooks {
+			if h.wants(p.Event) {
+				w.deliver(h, p, body)
+			}
+		}
+	}
+}
+
+// deliver posts body to h, retrying failures that may be temporary.
+func (w *webhookSender) deliver(h Webhook, p webhookPayload, body []byte) {
+	for attempt := 0; ; attempt++ {
+		retry, err := w.post(h, p, body)
+		if err == nil {
+			return
+		}
+		if !retry || attempt == len(w.retryDelays) {
+			log.Printf("webhook %s: %s event %s not delivered: %v", h.URL, p.Event, p.Delivery, err)
+			return
+		}
+		time.Sleep(w.retryDelays[attempt])
+	}
+}
+
+// post makes one delivery attempt. retry reports whether a later attempt
+// might succeed.
+func (w *webhookSender) post(h Webhook, p webhookPayload, body []byte) (retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "crit/"+version)
+	req.Header.Set("X-Crit-Event", p.Event)
+	req.Header.Set("X-Crit-Delivery", p.Delivery)
+	if h.Secret != "" {
+		req.Header.Set("X-Crit-Signature", webhookSignature(h.Secret, body))
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("HTTP %d", resp.StatusCode)
+}
+
+// webhookSignature is the X-Crit-Signature header for body: "sha256=" and
+// the hex HMAC-SHA256 of the exact bytes sent, keyed with the secret.
+func webhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// webhookReceiver records the deliveries it gets. It answers the first
+// failures requests with 503.
+func webhookReceiver(t *testing.T, failures int32) (*httptest.Server, chan *http.Request, chan webhookPayload) {
+	t.Helper()
+	reqs := make(chan *http.Request, 16)
+	payloads := make(chan webhookPayload, 16)
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var p webhookPayload
+		if err := json.Unmarshal(body, &p); err != nil {
+			t.Errorf("payload: %v", err)
+		}
+		if got, want := r.Header.Get("X-Crit-Signature"), webhookSignature("s3cret", body); got != want {
+			t.Errorf("signature = %q, want %q", got, want)
+		}
+		reqs <- r
+		payloads <- p
+	}))
+	t.Cleanup(ts.Close)
+	return ts, reqs, payloads
+}
+
+func recvPayload(t *testing.T, ch chan webhookPayload) webhookPayload {
+	t.Helper()
+	select {
+	case p := <-ch:
+		return p
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a webhook delivery")
+		return webhookPayload{}
+	}
+}
+
+func TestWebhooks_DeliverSignedAndFiltered(t *testing.T) {
+	ts, reqs, payloads := webhookReceiver(t, 0)
+	s := newTestSession(t)
+	s.startWebhooks(newWebhookSender([]Webhook{
+		{URL: ts.URL, Secret: "s3cret", Events: []string{"comment-added", "finish"}},
+	}, "abc123"))
+	defer s.closeWebhooks(time.Second)
+
+	s.webhook(webhookPayload{Event: "round-complete"}) // not subscribed
+	c, _ := s.AddComment("plan.md", 1, 1, "", "Why?", "", "", "")
+
+	p := recvPayload(t, payloads)
+	r := <-reqs
+	if p.Event != "comment-added" || p.Comment == nil || p.Comment.ID != c.ID || p.File != "plan.md" {
+		t.Errorf("payload = %+v", p)
+	}
+	if p.Session != "abc123" || p.Delivery == "" || p.Counts.Comments != 1 || p.Counts.Unresolved != 1 {
+		t.Errorf("session fields = %+v", p)
+	}
+	if r.Header.Get("X-Crit-Event") != "comment-added" || r.Header.Get("X-Crit-Delivery") != p.Delivery {
+		t.Errorf("headers = %v", r.Header)
+	}
+
+	approved := false
+	s.webhook(webhookPayload{Event: "finish", Approved: &approved})
+	if p := recvPayload(t, payloads); p.Event != "finish" || p.Approved == nil || *p.Approved {
+		t.Errorf("finish payload = %+v", p)
+	}
+}
+
+func TestWebhooks_Retry(t *testing.T) {
+	ts, _, payloads := webhookReceiver(t, 2)
+	s := newTestSession(t)
+	w := newWebhookSender([]Webhook{{URL: ts.URL, Secret: "s3cret"}}, "k")
+	w.retryDelays = []time.Duration{time.Millisecond, time.Millisecond}
+	s.startWebhooks(w)
+	defer s.closeWebhooks(time.Second)
+
+	s.webhook(webhookPayload{Event: "approved"})
+	if p := recvPayload(t, payloads); p.Event != "approved" {
+		t.Errorf("delivered %+v", p)
+	}
+}
+
+func TestWebhooks_PayloadDescribesTheEventTime(t *testing.T) {
+	ts, _, payloads := webhookReceiver(t, 1)
+	s := newTestSession(t)
+	w := newWebhookSender([]Webhook{{URL: ts.URL, Secret: "s3cret", Events: []string{"finish"}}}, "k")
+	w.retryDelays = []time.Duration{100 * time.Millisecond}
+	s.startWebhooks(w)
+	defer s.closeWebhooks(time.Second)
+
+	s.mu.RLock()
+	round := s.ReviewRound
+	s.mu.RUnlock()
+	approved := true
+	s.webhook(webhookPayload{Event: "finish", Approved: &approved})
+	// The next round starts while the delivery waits for its retry.
+	s.mu.Lock()
+	s.ReviewRound++
+	s.mu.Unlock()
+	s.AddComment("plan.md", 1, 1, "", "Why?", "", "", "")
+
+	p := recvPayload(t, payloads)
+	if p.Round != round || p.Counts.Comments != 0 {
+		t.Errorf("round %d with %d comments, want round %d with none", p.Round, p.Counts.Comments, round)
+	}
+}
+
+func TestWebhooks_NoRetryOnClientError(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer ts.Close()
+	w := newWebhookSender([]Webhook{{URL: ts.URL}}, "k")
+	w.retryDelays = []time.Duration{time.Millisecond, time.Millisecond}
+	s := newTestSession(t)
+	s.startWebhooks(w)
+	s.webhook(webhookPayload{Event: "finish"})
+	s.closeWebhooks(5 * time.Second)
+	if n := calls.Load(); n != 1 {
+		t.Errorf("attempts = %d, want 1", n)
+	}
+}
+
+func TestWebhooks_NoneConfigured(t *testing.T) {
+	if newWebhookSender(nil, "k") != nil {
+		t.Error("expected no sender without webhooks")
+	}
+	s := newTestSession(t)
+	s.webhook(webhookPayload{Event: "finish"}) // must not panic
+	s.closeWebhooks(time.Second)
+}